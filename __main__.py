@@ -14,11 +14,31 @@ def main():
                         dest='port', default=5001)
     parser.add_argument('-r', '--retro', action='store_true',
                         dest='retro')
+    parser.add_argument('--retro-integrations', action='store', type=str,
+                        metavar='DIR', dest='retro_integrations', default='')
     parser.add_argument('-u', '--universe', action='store_true',
                         dest='universe')
+    parser.add_argument('-e', '--envpool', action='store_true',
+                        dest='envpool')
+    parser.add_argument('--sb3', action='store', choices=['dummy', 'subproc'],
+                        dest='sb3')
+    parser.add_argument('--dm-control', action='store_true',
+                        dest='dm_control')
+    parser.add_argument('--unity', action='store', type=str, metavar='DIR',
+                        dest='unity', default='')
+    parser.add_argument('--pettingzoo', action='store_true',
+                        dest='pettingzoo')
     parser.add_argument('-s', '--setup', action='store', type=str,
                         dest='setup_code')
+    parser.add_argument('-t', '--idle-ttl', action='store', type=float,
+                        dest='idle_ttl', default=0)
+    parser.add_argument('--session-ttl', action='store', type=float,
+                        dest='session_ttl', default=300)
+    parser.add_argument('--unix', action='store', type=str, metavar='PATH',
+                        dest='unix', default='')
     options = parser.parse_args()
+    if options.envpool and options.sb3:
+        parser.error('--envpool and --sb3 cannot be used together')
     server.serve(**vars(options))
 
 if __name__ == '__main__':
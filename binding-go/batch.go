@@ -0,0 +1,180 @@
+package gym
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+
+	"github.com/unixpickle/essentials"
+)
+
+// BatchEnv is a handle on a batch of environments which
+// are reset and stepped together in one round trip.
+//
+// When an environment in the batch finishes an episode,
+// it is automatically reset by the following call to
+// Step(), which ignores its action and returns its
+// initial observation with a reward of 0.
+// With the AutoReset option, environments are instead
+// reset immediately, as described in AutoReset.
+//
+// On servers started with --envpool, batches are backed
+// by envpool's C++ environments.
+// On servers started with --sb3, batches are backed by a
+// Stable-Baselines3 VecEnv, which may step environments in
+// parallel processes; such batches require AutoReset.
+// Otherwise, the server steps separate Gym environments
+// one after another.
+//
+// The methods on a BatchEnv are thread-safe.
+type BatchEnv interface {
+	// BatchSize returns the number of environments.
+	BatchSize() int
+
+	// Reset resets every environment in the batch.
+	Reset() (obs []Obs, err error)
+
+	// Step takes one action in every environment.
+	Step(actions []interface{}) (obs []Obs, rewards []float64,
+		dones []bool, infos []interface{}, err error)
+
+	// ActionSpace gets the action space of a single
+	// environment in the batch.
+	ActionSpace() (*Space, error)
+
+	// ObservationSpace gets the observation space of a
+	// single environment in the batch.
+	ObservationSpace() (*Space, error)
+
+	// Renew extends the lease on the batch, as described
+	// in Env.Renew.
+	Renew() error
+
+	// Close stops and cleans up the environments.
+	Close() error
+}
+
+type connBatchEnv struct {
+	Env  *connEnv
+	Size int
+
+	// DecodeObs holds the byte list decoder for each
+	// environment.
+	DecodeObs []byteListDecoder
+}
+
+// MakeBatch creates a BatchEnv with n instances of the
+// given environment.
+func MakeBatch(host, envName string, n int,
+	opts ...Option) (env BatchEnv, err error) {
+	defer essentials.AddCtxTo("make batched environment", &err)
+	if n < 1 {
+		return nil, errors.New("batch size must be positive")
+	}
+	config := makeOptions(opts)
+	conn, err := dialEnvConn(host, &handshakeRequest{
+		EnvName:   envName,
+		BatchSize: n,
+		Options:   config,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &connBatchEnv{
+		Env:       &connEnv{envConn: conn, ID: -1},
+		Size:      n,
+		DecodeObs: make([]byteListDecoder, n),
+	}
+	for i := range res.DecodeObs {
+		res.DecodeObs[i] = config.obsDecoder(i)
+	}
+	return res, nil
+}
+
+func (c *connBatchEnv) BatchSize() int {
+	return c.Size
+}
+
+func (c *connBatchEnv) Renew() error {
+	return c.Env.Renew()
+}
+
+func (c *connBatchEnv) Reset() (obs []Obs, err error) {
+	defer essentials.AddCtxTo("reset batched environment", &err)
+	err = c.Env.command("reset_batch", func(w *bufio.Writer) error {
+		return c.Env.writeHeader(w, packetResetBatch)
+	}, func(r *bufio.Reader) error {
+		obs, err = c.readObservations(r)
+		return err
+	})
+	return
+}
+
+func (c *connBatchEnv) Step(actions []interface{}) (obs []Obs, rewards []float64,
+	dones []bool, infos []interface{}, err error) {
+	defer essentials.AddCtxTo("step batched environment", &err)
+	if len(actions) != c.Size {
+		err = fmt.Errorf("expected %d actions but got %d", c.Size, len(actions))
+		return
+	}
+	err = c.Env.command("step_batch", func(w *bufio.Writer) error {
+		if err := c.Env.writeHeader(w, packetStepBatch); err != nil {
+			return err
+		}
+		if err := writeUint32(w, uint32(len(actions))); err != nil {
+			return err
+		}
+		for _, action := range actions {
+			if err := writeAction(w, action, c.Env.BinaryActions); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(r *bufio.Reader) (err error) {
+		obs, err = c.readObservations(r)
+		if err != nil {
+			return
+		}
+		rewards = make([]float64, c.Size)
+		for i := range rewards {
+			rewards[i], err = readReward(r)
+			if err != nil {
+				return
+			}
+		}
+		dones = make([]bool, c.Size)
+		for i := range dones {
+			dones[i], err = readBool(r)
+			if err != nil {
+				return
+			}
+		}
+		infos, err = readBatchInfo(r, c.Env.InfoMode, c.Size)
+		return
+	})
+	return
+}
+
+func (c *connBatchEnv) ActionSpace() (*Space, error) {
+	return c.Env.ActionSpace()
+}
+
+func (c *connBatchEnv) ObservationSpace() (*Space, error) {
+	return c.Env.ObservationSpace()
+}
+
+func (c *connBatchEnv) Close() error {
+	return c.Env.Close()
+}
+
+func (c *connBatchEnv) readObservations(r *bufio.Reader) ([]Obs, error) {
+	res := make([]Obs, c.Size)
+	for i := range res {
+		obs, err := readObservation(r, c.DecodeObs[i])
+		if err != nil {
+			return nil, err
+		}
+		res[i] = obs
+	}
+	return res, nil
+}
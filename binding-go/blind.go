@@ -0,0 +1,34 @@
+package gym
+
+// A BlindStepper is an Env which can take a step without
+// receiving the observation, to save bandwidth when the
+// observation would be ignored.
+//
+// Environments created by Make and Client implement
+// BlindStepper.
+// Wrappers do not, since they may need every observation.
+type BlindStepper interface {
+	// StepBlind is like Step, except that the observation
+	// is nil unless done is true.
+	StepBlind(action interface{}) (obs Obs, reward float64,
+		done bool, info interface{}, err error)
+}
+
+// StepBlind takes a step without receiving the
+// observation, unless the episode is done.
+// This is useful for the inner steps of an action repeat,
+// or for warming up an environment.
+//
+// If env is not a BlindStepper, this calls Step and drops
+// the observation, so the results are the same either way.
+func StepBlind(env Stepper, action interface{}) (obs Obs, reward float64, done bool,
+	info interface{}, err error) {
+	if b, ok := env.(BlindStepper); ok {
+		return b.StepBlind(action)
+	}
+	obs, reward, done, info, err = env.Step(action)
+	if !done {
+		obs = nil
+	}
+	return
+}
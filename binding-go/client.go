@@ -0,0 +1,437 @@
+package gym
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A Client manages the connections to a single API server.
+//
+// Environments created by a Client recover from dropped
+// connections on their own.
+// When a command fails because of a connection problem,
+// the environment reconnects to its session on the
+// server, or creates a new environment if the session is
+// gone.
+// The failed command still returns its error, since the
+// command may or may not have run.
+//
+// If an environment has to be created anew, its episode
+// restarts, and changes from commands like Monitor,
+// Configure, or RetroWrap are lost.
+type Client struct {
+	host string
+	opts []Option
+
+	lock   sync.Mutex
+	envs   map[*clientEnv]struct{}
+	closed bool
+}
+
+// NewClient creates a Client for the given host.
+//
+// The options are used for every environment, along with
+// the Resumable option if the server supports it.
+func NewClient(host string, opts ...Option) *Client {
+	return &Client{
+		host: host,
+		opts: opts,
+		envs: map[*clientEnv]struct{}{},
+	}
+}
+
+// Make creates an environment on the server.
+//
+// The environment should be closed when it is no longer
+// needed, either directly or by closing the Client.
+func (c *Client) Make(envName string) (env Env, err error) {
+	defer essentials.AddCtxTo("make environment", &err)
+	stats := newEnvStats()
+	conn, err := c.makeEnv(envName, stats)
+	if err != nil {
+		return nil, err
+	}
+	res := &clientEnv{client: c, envName: envName, env: conn, stats: stats}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		conn.Close()
+		return nil, errors.New("client is closed")
+	}
+	c.envs[res] = struct{}{}
+	return res, nil
+}
+
+// makeEnv creates an environment whose commands are
+// recorded in stats.
+func (c *Client) makeEnv(envName string, stats *envStats) (Env, error) {
+	withStats := func(o *options) {
+		o.Stats = stats
+	}
+	opts := append(append([]Option{}, c.opts...), withStats)
+	env, err := Make(c.host, envName, append([]Option{Resumable()}, opts...)...)
+	if errors.Is(err, ErrUnsupported) {
+		env, err = Make(c.host, envName, opts...)
+	}
+	return env, err
+}
+
+// NumEnvs returns the number of open environments.
+func (c *Client) NumEnvs() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.envs)
+}
+
+// Close closes all of the Client's environments.
+//
+// Environments cannot be created after a Client is closed.
+func (c *Client) Close() error {
+	c.lock.Lock()
+	c.closed = true
+	envs := c.envs
+	c.envs = map[*clientEnv]struct{}{}
+	c.lock.Unlock()
+
+	var firstErr error
+	for env := range envs {
+		if err := env.closeConn(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type clientEnv struct {
+	client  *Client
+	envName string
+
+	// stats is shared by every environment which backs
+	// the handle, so it survives redialing.
+	stats *envStats
+
+	lock   sync.Mutex
+	env    Env
+	closed bool
+}
+
+func (c *clientEnv) Reset() (obs Obs, err error) {
+	err = c.do(func(env Env) (err error) {
+		obs, err = env.Reset()
+		return
+	})
+	return
+}
+
+func (c *clientEnv) Step(action interface{}) (obs Obs, reward float64,
+	done bool, info interface{}, err error) {
+	err = c.do(func(env Env) (err error) {
+		obs, reward, done, info, err = env.Step(action)
+		return
+	})
+	return
+}
+
+func (c *clientEnv) StepBlind(action interface{}) (obs Obs, reward float64,
+	done bool, info interface{}, err error) {
+	err = c.do(func(env Env) (err error) {
+		obs, reward, done, info, err = StepBlind(env, action)
+		return
+	})
+	return
+}
+
+func (c *clientEnv) StepMulti(actions map[string]interface{}) (result *MultiStepResult,
+	err error) {
+	err = c.do(func(env Env) (err error) {
+		result, err = StepMulti(env, actions)
+		return
+	})
+	return
+}
+
+func (c *clientEnv) ActionSpace() (space *Space, err error) {
+	err = c.do(func(env Env) (err error) {
+		space, err = env.ActionSpace()
+		return
+	})
+	return
+}
+
+func (c *clientEnv) ObservationSpace() (space *Space, err error) {
+	err = c.do(func(env Env) (err error) {
+		space, err = env.ObservationSpace()
+		return
+	})
+	return
+}
+
+func (c *clientEnv) Describe() (spec *EnvSpec, err error) {
+	err = c.do(func(env Env) (err error) {
+		spec, err = Describe(env)
+		return
+	})
+	return
+}
+
+func (c *clientEnv) Supports(feature string) (supported bool, err error) {
+	err = c.do(func(env Env) (err error) {
+		supported, err = Supports(env, feature)
+		return
+	})
+	return
+}
+
+func (c *clientEnv) SampleAction(dst interface{}) error {
+	return c.do(func(env Env) error {
+		return env.SampleAction(dst)
+	})
+}
+
+func (c *clientEnv) Monitor(dir string, force, resume, video bool) error {
+	return c.do(func(env Env) error {
+		return env.Monitor(dir, force, resume, video)
+	})
+}
+
+func (c *clientEnv) Render() error {
+	return c.do(Env.Render)
+}
+
+func (c *clientEnv) RenderText() (text string, err error) {
+	err = c.do(func(env Env) (err error) {
+		text, err = RenderText(env)
+		return
+	})
+	return
+}
+
+func (c *clientEnv) Screenshot() (data []byte, err error) {
+	err = c.do(func(env Env) (err error) {
+		data, err = Screenshot(env)
+		return
+	})
+	return
+}
+
+func (c *clientEnv) Configure(options map[string]interface{}) error {
+	return c.do(func(env Env) error {
+		return env.Configure(options)
+	})
+}
+
+func (c *clientEnv) UniverseConfigure(options map[string]interface{}) error {
+	return c.do(func(env Env) error {
+		return env.UniverseConfigure(options)
+	})
+}
+
+func (c *clientEnv) UniverseWrap(wrapper string, options map[string]interface{}) error {
+	return c.do(func(env Env) error {
+		return env.UniverseWrap(wrapper, options)
+	})
+}
+
+func (c *clientEnv) RetroConfigure(options map[string]interface{}) error {
+	return c.do(func(env Env) error {
+		return env.RetroConfigure(options)
+	})
+}
+
+func (c *clientEnv) RetroWrap(wrapper string, options map[string]interface{}) error {
+	return c.do(func(env Env) error {
+		return env.RetroWrap(wrapper, options)
+	})
+}
+
+func (c *clientEnv) RetroSaveState() (state []byte, err error) {
+	err = c.do(func(env Env) (err error) {
+		state, err = env.RetroSaveState()
+		return
+	})
+	return
+}
+
+func (c *clientEnv) RetroLoadState(state []byte) error {
+	return c.do(func(env Env) error {
+		return env.RetroLoadState(state)
+	})
+}
+
+func (c *clientEnv) RetroVariables() (variables map[string]int, err error) {
+	err = c.do(func(env Env) (err error) {
+		variables, err = env.RetroVariables()
+		return
+	})
+	return
+}
+
+func (c *clientEnv) RetroReadRAM(offset, size int) (data []byte, err error) {
+	err = c.do(func(env Env) (err error) {
+		data, err = env.RetroReadRAM(offset, size)
+		return
+	})
+	return
+}
+
+func (c *clientEnv) RetroStartMovie(path string) (moviePath string, err error) {
+	err = c.do(func(env Env) (err error) {
+		moviePath, err = env.RetroStartMovie(path)
+		return
+	})
+	return
+}
+
+func (c *clientEnv) RetroStopMovie() (moviePath string, err error) {
+	err = c.do(func(env Env) (err error) {
+		moviePath, err = env.RetroStopMovie()
+		return
+	})
+	return
+}
+
+func (c *clientEnv) Ping() (res *PingResult, err error) {
+	err = c.do(func(env Env) (err error) {
+		res, err = env.Ping()
+		return
+	})
+	return
+}
+
+func (c *clientEnv) SetLogLevel(level string) error {
+	return c.do(func(env Env) error {
+		return env.SetLogLevel(level)
+	})
+}
+
+func (c *clientEnv) KeepAlive() error {
+	return c.do(Env.KeepAlive)
+}
+
+func (c *clientEnv) Renew() error {
+	return c.do(Env.Renew)
+}
+
+func (c *clientEnv) CloneState() (state []byte, err error) {
+	err = c.do(func(env Env) (err error) {
+		state, err = env.CloneState()
+		return
+	})
+	return
+}
+
+func (c *clientEnv) RestoreState(state []byte) error {
+	return c.do(func(env Env) error {
+		return env.RestoreState(state)
+	})
+}
+
+func (c *clientEnv) GetAttr(name string, dst interface{}) error {
+	return c.do(func(env Env) error {
+		return env.GetAttr(name, dst)
+	})
+}
+
+func (c *clientEnv) CallMethod(name string, dst interface{}, args ...interface{}) error {
+	return c.do(func(env Env) error {
+		return env.CallMethod(name, dst, args...)
+	})
+}
+
+func (c *clientEnv) Stats() *EnvStats {
+	return c.stats.Snapshot()
+}
+
+func (c *clientEnv) Reconnect() (err error) {
+	defer essentials.AddCtxTo("reconnect environment", &err)
+	env, err := c.current()
+	if err != nil {
+		return err
+	}
+	return c.redial(env)
+}
+
+func (c *clientEnv) Close() error {
+	c.client.lock.Lock()
+	delete(c.client.envs, c)
+	c.client.lock.Unlock()
+	return c.closeConn()
+}
+
+func (c *clientEnv) closeConn() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.env.Close()
+}
+
+// do runs a command, and redials if it fails because of
+// the connection.
+func (c *clientEnv) do(f func(env Env) error) error {
+	env, err := c.current()
+	if err != nil {
+		return err
+	}
+	err = f(env)
+	if isConnFailure(err) {
+		// The error from the command is more useful than
+		// an error from redialing, which will show up again
+		// on the next command anyway.
+		c.redial(env)
+	}
+	return err
+}
+
+func (c *clientEnv) current() (Env, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return nil, errors.New("environment is closed")
+	}
+	return c.env, nil
+}
+
+// redial replaces the connection of a failed environment,
+// unless another command already replaced it.
+func (c *clientEnv) redial(failed Env) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return errors.New("environment is closed")
+	} else if c.env != failed {
+		return nil
+	}
+	if failed.Reconnect() == nil {
+		return nil
+	}
+	env, err := c.client.makeEnv(c.envName, c.stats)
+	if err != nil {
+		return err
+	}
+	failed.Close()
+	c.env = env
+	return nil
+}
+
+// isConnFailure checks if an error was caused by a problem
+// with a connection, rather than with a command.
+func isConnFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrConnBroken) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/gym-socket-api/binding-go/gymbench"
+)
+
+func bench(args []string) {
+	var config gymbench.Config
+	var maxConns int
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	flags.StringVar(&config.Host, "host", DefaultHost, "server address")
+	flags.StringVar(&config.EnvName, "env", "", "environment name (required)")
+	flags.IntVar(&maxConns, "conns", 1, "benchmark 1 through this many parallel connections")
+	flags.IntVar(&config.Steps, "steps", gymbench.DefaultSteps, "measured steps per connection")
+	flags.IntVar(&config.Warmup, "warmup", 10, "unmeasured steps per connection")
+	flags.IntVar(&config.PipelineDepth, "pipeline", 1, "steps in flight per connection")
+	flags.Parse(args)
+	if config.EnvName == "" {
+		fmt.Fprintln(os.Stderr, "missing -env flag")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fmt.Printf("env: %s, host: %s, steps: %d, pipeline: %d\n\n", config.EnvName,
+		config.Host, config.Steps, config.PipelineDepth)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "conns\tsteps/sec\tp50\tp90\tp99\tmax\tbytes/step\t")
+	for conns := 1; conns <= maxConns; conns++ {
+		res, err := gymbench.RunParallel(&config, conns)
+		if err != nil {
+			w.Flush()
+			essentials.Die(err)
+		}
+		fmt.Fprintf(w, "%d\t%.1f\t%v\t%v\t%v\t%v\t%.0f\t\n", conns,
+			res.StepsPerSecond(), roundLatency(res.Percentile(50)),
+			roundLatency(res.Percentile(90)), roundLatency(res.Percentile(99)),
+			roundLatency(res.Percentile(100)), res.BytesPerStep())
+	}
+	w.Flush()
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
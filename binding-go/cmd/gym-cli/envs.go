@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+func listEnvs(args []string) {
+	var host, filter string
+	var jsonOut bool
+	flags := flag.NewFlagSet("list-envs", flag.ExitOnError)
+	flags.StringVar(&host, "host", DefaultHost, "server address")
+	flags.StringVar(&filter, "filter", "", "only list names containing this substring")
+	flags.BoolVar(&jsonOut, "json", false, "print a JSON array")
+	flags.Parse(args)
+
+	names, err := gym.ListEnvs(host)
+	if err != nil {
+		essentials.Die(err)
+	}
+	matching := []string{}
+	for _, name := range names {
+		if strings.Contains(name, filter) {
+			matching = append(matching, name)
+		}
+	}
+	if jsonOut {
+		printJSON(matching)
+		return
+	}
+	for _, name := range matching {
+		fmt.Println(name)
+	}
+}
+
+func describe(args []string) {
+	var host string
+	var jsonOut bool
+	flags := flag.NewFlagSet("describe", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gym-cli describe [flags] <env-id>")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&host, "host", DefaultHost, "server address")
+	flags.BoolVar(&jsonOut, "json", false, "print the spec as JSON")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	spec, err := gym.Spec(host, flags.Arg(0))
+	if err != nil {
+		essentials.Die(err)
+	}
+	if jsonOut {
+		printJSON(spec)
+		return
+	}
+	fmt.Println("ID:               ", spec.ID)
+	fmt.Println("Entry point:      ", spec.EntryPoint)
+	if spec.MaxEpisodeSteps > 0 {
+		fmt.Println("Max episode steps:", spec.MaxEpisodeSteps)
+	} else {
+		fmt.Println("Max episode steps: none")
+	}
+	if spec.RewardThreshold != nil {
+		fmt.Println("Reward threshold: ", *spec.RewardThreshold)
+	} else {
+		fmt.Println("Reward threshold:  none")
+	}
+	fmt.Println("Nondeterministic: ", spec.Nondeterministic)
+	if len(spec.Kwargs) > 0 {
+		data, _ := json.Marshal(spec.Kwargs)
+		fmt.Println("Kwargs:           ", string(data))
+	}
+	fmt.Println("Action space:     ", formatSpace(spec.ActionSpace))
+	fmt.Println("Observation space:", formatSpace(spec.ObservationSpace))
+}
+
+// formatSpace describes a space on one line, in the same
+// way that Gym prints spaces.
+func formatSpace(s *gym.Space) string {
+	switch s.Type {
+	case "Discrete", "MultiBinary":
+		return fmt.Sprintf("%s(%d)", s.Type, s.N)
+	case "Box":
+		low, high := boxBounds(s.Low), boxBounds(s.High)
+		return fmt.Sprintf("Box(%s, %s, %s)", low, high, formatShape(s.Shape))
+	case "MultiDiscrete":
+		var pairs []string
+		for i, low := range s.Low {
+			pairs = append(pairs, fmt.Sprintf("[%g, %g]", low, s.High[i]))
+		}
+		return "MultiDiscrete(" + strings.Join(pairs, ", ") + ")"
+	case "Tuple":
+		var subs []string
+		for _, sub := range s.Subspaces {
+			subs = append(subs, formatSpace(sub))
+		}
+		return "Tuple(" + strings.Join(subs, ", ") + ")"
+	}
+	return s.Type
+}
+
+// boxBounds formats the bounds of a Box as a single
+// number if they are all the same, or as a range.
+func boxBounds(values []float64) string {
+	if len(values) == 0 {
+		return "?"
+	}
+	min, max := values[0], values[0]
+	for _, x := range values {
+		if x < min {
+			min = x
+		}
+		if x > max {
+			max = x
+		}
+	}
+	if min == max {
+		return fmt.Sprint(min)
+	}
+	return fmt.Sprintf("[%g..%g]", min, max)
+}
+
+func formatShape(shape []int) string {
+	parts := make([]string, len(shape))
+	for i, dim := range shape {
+		parts[i] = fmt.Sprint(dim)
+	}
+	if len(parts) == 1 {
+		return "(" + parts[0] + ",)"
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func printJSON(obj interface{}) {
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		essentials.Die(err)
+	}
+	fmt.Println(string(data))
+}
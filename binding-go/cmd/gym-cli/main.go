@@ -0,0 +1,74 @@
+// Command gym-cli is a command-line tool for checking and
+// measuring a gym-socket-api server.
+//
+// Run it with a subcommand, such as:
+//
+//	gym-cli random-agent -host localhost:5001 -env CartPole-v0
+//
+// Run it without arguments to list the subcommands.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// A command is a subcommand of gym-cli.
+type command struct {
+	Usage string
+	Run   func(args []string)
+}
+
+var commands = map[string]*command{
+	"bench": {
+		Usage: "measure throughput and latency across connections",
+		Run:   bench,
+	},
+	"describe": {
+		Usage: "show the spec and spaces of an environment",
+		Run:   describe,
+	},
+	"list-envs": {
+		Usage: "list the environments on a server",
+		Run:   listEnvs,
+	},
+	"random-agent": {
+		Usage: "run a random agent and report its returns",
+		Run:   randomAgent,
+	},
+	"record": {
+		Usage: "record trajectories and videos of a policy",
+		Run:   recordCmd,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown command:", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	cmd.Run(os.Args[2:])
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: gym-cli <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].Usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'gym-cli <command> -help' for a command's flags.")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// A policy chooses the next action in an environment.
+type policy func(env gym.Env) (interface{}, error)
+
+// randomPolicy samples actions from the action space.
+func randomPolicy(env gym.Env) (interface{}, error) {
+	var action interface{}
+	err := env.SampleAction(&action)
+	return action, err
+}
+
+// scriptPolicy creates a policy which plays a list of
+// actions, starting over once the list runs out.
+//
+// The file contains a sequence of JSON values, such as one
+// action per line.
+func scriptPolicy(path string) (p policy, err error) {
+	defer essentials.AddCtxTo("load action script", &err)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var actions []interface{}
+	dec := json.NewDecoder(f)
+	for {
+		var action interface{}
+		if err := dec.Decode(&action); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	if len(actions) == 0 {
+		return nil, errors.New("no actions in script")
+	}
+	var idx int
+	return func(env gym.Env) (interface{}, error) {
+		action := actions[idx%len(actions)]
+		idx++
+		return action, nil
+	}, nil
+}
+
+// runEpisode runs an episode and returns its undiscounted
+// return and length.
+//
+// If maxSteps is not 0, the episode is stopped early after
+// that many steps.
+func runEpisode(env gym.Env, p policy, maxSteps int, render bool) (ret float64,
+	steps int, err error) {
+	defer essentials.AddCtxTo("run episode", &err)
+	if _, err := env.Reset(); err != nil {
+		return 0, 0, err
+	}
+	for maxSteps == 0 || steps < maxSteps {
+		if render {
+			if err := env.Render(); err != nil {
+				return 0, 0, err
+			}
+		}
+		action, err := p(env)
+		if err != nil {
+			return 0, 0, err
+		}
+		_, rew, done, _, err := env.Step(action)
+		if err != nil {
+			return 0, 0, err
+		}
+		ret += rew
+		steps++
+		if done {
+			break
+		}
+	}
+	return ret, steps, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScriptPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.json")
+	if err := os.WriteFile(path, []byte("1\n[2, 3]\n\"x\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := scriptPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []interface{}
+	for i := 0; i < 4; i++ {
+		action, err := p(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, action)
+	}
+	expected := []interface{}{1.0, []interface{}{2.0, 3.0}, "x", 1.0}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected %v but got %v", expected, actions)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// DefaultHost is the server used when -host is not set.
+const DefaultHost = "localhost:5001"
+
+func randomAgent(args []string) {
+	var host, envName string
+	var episodes, maxSteps int
+	var render bool
+	flags := flag.NewFlagSet("random-agent", flag.ExitOnError)
+	flags.StringVar(&host, "host", DefaultHost, "server address")
+	flags.StringVar(&envName, "env", "", "environment name (required)")
+	flags.IntVar(&episodes, "episodes", 1, "number of episodes to run")
+	flags.IntVar(&maxSteps, "max-steps", 0, "maximum steps per episode (0 for no limit)")
+	flags.BoolVar(&render, "render", false, "render every step on the server")
+	flags.Parse(args)
+	if envName == "" {
+		fmt.Fprintln(os.Stderr, "missing -env flag")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	env, err := gym.Make(host, envName)
+	if err != nil {
+		essentials.Die(err)
+	}
+	defer env.Close()
+
+	returns := make([]float64, 0, episodes)
+	for i := 0; i < episodes; i++ {
+		start := time.Now()
+		ret, steps, err := runEpisode(env, randomPolicy, maxSteps, render)
+		if err != nil {
+			essentials.Die(err)
+		}
+		fmt.Printf("episode %d: return=%g steps=%d time=%v\n", i, ret, steps,
+			time.Since(start).Round(time.Millisecond))
+		returns = append(returns, ret)
+	}
+	if episodes > 1 {
+		mean, std, min, max := summarize(returns)
+		fmt.Printf("return: mean=%g std=%g min=%g max=%g\n", mean, std, min, max)
+	}
+}
+
+// summarize computes statistics of a non-empty list.
+func summarize(values []float64) (mean, std, min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, x := range values {
+		mean += x
+		min = math.Min(min, x)
+		max = math.Max(max, x)
+	}
+	mean /= float64(len(values))
+	for _, x := range values {
+		std += (x - mean) * (x - mean)
+	}
+	std = math.Sqrt(std / float64(len(values)))
+	return
+}
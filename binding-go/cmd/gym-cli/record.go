@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+	"github.com/unixpickle/gym-socket-api/binding-go/record"
+	"github.com/unixpickle/gym-socket-api/binding-go/video"
+)
+
+func recordCmd(args []string) {
+	var host, envName, outPath, blobDir, videoDir, scriptPath string
+	var episodes, maxSteps, fps int
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	flags.StringVar(&host, "host", DefaultHost, "server address")
+	flags.StringVar(&envName, "env", "", "environment name (required)")
+	flags.IntVar(&episodes, "episodes", 1, "number of episodes to record")
+	flags.IntVar(&maxSteps, "max-steps", 0, "maximum steps per episode (0 for no limit)")
+	flags.StringVar(&outPath, "out", "", "trajectory output file (JSON lines)")
+	flags.StringVar(&blobDir, "blobs", "", "directory for byte list observations "+
+		"(default: embed them in the output)")
+	flags.StringVar(&videoDir, "video", "", "directory for episode videos")
+	flags.IntVar(&fps, "fps", 30, "video frame rate")
+	flags.StringVar(&scriptPath, "actions", "", "file of JSON actions to play "+
+		"(default: random actions)")
+	flags.Parse(args)
+	if envName == "" {
+		fmt.Fprintln(os.Stderr, "missing -env flag")
+		flags.Usage()
+		os.Exit(2)
+	}
+	if outPath == "" && videoDir == "" {
+		fmt.Fprintln(os.Stderr, "nothing to record: set -out and/or -video")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	p := randomPolicy
+	if scriptPath != "" {
+		var err error
+		p, err = scriptPolicy(scriptPath)
+		if err != nil {
+			essentials.Die(err)
+		}
+	}
+
+	env, err := gym.Make(host, envName)
+	if err != nil {
+		essentials.Die(err)
+	}
+	if videoDir != "" {
+		videoEnv, err := video.NewEnv(env, videoDir, &video.Options{FPS: fps})
+		if err != nil {
+			env.Close()
+			essentials.Die(err)
+		}
+		env = videoEnv
+	}
+	if outPath != "" {
+		outFile, err := os.Create(outPath)
+		if err != nil {
+			env.Close()
+			essentials.Die(err)
+		}
+		defer outFile.Close()
+		recorder, err := record.NewRecorder(env, outFile, blobDir)
+		if err != nil {
+			env.Close()
+			essentials.Die(err)
+		}
+		env = recorder
+	}
+
+	for i := 0; i < episodes; i++ {
+		ret, steps, err := runEpisode(env, p, maxSteps, false)
+		if err != nil {
+			env.Close()
+			essentials.Die(err)
+		}
+		fmt.Printf("episode %d: return=%g steps=%d\n", i, ret, steps)
+	}
+	if err := env.Close(); err != nil {
+		essentials.Die(err)
+	}
+}
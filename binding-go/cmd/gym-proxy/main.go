@@ -0,0 +1,151 @@
+// Command gym-proxy exposes one or more gym-socket-api
+// servers through a single endpoint.
+//
+// Environments are routed to backends by name, e.g.:
+//
+//	gym-proxy -addr :5001 \
+//	    -route 'Pong*=gpu1:5001,gpu2:5001' \
+//	    -route '*=localhost:5002' \
+//	    -tls-cert cert.pem -tls-key key.pem \
+//	    -auth-tokens tokens.txt
+//
+// Clients connect with the gym.WithTLS and
+// gym.WithAuthToken options.
+//
+// With the -websocket flag, clients connect over
+// WebSockets instead, with addresses like
+// "wss://proxy.example.com/".
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/gym-socket-api/binding-go/proxy"
+	"github.com/unixpickle/gym-socket-api/binding-go/wsconn"
+)
+
+// routeFlags collects the routes of repeated -route flags.
+type routeFlags []*proxy.Route
+
+func (r *routeFlags) String() string {
+	var parts []string
+	for _, route := range *r {
+		parts = append(parts, route.Pattern+"="+strings.Join(route.Backends, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r *routeFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return errors.New("expected PATTERN=HOST:PORT[,HOST:PORT...]")
+	}
+	*r = append(*r, &proxy.Route{
+		Pattern:  parts[0],
+		Backends: strings.Split(parts[1], ","),
+	})
+	return nil
+}
+
+func main() {
+	var addr, certFile, keyFile, clientCA, tokensFile string
+	var websocket bool
+	var routes routeFlags
+	flag.StringVar(&addr, "addr", ":5001", "address to listen on")
+	flag.Var(&routes, "route", "route environments matching a pattern to "+
+		"backends, as PATTERN=HOST:PORT[,HOST:PORT...] (repeatable)")
+	flag.StringVar(&certFile, "tls-cert", "", "TLS certificate file")
+	flag.StringVar(&keyFile, "tls-key", "", "TLS key file")
+	flag.StringVar(&clientCA, "client-ca", "", "require client certificates "+
+		"signed by this CA file")
+	flag.StringVar(&tokensFile, "auth-tokens", "", "file of accepted auth "+
+		"tokens, one per line")
+	flag.BoolVar(&websocket, "websocket", false, "accept WebSocket connections "+
+		"instead of plain TCP")
+	flag.Parse()
+	if len(routes) == 0 {
+		fmt.Fprintln(os.Stderr, "at least one -route is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config := &proxy.Config{Routes: routes}
+	if tokensFile != "" {
+		tokens, err := readTokens(tokensFile)
+		if err != nil {
+			essentials.Die(err)
+		}
+		config.AuthTokens = tokens
+	}
+	p, err := proxy.New(config)
+	if err != nil {
+		essentials.Die(err)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		essentials.Die(err)
+	}
+	if certFile != "" || keyFile != "" {
+		tlsConfig, err := serverTLSConfig(certFile, keyFile, clientCA)
+		if err != nil {
+			essentials.Die(err)
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+	} else if clientCA != "" {
+		essentials.Die("-client-ca requires -tls-cert and -tls-key")
+	}
+	if websocket {
+		listener = wsconn.NewListener(listener)
+	}
+	log.Printf("listening on %s (routes: %s)", listener.Addr(), routes.String())
+	essentials.Die(p.Serve(listener))
+}
+
+func readTokens(path string) (tokens []string, err error) {
+	defer essentials.AddCtxTo("read auth tokens", &err)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if token := strings.TrimSpace(line); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("no tokens in " + path)
+	}
+	return tokens, nil
+}
+
+func serverTLSConfig(certFile, keyFile, clientCA string) (config *tls.Config, err error) {
+	defer essentials.AddCtxTo("configure TLS", &err)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCA != "" {
+		data, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificates in " + clientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
@@ -0,0 +1,150 @@
+package gym
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// An ObsCodec decodes the data of a custom observation
+// type into an Obs.
+//
+// The data is a fresh slice, so the Obs may keep it.
+type ObsCodec func(data []byte) (Obs, error)
+
+// Custom observation type IDs, which are reserved for
+// experimental encodings.
+const (
+	MinCustomObsType = 0x80
+	MaxCustomObsType = 0xfe
+)
+
+var obsCodecs = struct {
+	lock   sync.RWMutex
+	codecs map[int]ObsCodec
+}{codecs: map[int]ObsCodec{}}
+
+// RegisterObsCodec associates a custom observation type
+// ID with a decoder, so that experimental server-side
+// encodings, such as compressed or float16 observations,
+// can be read by every Env.
+//
+// The ID must be between MinCustomObsType and
+// MaxCustomObsType.
+// On the wire, a custom observation is the type ID
+// followed by a length-prefixed data field, like a JSON
+// observation.
+//
+// Like sql.Register, this panics if the ID is invalid or
+// already registered, so it is typically called from an
+// init function.
+func RegisterObsCodec(id int, codec ObsCodec) {
+	if id < MinCustomObsType || id > MaxCustomObsType {
+		panic(fmt.Sprintf("gym: observation type %d is not a custom type", id))
+	}
+	if codec == nil {
+		panic("gym: nil ObsCodec")
+	}
+	obsCodecs.lock.Lock()
+	defer obsCodecs.lock.Unlock()
+	if _, ok := obsCodecs.codecs[id]; ok {
+		panic(fmt.Sprintf("gym: observation type %d is already registered", id))
+	}
+	obsCodecs.codecs[id] = codec
+}
+
+// lookupObsCodec finds the codec for an observation type,
+// or returns nil.
+func lookupObsCodec(id int) ObsCodec {
+	obsCodecs.lock.RLock()
+	defer obsCodecs.lock.RUnlock()
+	return obsCodecs.codecs[id]
+}
+
+// An ActionCodec converts an action of a custom type into
+// one that Step can send: an int, a []float64, a
+// JSON-compatible value, or a RawAction.
+type ActionCodec func(action interface{}) (interface{}, error)
+
+// A RawAction is an action which is already encoded, with
+// a custom action type ID between MinCustomActionType and
+// MaxCustomActionType.
+//
+// It is only understood by servers with matching
+// experimental action encodings.
+type RawAction struct {
+	Type int
+	Data []byte
+}
+
+// Custom action type IDs, which are reserved for
+// experimental encodings.
+const (
+	MinCustomActionType = 0x80
+	MaxCustomActionType = 0xfe
+)
+
+var actionCodecs = struct {
+	lock   sync.RWMutex
+	codecs map[reflect.Type]ActionCodec
+}{codecs: map[reflect.Type]ActionCodec{}}
+
+// RegisterActionCodec associates a Go type, given by an
+// example value, with an encoder, so that actions of that
+// type can be passed directly to Step.
+//
+// For example, a struct of joint torques could be encoded
+// as a []float64 for a Box space, or a discrete choice
+// with extra metadata could be reduced to its index.
+//
+// Like RegisterObsCodec, this panics if the type is
+// already registered.
+func RegisterActionCodec(example interface{}, codec ActionCodec) {
+	if example == nil || codec == nil {
+		panic("gym: nil action codec or example")
+	}
+	t := reflect.TypeOf(example)
+	actionCodecs.lock.Lock()
+	defer actionCodecs.lock.Unlock()
+	if _, ok := actionCodecs.codecs[t]; ok {
+		panic(fmt.Sprintf("gym: action type %v is already registered", t))
+	}
+	actionCodecs.codecs[t] = codec
+}
+
+// encodeCustomAction applies the registered codec for an
+// action's type, if there is one.
+func encodeCustomAction(act interface{}) (interface{}, error) {
+	if act == nil {
+		return nil, nil
+	}
+	actionCodecs.lock.RLock()
+	codec := actionCodecs.codecs[reflect.TypeOf(act)]
+	actionCodecs.lock.RUnlock()
+	if codec == nil {
+		return act, nil
+	}
+	return codec(act)
+}
+
+// writeRawAction writes a RawAction, if act is one.
+func writeRawAction(w io.Writer, act interface{}) (bool, error) {
+	var raw *RawAction
+	switch act := act.(type) {
+	case RawAction:
+		raw = &act
+	case *RawAction:
+		raw = act
+	default:
+		return false, nil
+	}
+	if raw.Type < MinCustomActionType || raw.Type > MaxCustomActionType {
+		return true, errors.New("raw action type is not a custom type")
+	}
+	if err := writeByte(w, byte(raw.Type)); err != nil {
+		return true, err
+	}
+	return true, writeByteField(w, raw.Data)
+}
@@ -0,0 +1,82 @@
+package gym
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRegisterObsCodec(t *testing.T) {
+	// The registry is global, so only register once when
+	// the test is repeated.
+	if lookupObsCodec(MaxCustomObsType) == nil {
+		RegisterObsCodec(MaxCustomObsType, func(data []byte) (Obs, error) {
+			return NewUint8Obs([]int{len(data)}, data), nil
+		})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(MaxCustomObsType)
+	writeByteField(&buf, []byte{1, 2, 3})
+	obs, err := readObservation(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := obs.(Uint8Obs).Uint8Obs(); !bytes.Equal(values, []byte{1, 2, 3}) {
+		t.Errorf("unexpected values: %v", values)
+	}
+
+	buf.Reset()
+	buf.WriteByte(MaxCustomObsType - 1)
+	writeByteField(&buf, []byte{1})
+	if _, err := readObservation(&buf, nil); err == nil {
+		t.Error("expected an error for an unregistered type")
+	}
+
+	for _, id := range []int{observationJSON, MaxCustomObsType} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for type %d", id)
+				}
+			}()
+			RegisterObsCodec(id, func(data []byte) (Obs, error) { return nil, nil })
+		}()
+	}
+}
+
+type testJointTorques struct {
+	Shoulder, Elbow float64
+}
+
+func TestRegisterActionCodec(t *testing.T) {
+	actionCodecs.lock.RLock()
+	_, registered := actionCodecs.codecs[reflect.TypeOf(testJointTorques{})]
+	actionCodecs.lock.RUnlock()
+	if !registered {
+		RegisterActionCodec(testJointTorques{}, func(act interface{}) (interface{}, error) {
+			torques := act.(testJointTorques)
+			return []float64{torques.Shoulder, torques.Elbow}, nil
+		})
+	}
+
+	var custom, expected bytes.Buffer
+	if err := writeAction(&custom, testJointTorques{1, 2}, true); err != nil {
+		t.Fatal(err)
+	}
+	writeAction(&expected, []float64{1, 2}, true)
+	if !bytes.Equal(custom.Bytes(), expected.Bytes()) {
+		t.Errorf("expected %v but got %v", expected.Bytes(), custom.Bytes())
+	}
+
+	custom.Reset()
+	if err := writeAction(&custom, RawAction{Type: 0x90, Data: []byte{7}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(custom.Bytes(), []byte{0x90, 1, 0, 0, 0, 7}) {
+		t.Errorf("unexpected raw action: %v", custom.Bytes())
+	}
+	if err := writeAction(&custom, RawAction{Type: actionBox}, false); err == nil {
+		t.Error("expected an error for a built-in action type")
+	}
+}
@@ -0,0 +1,535 @@
+package gym
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxInFlight is the number of requests which may be sent
+// on a connection before their responses are read.
+const maxInFlight = 256
+
+// envConn is a connection to an API server, which may be
+// shared by multiple environments.
+//
+// Requests are sent by a writer Goroutine in the order
+// they are submitted.
+// Since the server answers requests in order, a reader
+// Goroutine can match each response to its request.
+// Callers wait on a channel for their own response, so
+// concurrent commands do not hold each other up while
+// they are in flight.
+type envConn struct {
+	Buf  *bufio.ReadWriter
+	Conn net.Conn
+
+	// Host and Token are used to resume a session.
+	// Token is empty if the session is not resumable.
+	Host  string
+	Token string
+
+	// Multiplexed is set if commands are prefixed with
+	// environment IDs.
+	Multiplexed bool
+
+	// Retry is the retry policy for failed commands, or
+	// nil if they are not retried.
+	Retry *RetryPolicy
+
+	// Timeout limits the time for each command, or is 0
+	// for no limit.
+	Timeout time.Duration
+
+	// Lease is the duration of the connection's lease, or
+	// 0 if it has no lease.
+	Lease time.Duration
+
+	// Dial, Socket, and AuthToken configure new
+	// connections when reconnecting.
+	Dial      DialFunc
+	Socket    *SocketConfig
+	AuthToken string
+
+	// BinaryActions is set if the server accepts binary
+	// action encodings.
+	BinaryActions bool
+
+	// InfoMode determines how info objects are decoded.
+	InfoMode infoMode
+
+	// Written counts the bytes sent on Conn, which tells
+	// if a failed request reached the server.
+	Written *countingWriter
+
+	// Read counts the bytes received on Conn.
+	Read *countingReader
+
+	// Tracer is notified of every command, or is nil.
+	Tracer Tracer
+
+	// Logger receives debug logs, or is nil.
+	Logger *slog.Logger
+
+	// connLock is held for reading while commands are
+	// submitted, and for writing while the connection is
+	// replaced or shut down.
+	// Commands do not hold it while they wait for their
+	// responses, so that a stuck command can be aborted by
+	// closing or reconnecting.
+	connLock sync.RWMutex
+
+	// generation counts the times the connection has been
+	// replaced.
+	generation int
+
+	submitLock sync.Mutex
+	requests   chan *request
+	closed     bool
+
+	stateLock sync.Mutex
+	brokenErr error
+
+	workers sync.WaitGroup
+
+	refLock sync.Mutex
+	refs    int
+}
+
+// A request is a command waiting to be sent, or waiting
+// for its response.
+type request struct {
+	// Write encodes the request.
+	Write func(w *bufio.Writer) error
+
+	// Read decodes the response, or is nil if the command
+	// has no response.
+	Read func(r *bufio.Reader) error
+
+	// Sent and Received count the bytes of the command.
+	// They are only valid once Done has been signaled.
+	Sent     int
+	Received int
+
+	// Done receives the result of the command.
+	Done chan error
+}
+
+// dialEnvConn connects to a server and performs a
+// handshake, retrying according to the request's retry
+// policy.
+func dialEnvConn(host string, req *handshakeRequest) (conn *envConn, err error) {
+	retry := req.Options.Retry
+	for attempt := 1; true; attempt++ {
+		conn, err = dialEnvConnOnce(host, req)
+		if retry == nil || attempt >= retry.MaxAttempts || !isTransient(err) {
+			break
+		}
+		time.Sleep(retry.delay(attempt))
+	}
+	if conn != nil {
+		conn.Multiplexed = req.NumEnvs > 0
+		conn.Retry = retry
+		conn.Timeout = req.Options.Timeout
+		conn.Lease = req.Options.Lease
+		conn.Dial = req.Options.Dial
+		conn.Socket = req.Options.Socket
+		conn.AuthToken = req.Options.AuthToken
+		conn.BinaryActions = req.Options.BinaryActions
+		conn.InfoMode = req.Options.InfoMode
+		conn.Tracer = req.Options.Tracer
+		conn.Logger = req.Options.Logger
+		conn.start()
+		if conn.Lease != 0 {
+			if err = conn.renewLease(); err != nil {
+				conn.release()
+				conn = nil
+			}
+		}
+	}
+	return
+}
+
+func dialEnvConnOnce(host string, req *handshakeRequest) (*envConn, error) {
+	conn, err := dialHost(host, req.Options.Dial)
+	if err != nil {
+		return nil, err
+	}
+
+	socket := req.Options.Socket
+	if socket == nil {
+		socket = &SocketConfig{}
+	}
+	if err := socket.apply(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if req.Options.Timeout != 0 {
+		conn.SetDeadline(time.Now().Add(req.Options.Timeout))
+	}
+	written := &countingWriter{W: conn}
+	read := &countingReader{R: conn}
+	readSize, writeSize := socket.bufferSizes()
+	rw := bufio.NewReadWriter(bufio.NewReaderSize(read, readSize),
+		bufio.NewWriterSize(written, writeSize))
+	token, err := handshake(rw, req)
+	if l := req.Options.Logger; l != nil {
+		l.Debug("handshake", "host", host, "env", req.EnvName, "flags", req.Flags(),
+			"resume", req.ResumeToken != "", "error", err)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return &envConn{
+		Buf:     rw,
+		Conn:    conn,
+		Host:    host,
+		Token:   token,
+		Written: written,
+		Read:    read,
+		refs:    1,
+	}, nil
+}
+
+// start launches the Goroutines which send requests and
+// read their responses.
+func (e *envConn) start() {
+	e.requests = make(chan *request)
+	e.closed = false
+	pending := make(chan *request, maxInFlight)
+	e.workers.Add(2)
+	go e.writeLoop(pending)
+	go e.readLoop(pending)
+}
+
+// stop shuts down the connection and its Goroutines.
+//
+// Requests which have not been answered fail.
+// The caller should hold connLock for writing.
+func (e *envConn) stop() error {
+	e.submitLock.Lock()
+	if e.closed {
+		e.submitLock.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.requests)
+	e.submitLock.Unlock()
+	err := e.Conn.Close()
+	e.workers.Wait()
+	return err
+}
+
+// writeLoop sends requests and passes them on to the
+// reader.
+//
+// If a request fails before any of it is sent, the partial
+// request is discarded.
+// If it fails after the server may have seen part of it,
+// the server's state is unknown, so the connection is
+// marked as broken.
+func (e *envConn) writeLoop(pending chan<- *request) {
+	defer e.workers.Done()
+	defer close(pending)
+	for req := range e.requests {
+		if err := e.broken(); err != nil {
+			req.Done <- err
+			continue
+		}
+		if e.Timeout != 0 {
+			e.Conn.SetWriteDeadline(time.Now().Add(e.Timeout))
+		}
+		written := e.Written.N
+		err := req.Write(e.Buf.Writer)
+		if err == nil {
+			err = e.Buf.Flush()
+		}
+		req.Sent = int(e.Written.N - written)
+		if err != nil {
+			if e.Written.N == written {
+				e.Buf.Writer.Reset(e.Written)
+			} else {
+				e.setBroken(err)
+			}
+			req.Done <- err
+		} else if req.Read == nil {
+			req.Done <- nil
+		} else {
+			pending <- req
+		}
+	}
+}
+
+// readLoop reads the responses to requests in the order
+// that they were sent.
+//
+// If a response cannot be read, other than because of an
+// error from the server, the next response could not be
+// told apart from stale bytes.
+// Thus, the connection is marked as broken, and later
+// commands fail until it is reconnected.
+func (e *envConn) readLoop(pending <-chan *request) {
+	defer e.workers.Done()
+	for req := range pending {
+		if err := e.broken(); err != nil {
+			req.Done <- err
+			continue
+		}
+		if e.Timeout != 0 {
+			e.Conn.SetReadDeadline(time.Now().Add(e.Timeout))
+		}
+		consumed := e.Read.N - int64(e.Buf.Reader.Buffered())
+		err := req.Read(e.Buf.Reader)
+		req.Received = int(e.Read.N - int64(e.Buf.Reader.Buffered()) - consumed)
+		if err != nil {
+			var envErr *EnvError
+			if !errors.As(err, &envErr) {
+				e.setBroken(err)
+			}
+		}
+		req.Done <- err
+	}
+}
+
+// broken returns a brokenConnError if the connection is
+// broken, or nil otherwise.
+func (e *envConn) broken() error {
+	e.stateLock.Lock()
+	defer e.stateLock.Unlock()
+	if e.brokenErr != nil {
+		return &brokenConnError{Cause: e.brokenErr}
+	}
+	return nil
+}
+
+func (e *envConn) setBroken(err error) {
+	e.stateLock.Lock()
+	defer e.stateLock.Unlock()
+	if e.brokenErr == nil {
+		e.brokenErr = err
+		e.debug("connection broken", "error", err)
+	}
+}
+
+// debug logs a message if there is a Logger.
+func (e *envConn) debug(msg string, args ...interface{}) {
+	if e.Logger != nil {
+		e.Logger.Debug(msg, append([]interface{}{"host", e.Host}, args...)...)
+	}
+}
+
+// submit queues a request without waiting for it to
+// finish.
+//
+// The caller should hold connLock for reading.
+func (e *envConn) submit(write func(w *bufio.Writer) error,
+	read func(r *bufio.Reader) error) (*request, error) {
+	req := &request{Write: write, Read: read, Done: make(chan error, 1)}
+	e.submitLock.Lock()
+	defer e.submitLock.Unlock()
+	if e.closed {
+		return nil, errors.New("connection is closed")
+	}
+	e.requests <- req
+	return req, nil
+}
+
+// command sends a request on the connection and waits for
+// its response.
+//
+// If read is nil, the command has no response.
+//
+// If the command fails to send because of a network error,
+// and if the session can be resumed, the command is sent
+// again on a new connection according to the retry policy.
+//
+// The op names the command for the Tracer.
+func (e *envConn) command(op string, write func(w *bufio.Writer) error,
+	read func(r *bufio.Reader) error) error {
+	_, _, err := e.commandTraffic(op, write, read)
+	return err
+}
+
+// commandTraffic is like command, but it also returns the
+// number of bytes sent and received, including retries.
+func (e *envConn) commandTraffic(op string, write func(w *bufio.Writer) error,
+	read func(r *bufio.Reader) error) (sent, received int, err error) {
+	if e.Tracer != nil {
+		e.Tracer.Begin(op)
+	}
+	start := time.Now()
+	gen, req, err := e.run(write, read)
+	for attempt := 1; e.canRetry(err, attempt); attempt++ {
+		sent, received = sent+req.Sent, received+req.Received
+		time.Sleep(e.Retry.delay(attempt))
+		if err = e.reconnect(gen); err == nil {
+			gen, req, err = e.run(write, read)
+		}
+	}
+	sent, received = sent+req.Sent, received+req.Received
+	if e.Tracer != nil {
+		e.Tracer.End(op, err, sent+received)
+	}
+	e.debug("command", "op", op, "sent", sent, "received", received,
+		"latency", time.Since(start), "error", err)
+	return
+}
+
+// run makes one attempt at a command.
+//
+// It returns the generation of the connection that was
+// used, and the request, which counts the bytes that were
+// sent and received.
+func (e *envConn) run(write func(w *bufio.Writer) error,
+	read func(r *bufio.Reader) error) (gen int, req *request, err error) {
+	e.connLock.RLock()
+	gen = e.generation
+	req, err = e.submit(write, read)
+	e.connLock.RUnlock()
+	if err != nil {
+		return gen, &request{}, err
+	}
+	// Stopping the connection answers every request.
+	err = <-req.Done
+	return gen, req, err
+}
+
+func (e *envConn) canRetry(err error, attempt int) bool {
+	return e.Retry != nil && e.Token != "" && attempt < e.Retry.MaxAttempts &&
+		isTransient(err)
+}
+
+// reconnect replaces the connection with a new one which
+// resumes the session.
+//
+// If the connection was already replaced since the given
+// generation, e.g. by another command which failed at the
+// same time, nothing is done.
+// A negative generation always reconnects.
+func (e *envConn) reconnect(gen int) (err error) {
+	if e.Token == "" {
+		return errors.New("environment is not resumable")
+	}
+	e.connLock.Lock()
+	defer e.connLock.Unlock()
+	if gen >= 0 && e.generation != gen {
+		return nil
+	}
+	if t, ok := e.Tracer.(ReconnectTracer); ok {
+		defer func() {
+			t.Reconnect(err)
+		}()
+	}
+	defer func() {
+		e.debug("reconnect", "generation", e.generation, "error", err)
+	}()
+	newConn, err := dialEnvConnOnce(e.Host, &handshakeRequest{
+		ResumeToken: e.Token,
+		Options: &options{
+			Timeout:   e.Timeout,
+			Dial:      e.Dial,
+			Socket:    e.Socket,
+			AuthToken: e.AuthToken,
+			Logger:    e.Logger,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	e.refLock.Lock()
+	defer e.refLock.Unlock()
+	if e.refs == 0 {
+		newConn.Conn.Close()
+		return errors.New("environment is closed")
+	}
+	e.stop()
+	e.Conn = newConn.Conn
+	e.Buf = newConn.Buf
+	e.Written = newConn.Written
+	e.Read = newConn.Read
+	e.brokenErr = nil
+	e.generation++
+	e.start()
+	return nil
+}
+
+// release drops a reference to the connection, closing it
+// once nothing references it.
+func (e *envConn) release() error {
+	e.refLock.Lock()
+	e.refs--
+	last := e.refs == 0
+	e.refLock.Unlock()
+	if !last {
+		return nil
+	}
+	if e.Token != "" && e.broken() == nil {
+		// Let the server free the session right away,
+		// rather than waiting for a reconnect.
+		e.endSession()
+	}
+	e.connLock.Lock()
+	defer e.connLock.Unlock()
+	return e.stop()
+}
+
+func (e *envConn) endSession() error {
+	_, _, err := e.run(func(w *bufio.Writer) error {
+		if e.Multiplexed {
+			if err := writeUint32(w, 0); err != nil {
+				return err
+			}
+		}
+		return writePacketType(w, packetEndSession)
+	}, nil)
+	return err
+}
+
+// renewLease grants or extends the connection's lease.
+func (e *envConn) renewLease() error {
+	_, _, err := e.run(func(w *bufio.Writer) error {
+		if e.Multiplexed {
+			if err := writeUint32(w, 0); err != nil {
+				return err
+			}
+		}
+		if err := writePacketType(w, packetRenewLease); err != nil {
+			return err
+		}
+		return writeFloat64(w, e.Lease.Seconds())
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
+	return err
+}
+
+// countingWriter counts the bytes written to a Writer.
+type countingWriter struct {
+	W io.Writer
+	N int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.W.Write(p)
+	c.N += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from a Reader.
+type countingReader struct {
+	R io.Reader
+	N int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.R.Read(p)
+	c.N += int64(n)
+	return n, err
+}
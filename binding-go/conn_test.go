@@ -0,0 +1,311 @@
+package gym
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/unixpickle/gym-socket-api/binding-go/wsconn"
+)
+
+func TestConcurrentCommands(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveEchoSteps(listener)
+
+	env, err := Make(listener.Addr().String(), "CartPole-v0",
+		WithTimeout(time.Second*5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				action := i*1000 + j
+				obs, _, _, _, err := env.Step(action)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				var actual int
+				if err := obs.Unmarshal(&actual); err != nil {
+					t.Error(err)
+					return
+				}
+				if actual != action {
+					t.Errorf("expected observation %d but got %d", action, actual)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// serveEchoSteps accepts one connection and responds to
+// each step with an observation equal to the action.
+func serveEchoSteps(listener net.Listener) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	if _, err := rw.ReadByte(); err != nil {
+		return
+	}
+	if _, err := readByteField(rw); err != nil {
+		return
+	}
+	writeUint32(rw, 0)
+	rw.Flush()
+	echoSteps(rw, -1)
+}
+
+// echoSteps responds to each step with an observation
+// equal to the action, until the connection fails or, if
+// limit is non-negative, limit steps have been answered.
+func echoSteps(rw *bufio.ReadWriter, limit int) {
+	for i := 0; i != limit; i++ {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(rw, header); err != nil {
+			return
+		}
+		action, err := readByteField(rw)
+		if err != nil {
+			return
+		}
+		writePacketType(rw, observationJSON)
+		writeByteField(rw, action)
+		binary.Write(rw, byteOrder, 0.0)
+		writeBool(rw, false)
+		writeByteField(rw, []byte("{}"))
+		rw.Flush()
+	}
+}
+
+func TestCloseDuringCommand(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveSilently(listener)
+
+	env, err := Make(listener.Addr().String(), "CartPole-v0", WithTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stepErr := make(chan error, 1)
+	go func() {
+		_, _, _, _, err := env.Step(1)
+		stepErr <- err
+	}()
+	time.Sleep(time.Millisecond * 50)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- env.Close()
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Close blocked on a command in flight")
+	}
+	select {
+	case err := <-stepErr:
+		if err == nil {
+			t.Error("expected the aborted step to fail")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("step was not aborted")
+	}
+}
+
+// serveSilently accepts one connection and completes the
+// handshake, but never answers a command.
+func serveSilently(listener net.Listener) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	if _, err := rw.ReadByte(); err != nil {
+		return
+	}
+	if _, err := readByteField(rw); err != nil {
+		return
+	}
+	writeUint32(rw, 0)
+	rw.Flush()
+	io.Copy(io.Discard, rw)
+}
+
+func TestReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveResumedSteps(listener)
+
+	env, err := Make(listener.Addr().String(), "CartPole-v0", Resumable(),
+		WithTimeout(time.Second*5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if _, _, _, _, err := env.Step(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server drops the first connection after one step,
+	// which the client only notices once it reconnects.
+	if err := env.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	obs, _, _, _, err := env.Step(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual int
+	if err := obs.Unmarshal(&actual); err != nil {
+		t.Fatal(err)
+	} else if actual != 2 {
+		t.Errorf("expected observation 2 but got %d", actual)
+	}
+}
+
+// serveResumedSteps accepts a resumable session and
+// answers one step before dropping the connection.
+// It then accepts a connection which resumes the session
+// and echoes steps on it.
+func serveResumedSteps(listener net.Listener) {
+	for _, resume := range []bool{false, true} {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		flags, err := rw.ReadByte()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		token, err := readByteField(rw)
+		if err != nil || resume != (flags&flagResume != 0) ||
+			(resume && string(token) != "token") {
+			conn.Close()
+			return
+		}
+		writeUint32(rw, 0)
+		if !resume {
+			writeByteField(rw, []byte("token"))
+		}
+		rw.Flush()
+		if !resume {
+			echoSteps(rw, 1)
+			conn.Close()
+		} else {
+			defer conn.Close()
+			echoSteps(rw, -1)
+		}
+	}
+}
+
+func TestAddressSchemes(t *testing.T) {
+	tcpListener := func() net.Listener {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return listener
+	}
+	unixPath := filepath.Join(t.TempDir(), "gym.sock")
+	unixListener, err := net.Listen("unix", unixPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := tcpListener()
+	ws := wsconn.NewListener(tcpListener())
+
+	tests := map[string]net.Listener{
+		"tcp://" + plain.Addr().String():      plain,
+		"unix://" + unixPath:                  unixListener,
+		"ws://" + ws.Addr().String() + "/gym": ws,
+	}
+	for host, listener := range tests {
+		defer listener.Close()
+		go serveEchoSteps(listener)
+		env, err := Make(host, "CartPole-v0", WithTimeout(time.Second*5))
+		if err != nil {
+			t.Errorf("%s: %v", host, err)
+			continue
+		}
+		obs, _, _, _, err := env.Step(3)
+		env.Close()
+		if err != nil {
+			t.Errorf("%s: %v", host, err)
+			continue
+		}
+		var actual int
+		if err := obs.Unmarshal(&actual); err != nil || actual != 3 {
+			t.Errorf("%s: unexpected observation: %v (error %v)", host, actual, err)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveEchoSteps(listener)
+
+	tracer := &testTracer{}
+	env, err := Make(listener.Addr().String(), "CartPole-v0", WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if _, _, _, _, err := env.Step(3); err != nil {
+		t.Fatal(err)
+	}
+	// Request: packet type, action type, and a 1-byte JSON
+	// field.
+	// Response: observation type, a 1-byte JSON field, the
+	// reward, done, and a 2-byte info field.
+	expected := []string{"begin step", fmt.Sprintf("end step <nil> %d", 2+5+1+5+8+1+6)}
+	if !reflect.DeepEqual(tracer.Events, expected) {
+		t.Errorf("expected %v but got %v", expected, tracer.Events)
+	}
+}
+
+type testTracer struct {
+	Events []string
+}
+
+func (t *testTracer) Begin(op string) {
+	t.Events = append(t.Events, "begin "+op)
+}
+
+func (t *testTracer) End(op string, err error, bytes int) {
+	t.Events = append(t.Events, fmt.Sprintf("end %s %v %d", op, err, bytes))
+}
@@ -0,0 +1,63 @@
+//go:build js && wasm
+
+// Command browser plays FrozenLake with a random agent in
+// a web page, showing each step as text.
+//
+// Build it with GOOS=js GOARCH=wasm, and serve it next to
+// index.html and Go's wasm_exec.js.
+// The page connects to a gym-proxy in front of a server,
+// such as one started with:
+//
+//	gym-proxy -websocket -addr :5002 -route '*=localhost:5001'
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"syscall/js"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+const Host = "ws://localhost:5002/"
+
+// colorCodes matches the ANSI color codes which highlight
+// the agent, since a web page cannot show them.
+var colorCodes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func main() {
+	screen := js.Global().Get("document").Call("getElementById", "screen")
+	show := func(text string) {
+		screen.Set("textContent", colorCodes.ReplaceAllString(text, ""))
+	}
+
+	// Browsers can only reach the server over WebSockets.
+	env, err := gym.Make(Host, "FrozenLake-v0")
+	must(err)
+	defer env.Close()
+
+	_, err = env.Reset()
+	must(err)
+	for step := 1; ; step++ {
+		var action int
+		must(env.SampleAction(&action))
+		_, rew, done, _, err := env.Step(action)
+		must(err)
+
+		// Render on the page rather than the server's console.
+		text, err := gym.RenderText(env)
+		must(err)
+		show(fmt.Sprintf("Step %d: reward=%f\n%s", step, rew, text))
+		if done {
+			break
+		}
+	}
+}
+
+func must(err error) {
+	if err != nil {
+		js.Global().Get("document").Call("getElementById", "screen").
+			Set("textContent", "Error: "+err.Error())
+		panic(err)
+	}
+}
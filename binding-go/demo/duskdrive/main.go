@@ -10,6 +10,7 @@ import (
 
 	"github.com/unixpickle/essentials"
 	gym "github.com/unixpickle/gym-socket-api/binding-go"
+	"github.com/unixpickle/gym-socket-api/binding-go/universe"
 )
 
 const (
@@ -45,8 +46,7 @@ func main() {
 	log.Println("Running...")
 	startTime := time.Now().UnixNano()
 	for i := 0; i < Frames; i++ {
-		action := []interface{}{[]interface{}{"KeyEvent", "ArrowUp", true}}
-		lastObs, _, _, _, err = env.Step(action)
+		lastObs, _, _, _, err = env.Step(universe.KeyPress("ArrowUp"))
 		must(err)
 	}
 	seconds := float64(time.Now().UnixNano()-startTime) / 1e9
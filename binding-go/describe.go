@@ -0,0 +1,92 @@
+package gym
+
+import (
+	"bufio"
+	"encoding/json"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A Describer is an Env which can get its spec and both of
+// its spaces in a single request.
+//
+// Environments created by Make and Client implement
+// Describer.
+type Describer interface {
+	// Describe gets the spec of the environment.
+	//
+	// The spaces are the environment's current spaces,
+	// which reflect server-side wrappers and configuration.
+	// If the environment was not made from a registered
+	// spec, the ID is empty.
+	Describe() (*EnvSpec, error)
+}
+
+// Describe gets the spec and spaces of an environment.
+//
+// If env is not a Describer, this queries the spaces one at
+// a time, and the rest of the spec is left empty.
+func Describe(env SpaceProvider) (*EnvSpec, error) {
+	if d, ok := env.(Describer); ok {
+		return d.Describe()
+	}
+	actionSpace, err := env.ActionSpace()
+	if err != nil {
+		return nil, err
+	}
+	obsSpace, err := env.ObservationSpace()
+	if err != nil {
+		return nil, err
+	}
+	return &EnvSpec{ActionSpace: actionSpace, ObservationSpace: obsSpace}, nil
+}
+
+// Describe gets the spec of the environment.
+//
+// The result is cached like the spaces, so ActionSpace and
+// ObservationSpace do not need their own round trips
+// afterwards.
+func (c *connEnv) Describe() (spec *EnvSpec, err error) {
+	c.cacheLock.Lock()
+	cached, gen := c.spec, c.cacheGen
+	c.cacheLock.Unlock()
+	if cached != nil {
+		return cached.copy(), nil
+	}
+	defer essentials.AddCtxTo("describe environment", &err)
+	err = c.command("describe", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetDescribe)
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		return readTempField(r, func(data []byte) error {
+			return json.Unmarshal(data, &spec)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	c.cacheLock.Lock()
+	if c.cacheGen == gen {
+		c.spec = spec.copy()
+		c.spaces[actionSpace] = spec.ActionSpace.copy()
+		c.spaces[observationSpace] = spec.ObservationSpace.copy()
+	}
+	c.cacheLock.Unlock()
+	return spec, nil
+}
+
+// invalidateSpaces clears the cached spec and spaces, and
+// keeps commands which are already running from caching
+// their results.
+func (c *connEnv) invalidateSpaces() {
+	c.cacheLock.Lock()
+	c.spec = nil
+	c.spaces = [2]*Space{}
+	c.cacheGen++
+	c.cacheLock.Unlock()
+}
@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"encoding/json"
 	"errors"
-	"net"
+	"fmt"
+	"io"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/unixpickle/essentials"
 )
@@ -14,15 +16,151 @@ import (
 // Env is a handle on a Gym environment.
 //
 // The methods on an Env are thread-safe.
+//
+// Env is made up of smaller interfaces, like Stepper and
+// RetroEnv, so that code which only needs part of an
+// environment can accept just that part.
+// Implementations of Env which do not support Universe or
+// Retro can embed UnsupportedUniverse and
+// UnsupportedRetro rather than stubbing every method.
 type Env interface {
+	Resetter
+	Stepper
+	SpaceProvider
+	Monitored
+	UniverseEnv
+	RetroEnv
+
+	// Close stops and cleans up the environment.
+	Close() error
+
+	// Configure changes the settings of a live Gym
+	// environment.
+	//
+	// Supported options include "render_fps", "frameskip",
+	// "max_episode_steps", "ale" (an object of ALE
+	// settings such as "repeat_action_probability"), and
+	// "observation_roi" (see SetObservationROI).
+	// Other options set attributes on the unwrapped
+	// environment, if they already exist.
+	Configure(options map[string]interface{}) error
+
+	// Ping checks that the server is responsive and gets
+	// its status.
+	Ping() (*PingResult, error)
+
+	// SetLogLevel sets the verbosity of the server's logs
+	// for this connection.
+	//
+	// The level is "debug", "info", "warning" (the
+	// default), or "error".
+	// At the "debug" level, the server logs every command
+	// it receives, along with Python tracebacks for any
+	// errors.
+	SetLogLevel(level string) error
+
+	// KeepAlive prevents the server from closing the
+	// connection when it is idle.
+	//
+	// See ServerStatus.IdleTTL for details.
+	KeepAlive() error
+
+	// Renew extends the lease on the environment by the
+	// duration given to WithLease.
+	// If the lease runs out, the server closes the
+	// connection and reclaims its environments, even if
+	// the client is still sending commands.
+	//
+	// For environments created by MakeN, the lease covers
+	// every environment on the shared connection.
+	// Without a lease, Renew does nothing.
+	Renew() error
+
+	// Reconnect replaces the environment's connection with
+	// a new one, and reattaches to the environment on the
+	// server.
+	// This only works for environments created with the
+	// Resumable option.
+	//
+	// The server keeps the environment alive for a while
+	// after a connection drops (see the server's
+	// --session-ttl flag), so a Reconnect can recover from
+	// a network failure without losing episode progress.
+	// The result of a command which was interrupted by the
+	// failure is lost, so it may have to be sent again.
+	//
+	// For environments created by MakeN, this reconnects
+	// every environment on the shared connection.
+	//
+	// Reconnect also recovers a connection which failed
+	// with ErrConnBroken.
+	Reconnect() error
+
+	// CloneState saves the state of the environment, such
+	// as the emulator state of a game.
+	//
+	// The state is opaque, and can be restored with
+	// RestoreState, even by a different server.
+	// Not every environment supports this; those which do
+	// not fail with ErrUnsupported.
+	CloneState() ([]byte, error)
+
+	// RestoreState restores a state from CloneState.
+	//
+	// The environment should be the same kind that saved
+	// the state, and should have been reset at least once.
+	RestoreState(state []byte) error
+
+	// GetAttr reads an attribute of the environment on the
+	// server, such as the init_qpos of a MuJoCo
+	// environment, and decodes its JSON value into dst.
+	//
+	// Attributes which are missing, private, or cannot be
+	// encoded as JSON fail with ErrInvalidArgument.
+	GetAttr(name string, dst interface{}) error
+
+	// CallMethod calls a method of the environment on the
+	// server and decodes its JSON result into dst.
+	// If dst is nil, the result is discarded.
+	//
+	// The arguments are encoded as JSON.
+	// Arguments which encode to arrays of numbers, such as
+	// []float64, are passed to the method as numpy arrays.
+	CallMethod(name string, dst interface{}, args ...interface{}) error
+
+	// Stats returns cumulative statistics about the
+	// environment's steps and traffic.
+	// It is cheap, and never contacts the server.
+	Stats() *EnvStats
+}
+
+// A Resetter is an environment which can be reset.
+type Resetter interface {
 	// Reset resets the environment.
 	Reset() (obs Obs, err error)
+}
 
+// A Stepper is an environment which can take actions.
+type Stepper interface {
 	// Step takes an action.
+	//
+	// If the environment raises an exception while
+	// stepping or resetting, the error matches ErrEnvFailed
+	// and the Env remains usable, e.g. to try a Reset.
 	Step(action interface{}) (obs Obs, reward float64,
 		done bool, info interface{}, err error)
+}
 
+// A SpaceProvider describes and samples an environment's
+// spaces.
+type SpaceProvider interface {
 	// ActionSpace gets the action space.
+	//
+	// Environments created by Make cache their spaces, so
+	// querying them repeatedly is cheap.
+	// The cache is cleared by commands which may change the
+	// spaces: Configure, UniverseConfigure, UniverseWrap,
+	// RetroConfigure, and RetroWrap.
 	ActionSpace() (*Space, error)
 
 	// ObservationSpace gets the observation space.
@@ -33,7 +171,11 @@ type Env interface {
 	// The action is written to dst in the same way
 	// that Obs.Unmarshal() does it.
 	SampleAction(dst interface{}) error
+}
 
+// Monitored is an environment which can be monitored and
+// rendered.
+type Monitored interface {
 	// Monitor sets the environment up to save results
 	// to the given directory.
 	//
@@ -43,10 +185,11 @@ type Env interface {
 
 	// Render graphically renders the environment.
 	Render() error
+}
 
-	// Close stops and cleans up the environment.
-	Close() error
-
+// A UniverseEnv is an environment which supports the
+// Universe commands.
+type UniverseEnv interface {
 	// UniverseConfigure configures a Universe environment.
 	//
 	// The options argument may be nil.
@@ -56,7 +199,11 @@ type Env interface {
 	//
 	// The options argument may be nil.
 	UniverseWrap(wrapper string, options map[string]interface{}) error
+}
 
+// A RetroEnv is an environment which supports the Retro
+// commands.
+type RetroEnv interface {
 	// RetroConfigure configures a Retro environment.
 	//
 	// The options argument may be nil.
@@ -66,80 +213,213 @@ type Env interface {
 	//
 	// The options argument may be nil.
 	RetroWrap(wrapper string, options map[string]interface{}) error
+
+	// RetroSaveState saves the emulator state of a Retro
+	// game.
+	//
+	// Unlike CloneState, the state is in the gzipped format
+	// of Retro's .state files, so it can be saved to disk
+	// and used as a start state.
+	RetroSaveState() ([]byte, error)
+
+	// RetroLoadState loads an emulator state into a Retro
+	// game, either from RetroSaveState or from a .state
+	// file.
+	//
+	// The next observation comes from the following step.
+	RetroLoadState(state []byte) error
+
+	// RetroVariables gets the current values of the
+	// variables in a Retro game's data.json, such as its
+	// score and lives.
+	RetroVariables() (map[string]int, error)
+
+	// RetroReadRAM reads size bytes of a Retro game's RAM,
+	// starting at offset.
+	RetroReadRAM(offset, size int) ([]byte, error)
+
+	// RetroStartMovie starts recording a .bk2 movie of a
+	// Retro game, which can be played back with Retro's own
+	// tools.
+	//
+	// The path is on the server's filesystem.
+	// If it is empty, the server picks a temporary file.
+	// The path of the movie is returned.
+	RetroStartMovie(path string) (string, error)
+
+	// RetroStopMovie stops recording a movie and returns
+	// its path on the server.
+	RetroStopMovie() (string, error)
 }
 
 type connEnv struct {
-	Buf  *bufio.ReadWriter
-	Conn net.Conn
+	*envConn
+
+	// ID is the index of the environment on a multiplexed
+	// connection, or -1 if the connection is not shared.
+	ID int
+
+	// DecodeObs decodes byte list observations, or is nil
+	// to use the default decoder.
+	DecodeObs byteListDecoder
+
+	// stats is updated by every command, or is nil.
+	stats *envStats
 
-	CmdLock sync.Mutex
+	// spec and spaces cache the results of Describe and
+	// getSpace until a command which may change the spaces,
+	// such as RetroWrap.
+	// The spaces are indexed by space ID.
+	cacheLock sync.Mutex
+	spec      *EnvSpec
+	spaces    [2]*Space
+	cacheGen  int
+
+	// features is the set of features that the server
+	// supports, or nil if it is not known yet.
+	features map[string]bool
+
+	closeOnce sync.Once
 }
 
 // Make creates an Env by connecting to an API server and
 // requesting the given environment.
-func Make(host, envName string) (env Env, err error) {
+//
+// The host is usually a "host:port" address, but a URL
+// scheme may pick a different transport, so that one
+// connection string can describe any server:
+//
+//	tcp://host:port
+//	    A plain TCP connection, like "host:port".
+//
+//	tls://host:port
+//	    TLS with the system's root certificates.
+//	    For other TLS settings, use WithTLS with a plain
+//	    address instead.
+//
+//	unix:///path/to/socket
+//	    A Unix domain socket, as served with the
+//	    server's --unix flag.
+//
+//	ws://host:port/path, wss://host:port/path
+//	    A WebSocket, as served by gym-proxy with its
+//	    -websocket flag.
+//
+// The host may also be a service name, as described in
+// ResolveHost.
+// Every transport dials with the WithDialer function, if
+// there is one.
+//
+// Under GOOS=js, such as in a web browser, WebSockets are
+// opened with the host's WebSocket API instead, and the
+// other transports need a WithDialer function, since
+// WebAssembly cannot open raw sockets.
+func Make(host, envName string, opts ...Option) (env Env, err error) {
 	defer essentials.AddCtxTo("make environment", &err)
-	conn, err := net.Dial("tcp", host)
+	config := makeOptions(opts)
+	conn, err := dialEnvConn(host, &handshakeRequest{
+		EnvName: envName,
+		Options: config,
+	})
 	if err != nil {
 		return nil, err
 	}
+	return &connEnv{
+		envConn:   conn,
+		ID:        -1,
+		DecodeObs: config.obsDecoder(0),
+		stats:     config.envStats(),
+	}, nil
+}
 
-	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
-	if err := handshake(rw, envName); err != nil {
-		conn.Close()
+// MakeN creates n instances of an environment which share
+// a single connection to an API server.
+//
+// This is much cheaper than calling Make n times, since
+// the server only runs one process for the connection.
+// However, the server runs commands on the environments
+// one at a time, so stepping them from different
+// Goroutines will not run the environments in parallel.
+// It does overlap the commands' round trips, since they
+// do not wait for each other to be sent.
+//
+// The connection is closed once every environment has
+// been closed.
+func MakeN(host, envName string, n int, opts ...Option) (envs []Env, err error) {
+	defer essentials.AddCtxTo("make environments", &err)
+	if n < 1 {
+		return nil, errors.New("number of environments must be positive")
+	}
+	config := makeOptions(opts)
+	conn, err := dialEnvConn(host, &handshakeRequest{
+		EnvName: envName,
+		NumEnvs: n,
+		Options: config,
+	})
+	if err != nil {
 		return nil, err
 	}
+	conn.refs = n
+	for i := 0; i < n; i++ {
+		envs = append(envs, &connEnv{
+			envConn:   conn,
+			ID:        i,
+			DecodeObs: config.obsDecoder(i),
+			stats:     newEnvStats(),
+		})
+	}
+	return envs, nil
+}
 
-	return &connEnv{Buf: rw, Conn: conn}, nil
+func (c *connEnv) Reconnect() (err error) {
+	defer essentials.AddCtxTo("reconnect environment", &err)
+	return c.reconnect(-1)
 }
 
 func (c *connEnv) Reset() (obs Obs, err error) {
 	defer essentials.AddCtxTo("reset environment", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	if err := writePacketType(c.Buf, packetReset); err != nil {
-		return nil, err
-	}
-	if err := c.Buf.Flush(); err != nil {
-		return nil, err
-	}
-	return readObservation(c.Buf)
+	err = c.command("reset", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetReset)
+	}, func(r *bufio.Reader) error {
+		obs, err = readObservation(r, c.DecodeObs)
+		return err
+	})
+	return
 }
 
 func (c *connEnv) Step(action interface{}) (obs Obs, reward float64,
 	done bool, info interface{}, err error) {
 	defer essentials.AddCtxTo("step environment", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	err = writePacketType(c.Buf, packetStep)
-	if err != nil {
-		return
-	}
-	err = writeAction(c.Buf, action)
-	if err != nil {
-		return
-	}
-	err = c.Buf.Flush()
-	if err != nil {
-		return
-	}
-	obs, err = readObservation(c.Buf)
-	if err != nil {
-		return
-	}
-	reward, err = readReward(c.Buf)
-	if err != nil {
-		return
-	}
-	done, err = readBool(c.Buf)
-	if err != nil {
+	err = c.command("step", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetStep); err != nil {
+			return err
+		}
+		return writeAction(w, action, c.BinaryActions)
+	}, func(r *bufio.Reader) (err error) {
+		obs, reward, done, info, err = readStepResult(r, c.DecodeObs, c.InfoMode)
 		return
+	})
+	if err == nil && done {
+		c.stats.Episode()
 	}
-	infoData, err := readByteField(c.Buf)
-	if err != nil {
+	return
+}
+
+func (c *connEnv) StepBlind(action interface{}) (obs Obs, reward float64,
+	done bool, info interface{}, err error) {
+	defer essentials.AddCtxTo("step environment", &err)
+	err = c.command("step_blind", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetStepBlind); err != nil {
+			return err
+		}
+		return writeAction(w, action, c.BinaryActions)
+	}, func(r *bufio.Reader) (err error) {
+		obs, reward, done, info, err = readBlindStepResult(r, c.DecodeObs, c.InfoMode)
 		return
+	})
+	if err == nil && done {
+		c.stats.Episode()
 	}
-	err = json.Unmarshal(infoData, &info)
 	return
 }
 
@@ -153,180 +433,413 @@ func (c *connEnv) ObservationSpace() (*Space, error) {
 
 func (c *connEnv) SampleAction(dst interface{}) (err error) {
 	essentials.AddCtxTo("sample action", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	if err := writePacketType(c.Buf, packetSampleAction); err != nil {
-		return err
-	}
-	if err := c.Buf.Flush(); err != nil {
-		return err
-	}
-	return readAction(c.Buf, dst)
+	return c.command("sample_action", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetSampleAction)
+	}, func(r *bufio.Reader) error {
+		return readAction(r, dst)
+	})
 }
 
 func (c *connEnv) Monitor(dir string, force, resume, video bool) (err error) {
 	essentials.AddCtxTo("monitor environment", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	if err := writePacketType(c.Buf, packetMonitor); err != nil {
-		return err
-	}
-	for _, b := range []bool{resume, force, video} {
-		if err := writeBool(c.Buf, b); err != nil {
+	return c.command("monitor", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetMonitor); err != nil {
 			return err
 		}
-	}
-	absDir, err := filepath.Abs(dir)
-	if err != nil {
-		return err
-	}
-	if err := writeByteField(c.Buf, []byte(absDir)); err != nil {
-		return err
-	}
-	if err := c.Buf.Flush(); err != nil {
-		return err
-	}
-	if errData, err := readByteField(c.Buf); err != nil {
-		return err
-	} else if len(errData) > 0 {
-		return errors.New(string(errData))
-	}
-	return nil
+		for _, b := range []bool{resume, force, video} {
+			if err := writeBool(w, b); err != nil {
+				return err
+			}
+		}
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		return writeByteField(w, []byte(absDir))
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
 }
 
 func (c *connEnv) Render() (err error) {
 	essentials.AddCtxTo("render environment", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	if err := writePacketType(c.Buf, packetRender); err != nil {
-		return err
+	return c.command("render", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetRender)
+	}, nil)
+}
+
+func (c *connEnv) SetLogLevel(level string) (err error) {
+	defer essentials.AddCtxTo("set log level", &err)
+	return c.command("set_log_level", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetSetLogLevel); err != nil {
+			return err
+		}
+		return writeByteField(w, []byte(level))
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
+}
+
+func (c *connEnv) KeepAlive() (err error) {
+	defer essentials.AddCtxTo("keep environment alive", &err)
+	return c.command("keep_alive", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetKeepAlive)
+	}, nil)
+}
+
+func (c *connEnv) Renew() (err error) {
+	if c.Lease == 0 {
+		return nil
 	}
-	return c.Buf.Flush()
+	defer essentials.AddCtxTo("renew lease", &err)
+	return c.command("renew_lease", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetRenewLease); err != nil {
+			return err
+		}
+		return writeFloat64(w, c.Lease.Seconds())
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
+}
+
+func (c *connEnv) Close() (err error) {
+	c.closeOnce.Do(func() {
+		err = c.release()
+	})
+	return
 }
 
-func (c *connEnv) Close() error {
-	return c.Conn.Close()
+func (c *connEnv) Configure(options map[string]interface{}) (err error) {
+	defer c.invalidateSpaces()
+	if options == nil {
+		options = map[string]interface{}{}
+	}
+	defer essentials.AddCtxTo("configure environment", &err)
+	return c.command("configure", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetConfigure); err != nil {
+			return err
+		}
+		jsonData, err := json.Marshal(options)
+		if err != nil {
+			return err
+		}
+		return writeByteField(w, jsonData)
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
 }
 
 func (c *connEnv) UniverseConfigure(options map[string]interface{}) (err error) {
+	defer c.invalidateSpaces()
 	if options == nil {
 		options = map[string]interface{}{}
 	}
 	essentials.AddCtxTo("configure Universe environment", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	if err := writePacketType(c.Buf, packetUniverseConfigure); err != nil {
-		return err
-	}
-	jsonData, err := json.Marshal(options)
-	if err != nil {
-		return err
-	}
-	if err := writeByteField(c.Buf, jsonData); err != nil {
-		return err
-	}
-	if err := c.Buf.Flush(); err != nil {
-		return err
-	}
-	return readErrorField(c.Buf)
+	return c.command("universe_configure", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetUniverseConfigure); err != nil {
+			return err
+		}
+		jsonData, err := json.Marshal(options)
+		if err != nil {
+			return err
+		}
+		return writeByteField(w, jsonData)
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
 }
 
 func (c *connEnv) UniverseWrap(wrapper string,
 	options map[string]interface{}) (err error) {
+	defer c.invalidateSpaces()
 	if options == nil {
 		options = map[string]interface{}{}
 	}
 	essentials.AddCtxTo("wrap Universe environment", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	if err := writePacketType(c.Buf, packetUniverseWrap); err != nil {
-		return err
-	}
-	if err := writeByteField(c.Buf, []byte(wrapper)); err != nil {
-		return err
-	}
-	jsonData, err := json.Marshal(options)
-	if err != nil {
-		return err
-	}
-	if err := writeByteField(c.Buf, jsonData); err != nil {
-		return err
-	}
-	if err := c.Buf.Flush(); err != nil {
-		return err
-	}
-	return readErrorField(c.Buf)
+	return c.command("universe_wrap", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetUniverseWrap); err != nil {
+			return err
+		}
+		if err := writeByteField(w, []byte(wrapper)); err != nil {
+			return err
+		}
+		jsonData, err := json.Marshal(options)
+		if err != nil {
+			return err
+		}
+		return writeByteField(w, jsonData)
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
 }
 
 func (c *connEnv) RetroConfigure(options map[string]interface{}) (err error) {
+	defer c.invalidateSpaces()
 	if options == nil {
 		options = map[string]interface{}{}
 	}
 	essentials.AddCtxTo("configure Retro environment", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	if err := writePacketType(c.Buf, packetRetroConfigure); err != nil {
-		return err
-	}
-	jsonData, err := json.Marshal(options)
-	if err != nil {
-		return err
-	}
-	if err := writeByteField(c.Buf, jsonData); err != nil {
-		return err
-	}
-	if err := c.Buf.Flush(); err != nil {
-		return err
-	}
-	return readErrorField(c.Buf)
+	return c.command("retro_configure", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetRetroConfigure); err != nil {
+			return err
+		}
+		jsonData, err := json.Marshal(options)
+		if err != nil {
+			return err
+		}
+		return writeByteField(w, jsonData)
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
 }
 
 func (c *connEnv) RetroWrap(wrapper string,
 	options map[string]interface{}) (err error) {
+	defer c.invalidateSpaces()
 	if options == nil {
 		options = map[string]interface{}{}
 	}
 	essentials.AddCtxTo("wrap Retro environment", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	if err := writePacketType(c.Buf, packetRetroWrap); err != nil {
+	return c.command("retro_wrap", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetRetroWrap); err != nil {
+			return err
+		}
+		if err := writeByteField(w, []byte(wrapper)); err != nil {
+			return err
+		}
+		jsonData, err := json.Marshal(options)
+		if err != nil {
+			return err
+		}
+		return writeByteField(w, jsonData)
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
+}
+
+func (c *connEnv) RetroSaveState() (state []byte, err error) {
+	defer essentials.AddCtxTo("save Retro state", &err)
+	err = c.command("retro_save_state", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetRetroSaveState)
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		state, err = readByteField(r)
 		return err
+	})
+	return
+}
+
+func (c *connEnv) RetroLoadState(state []byte) (err error) {
+	defer essentials.AddCtxTo("load Retro state", &err)
+	return c.command("retro_load_state", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetRetroLoadState); err != nil {
+			return err
+		}
+		return writeByteField(w, state)
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
+}
+
+func (c *connEnv) RetroVariables() (variables map[string]int, err error) {
+	defer essentials.AddCtxTo("get Retro variables", &err)
+	err = c.command("retro_variables", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetRetroVariables)
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		return readJSONResult(r, &variables)
+	})
+	return
+}
+
+func (c *connEnv) RetroReadRAM(offset, size int) (data []byte, err error) {
+	defer essentials.AddCtxTo("read Retro RAM", &err)
+	if offset < 0 || size < 0 {
+		return nil, fmt.Errorf("invalid RAM range: offset %d, size %d", offset, size)
 	}
-	if err := writeByteField(c.Buf, []byte(wrapper)); err != nil {
+	err = c.command("retro_read_ram", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetRetroReadRAM); err != nil {
+			return err
+		}
+		if err := writeUint32(w, uint32(offset)); err != nil {
+			return err
+		}
+		return writeUint32(w, uint32(size))
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		data, err = readByteField(r)
 		return err
-	}
-	jsonData, err := json.Marshal(options)
-	if err != nil {
+	})
+	return
+}
+
+func (c *connEnv) RetroStartMovie(path string) (moviePath string, err error) {
+	defer essentials.AddCtxTo("start Retro movie", &err)
+	err = c.command("retro_start_movie", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetRetroStartMovie); err != nil {
+			return err
+		}
+		return writeByteField(w, []byte(path))
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		data, err := readByteField(r)
+		moviePath = string(data)
 		return err
-	}
-	if err := writeByteField(c.Buf, jsonData); err != nil {
+	})
+	return
+}
+
+func (c *connEnv) RetroStopMovie() (moviePath string, err error) {
+	defer essentials.AddCtxTo("stop Retro movie", &err)
+	err = c.command("retro_stop_movie", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetRetroStopMovie)
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		data, err := readByteField(r)
+		moviePath = string(data)
 		return err
+	})
+	return
+}
+
+func (c *connEnv) CloneState() (state []byte, err error) {
+	defer essentials.AddCtxTo("clone environment state", &err)
+	err = c.command("clone_state", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetCloneState)
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		state, err = readByteField(r)
+		return err
+	})
+	return
+}
+
+func (c *connEnv) RestoreState(state []byte) (err error) {
+	defer essentials.AddCtxTo("restore environment state", &err)
+	return c.command("restore_state", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetRestoreState); err != nil {
+			return err
+		}
+		return writeByteField(w, state)
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
+}
+
+func (c *connEnv) GetAttr(name string, dst interface{}) (err error) {
+	defer essentials.AddCtxTo("get environment attribute "+name, &err)
+	return c.command("get_attr", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetGetAttr); err != nil {
+			return err
+		}
+		return writeByteField(w, []byte(name))
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		return readJSONResult(r, dst)
+	})
+}
+
+func (c *connEnv) CallMethod(name string, dst interface{},
+	args ...interface{}) (err error) {
+	defer essentials.AddCtxTo("call environment method "+name, &err)
+	if args == nil {
+		args = []interface{}{}
 	}
-	if err := c.Buf.Flush(); err != nil {
+	argsData, err := json.Marshal(args)
+	if err != nil {
 		return err
 	}
-	return readErrorField(c.Buf)
+	return c.command("call_method", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetCallMethod); err != nil {
+			return err
+		}
+		if err := writeByteField(w, []byte(name)); err != nil {
+			return err
+		}
+		return writeByteField(w, argsData)
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		return readJSONResult(r, dst)
+	})
+}
+
+// readJSONResult reads a JSON field and decodes it into
+// dst, or skips it if dst is nil.
+func readJSONResult(r io.Reader, dst interface{}) error {
+	return readTempField(r, func(data []byte) error {
+		if dst == nil {
+			return nil
+		}
+		return json.Unmarshal(data, dst)
+	})
 }
 
 func (c *connEnv) getSpace(spaceID int) (space *Space, err error) {
-	essentials.AddCtxTo("get space info", &err)
-	c.CmdLock.Lock()
-	defer c.CmdLock.Unlock()
-	if err := writePacketType(c.Buf, packetGetSpace); err != nil {
-		return nil, err
-	}
-	if err := writeSpaceType(c.Buf, spaceID); err != nil {
-		return nil, err
-	}
-	if err := c.Buf.Flush(); err != nil {
-		return nil, err
+	c.cacheLock.Lock()
+	cached, gen := c.spaces[spaceID], c.cacheGen
+	c.cacheLock.Unlock()
+	if cached != nil {
+		return cached.copy(), nil
 	}
-	data, err := readByteField(c.Buf)
+	essentials.AddCtxTo("get space info", &err)
+	err = c.command("get_space", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetGetSpace); err != nil {
+			return err
+		}
+		return writeSpaceType(w, spaceID)
+	}, func(r *bufio.Reader) error {
+		return readTempField(r, func(data []byte) (err error) {
+			space, err = decodeSpace(data)
+			return
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal(data, &space); err != nil {
-		return nil, err
+	c.cacheLock.Lock()
+	if c.cacheGen == gen {
+		c.spaces[spaceID] = space.copy()
 	}
+	c.cacheLock.Unlock()
 	return
 }
+
+func (c *connEnv) Stats() *EnvStats {
+	return c.stats.Snapshot()
+}
+
+// command runs a command with envConn.command, and records
+// it in the environment's stats.
+func (c *connEnv) command(op string, write func(w *bufio.Writer) error,
+	read func(r *bufio.Reader) error) error {
+	start := time.Now()
+	sent, received, err := c.commandTraffic(op, write, read)
+	c.stats.Command(op, time.Since(start), sent, received, err)
+	return err
+}
+
+// writeHeader starts a command packet for the environment.
+func (c *connEnv) writeHeader(w io.Writer, packetType int) error {
+	if c.ID >= 0 {
+		if err := writeUint32(w, uint32(c.ID)); err != nil {
+			return err
+		}
+	}
+	return writePacketType(w, packetType)
+}
@@ -0,0 +1,129 @@
+package gym
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Accept the handshake, then stall forever.
+		conn.Write([]byte{0, 0, 0, 0})
+		buf := make([]byte, 1024)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	env, err := Make(listener.Addr().String(), "CartPole-v0",
+		WithTimeout(time.Millisecond*50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	// A request which cannot be encoded is never sent, so
+	// the connection remains usable.
+	if _, _, _, _, err := env.Step(make(chan int)); err == nil {
+		t.Fatal("expected an encoding error")
+	}
+
+	_, err = env.Reset()
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected timeout but got: %v", err)
+	}
+	if _, err := env.Reset(); !errors.Is(err, ErrConnBroken) {
+		t.Fatalf("expected ErrConnBroken but got: %v", err)
+	}
+}
+
+func TestSocketConfig(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte{0, 0, 0, 0})
+		buf := make([]byte, 1024)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	env, err := Make(listener.Addr().String(), "CartPole-v0",
+		WithSocketConfig(&SocketConfig{
+			DelayWrites:    true,
+			SendBuffer:     1 << 16,
+			RecvBuffer:     1 << 16,
+			ReadBufferSize: 1 << 20,
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	conn := env.(*connEnv).envConn
+	if size := conn.Buf.Reader.Size(); size != 1<<20 {
+		t.Errorf("expected read buffer size %d but got %d", 1<<20, size)
+	}
+	if size := conn.Buf.Writer.Size(); size != defaultBufferSize {
+		t.Errorf("expected write buffer size %d but got %d", defaultBufferSize, size)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveEchoSteps(listener)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+	env, err := Make(listener.Addr().String(), "CartPole-v0", WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, _, err := env.Step(1); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	output := buf.String()
+	for _, expected := range []string{
+		"msg=handshake", "env=CartPole-v0",
+		"msg=command", "op=step",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("missing %q in logs:\n%s", expected, output)
+		}
+	}
+}
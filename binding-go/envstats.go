@@ -0,0 +1,128 @@
+package gym
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// statsLatencyWindow is the number of recent steps used
+// for the latency percentiles of EnvStats.
+const statsLatencyWindow = 1024
+
+// EnvStats are cumulative statistics which the client
+// keeps about an environment.
+type EnvStats struct {
+	// Steps counts the steps taken, including failed ones.
+	// Each call to StepMulti counts as one step.
+	Steps int64
+
+	// Episodes counts the steps which finished an episode.
+	// Multi-agent steps from StepMulti are not counted,
+	// since their agents may finish at different times.
+	Episodes int64
+
+	// BytesSent and BytesReceived count the traffic of all
+	// of the environment's commands.
+	// Traffic which is shared by the environments of a
+	// multiplexed connection, such as the handshake, is
+	// not included.
+	BytesSent     int64
+	BytesReceived int64
+
+	// MeanStepLatency is the average round-trip time of
+	// all the steps.
+	MeanStepLatency time.Duration
+
+	// StepLatencyP50, StepLatencyP90, and StepLatencyP99
+	// are percentiles of the round-trip times of the
+	// latest 1024 steps.
+	StepLatencyP50 time.Duration
+	StepLatencyP90 time.Duration
+	StepLatencyP99 time.Duration
+
+	// LastError is the error from the latest failed
+	// command, or nil if no command has failed.
+	LastError     error
+	LastErrorTime time.Time
+}
+
+// envStats maintains EnvStats.
+//
+// A nil *envStats ignores everything.
+type envStats struct {
+	lock  sync.Mutex
+	stats EnvStats
+
+	totalLatency time.Duration
+	latencies    []time.Duration
+	nextLatency  int
+}
+
+func newEnvStats() *envStats {
+	return &envStats{latencies: make([]time.Duration, 0, statsLatencyWindow)}
+}
+
+// Command records a finished command.
+func (e *envStats) Command(op string, latency time.Duration, sent, received int,
+	err error) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.stats.BytesSent += int64(sent)
+	e.stats.BytesReceived += int64(received)
+	if err != nil {
+		e.stats.LastError = err
+		e.stats.LastErrorTime = time.Now()
+	}
+	if op != "step" && op != "step_blind" && op != "step_multi" {
+		return
+	}
+	e.stats.Steps++
+	e.totalLatency += latency
+	if len(e.latencies) < statsLatencyWindow {
+		e.latencies = append(e.latencies, latency)
+	} else {
+		e.latencies[e.nextLatency] = latency
+		e.nextLatency = (e.nextLatency + 1) % statsLatencyWindow
+	}
+}
+
+// Episode records the end of an episode.
+func (e *envStats) Episode() {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	e.stats.Episodes++
+	e.lock.Unlock()
+}
+
+// Snapshot computes the current EnvStats.
+func (e *envStats) Snapshot() *EnvStats {
+	if e == nil {
+		return &EnvStats{}
+	}
+	e.lock.Lock()
+	res := e.stats
+	latencies := append([]time.Duration{}, e.latencies...)
+	if res.Steps > 0 {
+		res.MeanStepLatency = e.totalLatency / time.Duration(res.Steps)
+	}
+	e.lock.Unlock()
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool {
+			return latencies[i] < latencies[j]
+		})
+		percentile := func(p int) time.Duration {
+			return latencies[(len(latencies)-1)*p/100]
+		}
+		res.StepLatencyP50 = percentile(50)
+		res.StepLatencyP90 = percentile(90)
+		res.StepLatencyP99 = percentile(99)
+	}
+	return &res
+}
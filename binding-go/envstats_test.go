@@ -0,0 +1,70 @@
+package gym
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEnvStats(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveEchoSteps(listener)
+
+	env, err := Make(listener.Addr().String(), "CartPole-v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	for i := 0; i < 10; i++ {
+		if _, _, _, _, err := env.Step(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := env.Stats()
+	if stats.Steps != 10 || stats.Episodes != 0 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.BytesSent == 0 || stats.BytesReceived == 0 {
+		t.Errorf("unexpected traffic: %+v", stats)
+	}
+	if stats.MeanStepLatency <= 0 || stats.StepLatencyP99 < stats.StepLatencyP50 {
+		t.Errorf("unexpected latencies: %+v", stats)
+	}
+	if stats.LastError != nil {
+		t.Errorf("unexpected error: %v", stats.LastError)
+	}
+}
+
+func TestEnvStatsSnapshot(t *testing.T) {
+	stats := newEnvStats()
+	for i := 1; i <= statsLatencyWindow+100; i++ {
+		stats.Command("step", time.Duration(i), 1, 2, nil)
+	}
+	stats.Command("reset", time.Hour, 1, 2, errors.New("oops"))
+	stats.Episode()
+
+	res := stats.Snapshot()
+	n := int64(statsLatencyWindow + 100)
+	if res.Steps != n || res.Episodes != 1 {
+		t.Errorf("unexpected counts: %+v", res)
+	}
+	if res.BytesSent != n+1 || res.BytesReceived != 2*(n+1) {
+		t.Errorf("unexpected traffic: %+v", res)
+	}
+	if res.MeanStepLatency != time.Duration((n+1)/2) {
+		t.Errorf("unexpected mean latency: %v", res.MeanStepLatency)
+	}
+	// Only the latest window of steps is used for the
+	// percentiles.
+	if res.StepLatencyP50 != 101+(statsLatencyWindow-1)/2 {
+		t.Errorf("unexpected p50: %v", res.StepLatencyP50)
+	}
+	if res.LastError == nil || res.LastError.Error() != "oops" {
+		t.Errorf("unexpected last error: %v", res.LastError)
+	}
+}
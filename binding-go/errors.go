@@ -0,0 +1,138 @@
+package gym
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Error codes which the server attaches to errors.
+const (
+	CodeUnknownEnv      = "unknown_env"
+	CodeMakeFailed      = "make_failed"
+	CodeUnsupported     = "unsupported"
+	CodeUnknownSession  = "unknown_session"
+	CodeInvalidArgument = "invalid_argument"
+	CodeMonitorFailed   = "monitor_failed"
+	CodeUploadFailed    = "upload_failed"
+	CodeConfigureFailed = "configure_failed"
+	CodeUniverseFailed  = "universe_failed"
+	CodeRetroFailed     = "retro_failed"
+	CodeEnvFailed       = "env_failed"
+	CodeUnauthorized    = "unauthorized"
+)
+
+// Sentinel errors which match an EnvError with the
+// corresponding code, via errors.Is.
+//
+// For example, the following checks if Make failed because
+// the environment is not registered on the server:
+//
+//	if errors.Is(err, gym.ErrUnknownEnv) {
+//	    ...
+//	}
+var (
+	ErrUnknownEnv      = errors.New("unknown environment")
+	ErrMakeFailed      = errors.New("failed to make environment")
+	ErrUnsupported     = errors.New("unsupported by server")
+	ErrUnknownSession  = errors.New("unknown session")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrMonitorFailed   = errors.New("monitor failed")
+	ErrUploadFailed    = errors.New("upload failed")
+	ErrConfigureFailed = errors.New("configure failed")
+	ErrUniverseFailed  = errors.New("Universe command failed")
+	ErrRetroFailed     = errors.New("Retro command failed")
+	ErrEnvFailed       = errors.New("environment raised an exception")
+	ErrUnauthorized    = errors.New("unauthorized")
+)
+
+// ErrConnBroken is matched (via errors.Is) by the errors
+// of commands on a connection which an earlier failure
+// left in an unknown state, such as a timeout in the
+// middle of a response.
+//
+// A broken connection cannot be used again, unless the
+// environment is Resumable and can Reconnect.
+var ErrConnBroken = errors.New("connection is broken")
+
+type brokenConnError struct {
+	Cause error
+}
+
+func (b *brokenConnError) Error() string {
+	return "connection is broken (" + b.Cause.Error() + ")"
+}
+
+func (b *brokenConnError) Is(target error) bool {
+	return target == ErrConnBroken
+}
+
+var codeErrors = map[string]error{
+	CodeUnknownEnv:      ErrUnknownEnv,
+	CodeMakeFailed:      ErrMakeFailed,
+	CodeUnsupported:     ErrUnsupported,
+	CodeUnknownSession:  ErrUnknownSession,
+	CodeInvalidArgument: ErrInvalidArgument,
+	CodeMonitorFailed:   ErrMonitorFailed,
+	CodeUploadFailed:    ErrUploadFailed,
+	CodeConfigureFailed: ErrConfigureFailed,
+	CodeUniverseFailed:  ErrUniverseFailed,
+	CodeRetroFailed:     ErrRetroFailed,
+	CodeEnvFailed:       ErrEnvFailed,
+	CodeUnauthorized:    ErrUnauthorized,
+}
+
+// An EnvError is an error reported by the server.
+//
+// Use errors.As to get an EnvError from an error returned
+// by this package.
+type EnvError struct {
+	// Code is one of the Code constants, or "" if the
+	// server did not send a code.
+	Code string `json:"code"`
+
+	// Message is the human-readable error message.
+	Message string `json:"message"`
+
+	// Type is the qualified name of the Python exception
+	// which caused the error, such as "gym.error.Error",
+	// or "" if there was no exception.
+	Type string `json:"type"`
+
+	traceback string
+}
+
+// Error returns the error message.
+func (e *EnvError) Error() string {
+	return e.Message
+}
+
+// Traceback returns the formatted Python traceback of the
+// exception which caused the error, or "" if there was no
+// exception.
+func (e *EnvError) Traceback() string {
+	return e.traceback
+}
+
+// Is checks if target is the sentinel error for the
+// error's code.
+func (e *EnvError) Is(target error) bool {
+	sentinel, ok := codeErrors[e.Code]
+	return ok && sentinel == target
+}
+
+// decodeEnvError decodes a non-empty error field.
+//
+// Plain-text errors from older servers are supported, and
+// have no code.
+func decodeEnvError(data []byte) *EnvError {
+	var obj struct {
+		EnvError
+		Traceback string `json:"traceback"`
+	}
+	if data[0] != '{' || json.Unmarshal(data, &obj) != nil || obj.Message == "" {
+		return &EnvError{Message: string(data)}
+	}
+	res := obj.EnvError
+	res.traceback = obj.Traceback
+	return &res
+}
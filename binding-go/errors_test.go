@@ -0,0 +1,33 @@
+package gym
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/unixpickle/essentials"
+)
+
+func TestDecodeEnvError(t *testing.T) {
+	err := essentials.AddCtx("make environment",
+		decodeEnvError([]byte(`{"code": "unknown_env", "message": "no such env", `+
+			`"type": "gym.error.UnregisteredEnv", "traceback": "Traceback..."}`)))
+	if !errors.Is(err, ErrUnknownEnv) {
+		t.Error("expected ErrUnknownEnv")
+	}
+	if errors.Is(err, ErrMakeFailed) {
+		t.Error("unexpected ErrMakeFailed")
+	}
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatal("expected an EnvError")
+	}
+	if envErr.Code != CodeUnknownEnv || envErr.Message != "no such env" ||
+		envErr.Type != "gym.error.UnregisteredEnv" || envErr.Traceback() != "Traceback..." {
+		t.Errorf("unexpected error: %#v", envErr)
+	}
+
+	plain := decodeEnvError([]byte("something broke"))
+	if plain.Code != "" || plain.Message != "something broke" || plain.Traceback() != "" {
+		t.Errorf("unexpected error: %#v", plain)
+	}
+}
@@ -0,0 +1,81 @@
+package gym
+
+import "github.com/unixpickle/essentials"
+
+// Optional features which a server may support, as
+// reported in ServerStatus.Features.
+const (
+	FeatureBatch          = "batch"
+	FeatureMultiplex      = "multiplex"
+	FeatureSession        = "session"
+	FeatureBinaryActions  = "binary_actions"
+	FeatureStepBlind      = "step_blind"
+	FeatureDescribe       = "describe"
+	FeatureCloneState     = "clone_state"
+	FeatureGetAttr        = "get_attr"
+	FeatureCallMethod     = "call_method"
+	FeatureObservationROI = "observation_roi"
+	FeatureStepMulti      = "step_multi"
+	FeatureRenderText     = "render_text"
+	FeatureScreenshot     = "screenshot"
+	FeatureLease          = "lease"
+	FeatureUniverse       = "universe"
+	FeatureRetro          = "retro"
+	FeatureEnvPool        = "envpool"
+	FeatureSB3            = "sb3"
+	FeatureDMControl      = "dm_control"
+	FeatureUnity          = "unity"
+	FeaturePettingZoo     = "pettingzoo"
+)
+
+// A FeatureSupporter is an Env which can tell if its
+// server supports an optional feature.
+//
+// Environments created by Make and Client implement
+// FeatureSupporter.
+type FeatureSupporter interface {
+	// Supports checks if the server supports a feature,
+	// such as FeatureStepBlind.
+	//
+	// Servers which predate feature reporting support no
+	// features, so code can use Supports to fall back on
+	// older commands.
+	Supports(feature string) (bool, error)
+}
+
+// Supports checks if the server behind an Env supports a
+// feature, such as FeatureStepBlind.
+//
+// Wrappers are unwrapped to find the original Env.
+// If it is not a FeatureSupporter, no features are
+// supported.
+func Supports(env Env, feature string) (bool, error) {
+	if f, ok := Innermost(env).(FeatureSupporter); ok {
+		return f.Supports(feature)
+	}
+	return false, nil
+}
+
+// Supports checks if the server supports a feature.
+//
+// The features are found with a Ping the first time, and
+// cached afterwards.
+func (c *connEnv) Supports(feature string) (bool, error) {
+	c.cacheLock.Lock()
+	features := c.features
+	c.cacheLock.Unlock()
+	if features == nil {
+		res, err := c.Ping()
+		if err != nil {
+			return false, essentials.AddCtx("check feature support", err)
+		}
+		features = map[string]bool{}
+		for _, name := range res.Status.Features {
+			features[name] = true
+		}
+		c.cacheLock.Lock()
+		c.features = features
+		c.cacheLock.Unlock()
+	}
+	return features[feature], nil
+}
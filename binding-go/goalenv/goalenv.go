@@ -0,0 +1,69 @@
+// Package goalenv supports goal-conditioned environments,
+// such as the Gym robotics tasks, whose observations hold
+// an achieved goal and a desired goal.
+//
+// ComputeReward asks the environment itself for the reward
+// of a goal, so Hindsight Experience Replay can relabel
+// transitions without reimplementing each reward function.
+package goalenv
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// Obs is an observation from a goal-conditioned
+// environment.
+type Obs struct {
+	Observation  []float64 `json:"observation"`
+	AchievedGoal []float64 `json:"achieved_goal"`
+	DesiredGoal  []float64 `json:"desired_goal"`
+}
+
+// DecodeObs decodes an observation from a
+// goal-conditioned environment.
+func DecodeObs(obs gym.Obs) (res *Obs, err error) {
+	defer essentials.AddCtxTo("decode goal observation", &err)
+	res = &Obs{}
+	if err := obs.Unmarshal(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ComputeReward computes the reward for reaching
+// achievedGoal when desiredGoal was the goal, by calling
+// the environment's compute_reward method.
+//
+// The info is the info from the step which reached
+// achievedGoal, and may be nil.
+func ComputeReward(env gym.Env, achievedGoal, desiredGoal []float64,
+	info interface{}) (reward float64, err error) {
+	defer essentials.AddCtxTo("compute reward", &err)
+	err = env.CallMethod("compute_reward", &reward, achievedGoal, desiredGoal, info)
+	return
+}
+
+// ComputeRewards is like ComputeReward, but computes the
+// rewards for many goals in one round trip.
+//
+// The environment's compute_reward method must support
+// batches of goals, as the Gym robotics tasks do.
+func ComputeRewards(env gym.Env, achievedGoals, desiredGoals [][]float64,
+	info interface{}) (rewards []float64, err error) {
+	defer essentials.AddCtxTo("compute rewards", &err)
+	if len(achievedGoals) != len(desiredGoals) {
+		return nil, fmt.Errorf("have %d achieved goals but %d desired goals",
+			len(achievedGoals), len(desiredGoals))
+	} else if len(achievedGoals) == 0 {
+		return []float64{}, nil
+	}
+	err = env.CallMethod("compute_reward", &rewards, achievedGoals, desiredGoals, info)
+	if err == nil && len(rewards) != len(achievedGoals) {
+		return nil, fmt.Errorf("expected %d rewards but got %d", len(achievedGoals),
+			len(rewards))
+	}
+	return
+}
@@ -0,0 +1,67 @@
+package goalenv
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// fakeEnv gives a reward of 0 for goals within 0.5 of the
+// desired goal, and -1 otherwise.
+type fakeEnv struct {
+	gym.Env
+}
+
+func (f fakeEnv) CallMethod(name string, dst interface{}, args ...interface{}) error {
+	if name != "compute_reward" || len(args) != 3 {
+		return gym.ErrInvalidArgument
+	}
+	reward := func(achieved, desired []float64) float64 {
+		var dist float64
+		for i, x := range achieved {
+			dist += math.Pow(x-desired[i], 2)
+		}
+		if math.Sqrt(dist) < 0.5 {
+			return 0
+		}
+		return -1
+	}
+	var result interface{}
+	switch achieved := args[0].(type) {
+	case []float64:
+		result = reward(achieved, args[1].([]float64))
+	case [][]float64:
+		var rewards []float64
+		for i, goal := range achieved {
+			rewards = append(rewards, reward(goal, args[1].([][]float64)[i]))
+		}
+		result = rewards
+	default:
+		return errors.New("unexpected goal type")
+	}
+	data, _ := json.Marshal(result)
+	return json.Unmarshal(data, dst)
+}
+
+func TestComputeReward(t *testing.T) {
+	reward, err := ComputeReward(fakeEnv{}, []float64{1, 1}, []float64{1, 1.2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if reward != 0 {
+		t.Errorf("expected reward 0 but got %f", reward)
+	}
+	rewards, err := ComputeRewards(fakeEnv{}, [][]float64{{1, 1}, {0, 0}},
+		[][]float64{{1, 1.2}, {1, 1}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(rewards, []float64{0, -1}) {
+		t.Errorf("unexpected rewards: %v", rewards)
+	}
+	if _, err := ComputeRewards(fakeEnv{}, [][]float64{{1, 1}}, nil, nil); err == nil {
+		t.Error("expected error for mismatched goals")
+	}
+}
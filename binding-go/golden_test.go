@@ -0,0 +1,152 @@
+package gym
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// goldenFixture is a fixture from testdata/golden, which
+// holds bytes encoded by the Python server and digests of
+// the values they should decode to.
+//
+// The fixtures are produced by testdata/golden/generate.py.
+type goldenFixture struct {
+	Env              string
+	ActionSpace      goldenSpace `json:"action_space"`
+	ObservationSpace goldenSpace `json:"observation_space"`
+	Reset            struct {
+		Response  []byte
+		ObsSHA256 string `json:"obs_sha256"`
+	}
+	Steps []struct {
+		Response  []byte
+		ObsSHA256 string `json:"obs_sha256"`
+		Reward    float64
+		Done      bool
+	}
+}
+
+type goldenSpace struct {
+	Response   []byte
+	LowSHA256  string `json:"low_sha256"`
+	HighSHA256 string `json:"high_sha256"`
+}
+
+func TestGoldenFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "golden", "*.json*"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(paths) == 0 {
+		t.Fatal("no fixtures")
+	}
+	for _, path := range paths {
+		fixture := loadGoldenFixture(t, path)
+		t.Run(fixture.Env, func(t *testing.T) {
+			checkGoldenSpace(t, "action space", fixture.ActionSpace)
+			checkGoldenSpace(t, "observation space", fixture.ObservationSpace)
+
+			r := bytes.NewReader(fixture.Reset.Response)
+			obs, err := readObservation(r, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGoldenEnd(t, "reset", r)
+			checkGoldenDigest(t, "reset observation", goldenObsValues(t, obs),
+				fixture.Reset.ObsSHA256)
+
+			for i, step := range fixture.Steps {
+				r := bytes.NewReader(step.Response)
+				obs, reward, done, _, err := readStepResult(r, nil, infoDecode)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkGoldenEnd(t, "step", r)
+				checkGoldenDigest(t, "step observation", goldenObsValues(t, obs),
+					step.ObsSHA256)
+				if math.Float64bits(reward) != math.Float64bits(step.Reward) ||
+					done != step.Done {
+					t.Errorf("step %d: expected reward %v and done %v but got %v and %v",
+						i, step.Reward, step.Done, reward, done)
+				}
+			}
+		})
+	}
+}
+
+func loadGoldenFixture(t *testing.T, path string) *goldenFixture {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+	var fixture goldenFixture
+	if err := json.NewDecoder(r).Decode(&fixture); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return &fixture
+}
+
+func checkGoldenSpace(t *testing.T, name string, fixture goldenSpace) {
+	t.Helper()
+	r := bytes.NewReader(fixture.Response)
+	data, err := readByteField(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGoldenEnd(t, name, r)
+	space, err := decodeSpace(data)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if fixture.LowSHA256 != "" {
+		checkGoldenDigest(t, name+" low", space.Low, fixture.LowSHA256)
+		checkGoldenDigest(t, name+" high", space.High, fixture.HighSHA256)
+	}
+}
+
+func checkGoldenEnd(t *testing.T, name string, r *bytes.Reader) {
+	t.Helper()
+	if r.Len() != 0 {
+		t.Errorf("%s: %d bytes left after decoding", name, r.Len())
+	}
+}
+
+// checkGoldenDigest checks the bits of values against a
+// SHA-256 of their little-endian float64 encoding.
+func checkGoldenDigest(t *testing.T, name string, values []float64, expected string) {
+	t.Helper()
+	hash := sha256.New()
+	binary.Write(hash, binary.LittleEndian, values)
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		t.Errorf("%s: expected digest %s but got %s (%d values)", name, expected,
+			actual, len(values))
+	}
+}
+
+func goldenObsValues(t *testing.T, obs Obs) []float64 {
+	t.Helper()
+	values, err := Flatten(obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
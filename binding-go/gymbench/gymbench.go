@@ -0,0 +1,316 @@
+// Package gymbench measures the throughput and latency of
+// environments on a gym-socket-api server.
+//
+// Benchmarks run a fixed action in a fixed configuration,
+// so that changes to the protocol, the server, or the
+// network can be compared reproducibly.
+package gymbench
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// DefaultSteps is the number of measured steps used when a
+// Config does not specify one.
+const DefaultSteps = 1000
+
+// Config configures a benchmark.
+type Config struct {
+	// Host is the server to connect to.
+	Host string
+
+	// EnvName is the environment to benchmark.
+	EnvName string
+
+	// Options are passed to gym.Make.
+	// A custom dialer (gym.WithDialer) is used to wrap
+	// every connection with a byte counter.
+	Options []gym.Option
+
+	// Steps is the number of steps to measure.
+	// If 0, DefaultSteps is used.
+	Steps int
+
+	// Warmup is the number of steps to run before
+	// measuring, e.g. to fill caches.
+	Warmup int
+
+	// PipelineDepth is the number of steps to keep in
+	// flight with a gym.Pipeline.
+	// If it is 0 or 1, steps are run one at a time.
+	//
+	// Pipelined benchmarks use the gym.AutoReset option,
+	// since the benchmark cannot reset between steps that
+	// are already in flight.
+	PipelineDepth int
+
+	// Action returns the action for a step.
+	// If nil, a single action from the action space is
+	// sampled before the benchmark and used for every step.
+	Action func(step int) interface{}
+}
+
+// Result summarizes a benchmark.
+type Result struct {
+	Steps    int
+	Duration time.Duration
+
+	// BytesSent and BytesReceived count the traffic during
+	// the measured steps, including resets.
+	BytesSent     int64
+	BytesReceived int64
+
+	// Latencies are the times between sending each step
+	// and receiving its result, sorted in ascending order.
+	Latencies []time.Duration
+}
+
+// StepsPerSecond returns the throughput of the benchmark.
+func (r *Result) StepsPerSecond() float64 {
+	return float64(r.Steps) / r.Duration.Seconds()
+}
+
+// BytesPerStep returns the average traffic for each step,
+// in both directions.
+func (r *Result) BytesPerStep() float64 {
+	return float64(r.BytesSent+r.BytesReceived) / float64(r.Steps)
+}
+
+// Percentile returns the step latency at a percentile
+// between 0 and 100.
+func (r *Result) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	idx := int(p / 100 * float64(len(r.Latencies)))
+	if idx >= len(r.Latencies) {
+		idx = len(r.Latencies) - 1
+	} else if idx < 0 {
+		idx = 0
+	}
+	return r.Latencies[idx]
+}
+
+// String summarizes the result on one line.
+func (r *Result) String() string {
+	return fmt.Sprintf("%d steps: %.1f steps/sec, %.0f bytes/step, p50=%v p99=%v",
+		r.Steps, r.StepsPerSecond(), r.BytesPerStep(), r.Percentile(50),
+		r.Percentile(99))
+}
+
+// Run runs a benchmark.
+func Run(config *Config) (res *Result, err error) {
+	defer essentials.AddCtxTo("run benchmark", &err)
+	numSteps := config.Steps
+	if numSteps == 0 {
+		numSteps = DefaultSteps
+	}
+	pipelined := config.PipelineDepth > 1
+
+	var counter byteCounter
+	opts := append([]gym.Option{}, config.Options...)
+	opts = append(opts, gym.WithDialer(counter.Dial))
+	if pipelined {
+		opts = append(opts, gym.AutoReset())
+	}
+	env, err := gym.Make(config.Host, config.EnvName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer env.Close()
+
+	action := config.Action
+	if action == nil {
+		var sample interface{}
+		if err := env.SampleAction(&sample); err != nil {
+			return nil, err
+		}
+		action = func(int) interface{} {
+			return sample
+		}
+	}
+
+	if _, err := env.Reset(); err != nil {
+		return nil, err
+	}
+	r := &runner{env: env, action: action}
+	step := r.stepSerial
+	if pipelined {
+		pipeline, err := gym.NewPipeline(env, config.PipelineDepth)
+		if err != nil {
+			return nil, err
+		}
+		defer pipeline.Close()
+		r.pipeline = pipeline
+		r.depth = config.PipelineDepth
+		step = r.stepPipelined
+	}
+
+	if err := step(config.Warmup); err != nil {
+		return nil, err
+	}
+	r.latencies = make([]time.Duration, 0, numSteps)
+	sent, received := counter.Counts()
+	start := time.Now()
+	if err := step(numSteps); err != nil {
+		return nil, err
+	}
+	duration := time.Since(start)
+	newSent, newReceived := counter.Counts()
+
+	sort.Slice(r.latencies, func(i, j int) bool {
+		return r.latencies[i] < r.latencies[j]
+	})
+	return &Result{
+		Steps:         numSteps,
+		Duration:      duration,
+		BytesSent:     newSent - sent,
+		BytesReceived: newReceived - received,
+		Latencies:     r.latencies,
+	}, nil
+}
+
+// RunParallel runs a benchmark on conns connections at
+// once, each with its own environment, and merges the
+// results with Merge.
+//
+// Each connection runs config.Steps steps, so the merged
+// result has conns times as many steps.
+func RunParallel(config *Config, conns int) (res *Result, err error) {
+	results := make([]*Result, conns)
+	errs := make([]error, conns)
+	var wg sync.WaitGroup
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = Run(config)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, essentials.AddCtx("run parallel benchmark", err)
+		}
+	}
+	return Merge(results), nil
+}
+
+// Merge combines the results of benchmarks which ran at
+// the same time.
+//
+// The merged Duration is the longest of the durations, so
+// that StepsPerSecond is the combined throughput.
+func Merge(results []*Result) *Result {
+	res := &Result{}
+	for _, r := range results {
+		res.Steps += r.Steps
+		if r.Duration > res.Duration {
+			res.Duration = r.Duration
+		}
+		res.BytesSent += r.BytesSent
+		res.BytesReceived += r.BytesReceived
+		res.Latencies = append(res.Latencies, r.Latencies...)
+	}
+	sort.Slice(res.Latencies, func(i, j int) bool {
+		return res.Latencies[i] < res.Latencies[j]
+	})
+	return res
+}
+
+type runner struct {
+	env      gym.Env
+	pipeline *gym.Pipeline
+	depth    int
+	action   func(step int) interface{}
+
+	stepIdx   int
+	latencies []time.Duration
+}
+
+func (r *runner) stepSerial(n int) error {
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		_, _, done, _, err := r.env.Step(r.action(r.stepIdx))
+		if err != nil {
+			return err
+		}
+		r.record(time.Since(start))
+		r.stepIdx++
+		if done {
+			if _, err := r.env.Reset(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *runner) stepPipelined(n int) error {
+	sendTimes := make([]time.Time, 0, n)
+	for received := 0; received < n; received++ {
+		for len(sendTimes) < n && len(sendTimes)-received < r.depth {
+			if err := r.pipeline.Send(r.action(r.stepIdx)); err != nil {
+				return err
+			}
+			sendTimes = append(sendTimes, time.Now())
+			r.stepIdx++
+		}
+		if _, err := r.pipeline.Recv(); err != nil {
+			return err
+		}
+		r.record(time.Since(sendTimes[received]))
+	}
+	return nil
+}
+
+func (r *runner) record(latency time.Duration) {
+	if r.latencies != nil {
+		r.latencies = append(r.latencies, latency)
+	}
+}
+
+// byteCounter counts the traffic on the connections that
+// it dials.
+type byteCounter struct {
+	sent     int64
+	received int64
+}
+
+func (b *byteCounter) Dial(network, address string) (net.Conn, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &countedConn{Conn: conn, counter: b}, nil
+}
+
+// Counts returns the total bytes sent and received.
+func (b *byteCounter) Counts() (sent, received int64) {
+	return atomic.LoadInt64(&b.sent), atomic.LoadInt64(&b.received)
+}
+
+type countedConn struct {
+	net.Conn
+	counter *byteCounter
+}
+
+func (c *countedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(&c.counter.received, int64(n))
+	return n, err
+}
+
+func (c *countedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.counter.sent, int64(n))
+	return n, err
+}
@@ -0,0 +1,90 @@
+package gymbench
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// HostEnvVar names the environment variable which points
+// the benchmarks at a running server.
+const HostEnvVar = "GYM_BENCH_HOST"
+
+func TestPercentile(t *testing.T) {
+	res := &Result{Steps: 100}
+	for i := 1; i <= 100; i++ {
+		res.Latencies = append(res.Latencies, time.Duration(i))
+	}
+	if p := res.Percentile(50); p != 51 {
+		t.Errorf("bad p50: %v", p)
+	}
+	if p := res.Percentile(99); p != 100 {
+		t.Errorf("bad p99: %v", p)
+	}
+	if p := res.Percentile(100); p != 100 {
+		t.Errorf("bad p100: %v", p)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	res := Merge([]*Result{
+		{
+			Steps:     2,
+			Duration:  time.Second,
+			BytesSent: 10,
+			Latencies: []time.Duration{1, 4},
+		},
+		{
+			Steps:         2,
+			Duration:      time.Second * 2,
+			BytesReceived: 20,
+			Latencies:     []time.Duration{2, 3},
+		},
+	})
+	if res.Steps != 4 || res.Duration != time.Second*2 || res.BytesSent != 10 ||
+		res.BytesReceived != 20 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	for i, latency := range res.Latencies {
+		if latency != time.Duration(i+1) {
+			t.Errorf("unexpected latencies: %v", res.Latencies)
+			break
+		}
+	}
+	if res.StepsPerSecond() != 2 {
+		t.Errorf("unexpected throughput: %f", res.StepsPerSecond())
+	}
+}
+
+func BenchmarkCartPole(b *testing.B) {
+	benchmarkEnv(b, "CartPole-v0", 1)
+}
+
+func BenchmarkCartPolePipelined(b *testing.B) {
+	benchmarkEnv(b, "CartPole-v0", 8)
+}
+
+func BenchmarkPong(b *testing.B) {
+	benchmarkEnv(b, "Pong-v0", 1)
+}
+
+func benchmarkEnv(b *testing.B, envName string, depth int) {
+	host := os.Getenv(HostEnvVar)
+	if host == "" {
+		b.Skip("set " + HostEnvVar + " to run benchmarks")
+	}
+	res, err := Run(&Config{
+		Host:          host,
+		EnvName:       envName,
+		Steps:         b.N,
+		Warmup:        10,
+		PipelineDepth: depth,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(res.Duration.Nanoseconds())/float64(res.Steps), "ns/op")
+	b.ReportMetric(res.BytesPerStep(), "bytes/step")
+	b.ReportMetric(float64(res.Percentile(50).Microseconds()), "p50-µs")
+	b.ReportMetric(float64(res.Percentile(99).Microseconds()), "p99-µs")
+}
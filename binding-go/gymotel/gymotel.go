@@ -0,0 +1,156 @@
+// Package gymotel instruments environments with
+// OpenTelemetry spans.
+//
+// Every command sent to the server, such as a reset, a
+// step, or a monitor command, becomes a span with the
+// environment name and the size of its payload.
+// Spans are recorded with the global TracerProvider by
+// default, which does nothing until an SDK is installed,
+// so instrumented code costs little when tracing is off.
+//
+// For example:
+//
+//	env, err := gymotel.Make(ctx, host, "Pong-v0")
+//
+// creates a "gym.make" span, and the env's later commands
+// become children of ctx's span.
+package gymotel
+
+import (
+	"context"
+	"sync"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies the spans of this
+// package.
+const InstrumentationName = "github.com/unixpickle/gym-socket-api/binding-go/gymotel"
+
+// Span attribute keys.
+const (
+	AttrEnvName = attribute.Key("gym.env.name")
+	AttrOp      = attribute.Key("gym.op")
+	AttrBytes   = attribute.Key("gym.payload.bytes")
+	AttrHost    = attribute.Key("gym.host")
+)
+
+// Config configures the spans of an environment.
+type Config struct {
+	// Provider creates the tracer.
+	// If nil, the global TracerProvider is used.
+	Provider trace.TracerProvider
+
+	// Context is the parent of the command spans.
+	// If nil, context.Background() is used, and every
+	// command starts a new trace.
+	Context context.Context
+}
+
+func (c *Config) tracer() trace.Tracer {
+	provider := c.Provider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(InstrumentationName)
+}
+
+func (c *Config) context() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
+}
+
+// Make is like gym.Make, but it records the handshake as
+// a span and instruments the environment's commands.
+// The spans are children of ctx.
+func Make(ctx context.Context, host, envName string, opts ...gym.Option) (gym.Env, error) {
+	config := &Config{Context: ctx}
+	ctx, span := config.tracer().Start(ctx, "gym.make", trace.WithAttributes(
+		AttrEnvName.String(envName),
+		AttrHost.String(host),
+	))
+	defer span.End()
+	opts = append(opts, gym.WithTracer(NewTracer(envName, config)))
+	env, err := gym.Make(host, envName, opts...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return env, nil
+}
+
+// NewTracer creates a gym.ReconnectTracer which records
+// a span for every command of an environment.
+//
+// Use it with gym.WithTracer for environments which are
+// not created with Make, e.g. batched environments.
+// The config may be nil.
+func NewTracer(envName string, config *Config) gym.ReconnectTracer {
+	if config == nil {
+		config = &Config{}
+	}
+	return &tracer{
+		tracer: config.tracer(),
+		ctx:    config.context(),
+		env:    envName,
+		spans:  map[string][]trace.Span{},
+	}
+}
+
+// tracer matches each End with the oldest Begin of the
+// same op, since the server answers commands in order.
+type tracer struct {
+	tracer trace.Tracer
+	ctx    context.Context
+	env    string
+
+	lock  sync.Mutex
+	spans map[string][]trace.Span
+}
+
+func (t *tracer) Begin(op string) {
+	_, span := t.tracer.Start(t.ctx, "gym."+op, trace.WithAttributes(
+		AttrEnvName.String(t.env),
+		AttrOp.String(op),
+	), trace.WithSpanKind(trace.SpanKindClient))
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.spans[op] = append(t.spans[op], span)
+}
+
+func (t *tracer) End(op string, err error, bytes int) {
+	t.lock.Lock()
+	spans := t.spans[op]
+	if len(spans) == 0 {
+		t.lock.Unlock()
+		return
+	}
+	span := spans[0]
+	t.spans[op] = spans[1:]
+	t.lock.Unlock()
+
+	span.SetAttributes(AttrBytes.Int(bytes))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *tracer) Reconnect(err error) {
+	_, span := t.tracer.Start(t.ctx, "gym.reconnect", trace.WithAttributes(
+		AttrEnvName.String(t.env),
+	))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
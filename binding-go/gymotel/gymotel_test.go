@@ -0,0 +1,47 @@
+package gymotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "rollout")
+
+	tracer := NewTracer("Pong-v0", &Config{Provider: provider, Context: ctx})
+	tracer.Begin("reset")
+	tracer.Begin("step")
+	tracer.End("reset", nil, 100)
+	tracer.End("step", errors.New("oops"), 20)
+	tracer.End("step", nil, 0)
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans but got %d", len(spans))
+	}
+	reset, step := spans[0], spans[1]
+	if reset.Name() != "gym.reset" || step.Name() != "gym.step" {
+		t.Errorf("unexpected span names: %s, %s", reset.Name(), step.Name())
+	}
+	if reset.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("span should be a child of the context's span")
+	}
+	attrs := map[string]interface{}{}
+	for _, attr := range reset.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	if attrs["gym.env.name"] != "Pong-v0" || attrs["gym.payload.bytes"] != int64(100) {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	if step.Status().Code != codes.Error || reset.Status().Code == codes.Error {
+		t.Error("unexpected span statuses")
+	}
+}
@@ -0,0 +1,564 @@
+package gymtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// A conn is the server side of a connection.
+type conn struct {
+	server  *Server
+	netConn net.Conn
+	rw      *bufio.ReadWriter
+
+	name string
+
+	// env is the scripted environment, or nil if the
+	// connection has no environment.
+	env *Env
+
+	batch     bool
+	multi     bool
+	autoReset bool
+
+	// lease is the duration of the lease, or 0 if the
+	// connection has no lease.
+	// Expired leases are enforced with read deadlines.
+	lease time.Duration
+
+	// states has one entry per environment, either in the
+	// batch or on the multiplexed connection.
+	states []*envState
+}
+
+// serveConn performs a handshake and runs commands until
+// the client disconnects or sends bad data.
+func serveConn(s *Server, netConn net.Conn) {
+	c := &conn{
+		server:  s,
+		netConn: netConn,
+		rw:      bufio.NewReadWriter(bufio.NewReader(netConn), bufio.NewWriter(netConn)),
+	}
+	if !c.handshake() {
+		return
+	}
+	if c.env != nil {
+		s.addInstances(c.name, len(c.states))
+		defer s.addInstances(c.name, -len(c.states))
+	}
+	for {
+		if err := c.command(); err != nil {
+			return
+		}
+		if err := c.rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// handshake reads the client's handshake and replies to
+// it, returning true if commands may follow.
+func (c *conn) handshake() bool {
+	flags, err := readByte(c.rw)
+	if err != nil {
+		return false
+	}
+	name, err := readField(c.rw)
+	if err != nil {
+		return false
+	}
+	c.name = string(name)
+	c.batch = flags&flagBatch != 0
+	c.multi = flags&flagMulti != 0
+	c.autoReset = flags&flagAutoReset != 0
+	numEnvs := uint32(1)
+	if c.batch {
+		if numEnvs, err = readUint32(c.rw); err != nil {
+			return false
+		}
+	}
+	if c.multi {
+		if numEnvs, err = readUint32(c.rw); err != nil {
+			return false
+		}
+	}
+	if flags&flagAuth != 0 {
+		if _, err := readField(c.rw); err != nil {
+			return false
+		}
+		return c.reject(gym.CodeUnsupported, "servers do not check auth tokens")
+	}
+	if flags&flagResume != 0 {
+		return c.reject(gym.CodeUnknownSession, "sessions cannot be resumed")
+	}
+	if c.batch && c.multi {
+		return c.reject(gym.CodeInvalidArgument, "cannot multiplex batched environments")
+	}
+	if numEnvs == 0 {
+		return c.reject(gym.CodeInvalidArgument, "number of environments must be positive")
+	}
+
+	if flags&flagStats != 0 {
+		writeError(c.rw, nil)
+		writeJSON(c.rw, c.server.stats())
+		c.rw.Flush()
+		return false
+	}
+
+	if c.name != "" {
+		var ok bool
+		c.env, ok = c.server.envs[c.name]
+		if !ok {
+			return c.reject(gym.CodeUnknownEnv, "no registered env with id: "+c.name)
+		}
+	}
+	for i := uint32(0); i < numEnvs; i++ {
+		c.states = append(c.states, &envState{})
+	}
+	writeError(c.rw, nil)
+	if flags&flagSession != 0 {
+		writeField(c.rw, []byte(c.server.newSessionToken()))
+	}
+	return c.rw.Flush() == nil
+}
+
+func (c *conn) reject(code, message string) bool {
+	writeError(c.rw, &gym.EnvError{Code: code, Message: message})
+	c.rw.Flush()
+	return false
+}
+
+// features are the optional features that the fake
+// server supports.
+var features = []string{gym.FeatureBatch, gym.FeatureMultiplex,
+	gym.FeatureBinaryActions, gym.FeatureStepBlind, gym.FeatureDescribe,
+	gym.FeatureGetAttr, gym.FeatureCallMethod, gym.FeatureLease}
+
+// command runs one command.
+//
+// It returns an error if the connection should be closed.
+func (c *conn) command() error {
+	state := c.states[0]
+	if c.multi {
+		id, err := readUint32(c.rw)
+		if err != nil {
+			return err
+		}
+		if int(id) >= len(c.states) {
+			return fmt.Errorf("invalid environment index: %d", id)
+		}
+		state = c.states[id]
+	}
+	packetType, err := readByte(c.rw)
+	if err != nil {
+		return err
+	}
+	switch packetType {
+	case packetReset:
+		return c.reset(state)
+	case packetStep:
+		return c.step(state, false)
+	case packetStepBlind:
+		return c.step(state, true)
+	case packetResetBatch:
+		return c.resetBatch()
+	case packetStepBatch:
+		return c.stepBatch()
+	case packetGetSpace:
+		return c.getSpace()
+	case packetSampleAction:
+		return c.sampleAction()
+	case packetRender, packetKeepAlive:
+		return nil
+	case packetPing:
+		return writeJSON(c.rw, &gym.ServerStatus{
+			EnvName:  c.name,
+			PID:      os.Getpid(),
+			LeaseTTL: c.lease.Seconds(),
+			Features: features,
+		})
+	case packetSetLogLevel:
+		return c.setLogLevel()
+	case packetRenewLease:
+		return c.renewLease()
+	case packetEndSession:
+		return errors.New("session ended")
+	case packetListEnvs:
+		return c.listEnvs()
+	case packetSpec:
+		return c.spec()
+	case packetDescribe:
+		return c.describe()
+	case packetGetAttr:
+		return c.getAttr()
+	case packetCallMethod:
+		return c.callMethod()
+	}
+	if layout, ok := unsupportedPackets[packetType]; ok {
+		if err := skipFields(c.rw, layout); err != nil {
+			return err
+		}
+		return writeError(c.rw, &gym.EnvError{
+			Code:    gym.CodeUnsupported,
+			Message: fmt.Sprintf("packet type %d is not supported by gymtest", packetType),
+		})
+	}
+	return fmt.Errorf("unknown packet type: %d", packetType)
+}
+
+func (c *conn) reset(state *envState) error {
+	if err := c.checkEnv(false); err != nil {
+		return writeObsError(c.rw, err)
+	}
+	obs, err := c.resetState(state)
+	if err != nil {
+		return writeObsError(c.rw, err)
+	}
+	return writeObs(c.rw, obs)
+}
+
+// step runs a step, leaving out the observation if blind
+// is set and the episode is not done.
+func (c *conn) step(state *envState, blind bool) error {
+	action, err := readAction(c.rw)
+	if err != nil {
+		return err
+	}
+	c.server.recordAction(action)
+	if err := c.checkEnv(false); err != nil {
+		return writeObsError(c.rw, err)
+	}
+	result, err := c.stepState(state)
+	if err != nil {
+		return writeObsError(c.rw, err)
+	}
+	if blind && !result.Done {
+		result.Obs = nil
+	}
+	return c.writeStep(result)
+}
+
+func (c *conn) resetBatch() error {
+	if err := c.checkEnv(true); err != nil {
+		return writeObsError(c.rw, err)
+	}
+	var obses []*encodedObs
+	for _, state := range c.states {
+		obs, err := c.resetState(state)
+		if err != nil {
+			return writeObsError(c.rw, err)
+		}
+		obses = append(obses, obs)
+	}
+	for _, obs := range obses {
+		if err := writeObs(c.rw, obs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) stepBatch() error {
+	count, err := readUint32(c.rw)
+	if err != nil {
+		return err
+	}
+	if int(count) != len(c.states) {
+		return fmt.Errorf("expected %d actions but got %d", len(c.states), count)
+	}
+	for i := 0; i < int(count); i++ {
+		action, err := readAction(c.rw)
+		if err != nil {
+			return err
+		}
+		c.server.recordAction(action)
+	}
+	if err := c.checkEnv(true); err != nil {
+		return writeObsError(c.rw, err)
+	}
+	var results []*stepResult
+	for _, state := range c.states {
+		var result *stepResult
+		if state.done && !c.autoReset {
+			// Finished environments in a batch are reset by
+			// the next step, which ignores their actions.
+			obs, err := c.resetState(state)
+			if err != nil {
+				return writeObsError(c.rw, err)
+			}
+			result = &stepResult{Obs: obs, Info: map[string]interface{}{}}
+		} else if result, err = c.stepState(state); err != nil {
+			return writeObsError(c.rw, err)
+		}
+		results = append(results, result)
+	}
+	for _, result := range results {
+		if err := writeObs(c.rw, result.Obs); err != nil {
+			return err
+		}
+	}
+	for _, result := range results {
+		if err := writeReward(c.rw, result.Reward); err != nil {
+			return err
+		}
+	}
+	for _, result := range results {
+		if err := writeBool(c.rw, result.Done); err != nil {
+			return err
+		}
+	}
+	var infos []interface{}
+	for _, result := range results {
+		infos = append(infos, result.Info)
+	}
+	return writeJSON(c.rw, infos)
+}
+
+// checkEnv checks that the connection has an environment,
+// and that it is batched if and only if batch is set.
+func (c *conn) checkEnv(batch bool) error {
+	if c.env == nil {
+		return errNoEnv
+	} else if c.batch != batch {
+		return &gym.EnvError{Code: gym.CodeUnsupported,
+			Message: "batched environments need batched commands"}
+	}
+	return nil
+}
+
+// A stepResult is an encoded step.
+type stepResult struct {
+	// Obs is nil if it was left out of a blind step.
+	Obs    *encodedObs
+	Reward float64
+	Done   bool
+	Info   map[string]interface{}
+}
+
+func (c *conn) resetState(state *envState) (*encodedObs, error) {
+	state.reset()
+	return encodeObs(c.env.InitialObs)
+}
+
+// stepState advances an environment through its script,
+// resetting it right away if the connection auto-resets.
+func (c *conn) stepState(state *envState) (*stepResult, error) {
+	step, err := state.step(c.env)
+	if err != nil {
+		return nil, err
+	}
+	info := map[string]interface{}{}
+	for key, value := range step.Info {
+		info[key] = value
+	}
+	result := &stepResult{Reward: step.Reward, Done: step.Done, Info: info}
+	if step.Done && c.autoReset {
+		terminal, err := obsJSON(step.Obs)
+		if err != nil {
+			return nil, err
+		}
+		info["terminal_observation"] = terminal
+		result.Obs, err = c.resetState(state)
+	} else {
+		result.Obs, err = encodeObs(step.Obs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *conn) writeStep(result *stepResult) error {
+	if result.Obs == nil {
+		if err := writeByte(c.rw, observationNone); err != nil {
+			return err
+		}
+	} else if err := writeObs(c.rw, result.Obs); err != nil {
+		return err
+	}
+	if err := writeReward(c.rw, result.Reward); err != nil {
+		return err
+	}
+	if err := writeBool(c.rw, result.Done); err != nil {
+		return err
+	}
+	return writeJSON(c.rw, result.Info)
+}
+
+func (c *conn) getSpace() error {
+	spaceType, err := readByte(c.rw)
+	if err != nil {
+		return err
+	}
+	if c.env == nil {
+		return errors.New("connection has no environment")
+	}
+	if spaceType == 0 {
+		return writeJSON(c.rw, c.env.ActionSpace)
+	}
+	return writeJSON(c.rw, c.env.ObservationSpace)
+}
+
+func (c *conn) sampleAction() error {
+	if c.env == nil || c.env.ActionSpace == nil {
+		return errors.New("no action space to sample")
+	}
+	action, err := sample(c.env.ActionSpace)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	if err := writeByte(c.rw, actionJSON); err != nil {
+		return err
+	}
+	return writeField(c.rw, data)
+}
+
+func (c *conn) setLogLevel() error {
+	level, err := readField(c.rw)
+	if err != nil {
+		return err
+	}
+	switch string(level) {
+	case "debug", "info", "warning", "error":
+		return writeError(c.rw, nil)
+	}
+	return writeError(c.rw, &gym.EnvError{Code: gym.CodeInvalidArgument,
+		Message: "unknown log level: " + string(level)})
+}
+
+func (c *conn) listEnvs() error {
+	var names []string
+	for name := range c.server.envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if names == nil {
+		names = []string{}
+	}
+	if err := writeError(c.rw, nil); err != nil {
+		return err
+	}
+	return writeJSON(c.rw, names)
+}
+
+func (c *conn) spec() error {
+	name, err := readField(c.rw)
+	if err != nil {
+		return err
+	}
+	env, ok := c.server.envs[string(name)]
+	if !ok {
+		return writeError(c.rw, &gym.EnvError{Code: gym.CodeUnknownEnv,
+			Message: "no registered env with id: " + string(name)})
+	}
+	if err := writeError(c.rw, nil); err != nil {
+		return err
+	}
+	return writeJSON(c.rw, &gym.EnvSpec{
+		ID:               string(name),
+		EntryPoint:       "gymtest",
+		Kwargs:           map[string]interface{}{},
+		ActionSpace:      env.ActionSpace,
+		ObservationSpace: env.ObservationSpace,
+	})
+}
+
+func (c *conn) describe() error {
+	if c.env == nil {
+		return writeError(c.rw, errNoEnv)
+	}
+	if err := writeError(c.rw, nil); err != nil {
+		return err
+	}
+	return writeJSON(c.rw, &gym.EnvSpec{
+		ID:               c.name,
+		EntryPoint:       "gymtest",
+		Kwargs:           map[string]interface{}{},
+		ActionSpace:      c.env.ActionSpace,
+		ObservationSpace: c.env.ObservationSpace,
+	})
+}
+
+func (c *conn) getAttr() error {
+	name, err := readField(c.rw)
+	if err != nil {
+		return err
+	}
+	if c.env == nil {
+		return writeError(c.rw, errNoEnv)
+	}
+	value, err := c.env.attr(string(name))
+	if err != nil {
+		return writeError(c.rw, err)
+	}
+	return c.writeResult(value)
+}
+
+func (c *conn) callMethod() error {
+	name, err := readField(c.rw)
+	if err != nil {
+		return err
+	}
+	argsData, err := readField(c.rw)
+	if err != nil {
+		return err
+	}
+	if c.env == nil {
+		return writeError(c.rw, errNoEnv)
+	}
+	var args []json.RawMessage
+	if err := json.Unmarshal(argsData, &args); err != nil {
+		return writeError(c.rw, &gym.EnvError{Code: gym.CodeInvalidArgument,
+			Message: "invalid arguments: " + err.Error()})
+	}
+	result, err := c.env.call(string(name), args)
+	if err != nil {
+		return writeError(c.rw, err)
+	}
+	return c.writeResult(result)
+}
+
+// writeResult writes an empty error and a JSON result, or
+// an error if the result cannot be encoded.
+func (c *conn) writeResult(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return writeError(c.rw, &gym.EnvError{Code: gym.CodeInvalidArgument,
+			Message: "cannot encode result: " + err.Error()})
+	}
+	if err := writeError(c.rw, nil); err != nil {
+		return err
+	}
+	return writeField(c.rw, data)
+}
+
+func (c *conn) renewLease() error {
+	seconds, err := readFloat64(c.rw)
+	if err != nil {
+		return err
+	}
+	if math.IsNaN(seconds) || seconds < 0 {
+		return writeError(c.rw, &gym.EnvError{Code: gym.CodeInvalidArgument,
+			Message: fmt.Sprintf("invalid lease TTL: %g", seconds)})
+	}
+	c.lease = time.Duration(seconds * float64(time.Second))
+	var deadline time.Time
+	if c.lease != 0 {
+		deadline = time.Now().Add(c.lease)
+	}
+	c.netConn.SetReadDeadline(deadline)
+	return writeError(c.rw, nil)
+}
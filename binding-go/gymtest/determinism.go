@@ -0,0 +1,151 @@
+package gymtest
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// A Divergence is the first difference between two runs of
+// an environment.
+type Divergence struct {
+	// Step is the index of the action whose result
+	// differed.
+	// For a reset, it is the number of actions which were
+	// taken before the reset.
+	Step int
+
+	// Reset is set if the result came from a reset, which
+	// happens at the start and after each episode.
+	Reset bool
+
+	// Field is "observation", "reward", or "done".
+	Field string
+
+	// Path locates the first differing element of an
+	// observation, as in gym.ObsDifference.
+	// It is empty if the whole values are given.
+	Path string
+
+	// First and Second are the values from each run.
+	First  interface{}
+	Second interface{}
+}
+
+// String describes the divergence.
+func (d *Divergence) String() string {
+	when := fmt.Sprintf("step %d", d.Step)
+	if d.Reset {
+		when = fmt.Sprintf("reset after %d steps", d.Step)
+	}
+	return fmt.Sprintf("%s: %s%s differs: %v vs. %v", when, d.Field, d.Path, d.First,
+		d.Second)
+}
+
+// A SeedFunc seeds an environment before it is reset.
+type SeedFunc func(env gym.Env, seed int64) error
+
+// SeedMethod seeds an environment by calling its seed
+// method, which most Gym environments provide.
+func SeedMethod(env gym.Env, seed int64) error {
+	return env.CallMethod("seed", nil, seed)
+}
+
+// CheckDeterminism runs two environments side by side with
+// the same seed and actions, and returns the first point
+// where their observations, rewards, or done flags differ.
+// It returns nil if the runs match.
+//
+// The environments are created with makeEnv and seeded
+// with seedFunc, or SeedMethod if seedFunc is nil.
+// When an episode ends, both environments are reset and
+// the remaining actions continue in the next episode.
+//
+// This is useful for checking that seeding works, and for
+// finding nondeterministic environments before a long
+// experiment depends on reproducing them.
+func CheckDeterminism(makeEnv func() (gym.Env, error), seedFunc SeedFunc, seed int64,
+	actions []interface{}) (divergence *Divergence, err error) {
+	defer essentials.AddCtxTo("check determinism", &err)
+	if seedFunc == nil {
+		seedFunc = SeedMethod
+	}
+	var envs [2]gym.Env
+	for i := range envs {
+		env, err := makeEnv()
+		if err != nil {
+			return nil, err
+		}
+		defer env.Close()
+		if err := seedFunc(env, seed); err != nil {
+			return nil, essentials.AddCtx("seed", err)
+		}
+		envs[i] = env
+	}
+
+	if d, err := resetBoth(envs); d != nil || err != nil {
+		return d, err
+	}
+	for step, action := range actions {
+		var obs [2]gym.Obs
+		var rewards [2]float64
+		var dones [2]bool
+		for i, env := range envs {
+			var err error
+			obs[i], rewards[i], dones[i], _, err = env.Step(action)
+			if err != nil {
+				return nil, err
+			}
+		}
+		d, err := compareObs(obs[0], obs[1])
+		if err != nil {
+			return nil, err
+		}
+		if d == nil && rewards[0] != rewards[1] {
+			d = &Divergence{Field: "reward", First: rewards[0], Second: rewards[1]}
+		}
+		if d == nil && dones[0] != dones[1] {
+			d = &Divergence{Field: "done", First: dones[0], Second: dones[1]}
+		}
+		if d != nil {
+			d.Step = step
+			return d, nil
+		}
+		if dones[0] && step+1 < len(actions) {
+			if d, err := resetBoth(envs); d != nil || err != nil {
+				if d != nil {
+					d.Step = step + 1
+				}
+				return d, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+func resetBoth(envs [2]gym.Env) (*Divergence, error) {
+	var obs [2]gym.Obs
+	for i, env := range envs {
+		var err error
+		if obs[i], err = env.Reset(); err != nil {
+			return nil, err
+		}
+	}
+	d, err := compareObs(obs[0], obs[1])
+	if d != nil {
+		d.Reset = true
+	}
+	return d, err
+}
+
+// compareObs returns the first difference between two
+// observations, or nil if they are equal.
+func compareObs(obs1, obs2 gym.Obs) (*Divergence, error) {
+	diff, err := gym.ObsDiff(obs1, obs2, 0)
+	if err != nil || diff == nil {
+		return nil, err
+	}
+	return &Divergence{Field: "observation", Path: diff.Path, First: diff.A,
+		Second: diff.B}, nil
+}
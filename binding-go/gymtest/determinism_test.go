@@ -0,0 +1,82 @@
+package gymtest
+
+import (
+	"encoding/json"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+func TestCheckDeterminism(t *testing.T) {
+	var seeds []int64
+	var made int
+	makeEnv := func(pixels []uint8) func() (gym.Env, error) {
+		return func() (gym.Env, error) {
+			made++
+			second := made%2 == 0
+			last := gym.NewUint8Obs([]int{2}, []uint8{5, 6})
+			if second {
+				last = gym.NewUint8Obs([]int{2}, pixels)
+			}
+			return NewScriptedEnv(&Env{
+				InitialObs: gym.NewUint8Obs([]int{2}, []uint8{1, 2}),
+				Steps: []Step{
+					{Obs: gym.NewUint8Obs([]int{2}, []uint8{3, 4}), Reward: 1},
+					{Obs: last, Reward: 1},
+				},
+				Methods: map[string]func(args []json.RawMessage) (interface{}, error){
+					"seed": func(args []json.RawMessage) (interface{}, error) {
+						var seed int64
+						if err := json.Unmarshal(args[0], &seed); err != nil {
+							return nil, err
+						}
+						seeds = append(seeds, seed)
+						return []int64{seed}, nil
+					},
+				},
+			}), nil
+		}
+	}
+	actions := []interface{}{0, 1, 0, 1, 0}
+
+	d, err := CheckDeterminism(makeEnv([]uint8{5, 6}), nil, 7, actions)
+	if err != nil {
+		t.Fatal(err)
+	} else if d != nil {
+		t.Errorf("unexpected divergence: %v", d)
+	}
+	if len(seeds) != 2 || seeds[0] != 7 || seeds[1] != 7 {
+		t.Errorf("unexpected seeds: %v", seeds)
+	}
+
+	d, err = CheckDeterminism(makeEnv([]uint8{5, 9}), nil, 7, actions)
+	if err != nil {
+		t.Fatal(err)
+	} else if d == nil {
+		t.Fatal("expected divergence")
+	}
+	expected := "step 1: observation[1] differs: 6 vs. 9"
+	if d.String() != expected {
+		t.Errorf("expected %q but got %q", expected, d.String())
+	}
+}
+
+func TestCheckDeterminismReset(t *testing.T) {
+	var made int
+	makeEnv := func() (gym.Env, error) {
+		made++
+		return NewScriptedEnv(&Env{
+			InitialObs: made,
+			Steps:      []Step{{Obs: 0, Done: true}},
+		}), nil
+	}
+	noSeed := func(env gym.Env, seed int64) error {
+		return nil
+	}
+	d, err := CheckDeterminism(makeEnv, noSeed, 0, []interface{}{0})
+	if err != nil {
+		t.Fatal(err)
+	} else if d == nil || !d.Reset || d.Step != 0 || d.Path != "" {
+		t.Errorf("unexpected divergence: %+v", d)
+	}
+}
@@ -0,0 +1,269 @@
+// Package gymtest runs a fake API server inside the test
+// process, so that agents and wrappers can be tested
+// without Python or network access.
+//
+// The server speaks the same protocol as the real one, but
+// its environments follow scripts of observations and
+// rewards:
+//
+//	server := gymtest.NewServer(map[string]*gymtest.Env{
+//		"Count-v0": {
+//			ActionSpace: &gym.Space{Type: "Discrete", N: 2},
+//			InitialObs:  0,
+//			Steps: []gymtest.Step{
+//				{Obs: 1, Reward: 1},
+//				{Obs: 2, Reward: 1},
+//			},
+//		},
+//	})
+//	defer server.Close()
+//	env, err := gym.Make("gymtest", "Count-v0", gym.WithDialer(server.Dial))
+//
+// For tests which do not need the protocol, NewScriptedEnv
+// follows the same kind of script without a server.
+// Either way, NewCallRecorder can record the calls which an
+// agent makes to its environment.
+//
+// A TranscriptRecorder saves the raw traffic of a session,
+// and a Replayer plays it back to the client, so that golden
+// transcripts catch changes to the wire format.
+//
+// CheckDeterminism compares two seeded runs of an
+// environment, on this server or a real one.
+package gymtest
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// A Step is the scripted result of a step.
+type Step struct {
+	// Obs is the observation, which is sent as JSON unless
+	// it is a byte list observation, like those from
+	// gym.NewUint8Obs.
+	Obs interface{}
+
+	Reward float64
+	Done   bool
+
+	// Info is the info object, or nil for an empty one.
+	Info map[string]interface{}
+}
+
+// An Env is a scripted environment on a fake server.
+//
+// An Env may be used by many connections at once, and it
+// should not be modified while the server is running.
+type Env struct {
+	// ActionSpace and ObservationSpace are the spaces of
+	// the environment.
+	// Like on the real server, unbounded Box spaces should
+	// use bounds of -1e30 and 1e30, since JSON cannot
+	// encode infinity.
+	ActionSpace      *gym.Space
+	ObservationSpace *gym.Space
+
+	// InitialObs is the observation from every reset,
+	// which is encoded like Step.Obs.
+	InitialObs interface{}
+
+	// Steps are the results of the steps of an episode.
+	// Every episode follows the same script, and the last
+	// step always ends the episode.
+	//
+	// Stepping an environment which has not been reset, or
+	// whose episode is done, fails with gym.ErrEnvFailed.
+	Steps []Step
+
+	// Attrs are the attributes which gym.Env.GetAttr can
+	// read.
+	Attrs map[string]interface{}
+
+	// Methods are the methods which gym.Env.CallMethod can
+	// call, with the JSON of each argument.
+	//
+	// Errors are sent to the client with the env_failed
+	// code, unless they are *gym.EnvErrors.
+	Methods map[string]func(args []json.RawMessage) (interface{}, error)
+}
+
+// A Server is a fake API server.
+//
+// Besides the commands which act on the scripted
+// environments, it answers pings, server stats, and
+// environment listings.
+// Commands for features which the fake environments lack,
+// such as Retro, Universe, Configure, and CloneState, fail
+// with gym.ErrUnsupported.
+// Sessions cannot be resumed.
+type Server struct {
+	envs  map[string]*Env
+	start time.Time
+
+	lock      sync.Mutex
+	closed    bool
+	listeners []net.Listener
+	conns     map[net.Conn]bool
+	total     int
+	instances map[string]int
+	actions   []json.RawMessage
+	sessions  int
+
+	wg sync.WaitGroup
+}
+
+// NewServer creates a server with the given environments,
+// keyed by name.
+func NewServer(envs map[string]*Env) *Server {
+	return &Server{
+		envs:      envs,
+		start:     time.Now(),
+		conns:     map[net.Conn]bool{},
+		instances: map[string]int{},
+	}
+}
+
+// Dial opens an in-memory connection to the server.
+//
+// It has the signature of a gym.DialFunc, so that it can
+// be passed to gym.WithDialer.
+// The network and address are ignored.
+func (s *Server) Dial(network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+	if !s.serve(server) {
+		return nil, errors.New("gymtest: server is closed")
+	}
+	return client, nil
+}
+
+// Listen starts serving on a loopback TCP port, for code
+// which cannot use Dial, and returns the address.
+func (s *Server) Listen() (addr string, err error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		listener.Close()
+		return "", errors.New("gymtest: server is closed")
+	}
+	s.listeners = append(s.listeners, listener)
+	s.wg.Add(1)
+	s.lock.Unlock()
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.serve(conn)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+// Actions returns every action which the server has
+// received, in order, across all connections.
+//
+// Actions are converted to JSON, even if they were sent in
+// a binary format.
+func (s *Server) Actions() []json.RawMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]json.RawMessage{}, s.actions...)
+}
+
+// Close stops the server and closes its connections.
+func (s *Server) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// serve handles a connection in a new Goroutine, unless
+// the server is closed.
+func (s *Server) serve(conn net.Conn) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		conn.Close()
+		return false
+	}
+	s.conns[conn] = true
+	s.total++
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.lock.Lock()
+			delete(s.conns, conn)
+			s.lock.Unlock()
+		}()
+		defer conn.Close()
+		serveConn(s, conn)
+	}()
+	return true
+}
+
+func (s *Server) recordAction(action json.RawMessage) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.actions = append(s.actions, action)
+}
+
+// addInstances updates the count of live environments
+// with a name.
+func (s *Server) addInstances(name string, n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.instances[name] += n
+	if s.instances[name] == 0 {
+		delete(s.instances, name)
+	}
+}
+
+func (s *Server) stats() *gym.ServerStatsResult {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	envs := map[string]int{}
+	for name, count := range s.instances {
+		envs[name] = count
+	}
+	return &gym.ServerStatsResult{
+		ActiveConnections: len(s.conns),
+		TotalConnections:  s.total,
+		Envs:              envs,
+		UptimeSeconds:     time.Since(s.start).Seconds(),
+	}
+}
+
+// newSessionToken creates a token for a session, which can
+// never be resumed.
+func (s *Server) newSessionToken() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sessions++
+	return "gymtest-" + strconv.Itoa(s.sessions)
+}
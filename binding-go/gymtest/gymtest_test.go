@@ -0,0 +1,615 @@
+package gymtest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+	"github.com/unixpickle/gym-socket-api/binding-go/wrappers"
+)
+
+func testServer() *Server {
+	return NewServer(map[string]*Env{
+		"Count-v0": {
+			ActionSpace:      &gym.Space{Type: "Discrete", N: 3},
+			ObservationSpace: &gym.Space{Type: "Box", Shape: []int{1}, Low: []float64{0}, High: []float64{3}},
+			InitialObs:       []int{0},
+			Steps: []Step{
+				{Obs: []int{1}, Reward: 1},
+				{Obs: []int{2}, Reward: 2, Info: map[string]interface{}{"lives": 1}},
+				{Obs: []int{3}, Reward: 3},
+			},
+			Attrs: map[string]interface{}{"buttons": []string{"A", "B"}},
+			Methods: map[string]func(args []json.RawMessage) (interface{}, error){
+				"double": func(args []json.RawMessage) (interface{}, error) {
+					var x float64
+					if err := json.Unmarshal(args[0], &x); err != nil {
+						return nil, err
+					}
+					return x * 2, nil
+				},
+			},
+		},
+		"Pixels-v0": {
+			ActionSpace: &gym.Space{Type: "Box", Shape: []int{2}, Low: []float64{-1, -1e30},
+				High: []float64{1, 1e30}},
+			InitialObs: gym.NewUint8Obs([]int{1, 2}, []uint8{1, 2}),
+			Steps:      []Step{{Obs: gym.NewUint8Obs([]int{1, 2}, []uint8{3, 4})}},
+		},
+	})
+}
+
+func TestScript(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	env, err := gym.Make("gymtest", "Count-v0", gym.WithDialer(server.Dial),
+		gym.BinaryActions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	if _, _, _, _, err := env.Step(0); !errors.Is(err, gym.ErrEnvFailed) {
+		t.Errorf("expected ErrEnvFailed before reset but got %v", err)
+	}
+	for episode := 0; episode < 2; episode++ {
+		obs, err := env.Reset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkObs(t, obs, []int{0})
+		for i := 1; i <= 3; i++ {
+			obs, reward, done, info, err := env.Step(i - 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkObs(t, obs, []int{i})
+			if reward != float64(i) || done != (i == 3) {
+				t.Errorf("step %d: unexpected reward %f and done %v", i, reward, done)
+			}
+			if i == 2 && !reflect.DeepEqual(info, map[string]interface{}{"lives": 1.0}) {
+				t.Errorf("unexpected info: %v", info)
+			}
+		}
+	}
+	if _, _, _, _, err := env.Step(0); !errors.Is(err, gym.ErrEnvFailed) {
+		t.Errorf("expected ErrEnvFailed after episode but got %v", err)
+	}
+
+	var actions []int
+	for _, data := range server.Actions() {
+		var action int
+		if err := json.Unmarshal(data, &action); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, action)
+	}
+	if !reflect.DeepEqual(actions, []int{0, 0, 1, 2, 0, 1, 2, 0}) {
+		t.Errorf("unexpected actions: %v", actions)
+	}
+}
+
+func TestAutoReset(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	envs, err := gym.MakeN("gymtest", "Count-v0", 2, gym.WithDialer(server.Dial),
+		gym.AutoReset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range envs {
+		defer env.Close()
+	}
+	if _, err := envs[1].Reset(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, _, _, err := envs[1].Step(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	obs, _, done, info, err := envs[1].Step(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkObs(t, obs, []int{0})
+	terminal, ok := gym.TerminalObs(info)
+	if !done || !ok {
+		t.Fatalf("expected terminal observation but got done=%v info=%v", done, info)
+	}
+	checkObs(t, terminal, []int{3})
+
+	if _, _, _, _, err := envs[0].Step(0); !errors.Is(err, gym.ErrEnvFailed) {
+		t.Errorf("expected ErrEnvFailed for other environment but got %v", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	env, err := gym.MakeBatch("gymtest", "Pixels-v0", 2, gym.WithDialer(server.Dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	obses, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obs := range obses {
+		checkPixels(t, obs, []uint8{1, 2})
+	}
+	actions := []interface{}{[]float64{0, 0}, []float64{1, 2}}
+	for i := 0; i < 2; i++ {
+		obses, _, dones, _, err := env.Step(actions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, obs := range obses {
+			if i == 0 {
+				checkPixels(t, obs, []uint8{3, 4})
+			} else {
+				checkPixels(t, obs, []uint8{1, 2})
+			}
+			if dones[j] != (i == 0) {
+				t.Errorf("step %d: unexpected done %v", i, dones[j])
+			}
+		}
+	}
+
+	space, err := env.ActionSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if space.Type != "Box" || !reflect.DeepEqual(space.Shape, []int{2}) {
+		t.Errorf("unexpected action space: %+v", space)
+	}
+}
+
+func TestStreamObs(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+
+	var lock sync.Mutex
+	var indices []int
+	handler := func(index int, dims []int, values io.Reader) (gym.Obs, error) {
+		lock.Lock()
+		indices = append(indices, index)
+		lock.Unlock()
+		return gym.NewJSONObs(index)
+	}
+	envs, err := gym.MakeN("gymtest", "Pixels-v0", 3, gym.WithDialer(server.Dial),
+		gym.StreamObs(handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, env := range envs {
+		defer env.Close()
+		obs, err := env.Reset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var index int
+		if err := obs.Unmarshal(&index); err != nil {
+			t.Fatal(err)
+		}
+		if index != i {
+			t.Errorf("env %d: handler got index %d", i, index)
+		}
+	}
+	if expected := []int{0, 1, 2}; !reflect.DeepEqual(indices, expected) {
+		t.Errorf("expected indices %v but got %v", expected, indices)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	addr, err := server.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := gym.Make(addr, "Count-v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	var action int
+	if err := env.SampleAction(&action); err != nil {
+		t.Fatal(err)
+	} else if action < 0 || action >= 3 {
+		t.Errorf("invalid sample: %d", action)
+	}
+	var buttons []string
+	if err := env.GetAttr("buttons", &buttons); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(buttons, []string{"A", "B"}) {
+		t.Errorf("unexpected buttons: %v", buttons)
+	}
+	if err := env.GetAttr("_private", nil); !errors.Is(err, gym.ErrInvalidArgument) {
+		t.Errorf("unexpected error for private attribute: %v", err)
+	}
+	var doubled float64
+	if err := env.CallMethod("double", &doubled, 2.5); err != nil {
+		t.Fatal(err)
+	} else if doubled != 5 {
+		t.Errorf("unexpected result: %f", doubled)
+	}
+	if _, err := env.CloneState(); !errors.Is(err, gym.ErrUnsupported) {
+		t.Errorf("unexpected error for CloneState: %v", err)
+	}
+	_, err = gym.StepMulti(env, map[string]interface{}{"a": 1})
+	if !errors.Is(err, gym.ErrUnsupported) {
+		t.Errorf("unexpected error for StepMulti: %v", err)
+	}
+	if _, err := gym.RenderText(env); !errors.Is(err, gym.ErrUnsupported) {
+		t.Errorf("unexpected error for RenderText: %v", err)
+	}
+	if _, err := gym.Screenshot(env); !errors.Is(err, gym.ErrUnsupported) {
+		t.Errorf("unexpected error for Screenshot: %v", err)
+	}
+	res, err := env.Ping()
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Status.EnvName != "Count-v0" {
+		t.Errorf("unexpected status: %+v", res.Status)
+	}
+
+	names, err := gym.ListEnvs(addr)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(names, []string{"Count-v0", "Pixels-v0"}) {
+		t.Errorf("unexpected names: %v", names)
+	}
+	spec, err := gym.Spec(addr, "Count-v0")
+	if err != nil {
+		t.Fatal(err)
+	} else if spec.ActionSpace.N != 3 {
+		t.Errorf("unexpected spec: %+v", spec)
+	}
+	if _, err := gym.Make(addr, "Missing-v0"); !errors.Is(err, gym.ErrUnknownEnv) {
+		t.Errorf("unexpected error for missing env: %v", err)
+	}
+	stats, err := gym.ServerStats(addr)
+	if err != nil {
+		t.Fatal(err)
+	} else if stats.Envs["Count-v0"] != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	stats, err = gym.ServerStats("gymtest", gym.WithDialer(server.Dial))
+	if err != nil {
+		t.Fatal(err)
+	} else if stats.TotalConnections == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestSample(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	env, err := gym.Make("gymtest", "Pixels-v0", gym.WithDialer(server.Dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	for i := 0; i < 10; i++ {
+		var action []float64
+		if err := env.SampleAction(&action); err != nil {
+			t.Fatal(err)
+		}
+		if len(action) != 2 || action[0] < -1 || action[0] > 1 {
+			t.Errorf("invalid sample: %v", action)
+		}
+	}
+}
+
+func checkObs(t *testing.T, obs gym.Obs, expected []int) {
+	t.Helper()
+	var actual []int
+	if err := obs.Unmarshal(&actual); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected observation %v but got %v", expected, actual)
+	}
+}
+
+func checkPixels(t *testing.T, obs gym.Obs, expected []uint8) {
+	t.Helper()
+	u8, ok := obs.(gym.Uint8Obs)
+	if !ok {
+		t.Fatalf("expected byte list observation but got %T", obs)
+	}
+	if !reflect.DeepEqual(u8.Uint8Obs(), expected) {
+		t.Errorf("expected pixels %v but got %v", expected, u8.Uint8Obs())
+	}
+}
+
+func TestStepBlind(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	env, err := gym.Make("gymtest", "Count-v0", gym.WithDialer(server.Dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		obs, reward, done, info, err := gym.StepBlind(env, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reward != float64(i) || done != (i == 3) || info == nil {
+			t.Errorf("step %d: unexpected reward %f, done %v, info %v", i, reward, done, info)
+		}
+		if i < 3 && obs != nil {
+			t.Errorf("step %d: expected no observation", i)
+		} else if i == 3 {
+			checkObs(t, obs, []int{3})
+		}
+	}
+	if stats := env.Stats(); stats.Steps != 3 || stats.Episodes != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	repeated := wrappers.ActionRepeat(env, 2)
+	if _, err := repeated.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	obs, reward, _, _, err := repeated.Step(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkObs(t, obs, []int{2})
+	if reward != 3 {
+		t.Errorf("unexpected reward: %f", reward)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	env, err := gym.Make("gymtest", "Count-v0", gym.WithDialer(server.Dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	spec, err := gym.Describe(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := server.envs["Count-v0"]
+	if spec.ID != "Count-v0" || !reflect.DeepEqual(spec.ActionSpace, expected.ActionSpace) ||
+		!reflect.DeepEqual(spec.ObservationSpace, expected.ObservationSpace) {
+		t.Errorf("unexpected spec: %+v", spec)
+	}
+
+	// The spaces come from the cache.
+	sent := env.Stats().BytesSent
+	space, err := env.ObservationSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	space.High[0] = 100
+	if space, err = env.ObservationSpace(); err != nil {
+		t.Fatal(err)
+	} else if space.High[0] != 3 {
+		t.Error("cached space was modified")
+	}
+	if env.Stats().BytesSent != sent {
+		t.Error("expected cached spaces")
+	}
+
+	// Configure clears the cache, even though it fails.
+	env.Configure(nil)
+	sent = env.Stats().BytesSent
+	if _, err := env.ActionSpace(); err != nil {
+		t.Fatal(err)
+	}
+	if env.Stats().BytesSent == sent {
+		t.Error("expected spaces to be queried after Configure")
+	}
+}
+
+func TestSpaceCache(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	env, err := gym.Make("gymtest", "Count-v0", gym.WithDialer(server.Dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	if _, err := env.ActionSpace(); err != nil {
+		t.Fatal(err)
+	}
+	sent := env.Stats().BytesSent
+	space, err := env.ActionSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Stats().BytesSent != sent {
+		t.Error("expected a cached action space")
+	}
+	if space.N != 3 {
+		t.Errorf("unexpected space: %+v", space)
+	}
+
+	env.RetroWrap("discretizer", nil)
+	sent = env.Stats().BytesSent
+	if _, err := env.ActionSpace(); err != nil {
+		t.Fatal(err)
+	}
+	if env.Stats().BytesSent == sent {
+		t.Error("expected the space to be queried after RetroWrap")
+	}
+}
+
+func TestLease(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	lease := time.Millisecond * 200
+	env, err := gym.Make("gymtest", "Count-v0", gym.WithDialer(server.Dial),
+		gym.WithLease(lease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	res, err := env.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status.LeaseTTL != lease.Seconds() {
+		t.Errorf("expected lease TTL %f but got %f", lease.Seconds(), res.Status.LeaseTTL)
+	}
+
+	// Renewing keeps the environment alive past the
+	// original lease.
+	for i := 0; i < 5; i++ {
+		time.Sleep(lease / 4)
+		if err := env.Renew(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := env.Reset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(lease * 2)
+	if _, err := env.Reset(); err == nil {
+		t.Error("expected an error after the lease expired")
+	}
+}
+
+func TestSupports(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	env, err := gym.Make("gymtest", "Count-v0", gym.WithDialer(server.Dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	wrapped := wrappers.ActionRepeat(env, 2)
+	for feature, expected := range map[string]bool{
+		gym.FeatureStepBlind: true,
+		gym.FeatureDescribe:  true,
+		gym.FeatureRetro:     false,
+	} {
+		actual, err := gym.Supports(wrapped, feature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("feature %s: expected %v but got %v", feature, expected, actual)
+		}
+	}
+}
+
+func TestClientRedial(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	client := gym.NewClient("gymtest", gym.WithDialer(server.Dial))
+	defer client.Close()
+	env, err := client.Make("Count-v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, _, _, err := env.Step(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The failed command reports its error, and the next
+	// commands run on a new environment, since the fake
+	// server cannot resume sessions.
+	dropConns(server)
+	if _, _, _, _, err := env.Step(0); err == nil {
+		t.Fatal("expected an error from the dropped connection")
+	}
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkObs(t, obs, []int{0})
+	for i := 1; i <= 3; i++ {
+		obs, _, done, _, err := env.Step(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkObs(t, obs, []int{i})
+		if done != (i == 3) {
+			t.Errorf("step %d: unexpected done %v", i, done)
+		}
+	}
+
+	stats := env.Stats()
+	if stats.Steps != 6 || stats.Episodes != 1 || stats.LastError == nil {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if n := client.NumEnvs(); n != 1 {
+		t.Errorf("expected 1 environment but got %d", n)
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	client := gym.NewClient("gymtest", gym.WithDialer(server.Dial))
+	env, err := client.Make("Count-v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	dropConns(server)
+	if err := env.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	obs, _, _, _, err := env.Step(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkObs(t, obs, []int{1})
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if client.NumEnvs() != 0 {
+		t.Error("expected no environments after closing the client")
+	}
+	if _, err := env.Reset(); err == nil {
+		t.Error("expected an error after closing the client")
+	}
+	if _, err := client.Make("Count-v0"); err == nil {
+		t.Error("expected an error from a closed client")
+	}
+}
+
+// dropConns closes the server's end of every connection,
+// as if the network failed.
+func dropConns(s *Server) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
@@ -0,0 +1,364 @@
+package gymtest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// Packet types, as listed in the protocol documentation.
+const (
+	packetReset = iota
+	packetStep
+	packetGetSpace
+	packetSampleAction
+	packetMonitor
+	packetRender
+	packetUpload
+	packetUniverseConfigure
+	packetUniverseWrap
+	packetRetroConfigure
+	packetRetroWrap
+	packetResetBatch
+	packetStepBatch
+	packetPing
+	packetKeepAlive
+	packetConfigure
+	packetSetLogLevel
+	packetEndSession
+	packetCloneState
+	packetRestoreState
+	packetListEnvs
+	packetSpec
+	packetGetAttr
+	packetCallMethod
+	packetRetroListGames
+	packetRetroListStates
+	packetRetroSaveState
+	packetRetroLoadState
+	packetRetroVariables
+	packetRetroReadRAM
+	packetRetroStartMovie
+	packetRetroStopMovie
+	packetRetroUploadIntegration
+	packetUniverseAllocateRemotes
+	packetUniverseListRemotes
+	packetUniverseReleaseRemotes
+	packetStepBlind
+	packetDescribe
+	packetStepMulti
+	packetRenderText
+	packetScreenshot
+	packetRenewLease
+)
+
+// Handshake flags.
+const (
+	flagBatch = 1 << iota
+	flagAutoReset
+	flagMulti
+	flagStats
+	flagSession
+	flagResume
+	flagBinaryActions
+	flagAuth
+)
+
+const (
+	observationJSON = iota
+	observationByteList
+	observationNone
+	observationError = 0xff
+)
+
+const (
+	actionJSON = iota
+	actionDiscrete
+	actionBox
+)
+
+// unsupportedPackets lists the fields of the packets which
+// the fake server answers with an unsupported error.
+//
+// Each byte of a layout is a field: 'b' for a bool, 'u'
+// for a uint32, 'f' for a length-prefixed field, and 'n'
+// for a uint32 count followed by that many pairs of
+// length-prefixed fields.
+var unsupportedPackets = map[byte]string{
+	packetMonitor:                 "bbbf",
+	packetUpload:                  "fff",
+	packetConfigure:               "f",
+	packetUniverseConfigure:       "f",
+	packetUniverseWrap:            "ff",
+	packetRetroConfigure:          "f",
+	packetRetroWrap:               "ff",
+	packetCloneState:              "",
+	packetRestoreState:            "f",
+	packetRetroListGames:          "",
+	packetRetroListStates:         "f",
+	packetRetroSaveState:          "",
+	packetRetroLoadState:          "f",
+	packetRetroVariables:          "",
+	packetRetroReadRAM:            "uu",
+	packetRetroStartMovie:         "f",
+	packetRetroStopMovie:          "",
+	packetRetroUploadIntegration:  "fn",
+	packetUniverseAllocateRemotes: "uf",
+	packetUniverseListRemotes:     "",
+	packetUniverseReleaseRemotes:  "f",
+	packetStepMulti:               "f",
+	packetRenderText:              "",
+	packetScreenshot:              "",
+}
+
+// maxFieldSize limits the size of fields from clients.
+const maxFieldSize = 1 << 28
+
+var byteOrder = binary.LittleEndian
+
+func readByte(r io.Reader) (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r, b[:])
+	return b[0], err
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var x uint32
+	err := binary.Read(r, byteOrder, &x)
+	return x, err
+}
+
+func readFloat64(r io.Reader) (float64, error) {
+	var x float64
+	err := binary.Read(r, byteOrder, &x)
+	return x, err
+}
+
+func readField(r io.Reader) ([]byte, error) {
+	length, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxFieldSize {
+		return nil, fmt.Errorf("field is too long (%d bytes)", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// skipFields reads and discards fields with a layout from
+// unsupportedPackets.
+func skipFields(r io.Reader, layout string) error {
+	for _, kind := range layout {
+		var err error
+		switch kind {
+		case 'b':
+			_, err = readByte(r)
+		case 'u':
+			_, err = readUint32(r)
+		case 'f':
+			_, err = readField(r)
+		case 'n':
+			var count uint32
+			count, err = readUint32(r)
+			for i := uint32(0); i < count && err == nil; i++ {
+				err = skipFields(r, "ff")
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readAction reads an action in any format and converts it
+// to JSON.
+func readAction(r io.Reader) (json.RawMessage, error) {
+	actionType, err := readByte(r)
+	if err != nil {
+		return nil, err
+	}
+	switch actionType {
+	case actionJSON:
+		data, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(data) {
+			return nil, errors.New("invalid action JSON")
+		}
+		return data, nil
+	case actionDiscrete:
+		var action uint64
+		for shift := uint(0); ; shift += 7 {
+			b, err := readByte(r)
+			if err != nil {
+				return nil, err
+			}
+			if shift > 63 {
+				return nil, errors.New("discrete action is too large")
+			}
+			action |= uint64(b&0x7f) << shift
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		return json.RawMessage(strconv.FormatUint(action, 10)), nil
+	case actionBox:
+		size, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		if size > maxFieldSize/8 {
+			return nil, fmt.Errorf("box action is too long (%d values)", size)
+		}
+		values := make([]float64, size)
+		if err := binary.Read(r, byteOrder, values); err != nil {
+			return nil, err
+		}
+		return json.Marshal(values)
+	default:
+		return nil, fmt.Errorf("unknown action type: %d", actionType)
+	}
+}
+
+func writeByte(w io.Writer, b byte) error {
+	_, err := w.Write([]byte{b})
+	return err
+}
+
+func writeUint32(w io.Writer, x uint32) error {
+	return binary.Write(w, byteOrder, x)
+}
+
+func writeField(w io.Writer, data []byte) error {
+	if err := writeUint32(w, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// writeJSON writes a value as a JSON field.
+func writeJSON(w io.Writer, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return writeField(w, data)
+}
+
+// writeError writes an error field, which is empty if err
+// is nil.
+func writeError(w io.Writer, err error) error {
+	if err == nil {
+		return writeField(w, nil)
+	}
+	return writeJSON(w, envError(err))
+}
+
+func writeReward(w io.Writer, reward float64) error {
+	return binary.Write(w, byteOrder, math.Float64bits(reward))
+}
+
+func writeBool(w io.Writer, b bool) error {
+	if b {
+		return writeByte(w, 1)
+	}
+	return writeByte(w, 0)
+}
+
+// An encodedObs is an observation which is ready to send,
+// either as JSON or as a byte list.
+type encodedObs struct {
+	JSON json.RawMessage
+
+	Shape  []int
+	Values []uint8
+}
+
+// encodeObs encodes an observation.
+//
+// Observations which implement gym.Uint8Obs and
+// gym.ShapedObs, like those from gym.NewUint8Obs, are sent
+// as byte lists.
+// Other values are sent as JSON.
+func encodeObs(obs interface{}) (*encodedObs, error) {
+	if o, ok := obs.(gym.Obs); ok {
+		u8, isUint8 := o.(gym.Uint8Obs)
+		shaped, isShaped := o.(gym.ShapedObs)
+		if isUint8 && isShaped {
+			return &encodedObs{Shape: shaped.Shape(), Values: u8.Uint8Obs()}, nil
+		}
+	}
+	data, err := obsJSON(obs)
+	if err != nil {
+		return nil, err
+	}
+	return &encodedObs{JSON: data}, nil
+}
+
+func writeObs(w io.Writer, obs *encodedObs) error {
+	if obs.JSON != nil {
+		if err := writeByte(w, observationJSON); err != nil {
+			return err
+		}
+		return writeField(w, obs.JSON)
+	}
+	if err := writeByte(w, observationByteList); err != nil {
+		return err
+	}
+	if err := writeUint32(w, uint32(4+4*len(obs.Shape)+len(obs.Values))); err != nil {
+		return err
+	}
+	if err := writeUint32(w, uint32(len(obs.Shape))); err != nil {
+		return err
+	}
+	for _, dim := range obs.Shape {
+		if err := writeUint32(w, uint32(dim)); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(obs.Values)
+	return err
+}
+
+// writeObsError writes an error in place of an
+// observation.
+func writeObsError(w io.Writer, err error) error {
+	if err := writeByte(w, observationError); err != nil {
+		return err
+	}
+	return writeJSON(w, envError(err))
+}
+
+// obsJSON converts an observation to JSON, even if it is a
+// byte list.
+func obsJSON(obs interface{}) (json.RawMessage, error) {
+	if o, ok := obs.(gym.Obs); ok {
+		var data json.RawMessage
+		err := o.Unmarshal(&data)
+		return data, err
+	}
+	return json.Marshal(obs)
+}
+
+// envError converts an error to a gym.EnvError, using the
+// env_failed code for errors which do not have one.
+func envError(err error) *gym.EnvError {
+	var envErr *gym.EnvError
+	if errors.As(err, &envErr) {
+		return envErr
+	}
+	return &gym.EnvError{Code: gym.CodeEnvFailed, Message: err.Error()}
+}
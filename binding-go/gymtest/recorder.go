@@ -0,0 +1,236 @@
+package gymtest
+
+import (
+	"sync"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// A Call is a call to a method of a gym.Env.
+type Call struct {
+	// Method is the name of the method, such as "Step".
+	Method string
+
+	// Args are the arguments of the call, except for
+	// destinations which results are decoded into.
+	Args []interface{}
+
+	// Err is the error which the call returned.
+	Err error
+}
+
+// A CallRecorder is a gym.Env which records every call to
+// the Env it wraps, so that tests can check what an agent
+// did.
+// Calls to Stats are not recorded, since they do not act
+// on the environment.
+type CallRecorder struct {
+	gym.Wrapper
+
+	lock  sync.Mutex
+	calls []Call
+}
+
+// NewCallRecorder wraps an Env to record calls to it.
+func NewCallRecorder(env gym.Env) *CallRecorder {
+	return &CallRecorder{Wrapper: gym.Wrapper{Env: env}}
+}
+
+// Calls returns the calls so far, in the order they
+// finished.
+func (c *CallRecorder) Calls() []Call {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]Call{}, c.calls...)
+}
+
+// Methods returns the method names of the calls so far.
+func (c *CallRecorder) Methods() []string {
+	var res []string
+	for _, call := range c.Calls() {
+		res = append(res, call.Method)
+	}
+	return res
+}
+
+// Actions returns the actions passed to Step so far.
+func (c *CallRecorder) Actions() []interface{} {
+	var res []interface{}
+	for _, call := range c.Calls() {
+		if call.Method == "Step" {
+			res = append(res, call.Args[0])
+		}
+	}
+	return res
+}
+
+func (c *CallRecorder) record(method string, err error, args ...interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.calls = append(c.calls, Call{Method: method, Args: args, Err: err})
+}
+
+func (c *CallRecorder) Reset() (obs gym.Obs, err error) {
+	obs, err = c.Env.Reset()
+	c.record("Reset", err)
+	return
+}
+
+func (c *CallRecorder) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = c.Env.Step(action)
+	c.record("Step", err, action)
+	return
+}
+
+func (c *CallRecorder) ActionSpace() (space *gym.Space, err error) {
+	space, err = c.Env.ActionSpace()
+	c.record("ActionSpace", err)
+	return
+}
+
+func (c *CallRecorder) ObservationSpace() (space *gym.Space, err error) {
+	space, err = c.Env.ObservationSpace()
+	c.record("ObservationSpace", err)
+	return
+}
+
+func (c *CallRecorder) SampleAction(dst interface{}) (err error) {
+	err = c.Env.SampleAction(dst)
+	c.record("SampleAction", err)
+	return
+}
+
+func (c *CallRecorder) Monitor(dir string, force, resume, video bool) (err error) {
+	err = c.Env.Monitor(dir, force, resume, video)
+	c.record("Monitor", err, dir, force, resume, video)
+	return
+}
+
+func (c *CallRecorder) Render() (err error) {
+	err = c.Env.Render()
+	c.record("Render", err)
+	return
+}
+
+func (c *CallRecorder) Close() (err error) {
+	err = c.Env.Close()
+	c.record("Close", err)
+	return
+}
+
+func (c *CallRecorder) Configure(options map[string]interface{}) (err error) {
+	err = c.Env.Configure(options)
+	c.record("Configure", err, options)
+	return
+}
+
+func (c *CallRecorder) UniverseConfigure(options map[string]interface{}) (err error) {
+	err = c.Env.UniverseConfigure(options)
+	c.record("UniverseConfigure", err, options)
+	return
+}
+
+func (c *CallRecorder) UniverseWrap(wrapper string,
+	options map[string]interface{}) (err error) {
+	err = c.Env.UniverseWrap(wrapper, options)
+	c.record("UniverseWrap", err, wrapper, options)
+	return
+}
+
+func (c *CallRecorder) RetroConfigure(options map[string]interface{}) (err error) {
+	err = c.Env.RetroConfigure(options)
+	c.record("RetroConfigure", err, options)
+	return
+}
+
+func (c *CallRecorder) RetroWrap(wrapper string, options map[string]interface{}) (err error) {
+	err = c.Env.RetroWrap(wrapper, options)
+	c.record("RetroWrap", err, wrapper, options)
+	return
+}
+
+func (c *CallRecorder) RetroSaveState() (state []byte, err error) {
+	state, err = c.Env.RetroSaveState()
+	c.record("RetroSaveState", err)
+	return
+}
+
+func (c *CallRecorder) RetroLoadState(state []byte) (err error) {
+	err = c.Env.RetroLoadState(state)
+	c.record("RetroLoadState", err, state)
+	return
+}
+
+func (c *CallRecorder) RetroVariables() (variables map[string]int, err error) {
+	variables, err = c.Env.RetroVariables()
+	c.record("RetroVariables", err)
+	return
+}
+
+func (c *CallRecorder) RetroReadRAM(offset, size int) (data []byte, err error) {
+	data, err = c.Env.RetroReadRAM(offset, size)
+	c.record("RetroReadRAM", err, offset, size)
+	return
+}
+
+func (c *CallRecorder) RetroStartMovie(path string) (moviePath string, err error) {
+	moviePath, err = c.Env.RetroStartMovie(path)
+	c.record("RetroStartMovie", err, path)
+	return
+}
+
+func (c *CallRecorder) RetroStopMovie() (moviePath string, err error) {
+	moviePath, err = c.Env.RetroStopMovie()
+	c.record("RetroStopMovie", err)
+	return
+}
+
+func (c *CallRecorder) Ping() (res *gym.PingResult, err error) {
+	res, err = c.Env.Ping()
+	c.record("Ping", err)
+	return
+}
+
+func (c *CallRecorder) SetLogLevel(level string) (err error) {
+	err = c.Env.SetLogLevel(level)
+	c.record("SetLogLevel", err, level)
+	return
+}
+
+func (c *CallRecorder) KeepAlive() (err error) {
+	err = c.Env.KeepAlive()
+	c.record("KeepAlive", err)
+	return
+}
+
+func (c *CallRecorder) Reconnect() (err error) {
+	err = c.Env.Reconnect()
+	c.record("Reconnect", err)
+	return
+}
+
+func (c *CallRecorder) CloneState() (state []byte, err error) {
+	state, err = c.Env.CloneState()
+	c.record("CloneState", err)
+	return
+}
+
+func (c *CallRecorder) RestoreState(state []byte) (err error) {
+	err = c.Env.RestoreState(state)
+	c.record("RestoreState", err, state)
+	return
+}
+
+func (c *CallRecorder) GetAttr(name string, dst interface{}) (err error) {
+	err = c.Env.GetAttr(name, dst)
+	c.record("GetAttr", err, name)
+	return
+}
+
+func (c *CallRecorder) CallMethod(name string, dst interface{},
+	args ...interface{}) (err error) {
+	err = c.Env.CallMethod(name, dst, args...)
+	c.record("CallMethod", err, append([]interface{}{name}, args...)...)
+	return
+}
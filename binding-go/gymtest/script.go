@@ -0,0 +1,159 @@
+package gymtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// errNoEnv is the error for environment commands on a
+// connection without an environment.
+var errNoEnv = &gym.EnvError{Code: gym.CodeUnsupported, Message: "connection has no environment"}
+
+// envState tracks the progress of an environment through
+// its script.
+type envState struct {
+	started bool
+	done    bool
+	t       int
+}
+
+func (s *envState) reset() {
+	*s = envState{started: true}
+}
+
+// step advances the environment through a script and
+// returns the scripted step, whose Done field is set if it
+// ends the episode.
+func (s *envState) step(script *Env) (Step, error) {
+	if !s.started {
+		return Step{}, errors.New("environment must be reset before stepping")
+	} else if len(script.Steps) == 0 {
+		return Step{}, errors.New("environment has no scripted steps")
+	} else if s.done {
+		return Step{}, errors.New("episode is done; the environment must be reset")
+	}
+	step := script.Steps[s.t]
+	s.t++
+	s.done = step.Done || s.t == len(script.Steps)
+	step.Done = s.done
+	return step, nil
+}
+
+// attr looks up an attribute for GetAttr.
+func (e *Env) attr(name string) (interface{}, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	value, ok := e.Attrs[name]
+	if !ok {
+		return nil, noSuchAttr(name)
+	}
+	return value, nil
+}
+
+// call calls a method for CallMethod.
+func (e *Env) call(name string, args []json.RawMessage) (interface{}, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	method, ok := e.Methods[name]
+	if !ok {
+		return nil, noSuchAttr(name)
+	}
+	return method(args)
+}
+
+// checkName checks that an attribute name is not private,
+// like the server does.
+func checkName(name string) error {
+	if name == "" || strings.HasPrefix(name, "_") {
+		return &gym.EnvError{Code: gym.CodeInvalidArgument,
+			Message: fmt.Sprintf("invalid attribute name: %q", name)}
+	}
+	return nil
+}
+
+func noSuchAttr(name string) error {
+	return &gym.EnvError{Code: gym.CodeInvalidArgument, Message: "no such attribute: " + name}
+}
+
+// sample samples a random element of a space.
+func sample(space *gym.Space) (interface{}, error) {
+	switch space.Type {
+	case "Discrete":
+		if space.N <= 0 {
+			return nil, errors.New("empty Discrete space")
+		}
+		return rand.Intn(space.N), nil
+	case "MultiBinary":
+		res := make([]int, space.N)
+		for i := range res {
+			res[i] = rand.Intn(2)
+		}
+		return res, nil
+	case "MultiDiscrete":
+		res := make([]int, len(space.Low))
+		for i, low := range space.Low {
+			res[i] = int(low) + rand.Intn(int(space.High[i]-low)+1)
+		}
+		return res, nil
+	case "Box":
+		values := make([]float64, len(space.Low))
+		for i, low := range space.Low {
+			values[i] = sampleInterval(low, space.High[i])
+		}
+		return reshape(values, space.Shape), nil
+	case "Tuple":
+		var res []interface{}
+		for _, subspace := range space.Subspaces {
+			x, err := sample(subspace)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, x)
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("cannot sample %s space", space.Type)
+}
+
+// unbounded is the magnitude at which the server clips the
+// bounds of Box spaces, since JSON cannot encode infinity.
+const unbounded = 1e30
+
+// sampleInterval samples uniformly from a bounded interval,
+// or from a normal distribution shifted into an unbounded
+// one.
+func sampleInterval(low, high float64) float64 {
+	lowInf, highInf := low <= -unbounded, high >= unbounded
+	switch {
+	case !lowInf && !highInf:
+		return low + rand.Float64()*(high-low)
+	case lowInf && highInf:
+		return rand.NormFloat64()
+	case lowInf:
+		return high - math.Abs(rand.NormFloat64())
+	default:
+		return low + math.Abs(rand.NormFloat64())
+	}
+}
+
+// reshape nests a flat, row-major slice according to a
+// shape.
+func reshape(values []float64, shape []int) interface{} {
+	if len(shape) <= 1 {
+		return values
+	}
+	stride := len(values) / shape[0]
+	res := make([]interface{}, shape[0])
+	for i := range res {
+		res[i] = reshape(values[i*stride:(i+1)*stride], shape[1:])
+	}
+	return res
+}
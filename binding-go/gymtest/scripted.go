@@ -0,0 +1,200 @@
+package gymtest
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+type scriptedEnv struct {
+	gym.UnsupportedUniverse
+	gym.UnsupportedRetro
+
+	script *Env
+
+	lock     sync.Mutex
+	state    envState
+	steps    int64
+	episodes int64
+}
+
+// NewScriptedEnv creates a gym.Env which follows a script
+// directly, without a server.
+//
+// Results look like they came from a server: byte list
+// observations, like those from gym.NewUint8Obs, are
+// returned as is, and other observations and info objects
+// go through JSON.
+// Errors also match the same sentinels, such as
+// gym.ErrEnvFailed for stepping an environment which has
+// not been reset.
+//
+// Commands which the script cannot answer, such as
+// CloneState or Retro commands, fail with
+// gym.ErrUnsupported.
+func NewScriptedEnv(script *Env) gym.Env {
+	return &scriptedEnv{script: script}
+}
+
+func (s *scriptedEnv) Reset() (obs gym.Obs, err error) {
+	defer essentials.AddCtxTo("reset environment", &err)
+	s.lock.Lock()
+	s.state.reset()
+	s.lock.Unlock()
+	return toObs(s.script.InitialObs)
+}
+
+func (s *scriptedEnv) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	defer essentials.AddCtxTo("step environment", &err)
+	if _, err := json.Marshal(action); err != nil {
+		return nil, 0, false, nil, err
+	}
+	s.lock.Lock()
+	step, err := s.state.step(s.script)
+	s.steps++
+	if err == nil && step.Done {
+		s.episodes++
+	}
+	s.lock.Unlock()
+	if err != nil {
+		return nil, 0, false, nil, envError(err)
+	}
+	if obs, err = toObs(step.Obs); err != nil {
+		return nil, 0, false, nil, err
+	}
+	info = map[string]interface{}{}
+	if step.Info != nil {
+		if err := roundTrip(step.Info, &info); err != nil {
+			return nil, 0, false, nil, err
+		}
+	}
+	return obs, step.Reward, step.Done, info, nil
+}
+
+func (s *scriptedEnv) ActionSpace() (*gym.Space, error) {
+	return s.script.ActionSpace, nil
+}
+
+func (s *scriptedEnv) ObservationSpace() (*gym.Space, error) {
+	return s.script.ObservationSpace, nil
+}
+
+func (s *scriptedEnv) SampleAction(dst interface{}) (err error) {
+	defer essentials.AddCtxTo("sample action", &err)
+	if s.script.ActionSpace == nil {
+		return unsupported("sample action")
+	}
+	action, err := sample(s.script.ActionSpace)
+	if err != nil {
+		return err
+	}
+	return roundTrip(action, dst)
+}
+
+func (s *scriptedEnv) GetAttr(name string, dst interface{}) (err error) {
+	defer essentials.AddCtxTo("get environment attribute "+name, &err)
+	value, err := s.script.attr(name)
+	if err != nil {
+		return err
+	}
+	return roundTrip(value, dst)
+}
+
+func (s *scriptedEnv) CallMethod(name string, dst interface{},
+	args ...interface{}) (err error) {
+	defer essentials.AddCtxTo("call environment method "+name, &err)
+	var rawArgs []json.RawMessage
+	for _, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			return err
+		}
+		rawArgs = append(rawArgs, data)
+	}
+	result, err := s.script.call(name, rawArgs)
+	if err != nil {
+		return envError(err)
+	}
+	return roundTrip(result, dst)
+}
+
+func (s *scriptedEnv) Ping() (*gym.PingResult, error) {
+	return &gym.PingResult{Status: gym.ServerStatus{PID: os.Getpid()}}, nil
+}
+
+// Stats counts steps and episodes, since nothing is sent
+// to a server.
+func (s *scriptedEnv) Stats() *gym.EnvStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return &gym.EnvStats{Steps: s.steps, Episodes: s.episodes}
+}
+
+func (s *scriptedEnv) Monitor(dir string, force, resume, video bool) error {
+	return unsupported("monitor")
+}
+
+func (s *scriptedEnv) Render() error {
+	return nil
+}
+
+func (s *scriptedEnv) Configure(options map[string]interface{}) error {
+	return unsupported("configure")
+}
+
+func (s *scriptedEnv) SetLogLevel(level string) error {
+	return nil
+}
+
+func (s *scriptedEnv) KeepAlive() error {
+	return nil
+}
+
+func (s *scriptedEnv) Renew() error {
+	return nil
+}
+
+func (s *scriptedEnv) Reconnect() error {
+	return nil
+}
+
+func (s *scriptedEnv) CloneState() ([]byte, error) {
+	return nil, unsupported("clone state")
+}
+
+func (s *scriptedEnv) RestoreState(state []byte) error {
+	return unsupported("restore state")
+}
+
+func (s *scriptedEnv) Close() error {
+	return nil
+}
+
+// toObs converts a scripted observation to a gym.Obs.
+func toObs(obs interface{}) (gym.Obs, error) {
+	if o, ok := obs.(gym.Obs); ok {
+		return o, nil
+	}
+	return gym.NewJSONObs(obs)
+}
+
+// roundTrip copies a value into dst through JSON, or does
+// nothing if dst is nil.
+func roundTrip(value, dst interface{}) error {
+	if dst == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func unsupported(op string) error {
+	return essentials.AddCtx(op+" (scripted environment)", gym.ErrUnsupported)
+}
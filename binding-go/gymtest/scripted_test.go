@@ -0,0 +1,67 @@
+package gymtest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+func TestScriptedEnv(t *testing.T) {
+	script := testServer().envs["Count-v0"]
+	env := NewCallRecorder(NewScriptedEnv(script))
+
+	if _, _, _, _, err := env.Step(0); !errors.Is(err, gym.ErrEnvFailed) {
+		t.Errorf("expected ErrEnvFailed before reset but got %v", err)
+	}
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkObs(t, obs, []int{0})
+	for i := 1; i <= 3; i++ {
+		obs, reward, done, info, err := env.Step(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkObs(t, obs, []int{i})
+		if reward != float64(i) || done != (i == 3) {
+			t.Errorf("step %d: unexpected reward %f and done %v", i, reward, done)
+		}
+		if i == 2 && !reflect.DeepEqual(info, map[string]interface{}{"lives": 1.0}) {
+			t.Errorf("unexpected info: %v", info)
+		}
+	}
+	var doubled float64
+	if err := env.CallMethod("double", &doubled, 4); err != nil {
+		t.Fatal(err)
+	} else if doubled != 8 {
+		t.Errorf("unexpected result: %f", doubled)
+	}
+	if err := env.GetAttr("missing", nil); !errors.Is(err, gym.ErrInvalidArgument) {
+		t.Errorf("unexpected error for missing attribute: %v", err)
+	}
+	if _, err := env.CloneState(); !errors.Is(err, gym.ErrUnsupported) {
+		t.Errorf("unexpected error for CloneState: %v", err)
+	}
+
+	expected := []string{"Step", "Reset", "Step", "Step", "Step", "CallMethod", "GetAttr",
+		"CloneState"}
+	if methods := env.Methods(); !reflect.DeepEqual(methods, expected) {
+		t.Errorf("expected calls %v but got %v", expected, methods)
+	}
+	if actions := env.Actions(); !reflect.DeepEqual(actions, []interface{}{0, 1, 2, 3}) {
+		t.Errorf("unexpected actions: %v", actions)
+	}
+	calls := env.Calls()
+	if calls[0].Err == nil || calls[1].Err != nil {
+		t.Error("unexpected errors in calls")
+	}
+	if !reflect.DeepEqual(calls[5].Args, []interface{}{"double", 4}) {
+		t.Errorf("unexpected arguments: %v", calls[5].Args)
+	}
+	if stats := env.Stats(); stats.Steps != 4 || stats.Episodes != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,315 @@
+package gymtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// transcriptLineSize is the number of bytes on each line
+// of a saved transcript.
+const transcriptLineSize = 32
+
+// A Chunk is a run of bytes sent in one direction on a
+// connection.
+type Chunk struct {
+	// FromServer is set for bytes which the server sent,
+	// and unset for bytes which the client sent.
+	FromServer bool
+
+	Data []byte
+}
+
+// A Transcript is the raw traffic of a sequence of
+// connections to a server, such as those of a session
+// which reconnects.
+//
+// Transcripts are saved as text, so that golden files can
+// be reviewed and diffed.
+// Each connection starts with a "connection" line, which is
+// followed by lines of hex bytes prefixed with "C" for the
+// client or "S" for the server.
+// Blank lines and lines starting with "#" are ignored.
+type Transcript struct {
+	Conns [][]Chunk
+}
+
+// ReadTranscript decodes a saved Transcript.
+func ReadTranscript(r io.Reader) (transcript *Transcript, err error) {
+	defer essentials.AddCtxTo("read transcript", &err)
+	transcript = &Transcript{}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		} else if line == "connection" {
+			transcript.Conns = append(transcript.Conns, nil)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || (fields[0] != "C" && fields[0] != "S") {
+			return nil, fmt.Errorf("line %d: invalid line", lineNum)
+		} else if len(transcript.Conns) == 0 {
+			return nil, fmt.Errorf("line %d: data before first connection", lineNum)
+		}
+		data, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		idx := len(transcript.Conns) - 1
+		transcript.Conns[idx] = appendChunk(transcript.Conns[idx], fields[0] == "S", data)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return transcript, nil
+}
+
+// LoadTranscript reads a Transcript from a file.
+func LoadTranscript(path string) (*Transcript, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, essentials.AddCtx("load transcript", err)
+	}
+	defer f.Close()
+	return ReadTranscript(f)
+}
+
+// Write encodes the Transcript.
+func (t *Transcript) Write(w io.Writer) (err error) {
+	defer essentials.AddCtxTo("write transcript", &err)
+	bw := bufio.NewWriter(w)
+	for _, chunks := range t.Conns {
+		fmt.Fprintln(bw, "connection")
+		for _, chunk := range chunks {
+			prefix := "C"
+			if chunk.FromServer {
+				prefix = "S"
+			}
+			for i := 0; i < len(chunk.Data); i += transcriptLineSize {
+				end := i + transcriptLineSize
+				if end > len(chunk.Data) {
+					end = len(chunk.Data)
+				}
+				fmt.Fprintln(bw, prefix, hex.EncodeToString(chunk.Data[i:end]))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// Save writes the Transcript to a file.
+func (t *Transcript) Save(path string) error {
+	var buf bytes.Buffer
+	if err := t.Write(&buf); err != nil {
+		return err
+	}
+	return essentials.AddCtx("save transcript", os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+// appendChunk adds data to the chunks of a connection,
+// merging it with the last chunk if it has the same
+// direction.
+func appendChunk(chunks []Chunk, fromServer bool, data []byte) []Chunk {
+	if n := len(chunks); n > 0 && chunks[n-1].FromServer == fromServer {
+		chunks[n-1].Data = append(chunks[n-1].Data, data...)
+		return chunks
+	}
+	return append(chunks, Chunk{FromServer: fromServer, Data: append([]byte{}, data...)})
+}
+
+// A TranscriptRecorder records the traffic of connections
+// into a Transcript.
+type TranscriptRecorder struct {
+	dial gym.DialFunc
+
+	lock       sync.Mutex
+	transcript Transcript
+}
+
+// NewTranscriptRecorder creates a TranscriptRecorder which
+// opens connections with dial, such as net.Dial or
+// Server.Dial.
+func NewTranscriptRecorder(dial gym.DialFunc) *TranscriptRecorder {
+	return &TranscriptRecorder{dial: dial}
+}
+
+// Dial opens and records a connection.
+//
+// It can be passed to gym.WithDialer.
+func (t *TranscriptRecorder) Dial(network, address string) (net.Conn, error) {
+	conn, err := t.dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.transcript.Conns = append(t.transcript.Conns, nil)
+	return &recordedConn{Conn: conn, recorder: t, index: len(t.transcript.Conns) - 1}, nil
+}
+
+// Transcript returns a copy of the traffic so far.
+func (t *TranscriptRecorder) Transcript() *Transcript {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	res := &Transcript{}
+	for _, chunks := range t.transcript.Conns {
+		var copied []Chunk
+		for _, chunk := range chunks {
+			copied = appendChunk(copied, chunk.FromServer, chunk.Data)
+		}
+		res.Conns = append(res.Conns, copied)
+	}
+	return res
+}
+
+func (t *TranscriptRecorder) add(index int, fromServer bool, data []byte) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.transcript.Conns[index] = appendChunk(t.transcript.Conns[index], fromServer, data)
+}
+
+type recordedConn struct {
+	net.Conn
+	recorder *TranscriptRecorder
+	index    int
+}
+
+func (r *recordedConn) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	if n > 0 {
+		r.recorder.add(r.index, true, p[:n])
+	}
+	return n, err
+}
+
+func (r *recordedConn) Write(p []byte) (int, error) {
+	n, err := r.Conn.Write(p)
+	if n > 0 {
+		r.recorder.add(r.index, false, p[:n])
+	}
+	return n, err
+}
+
+// A Replayer plays the server's side of a Transcript to a
+// client, and checks that the client sends exactly the
+// bytes which were recorded.
+//
+// This catches changes to the encoding of requests, and
+// checks that the client can still decode the recorded
+// responses.
+type Replayer struct {
+	transcript *Transcript
+
+	lock  sync.Mutex
+	next  int
+	conns []net.Conn
+	err   error
+
+	wg sync.WaitGroup
+}
+
+// NewReplayer creates a Replayer for a Transcript.
+func NewReplayer(transcript *Transcript) *Replayer {
+	return &Replayer{transcript: transcript}
+}
+
+// Dial opens the next connection of the transcript.
+//
+// It can be passed to gym.WithDialer.
+func (r *Replayer) Dial(network, address string) (net.Conn, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.next >= len(r.transcript.Conns) {
+		return nil, errors.New("gymtest: no more connections in transcript")
+	}
+	index := r.next
+	r.next++
+	client, server := net.Pipe()
+	r.conns = append(r.conns, server)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer server.Close()
+		if err := replayConn(server, r.transcript.Conns[index]); err != nil {
+			r.fail(fmt.Errorf("connection %d: %w", index, err))
+		}
+	}()
+	return client, nil
+}
+
+// Close closes the connections and checks that the client
+// made every connection in the transcript, and sent all
+// of the recorded bytes and nothing else.
+//
+// Clients should be closed first, since a connection which
+// is still open has not sent all of its bytes.
+func (r *Replayer) Close() error {
+	r.lock.Lock()
+	for _, conn := range r.conns {
+		conn.Close()
+	}
+	r.lock.Unlock()
+	r.wg.Wait()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return r.err
+	} else if r.next < len(r.transcript.Conns) {
+		return fmt.Errorf("gymtest: client made %d of %d connections",
+			r.next, len(r.transcript.Conns))
+	}
+	return nil
+}
+
+func (r *Replayer) fail(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err == nil {
+		r.err = essentials.AddCtx("gymtest: replay", err)
+	}
+}
+
+// replayConn plays a connection's chunks to the client and
+// returns the first difference from the transcript.
+func replayConn(conn net.Conn, chunks []Chunk) error {
+	var offset int
+	for _, chunk := range chunks {
+		if chunk.FromServer {
+			if _, err := conn.Write(chunk.Data); err != nil {
+				return fmt.Errorf("client stopped reading: %w", err)
+			}
+			continue
+		}
+		actual := make([]byte, len(chunk.Data))
+		n, err := io.ReadFull(conn, actual)
+		for i := 0; i < n; i++ {
+			if actual[i] != chunk.Data[i] {
+				return fmt.Errorf("client sent byte 0x%02x at offset %d, expected 0x%02x",
+					actual[i], offset+i, chunk.Data[i])
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("client stopped sending at offset %d, expected %d more bytes",
+				offset+n, len(chunk.Data)-n)
+		}
+		offset += n
+	}
+	var extra [1]byte
+	if n, _ := conn.Read(extra[:]); n > 0 {
+		return fmt.Errorf("client sent unexpected byte 0x%02x at offset %d", extra[0], offset)
+	}
+	return nil
+}
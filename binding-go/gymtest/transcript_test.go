@@ -0,0 +1,124 @@
+package gymtest
+
+import (
+	"bytes"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+var updateTranscripts = flag.Bool("update", false, "rewrite golden transcripts")
+
+const countTranscript = "count.transcript"
+
+func TestTranscriptReplay(t *testing.T) {
+	path := filepath.Join("testdata", countTranscript)
+	if *updateTranscripts {
+		server := testServer()
+		defer server.Close()
+		recorder := NewTranscriptRecorder(server.Dial)
+		if err := runCountSession(recorder.Dial); err != nil {
+			t.Fatal(err)
+		}
+		if err := recorder.Transcript().Save(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transcript, err := LoadTranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := NewReplayer(transcript)
+	if err := runCountSession(replayer.Dial); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTranscriptRoundTrip(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+	recorder := NewTranscriptRecorder(server.Dial)
+	if err := runCountSession(recorder.Dial); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := recorder.Transcript().Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	transcript, err := ReadTranscript(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transcript.Conns) != 1 || len(transcript.Conns[0]) < 2 {
+		t.Fatalf("unexpected transcript: %+v", transcript)
+	}
+
+	replayer := NewReplayer(transcript)
+	if err := runCountSession(replayer.Dial); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTranscriptMismatch(t *testing.T) {
+	transcript, err := LoadTranscript(filepath.Join("testdata", countTranscript))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt the last byte the client sent before the
+	// server's last response.
+	chunks := transcript.Conns[0]
+	for i := len(chunks) - 1; i >= 0; i-- {
+		if !chunks[i].FromServer && i+1 < len(chunks) {
+			chunks[i].Data[len(chunks[i].Data)-1] ^= 0xff
+			break
+		}
+	}
+	replayer := NewReplayer(transcript)
+	if err := runCountSession(replayer.Dial); err == nil {
+		t.Error("expected session to fail")
+	}
+	if err := replayer.Close(); err == nil || !strings.Contains(err.Error(), "client sent byte") {
+		t.Errorf("unexpected replay error: %v", err)
+	}
+
+	replayer = NewReplayer(&Transcript{})
+	if _, err := gym.Make("gymtest", "Count-v0", gym.WithDialer(replayer.Dial)); err == nil {
+		t.Error("expected dial to fail for empty transcript")
+	}
+}
+
+// runCountSession runs a fixed session on Count-v0, which
+// covers requests and responses with several encodings.
+func runCountSession(dial gym.DialFunc) error {
+	env, err := gym.Make("gymtest", "Count-v0", gym.WithDialer(dial))
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	if _, err := env.ActionSpace(); err != nil {
+		return err
+	}
+	if _, err := env.Reset(); err != nil {
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, _, _, err := env.Step(i); err != nil {
+			return err
+		}
+	}
+	var buttons []string
+	if err := env.GetAttr("buttons", &buttons); err != nil {
+		return err
+	}
+	var doubled float64
+	return env.CallMethod("double", &doubled, 1.5)
+}
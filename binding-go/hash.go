@@ -0,0 +1,177 @@
+package gym
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math/bits"
+
+	"github.com/unixpickle/essentials"
+)
+
+// An ObsHasher is an observation which can hash itself
+// without being decoded.
+//
+// Observations from an Env implement ObsHasher.
+type ObsHasher interface {
+	// Hash computes a 64-bit xxHash (XXH64) of the
+	// observation's canonical byte representation.
+	Hash() uint64
+}
+
+// HashObs hashes an observation, for things like
+// count-based exploration, deduplicating replay entries,
+// or archives of visited states.
+//
+// Observations which implement ObsHasher hash themselves.
+// Other observations are hashed like JSON observations.
+// Byte list observations are hashed as their shape and raw
+// bytes, so they never hash to the same value as an
+// equivalent JSON observation.
+func HashObs(obs Obs) (uint64, error) {
+	if h, ok := obs.(ObsHasher); ok {
+		return h.Hash(), nil
+	}
+	var obj interface{}
+	if err := obs.Unmarshal(&obj); err != nil {
+		return 0, essentials.AddCtx("hash observation", err)
+	}
+	return hashJSONObject(obj), nil
+}
+
+// Hash hashes the canonical form of the JSON, in which
+// numbers are formatted by Go and object keys are sorted.
+// Invalid JSON is hashed as is.
+func (j jsonObs) Hash() uint64 {
+	var obj interface{}
+	if err := json.Unmarshal(j, &obj); err != nil {
+		return hashBytes(hashTagJSON, j)
+	}
+	return hashJSONObject(obj)
+}
+
+// Hash hashes the shape and values of the observation.
+func (u *uint8Obs) Hash() uint64 {
+	var d xxhash64
+	d.reset()
+	d.write([]byte{hashTagUint8})
+	var header [4]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(u.Dims)))
+	d.write(header[:])
+	for _, dim := range u.Dims {
+		binary.LittleEndian.PutUint32(header[:], uint32(dim))
+		d.write(header[:])
+	}
+	d.write(u.Values)
+	return d.sum()
+}
+
+// Tags which distinguish the kinds of hashed data.
+const (
+	hashTagJSON  = 0
+	hashTagUint8 = 1
+)
+
+func hashJSONObject(obj interface{}) uint64 {
+	// Go sorts map keys, so this encoding is canonical.
+	data, _ := json.Marshal(obj)
+	return hashBytes(hashTagJSON, data)
+}
+
+func hashBytes(tag byte, data []byte) uint64 {
+	var d xxhash64
+	d.reset()
+	d.write([]byte{tag})
+	d.write(data)
+	return d.sum()
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxhash64 is a streaming XXH64 digest with a seed of 0.
+type xxhash64 struct {
+	v     [4]uint64
+	total uint64
+	buf   [32]byte
+	n     int
+}
+
+func (x *xxhash64) reset() {
+	// Constant arithmetic cannot wrap, so use variables.
+	p1, p2 := xxPrime1, xxPrime2
+	x.v = [4]uint64{p1 + p2, p2, 0, -p1}
+	x.total = 0
+	x.n = 0
+}
+
+func (x *xxhash64) write(data []byte) {
+	x.total += uint64(len(data))
+	if x.n+len(data) < 32 {
+		x.n += copy(x.buf[x.n:], data)
+		return
+	}
+	if x.n > 0 {
+		c := copy(x.buf[x.n:], data)
+		x.blocks(x.buf[:])
+		data = data[c:]
+		x.n = 0
+	}
+	full := len(data) - len(data)%32
+	x.blocks(data[:full])
+	x.n = copy(x.buf[:], data[full:])
+}
+
+func (x *xxhash64) blocks(data []byte) {
+	for ; len(data) >= 32; data = data[32:] {
+		for i := range x.v {
+			x.v[i] = xxRound(x.v[i], binary.LittleEndian.Uint64(data[i*8:]))
+		}
+	}
+}
+
+func (x *xxhash64) sum() uint64 {
+	var h uint64
+	if x.total >= 32 {
+		h = bits.RotateLeft64(x.v[0], 1) + bits.RotateLeft64(x.v[1], 7) +
+			bits.RotateLeft64(x.v[2], 12) + bits.RotateLeft64(x.v[3], 18)
+		for _, v := range x.v {
+			h = (h^xxRound(0, v))*xxPrime1 + xxPrime4
+		}
+	} else {
+		h = xxPrime5
+	}
+	h += x.total
+
+	data := x.buf[:x.n]
+	for ; len(data) >= 8; data = data[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(data))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(data) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(data)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		data = data[4:]
+	}
+	for _, b := range data {
+		h ^= uint64(b) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
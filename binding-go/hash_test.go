@@ -0,0 +1,89 @@
+package gym
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestXXHash64(t *testing.T) {
+	long := strings.Repeat("0123456789abcdef", 10)
+	tests := []struct {
+		Input    string
+		Expected uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+	}
+	for _, test := range tests {
+		var d xxhash64
+		d.reset()
+		d.write([]byte(test.Input))
+		if actual := d.sum(); actual != test.Expected {
+			t.Errorf("input %q: expected %x but got %x", test.Input, test.Expected, actual)
+		}
+	}
+
+	// Streaming writes must match a single write.
+	var whole xxhash64
+	whole.reset()
+	whole.write([]byte(long))
+	for _, chunk := range []int{1, 5, 31, 32, 33} {
+		var d xxhash64
+		d.reset()
+		for i := 0; i < len(long); i += chunk {
+			end := i + chunk
+			if end > len(long) {
+				end = len(long)
+			}
+			d.write([]byte(long[i:end]))
+		}
+		if d.sum() != whole.sum() {
+			t.Errorf("chunk size %d: hash mismatch", chunk)
+		}
+	}
+}
+
+func TestHashObs(t *testing.T) {
+	obs1, _ := NewJSONObs(map[string]interface{}{"a": 1, "b": []float64{2, 3}})
+	obs2 := jsonObs(`{ "b": [2.0, 3], "a": 1 }`)
+	obs3, _ := NewJSONObs(map[string]interface{}{"a": 1, "b": []float64{2, 4}})
+	if hashOrFail(t, obs1) != hashOrFail(t, obs2) {
+		t.Error("equivalent JSON observations should have the same hash")
+	}
+	if hashOrFail(t, obs1) == hashOrFail(t, obs3) {
+		t.Error("different JSON observations should have different hashes")
+	}
+
+	pixels1 := NewUint8Obs([]int{2, 3}, []uint8{1, 2, 3, 4, 5, 6})
+	pixels2 := NewUint8Obs([]int{3, 2}, []uint8{1, 2, 3, 4, 5, 6})
+	pixels3 := NewUint8Obs([]int{2, 3}, []uint8{1, 2, 3, 4, 5, 6})
+	if hashOrFail(t, pixels1) == hashOrFail(t, pixels2) {
+		t.Error("observations with different shapes should have different hashes")
+	}
+	if hashOrFail(t, pixels1) != hashOrFail(t, pixels3) {
+		t.Error("equal byte list observations should have the same hash")
+	}
+
+	// Observations without a Hash method fall back to JSON.
+	plain := &plainObs{Obs: obs2}
+	if hashOrFail(t, plain) != hashOrFail(t, obs1) {
+		t.Error("fallback hash should match the JSON hash")
+	}
+}
+
+func hashOrFail(t *testing.T, obs Obs) uint64 {
+	h, err := HashObs(obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+type plainObs struct {
+	Obs Obs
+}
+
+func (p *plainObs) Unmarshal(dst interface{}) error {
+	return p.Obs.Unmarshal(dst)
+}
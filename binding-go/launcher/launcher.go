@@ -0,0 +1,378 @@
+// Package launcher starts gym-socket-api servers as child
+// processes, so that Go programs do not have to run and
+// coordinate a server by hand.
+package launcher
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// DefaultStartTimeout is the StartTimeout used when a
+// Config does not specify one.
+const DefaultStartTimeout = time.Second * 30
+
+// ServerDirEnvVar is the environment variable which can
+// point to the server's source directory.
+const ServerDirEnvVar = "GYM_SOCKET_API_DIR"
+
+// Config configures how a server is launched.
+//
+// The zero value is a valid configuration.
+type Config struct {
+	// ServerDir is the directory containing the server's
+	// Python source.
+	//
+	// If empty, the directory is read from ServerDirEnvVar,
+	// or found relative to this package's source.
+	ServerDir string
+
+	// UnixSocket is the path of a Unix domain socket for
+	// the server to listen on, instead of a free TCP port.
+	// This avoids the TCP stack, and keeps the server from
+	// being reached by other machines.
+	// The socket is removed when the server is closed.
+	UnixSocket string
+
+	// Args are extra command-line arguments for the server,
+	// such as "--retro" or "--universe".
+	Args []string
+
+	// Python is the Python interpreter to run, either as a
+	// path or as a name to look up in $PATH.
+	// If empty, "python3" or "python" is used.
+	Python string
+
+	// Virtualenv is the root directory of a virtualenv to
+	// run the server in.
+	// If set, the virtualenv's interpreter is used unless
+	// Python is also set.
+	Virtualenv string
+
+	// CondaEnv is the name or path of a conda environment
+	// to run the server in.
+	// If set, the environment's interpreter is used unless
+	// Python is also set.
+	CondaEnv string
+
+	// PythonPath contains extra directories to prepend to
+	// the server's PYTHONPATH, e.g. for a retro or
+	// universe checkout.
+	PythonPath []string
+
+	// StartTimeout is how long to wait for the server to
+	// start accepting connections.
+	// If 0, DefaultStartTimeout is used.
+	StartTimeout time.Duration
+
+	// Output receives the server's stdout and stderr.
+	// If nil, os.Stderr is used.
+	Output io.Writer
+}
+
+// A Server is an API server running as a child process.
+type Server struct {
+	// Host is the address of the server, which may be
+	// passed to gym.Make.
+	Host string
+
+	// socketPath is the server's Unix socket, if any.
+	socketPath string
+
+	cmd       *exec.Cmd
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Start launches a server on a free local port (or the
+// Unix socket in the config) and waits for it to accept
+// connections.
+//
+// The config may be nil.
+func Start(c *Config) (server *Server, err error) {
+	defer essentials.AddCtxTo("start server", &err)
+	if c == nil {
+		c = &Config{}
+	}
+	serverDir, err := findServerDir(c.ServerDir)
+	if err != nil {
+		return nil, err
+	}
+	python, env, err := pythonCommand(c)
+	if err != nil {
+		return nil, err
+	}
+	listenArgs, host, err := listenAddress(c)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append(append([]string{serverDir}, listenArgs...), c.Args...)
+	cmd := exec.Command(python, args...)
+	cmd.Env = env
+	cmd.Stdout = c.Output
+	cmd.Stderr = c.Output
+	if c.Output == nil {
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	server = &Server{
+		Host: host,
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+	if strings.HasPrefix(host, "unix://") {
+		server.socketPath = strings.TrimPrefix(host, "unix://")
+	}
+	go func() {
+		cmd.Wait()
+		close(server.done)
+	}()
+
+	timeout := c.StartTimeout
+	if timeout == 0 {
+		timeout = DefaultStartTimeout
+	}
+	if err := server.waitReady(timeout); err != nil {
+		server.Close()
+		return nil, err
+	}
+	return server, nil
+}
+
+// Make creates an environment on the server.
+func (s *Server) Make(envName string, opts ...gym.Option) (gym.Env, error) {
+	return gym.Make(s.Host, envName, opts...)
+}
+
+// Close stops the server.
+//
+// Environments on the server should be closed first.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		s.cmd.Process.Kill()
+		<-s.done
+		if s.socketPath != "" {
+			os.Remove(s.socketPath)
+		}
+	})
+	return nil
+}
+
+func (s *Server) waitReady(timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		network, addr := "tcp", s.Host
+		if s.socketPath != "" {
+			network, addr = "unix", s.socketPath
+		}
+		conn, err := net.Dial(network, addr)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-s.done:
+			return errors.New("server exited during startup")
+		case <-deadline:
+			return errors.New("timed out waiting for server")
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+}
+
+// Launch starts a dedicated server and creates an
+// environment on it.
+//
+// Closing the environment also stops the server.
+//
+// The config may be nil.
+func Launch(envName string, c *Config, opts ...gym.Option) (env gym.Env, err error) {
+	defer essentials.AddCtxTo("launch environment", &err)
+	server, err := Start(c)
+	if err != nil {
+		return nil, err
+	}
+	env, err = server.Make(envName, opts...)
+	if err != nil {
+		server.Close()
+		return nil, err
+	}
+	return &launchedEnv{Env: env, Server: server}, nil
+}
+
+type launchedEnv struct {
+	gym.Env
+	Server *Server
+}
+
+func (l *launchedEnv) Close() error {
+	err := l.Env.Close()
+	l.Server.Close()
+	return err
+}
+
+func findServerDir(dir string) (string, error) {
+	if dir == "" {
+		dir = os.Getenv(ServerDirEnvVar)
+	}
+	if dir == "" {
+		_, sourceFile, _, ok := runtime.Caller(0)
+		if !ok {
+			return "", errors.New("cannot locate server (set " + ServerDirEnvVar + ")")
+		}
+		dir = filepath.Join(filepath.Dir(sourceFile), "..", "..")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "__main__.py")); err != nil {
+		return "", errors.New("no server found in " + dir + " (set " +
+			ServerDirEnvVar + ")")
+	}
+	return filepath.Abs(dir)
+}
+
+// pythonCommand finds the interpreter and environment
+// variables for running the server.
+func pythonCommand(c *Config) (python string, env []string, err error) {
+	env = os.Environ()
+	var prefix string
+	if c.Virtualenv != "" {
+		prefix, err = filepath.Abs(c.Virtualenv)
+		if err != nil {
+			return "", nil, err
+		}
+		env = setEnv(env, "VIRTUAL_ENV", prefix)
+	} else if c.CondaEnv != "" {
+		prefix, err = condaPrefix(c.CondaEnv)
+		if err != nil {
+			return "", nil, err
+		}
+		env = setEnv(env, "CONDA_PREFIX", prefix)
+		env = setEnv(env, "CONDA_DEFAULT_ENV", filepath.Base(prefix))
+	}
+	if prefix != "" {
+		binDir := filepath.Join(prefix, "bin")
+		if runtime.GOOS == "windows" {
+			binDir = filepath.Join(prefix, "Scripts")
+		}
+		env = setEnv(env, "PATH", joinList(binDir, os.Getenv("PATH")))
+		if c.Python == "" {
+			python = filepath.Join(binDir, "python")
+			if runtime.GOOS == "windows" {
+				python += ".exe"
+			}
+		}
+	}
+	if len(c.PythonPath) > 0 {
+		paths := append(append([]string{}, c.PythonPath...), os.Getenv("PYTHONPATH"))
+		env = setEnv(env, "PYTHONPATH", joinList(paths...))
+	}
+
+	if c.Python != "" {
+		python, err = exec.LookPath(c.Python)
+	} else if python == "" {
+		python, err = findPython()
+	} else {
+		_, err = os.Stat(python)
+	}
+	return python, env, err
+}
+
+func findPython() (string, error) {
+	for _, name := range []string{"python3", "python"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("cannot find a Python interpreter")
+}
+
+// condaPrefix finds the root directory of a conda
+// environment given its name or path.
+func condaPrefix(nameOrPath string) (string, error) {
+	if strings.ContainsRune(nameOrPath, filepath.Separator) {
+		return filepath.Abs(nameOrPath)
+	}
+	output, err := exec.Command("conda", "env", "list", "--json").Output()
+	if err != nil {
+		return "", essentials.AddCtx("list conda environments", err)
+	}
+	var info struct {
+		Envs []string `json:"envs"`
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return "", essentials.AddCtx("list conda environments", err)
+	}
+	for _, prefix := range info.Envs {
+		if filepath.Base(prefix) == nameOrPath {
+			return prefix, nil
+		}
+	}
+	return "", errors.New("unknown conda environment: " + nameOrPath)
+}
+
+// setEnv sets a variable in a list of KEY=VALUE pairs.
+func setEnv(env []string, key, value string) []string {
+	var res []string
+	for _, pair := range env {
+		if !strings.HasPrefix(pair, key+"=") {
+			res = append(res, pair)
+		}
+	}
+	return append(res, key+"="+value)
+}
+
+// joinList joins non-empty path lists.
+func joinList(lists ...string) string {
+	var nonEmpty []string
+	for _, list := range lists {
+		if list != "" {
+			nonEmpty = append(nonEmpty, list)
+		}
+	}
+	return strings.Join(nonEmpty, string(filepath.ListSeparator))
+}
+
+// listenAddress picks the address for the server to
+// listen on, returning the server's arguments for it and
+// the address for clients.
+func listenAddress(c *Config) (args []string, host string, err error) {
+	if c.UnixSocket != "" {
+		path, err := filepath.Abs(c.UnixSocket)
+		if err != nil {
+			return nil, "", err
+		}
+		return []string{"--unix", path}, "unix://" + path, nil
+	}
+	port, err := freePort()
+	if err != nil {
+		return nil, "", err
+	}
+	portStr := strconv.Itoa(port)
+	return []string{"--port", portStr}, net.JoinHostPort("127.0.0.1", portStr), nil
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,281 @@
+package launcher
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFindServerDir(t *testing.T) {
+	serverDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(serverDir, "__main__.py"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	emptyDir := t.TempDir()
+	// The source is usually in a checkout of the server.
+	repoDir, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "__main__.py")); err != nil {
+		repoDir = ""
+	}
+
+	for _, test := range []struct {
+		Name     string
+		Dir      string
+		EnvVar   string
+		Expected string
+	}{
+		{"Explicit", serverDir, emptyDir, serverDir},
+		{"EnvVar", "", serverDir, serverDir},
+		{"Source", "", "", repoDir},
+		{"Missing", emptyDir, "", ""},
+		{"MissingEnvVar", "", emptyDir, ""},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			t.Setenv(ServerDirEnvVar, test.EnvVar)
+			dir, err := findServerDir(test.Dir)
+			if test.Expected == "" {
+				if err == nil {
+					t.Errorf("expected an error but got %s", dir)
+				}
+			} else if err != nil {
+				t.Error(err)
+			} else if dir != test.Expected {
+				t.Errorf("expected %s but got %s", test.Expected, dir)
+			}
+		})
+	}
+}
+
+func TestPythonCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stub commands are shell scripts")
+	}
+	sep := string(filepath.ListSeparator)
+
+	// Stub interpreters and conda live in the only
+	// directory on the PATH.
+	stubDir := t.TempDir()
+	venv := makePrefix(t)
+	condaEnv := makePrefix(t)
+	namedEnv := filepath.Join(t.TempDir(), "envs", "gymenv")
+	if err := os.MkdirAll(filepath.Join(namedEnv, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(namedEnv, "bin", "python"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeStub(t, stubDir, "python3", "")
+	writeStub(t, stubDir, "custom-python", "")
+	writeStub(t, stubDir, "conda", `echo '{"envs": ["/opt/conda", "`+namedEnv+`"]}'`)
+	t.Setenv("PATH", stubDir)
+	t.Setenv("PYTHONPATH", "/existing")
+	for _, key := range []string{"VIRTUAL_ENV", "CONDA_PREFIX", "CONDA_DEFAULT_ENV"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	for _, test := range []struct {
+		Name   string
+		Config Config
+
+		// Python is the expected interpreter, or empty if
+		// an error is expected.
+		Python string
+
+		// Env holds expected variables, where empty values
+		// mean that the variable is unset.
+		Env map[string]string
+	}{
+		{
+			Name:   "Default",
+			Python: filepath.Join(stubDir, "python3"),
+			Env: map[string]string{"PATH": stubDir, "PYTHONPATH": "/existing",
+				"VIRTUAL_ENV": "", "CONDA_PREFIX": ""},
+		},
+		{
+			Name:   "Python",
+			Config: Config{Python: "custom-python"},
+			Python: filepath.Join(stubDir, "custom-python"),
+			Env:    map[string]string{"PATH": stubDir, "VIRTUAL_ENV": ""},
+		},
+		{
+			Name:   "Virtualenv",
+			Config: Config{Virtualenv: venv},
+			Python: filepath.Join(venv, "bin", "python"),
+			Env: map[string]string{"PATH": filepath.Join(venv, "bin") + sep + stubDir,
+				"VIRTUAL_ENV": venv, "CONDA_PREFIX": ""},
+		},
+		{
+			Name:   "VirtualenvPython",
+			Config: Config{Virtualenv: venv, Python: "custom-python"},
+			Python: filepath.Join(stubDir, "custom-python"),
+			Env: map[string]string{"PATH": filepath.Join(venv, "bin") + sep + stubDir,
+				"VIRTUAL_ENV": venv},
+		},
+		{
+			Name:   "MissingVirtualenv",
+			Config: Config{Virtualenv: stubDir},
+		},
+		{
+			Name:   "CondaPath",
+			Config: Config{CondaEnv: condaEnv},
+			Python: filepath.Join(condaEnv, "bin", "python"),
+			Env: map[string]string{"PATH": filepath.Join(condaEnv, "bin") + sep + stubDir,
+				"CONDA_PREFIX": condaEnv, "CONDA_DEFAULT_ENV": filepath.Base(condaEnv),
+				"VIRTUAL_ENV": ""},
+		},
+		{
+			Name:   "CondaName",
+			Config: Config{CondaEnv: "gymenv"},
+			Python: filepath.Join(namedEnv, "bin", "python"),
+			Env: map[string]string{"PATH": filepath.Join(namedEnv, "bin") + sep + stubDir,
+				"CONDA_PREFIX": namedEnv, "CONDA_DEFAULT_ENV": "gymenv"},
+		},
+		{
+			Name:   "UnknownCondaName",
+			Config: Config{CondaEnv: "missing"},
+		},
+		{
+			Name:   "PythonPath",
+			Config: Config{PythonPath: []string{"/retro", "/universe"}},
+			Python: filepath.Join(stubDir, "python3"),
+			Env: map[string]string{"PATH": stubDir,
+				"PYTHONPATH": "/retro" + sep + "/universe" + sep + "/existing"},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			python, env, err := pythonCommand(&test.Config)
+			if test.Python == "" {
+				if err == nil {
+					t.Errorf("expected an error but got %s", python)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if python != test.Python {
+				t.Errorf("expected interpreter %s but got %s", test.Python, python)
+			}
+			for key, expected := range test.Env {
+				if actual := lookupEnv(env, key); actual != expected {
+					t.Errorf("expected %s=%q but got %q", key, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+// makePrefix creates the root of a fake Python
+// environment with an interpreter.
+func makePrefix(t *testing.T) string {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "python"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// writeStub creates an executable shell script.
+func writeStub(t *testing.T, dir, name, script string) {
+	data := []byte("#!/bin/sh\n" + script + "\n")
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lookupEnv finds a variable in a list of KEY=VALUE pairs,
+// or returns "" if it is missing.
+func lookupEnv(env []string, key string) string {
+	for _, pair := range env {
+		if strings.HasPrefix(pair, key+"=") {
+			return strings.TrimPrefix(pair, key+"=")
+		}
+	}
+	return ""
+}
+
+func TestSetEnv(t *testing.T) {
+	for _, test := range []struct {
+		Env      []string
+		Expected []string
+	}{
+		{nil, []string{"PATH=/bin"}},
+		{[]string{"HOME=/root"}, []string{"HOME=/root", "PATH=/bin"}},
+		{[]string{"PATH=/usr/bin", "HOME=/root"}, []string{"HOME=/root", "PATH=/bin"}},
+		{[]string{"PATHEXT=.exe"}, []string{"PATHEXT=.exe", "PATH=/bin"}},
+	} {
+		actual := setEnv(test.Env, "PATH", "/bin")
+		if !reflect.DeepEqual(actual, test.Expected) {
+			t.Errorf("setEnv(%v): expected %v but got %v", test.Env, test.Expected, actual)
+		}
+	}
+}
+
+func TestJoinList(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	for _, test := range []struct {
+		Lists    []string
+		Expected string
+	}{
+		{nil, ""},
+		{[]string{"", ""}, ""},
+		{[]string{"a"}, "a"},
+		{[]string{"a", "", "b" + sep + "c"}, "a" + sep + "b" + sep + "c"},
+	} {
+		if actual := joinList(test.Lists...); actual != test.Expected {
+			t.Errorf("joinList(%q): expected %q but got %q", test.Lists, test.Expected, actual)
+		}
+	}
+}
+
+func TestFreePort(t *testing.T) {
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("port %d is not free: %v", port, err)
+	}
+	listener.Close()
+}
+
+func TestListenAddress(t *testing.T) {
+	args, host, err := listenAddress(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 2 || args[0] != "--port" || host != "127.0.0.1:"+args[1] {
+		t.Errorf("unexpected TCP address: args %v, host %s", args, host)
+	}
+
+	socket := filepath.Join(t.TempDir(), "gym.sock")
+	args, host, err = listenAddress(&Config{UnixSocket: socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args, []string{"--unix", socket}) || host != "unix://"+socket {
+		t.Errorf("unexpected Unix address: args %v, host %s", args, host)
+	}
+
+	// Relative paths are made absolute, since the server
+	// may run in another directory.
+	_, host, err = listenAddress(&Config{UnixSocket: "gym.sock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := strings.TrimPrefix(host, "unix://"); !filepath.IsAbs(path) {
+		t.Errorf("expected an absolute path but got %s", host)
+	}
+}
@@ -0,0 +1,253 @@
+// Package metrics exports Prometheus metrics about
+// environments, such as throughput, episode returns, and
+// command latencies.
+//
+// A typical rollout worker looks like this:
+//
+//	m := metrics.New()
+//	prometheus.MustRegister(m)
+//	http.Handle("/metrics", promhttp.Handler())
+//	go http.ListenAndServe(":9090", nil)
+//
+//	env, err := gym.Make(host, "Pong-v0", m.Options("Pong-v0")...)
+//	...
+//	env = m.Wrap(env, "Pong-v0")
+//
+// Every metric has an "env" label, so that one Metrics can
+// be shared by many environments.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// Namespace prefixes the names of the metrics.
+const Namespace = "gym"
+
+// Metrics is a set of Prometheus collectors for
+// environments.
+//
+// Metrics is itself a prometheus.Collector, so it can be
+// registered all at once.
+type Metrics struct {
+	// Steps counts the steps taken, e.g. for steps/sec.
+	Steps *prometheus.CounterVec
+
+	// Episodes counts the finished episodes.
+	Episodes *prometheus.CounterVec
+
+	EpisodeReturn *prometheus.HistogramVec
+	EpisodeLength *prometheus.HistogramVec
+
+	// CommandLatency measures the time from sending each
+	// command to receiving its response, by op.
+	CommandLatency *prometheus.HistogramVec
+
+	// CommandErrors counts failed commands by op.
+	CommandErrors *prometheus.CounterVec
+
+	// CommandBytes counts the traffic of commands by op.
+	CommandBytes *prometheus.CounterVec
+
+	// Reconnects counts attempts to resume sessions, by
+	// result ("ok" or "failed").
+	Reconnects *prometheus.CounterVec
+}
+
+// New creates a set of unregistered metrics.
+func New() *Metrics {
+	return &Metrics{
+		Steps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "steps_total",
+			Help:      "Number of environment steps.",
+		}, []string{"env"}),
+		Episodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "episodes_total",
+			Help:      "Number of finished episodes.",
+		}, []string{"env"}),
+		EpisodeReturn: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "episode_return",
+			Help:      "Undiscounted return of finished episodes.",
+			Buckets:   []float64{-100, -10, -1, 0, 1, 10, 100, 1000, 10000},
+		}, []string{"env"}),
+		EpisodeLength: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "episode_length",
+			Help:      "Number of steps in finished episodes.",
+			Buckets:   prometheus.ExponentialBuckets(10, 4, 8),
+		}, []string{"env"}),
+		CommandLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "command_latency_seconds",
+			Help:      "Round-trip time of commands sent to the server.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"env", "op"}),
+		CommandErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "command_errors_total",
+			Help:      "Number of failed commands.",
+		}, []string{"env", "op"}),
+		CommandBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "command_bytes_total",
+			Help:      "Bytes sent and received for commands.",
+		}, []string{"env", "op"}),
+		Reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "reconnects_total",
+			Help:      "Number of attempts to resume a session.",
+		}, []string{"env", "result"}),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.Steps, m.Episodes, m.EpisodeReturn,
+		m.EpisodeLength, m.CommandLatency, m.CommandErrors, m.CommandBytes,
+		m.Reconnects}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// Handler serves the metrics in the Prometheus format,
+// using a private registry which holds only m.
+//
+// To serve other metrics as well, register m with a shared
+// registry and use promhttp instead.
+func (m *Metrics) Handler() http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m)
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+// Options returns the options that an environment needs
+// to report command latencies and reconnects.
+func (m *Metrics) Options(envName string) []gym.Option {
+	return []gym.Option{gym.WithTracer(m.Tracer(envName))}
+}
+
+// Tracer creates a gym.ReconnectTracer which reports the
+// commands of environments with the given name.
+func (m *Metrics) Tracer(envName string) gym.ReconnectTracer {
+	return &tracer{metrics: m, env: envName, starts: map[string][]time.Time{}}
+}
+
+// Wrap creates an environment which reports its steps and
+// episodes.
+func (m *Metrics) Wrap(env gym.Env, envName string) gym.Env {
+	return &metricsEnv{
+		Wrapper: gym.Wrapper{Env: env},
+		steps:   m.Steps.WithLabelValues(envName),
+		eps:     m.Episodes.WithLabelValues(envName),
+		returns: m.EpisodeReturn.WithLabelValues(envName),
+		lengths: m.EpisodeLength.WithLabelValues(envName),
+	}
+}
+
+type metricsEnv struct {
+	gym.Wrapper
+
+	steps   prometheus.Counter
+	eps     prometheus.Counter
+	returns prometheus.Observer
+	lengths prometheus.Observer
+
+	lock   sync.Mutex
+	ret    float64
+	length int
+}
+
+func (m *metricsEnv) Reset() (gym.Obs, error) {
+	obs, err := m.Env.Reset()
+	if err == nil {
+		m.lock.Lock()
+		m.ret, m.length = 0, 0
+		m.lock.Unlock()
+	}
+	return obs, err
+}
+
+func (m *metricsEnv) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = m.Env.Step(action)
+	if err != nil {
+		return
+	}
+	m.steps.Inc()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.ret += reward
+	m.length++
+	if done {
+		m.eps.Inc()
+		m.returns.Observe(m.ret)
+		m.lengths.Observe(float64(m.length))
+		m.ret, m.length = 0, 0
+	}
+	return
+}
+
+// tracer times commands by matching each End with the
+// oldest Begin of the same op, since the server answers
+// commands in order.
+type tracer struct {
+	metrics *Metrics
+	env     string
+
+	lock   sync.Mutex
+	starts map[string][]time.Time
+}
+
+func (t *tracer) Begin(op string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.starts[op] = append(t.starts[op], time.Now())
+}
+
+func (t *tracer) End(op string, err error, bytes int) {
+	t.lock.Lock()
+	starts := t.starts[op]
+	var start time.Time
+	if len(starts) > 0 {
+		start = starts[0]
+		t.starts[op] = starts[1:]
+	}
+	t.lock.Unlock()
+
+	if !start.IsZero() {
+		latency := time.Since(start).Seconds()
+		t.metrics.CommandLatency.WithLabelValues(t.env, op).Observe(latency)
+	}
+	if err != nil {
+		t.metrics.CommandErrors.WithLabelValues(t.env, op).Inc()
+	}
+	t.metrics.CommandBytes.WithLabelValues(t.env, op).Add(float64(bytes))
+}
+
+func (t *tracer) Reconnect(err error) {
+	result := "ok"
+	if err != nil {
+		result = "failed"
+	}
+	t.metrics.Reconnects.WithLabelValues(t.env, result).Inc()
+}
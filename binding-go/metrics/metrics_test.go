@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+type countEnv struct {
+	gym.Env
+	t int
+}
+
+func (c *countEnv) Reset() (gym.Obs, error) {
+	c.t = 0
+	return gym.NewJSONObs(c.t)
+}
+
+func (c *countEnv) Step(action interface{}) (gym.Obs, float64, bool, interface{}, error) {
+	c.t++
+	obs, _ := gym.NewJSONObs(c.t)
+	return obs, 2, c.t == 3, nil, nil
+}
+
+func TestWrap(t *testing.T) {
+	m := New()
+	env := m.Wrap(&countEnv{}, "Count-v0")
+	for i := 0; i < 2; i++ {
+		env.Reset()
+		for j := 0; j < 3; j++ {
+			env.Step(0)
+		}
+	}
+	if n := testutil.ToFloat64(m.Steps.WithLabelValues("Count-v0")); n != 6 {
+		t.Errorf("expected 6 steps but got %f", n)
+	}
+	if n := testutil.ToFloat64(m.Episodes.WithLabelValues("Count-v0")); n != 2 {
+		t.Errorf("expected 2 episodes but got %f", n)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	for _, expected := range []string{
+		`gym_episode_return_sum{env="Count-v0"} 12`,
+		`gym_episode_length_count{env="Count-v0"} 2`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("missing %q in:\n%s", expected, body)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	m := New()
+	tracer := m.Tracer("Count-v0")
+	tracer.Begin("step")
+	tracer.Begin("step")
+	tracer.End("step", nil, 10)
+	tracer.End("step", errors.New("failed"), 5)
+	tracer.Reconnect(nil)
+
+	if n := testutil.ToFloat64(m.CommandBytes.WithLabelValues("Count-v0", "step")); n != 15 {
+		t.Errorf("expected 15 bytes but got %f", n)
+	}
+	if n := testutil.ToFloat64(m.CommandErrors.WithLabelValues("Count-v0", "step")); n != 1 {
+		t.Errorf("expected 1 error but got %f", n)
+	}
+	if n := testutil.CollectAndCount(m.CommandLatency); n != 1 {
+		t.Errorf("expected 1 latency series but got %d", n)
+	}
+	if n := testutil.ToFloat64(m.Reconnects.WithLabelValues("Count-v0", "ok")); n != 1 {
+		t.Errorf("expected 1 reconnect but got %f", n)
+	}
+}
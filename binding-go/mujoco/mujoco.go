@@ -0,0 +1,44 @@
+// Package mujoco reads and sets the physics state of
+// MuJoCo environments, such as HalfCheetah-v2, for
+// model-based RL and reset-to-state experiments.
+//
+// It works with any environment which has the init_qpos
+// attribute and the state_vector and set_state methods of
+// Gym's MujocoEnv, using Env.GetAttr and Env.CallMethod.
+package mujoco
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// GetState gets the joint positions and velocities of a
+// MuJoCo environment.
+func GetState(env gym.Env) (qpos, qvel []float64, err error) {
+	defer essentials.AddCtxTo("get MuJoCo state", &err)
+	var initQpos []float64
+	if err := env.GetAttr("init_qpos", &initQpos); err != nil {
+		return nil, nil, err
+	}
+	var state []float64
+	if err := env.CallMethod("state_vector", &state); err != nil {
+		return nil, nil, err
+	}
+	if len(state) < len(initQpos) {
+		return nil, nil, fmt.Errorf("state vector has %d values but qpos has %d",
+			len(state), len(initQpos))
+	}
+	return state[:len(initQpos)], state[len(initQpos):], nil
+}
+
+// SetState sets the joint positions and velocities of a
+// MuJoCo environment.
+//
+// The observation from the last step is not updated, so
+// the next observation comes from the following step.
+func SetState(env gym.Env, qpos, qvel []float64) (err error) {
+	defer essentials.AddCtxTo("set MuJoCo state", &err)
+	return env.CallMethod("set_state", nil, qpos, qvel)
+}
@@ -0,0 +1,69 @@
+package mujoco
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// fakeEnv mimics the RPCs of a MujocoEnv with two joints.
+type fakeEnv struct {
+	gym.Env
+
+	qpos []float64
+	qvel []float64
+}
+
+func (f *fakeEnv) GetAttr(name string, dst interface{}) error {
+	if name != "init_qpos" {
+		return gym.ErrInvalidArgument
+	}
+	return roundTrip([]float64{0, 0}, dst)
+}
+
+func (f *fakeEnv) CallMethod(name string, dst interface{}, args ...interface{}) error {
+	switch name {
+	case "state_vector":
+		return roundTrip(append(append([]float64{}, f.qpos...), f.qvel...), dst)
+	case "set_state":
+		if len(args) != 2 {
+			return errors.New("expected two arguments")
+		}
+		f.qpos = args[0].([]float64)
+		f.qvel = args[1].([]float64)
+		return nil
+	}
+	return gym.ErrInvalidArgument
+}
+
+func roundTrip(value, dst interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func TestState(t *testing.T) {
+	env := &fakeEnv{qpos: []float64{1, 2}, qvel: []float64{3, 4}}
+	qpos, qvel, err := GetState(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(qpos, []float64{1, 2}) || !reflect.DeepEqual(qvel, []float64{3, 4}) {
+		t.Fatalf("unexpected state: %v %v", qpos, qvel)
+	}
+	if err := SetState(env, []float64{5, 6}, []float64{7, 8}); err != nil {
+		t.Fatal(err)
+	}
+	qpos, qvel, err = GetState(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(qpos, []float64{5, 6}) || !reflect.DeepEqual(qvel, []float64{7, 8}) {
+		t.Fatalf("unexpected state: %v %v", qpos, qvel)
+	}
+}
@@ -0,0 +1,95 @@
+package gym
+
+import (
+	"bufio"
+	"encoding/json"
+
+	"github.com/unixpickle/essentials"
+)
+
+// MultiStepResult is the result of a multi-agent step.
+// Each map is keyed by agent name.
+type MultiStepResult struct {
+	Observations map[string]Obs
+	Rewards      map[string]float64
+	Dones        map[string]bool
+	Infos        map[string]interface{}
+
+	// Agents are the agents which are still active after
+	// the step.
+	Agents []string
+}
+
+// A MultiAgentStepper is an Env which can step every agent
+// of a multi-agent environment at once, such as a
+// PettingZoo environment.
+//
+// Environments created by Make and Client implement
+// MultiAgentStepper, although the server only supports it
+// for multi-agent environments.
+type MultiAgentStepper interface {
+	// StepMulti takes a step with a map from agent names
+	// to actions.
+	//
+	// Agents which are missing from the map take no
+	// action.
+	StepMulti(actions map[string]interface{}) (*MultiStepResult, error)
+}
+
+// StepMulti takes a step in a multi-agent environment with
+// one action per agent.
+//
+// If env is not a MultiAgentStepper, this fails with
+// ErrUnsupported.
+// Wrappers are not unwrapped, since they cannot see the
+// per-agent results.
+func StepMulti(env Env, actions map[string]interface{}) (*MultiStepResult, error) {
+	if m, ok := env.(MultiAgentStepper); ok {
+		return m.StepMulti(actions)
+	}
+	return nil, unsupported("multi-agent step")
+}
+
+func (c *connEnv) StepMulti(actions map[string]interface{}) (result *MultiStepResult,
+	err error) {
+	defer essentials.AddCtxTo("multi-agent step", &err)
+	if actions == nil {
+		actions = map[string]interface{}{}
+	}
+	actionsData, err := json.Marshal(actions)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Observations map[string]json.RawMessage `json:"observations"`
+		Rewards      map[string]float64         `json:"rewards"`
+		Dones        map[string]bool            `json:"dones"`
+		Infos        map[string]interface{}     `json:"infos"`
+		Agents       []string                   `json:"agents"`
+	}
+	err = c.command("step_multi", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetStepMulti); err != nil {
+			return err
+		}
+		return writeByteField(w, actionsData)
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		return readJSONResult(r, &raw)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result = &MultiStepResult{
+		Observations: map[string]Obs{},
+		Rewards:      raw.Rewards,
+		Dones:        raw.Dones,
+		Infos:        raw.Infos,
+		Agents:       raw.Agents,
+	}
+	for agent, obs := range raw.Observations {
+		result.Observations[agent] = jsonObs(obs)
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package gym
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestStepMulti(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	actionsCh := make(chan map[string]interface{}, 1)
+	go serveMultiSteps(listener, actionsCh)
+
+	env, err := Make(listener.Addr().String(), "pettingzoo/butterfly-pistonball-v6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	result, err := StepMulti(env, map[string]interface{}{"a": 1, "b": []float64{0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedActions := map[string]interface{}{"a": 1.0, "b": []interface{}{0.5}}
+	if actions := <-actionsCh; !reflect.DeepEqual(actions, expectedActions) {
+		t.Errorf("expected actions %v but got %v", expectedActions, actions)
+	}
+	var obs []int
+	if err := result.Observations["b"].Unmarshal(&obs); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(obs, []int{3, 4}) {
+		t.Errorf("unexpected observation: %v", obs)
+	}
+	if result.Rewards["a"] != 1 || result.Rewards["b"] != -0.5 {
+		t.Errorf("unexpected rewards: %v", result.Rewards)
+	}
+	if result.Dones["a"] || !result.Dones["b"] {
+		t.Errorf("unexpected dones: %v", result.Dones)
+	}
+	if !reflect.DeepEqual(result.Agents, []string{"a"}) {
+		t.Errorf("unexpected agents: %v", result.Agents)
+	}
+
+	_, err = StepMulti(env, nil)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported but got: %v", err)
+	}
+	if stats := env.Stats(); stats.Steps != 2 || stats.MeanStepLatency <= 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+// serveMultiSteps accepts one connection, answers the
+// first multi-agent step with a fixed result, and rejects
+// the rest as unsupported.
+func serveMultiSteps(listener net.Listener, actionsCh chan<- map[string]interface{}) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	if _, err := rw.ReadByte(); err != nil {
+		return
+	}
+	if _, err := readByteField(rw); err != nil {
+		return
+	}
+	writeUint32(rw, 0)
+	rw.Flush()
+	for first := true; ; first = false {
+		header := make([]byte, 1)
+		if _, err := io.ReadFull(rw, header); err != nil {
+			return
+		}
+		data, err := readByteField(rw)
+		if err != nil {
+			return
+		}
+		if !first {
+			writeByteField(rw, []byte(`{"code":"unsupported","message":"not multi-agent"}`))
+			rw.Flush()
+			continue
+		}
+		var actions map[string]interface{}
+		json.Unmarshal(data, &actions)
+		actionsCh <- actions
+		writeByteField(rw, nil)
+		writeByteField(rw, []byte(`{"observations":{"a":[1,2],"b":[3,4]},`+
+			`"rewards":{"a":1,"b":-0.5},"dones":{"a":false,"b":true},`+
+			`"infos":{"a":{},"b":{}},"agents":["a"]}`))
+		rw.Flush()
+	}
+}
@@ -3,6 +3,7 @@ package gym
 import (
 	"encoding/json"
 	"errors"
+	"io"
 
 	"github.com/unixpickle/essentials"
 )
@@ -25,10 +26,59 @@ type Obs interface {
 //
 // The slice returned by Uint8Obs is read-only.
 // The caller should not modify it.
+// It refers to the observation's own memory, which is
+// overwritten by the next Reset or Step if the Env was
+// created with the ReuseObs option.
 type Uint8Obs interface {
 	Uint8Obs() []uint8
 }
 
+// ShapedObs is an observation with a known tensor shape,
+// such as a byte list observation.
+type ShapedObs interface {
+	// Shape returns the dimensions of the observation.
+	// The caller should not modify it.
+	Shape() []int
+}
+
+// NewUint8Obs creates an observation from a flattened,
+// row-major tensor of 8-bit unsigned integers.
+// This is useful for code that transforms observations,
+// such as wrappers.
+//
+// The observation implements Uint8Obs and ShapedObs.
+// It takes ownership of the shape and values.
+func NewUint8Obs(shape []int, values []uint8) Obs {
+	return &uint8Obs{Dims: shape, Values: values}
+}
+
+// NewJSONObs creates an observation by encoding an object
+// as JSON.
+func NewJSONObs(obj interface{}) (Obs, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return jsonObs(data), nil
+}
+
+// An ObsHandler consumes a byte list observation as it
+// arrives from the server.
+//
+// The index is the environment's index in a BatchEnv, or
+// its position in the list returned by MakeN.
+// It is 0 for other environments.
+// The dims slice holds the shape of the observation, and
+// is only valid until the handler returns.
+// The values can be read from the reader, in row-major
+// order.
+// Values which the handler does not read are skipped.
+//
+// If the handler returns an error, the command fails and
+// the connection cannot be used again until it is
+// reconnected.
+type ObsHandler func(index int, dims []int, values io.Reader) (Obs, error)
+
 // Flatten turns a tensor observation into a 1-dimensional
 // vector.
 // This fails if the observation is not a tensor.
@@ -107,6 +157,38 @@ func UnpackTuple(o Obs) (children []Obs, err error) {
 	return res, nil
 }
 
+// TerminalObs extracts the last observation of a finished
+// episode from the info returned by Step.
+//
+// This is only available for environments created with
+// the AutoReset option, and only when done is true.
+func TerminalObs(info interface{}) (obs Obs, ok bool) {
+	if raw, isRaw := info.(json.RawMessage); isRaw {
+		var rawMap map[string]json.RawMessage
+		if json.Unmarshal(raw, &rawMap) != nil {
+			return nil, false
+		}
+		rawObs, ok := rawMap["terminal_observation"]
+		if !ok {
+			return nil, false
+		}
+		return jsonObs(rawObs), true
+	}
+	infoMap, ok := info.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	rawObs, ok := infoMap["terminal_observation"]
+	if !ok {
+		return nil, false
+	}
+	jsonData, err := json.Marshal(rawObs)
+	if err != nil {
+		return nil, false
+	}
+	return jsonObs(jsonData), true
+}
+
 // jsonObs is an observation which was encoded as JSON.
 type jsonObs []byte
 
@@ -140,6 +222,10 @@ func (u *uint8Obs) Uint8Obs() []uint8 {
 	return u.Values
 }
 
+func (u *uint8Obs) Shape() []int {
+	return u.Dims
+}
+
 func (u *uint8Obs) jsonObject() interface{} {
 	if len(u.Dims) == 1 {
 		res := make([]float64, len(u.Values))
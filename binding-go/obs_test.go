@@ -1,6 +1,7 @@
 package gym
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 )
@@ -101,3 +102,27 @@ func TestUnpackTuple(t *testing.T) {
 		}
 	}
 }
+
+func TestTerminalObs(t *testing.T) {
+	rawInfo := json.RawMessage(`{"terminal_observation": [1, 2], "t": 3}`)
+	var info interface{}
+	if err := json.Unmarshal(rawInfo, &info); err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range []interface{}{info, rawInfo} {
+		obs, ok := TerminalObs(info)
+		if !ok {
+			t.Fatalf("no terminal observation in %T", info)
+		}
+		var actual []int
+		if err := obs.Unmarshal(&actual); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, []int{1, 2}) {
+			t.Errorf("unexpected observation from %T: %v", info, actual)
+		}
+	}
+	if _, ok := TerminalObs(json.RawMessage(`{}`)); ok {
+		t.Error("unexpected terminal observation")
+	}
+}
@@ -0,0 +1,193 @@
+package gym
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/unixpickle/essentials"
+)
+
+// ObsDifference describes how two observations differ.
+type ObsDifference struct {
+	// Path locates the first difference, such as "[3][1]"
+	// for an element of a nested list, or ".pos[0]" for
+	// an element of a list in an object.
+	// It is empty if the observations differ as a whole,
+	// for example because one is a list and the other is a
+	// number.
+	Path string
+
+	// A and B are the values at Path.
+	A interface{}
+	B interface{}
+
+	// Count is the number of values which differ.
+	// A mismatch in structure, such as lists of different
+	// lengths, counts as one value.
+	Count int
+
+	// MaxAbs is the largest absolute difference between
+	// two numbers.
+	MaxAbs float64
+}
+
+// String summarizes the difference.
+func (o *ObsDifference) String() string {
+	path := o.Path
+	if path == "" {
+		path = "observation"
+	}
+	return fmt.Sprintf("%s: %v vs. %v (%d differences, max abs %g)", path, o.A, o.B,
+		o.Count, o.MaxAbs)
+}
+
+// ObsEqual checks if two observations are equal, allowing
+// numbers to differ by at most tol.
+//
+// Observations which cannot be decoded are not equal.
+func ObsEqual(a, b Obs, tol float64) bool {
+	diff, err := ObsDiff(a, b, tol)
+	return err == nil && diff == nil
+}
+
+// ObsDiff compares two observations element by element,
+// allowing numbers to differ by at most tol.
+// It returns nil if the observations are equal.
+//
+// Byte list observations are compared directly, without
+// decoding them to JSON.
+// Other observations, including a byte list compared to a
+// JSON observation, are decoded and compared as nested
+// lists, objects, and numbers.
+func ObsDiff(a, b Obs, tol float64) (diff *ObsDifference, err error) {
+	defer essentials.AddCtxTo("diff observations", &err)
+	u1, ok1 := a.(Uint8Obs)
+	u2, ok2 := b.(Uint8Obs)
+	if ok1 && ok2 {
+		return diffUint8Obs(a, b, u1.Uint8Obs(), u2.Uint8Obs(), tol), nil
+	}
+	var obj1, obj2 interface{}
+	if err := a.Unmarshal(&obj1); err != nil {
+		return nil, err
+	}
+	if err := b.Unmarshal(&obj2); err != nil {
+		return nil, err
+	}
+	diff = &ObsDifference{}
+	diff.add("", obj1, obj2, tol)
+	if diff.Count == 0 {
+		return nil, nil
+	}
+	return diff, nil
+}
+
+func diffUint8Obs(a, b Obs, v1, v2 []uint8, tol float64) *ObsDifference {
+	var shape []int
+	s1, ok1 := a.(ShapedObs)
+	s2, ok2 := b.(ShapedObs)
+	if ok1 && ok2 {
+		if !reflect.DeepEqual(s1.Shape(), s2.Shape()) {
+			return &ObsDifference{A: s1.Shape(), B: s2.Shape(), Count: 1}
+		}
+		shape = s1.Shape()
+	}
+	if len(v1) != len(v2) {
+		return &ObsDifference{A: len(v1), B: len(v2), Count: 1}
+	}
+	diff := &ObsDifference{}
+	for i, x := range v1 {
+		d := math.Abs(float64(x) - float64(v2[i]))
+		if d <= tol {
+			continue
+		}
+		if diff.Count == 0 {
+			diff.Path = shapedPath(shape, i)
+			diff.A, diff.B = x, v2[i]
+		}
+		diff.Count++
+		diff.MaxAbs = math.Max(diff.MaxAbs, d)
+	}
+	if diff.Count == 0 {
+		return nil
+	}
+	return diff
+}
+
+// shapedPath turns a flat index into a path like "[1][2]"
+// for a row-major tensor.
+func shapedPath(shape []int, index int) string {
+	if len(shape) == 0 {
+		return "[" + strconv.Itoa(index) + "]"
+	}
+	var path string
+	for i := len(shape) - 1; i >= 0; i-- {
+		dim := shape[i]
+		if dim == 0 {
+			dim = 1
+		}
+		path = "[" + strconv.Itoa(index%dim) + "]" + path
+		index /= dim
+	}
+	return path
+}
+
+func (o *ObsDifference) add(path string, a, b interface{}, tol float64) {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			d := math.Abs(a - b)
+			if d > tol {
+				o.record(path, a, b)
+				o.MaxAbs = math.Max(o.MaxAbs, d)
+			}
+			return
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok && len(a) == len(b) {
+			for i, x := range a {
+				o.add(path+"["+strconv.Itoa(i)+"]", x, b[i], tol)
+			}
+			return
+		}
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok && sameKeys(a, b) {
+			keys := make([]string, 0, len(a))
+			for key := range a {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				o.add(path+"."+key, a[key], b[key], tol)
+			}
+			return
+		}
+	default:
+		if reflect.DeepEqual(a, b) {
+			return
+		}
+	}
+	o.record(path, a, b)
+}
+
+func (o *ObsDifference) record(path string, a, b interface{}) {
+	if o.Count == 0 {
+		o.Path = path
+		o.A, o.B = a, b
+	}
+	o.Count++
+}
+
+func sameKeys(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package gym
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestObsDiff(t *testing.T) {
+	jsonObs := func(obj interface{}) Obs {
+		obs, err := NewJSONObs(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obs
+	}
+	pixels := NewUint8Obs([]int{2, 3}, []uint8{1, 2, 3, 4, 5, 6})
+
+	tests := []struct {
+		A, B     Obs
+		Tol      float64
+		Expected *ObsDifference
+	}{
+		{jsonObs([]float64{1, 2}), jsonObs([]float64{1, 2.05}), 0.1, nil},
+		{
+			jsonObs([]float64{1, 2, 3}),
+			jsonObs([]float64{1, 2.5, 4}),
+			0.1,
+			&ObsDifference{Path: "[1]", A: 2.0, B: 2.5, Count: 2, MaxAbs: 1},
+		},
+		{
+			jsonObs(map[string]interface{}{"pos": []float64{0, 1}, "name": "a"}),
+			jsonObs(map[string]interface{}{"pos": []float64{0, 2}, "name": "b"}),
+			0,
+			&ObsDifference{Path: ".name", A: "a", B: "b", Count: 2, MaxAbs: 1},
+		},
+		{
+			jsonObs([]float64{1, 2}),
+			jsonObs([]float64{1}),
+			0,
+			&ObsDifference{A: []interface{}{1.0, 2.0}, B: []interface{}{1.0}, Count: 1},
+		},
+		{pixels, NewUint8Obs([]int{2, 3}, []uint8{1, 2, 3, 4, 5, 7}), 1, nil},
+		{
+			pixels,
+			NewUint8Obs([]int{2, 3}, []uint8{1, 2, 3, 9, 5, 7}),
+			0,
+			&ObsDifference{Path: "[1][0]", A: uint8(4), B: uint8(9), Count: 2, MaxAbs: 5},
+		},
+		{
+			pixels,
+			NewUint8Obs([]int{3, 2}, []uint8{1, 2, 3, 4, 5, 6}),
+			0,
+			&ObsDifference{A: []int{2, 3}, B: []int{3, 2}, Count: 1},
+		},
+		{pixels, jsonObs([][]int{{1, 2, 3}, {4, 5, 6}}), 0, nil},
+	}
+	for i, test := range tests {
+		actual, err := ObsDiff(test.A, test.B, test.Tol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, test.Expected) {
+			t.Errorf("test %d: expected %v but got %v", i, test.Expected, actual)
+		}
+		if ObsEqual(test.A, test.B, test.Tol) != (test.Expected == nil) {
+			t.Errorf("test %d: unexpected ObsEqual result", i)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package gym
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// DefaultTimeout is the default limit on how long a single
+// request to the server may take.
+// It may be changed for individual environments with the
+// WithTimeout option.
+var DefaultTimeout = time.Minute * 5
+
+// An Option customizes an environment created by Make,
+// MakeN, or MakeBatch.
+type Option func(o *options)
+
+type options struct {
+	AutoReset bool
+	Resumable bool
+	Retry     *RetryPolicy
+	Timeout   time.Duration
+	Lease     time.Duration
+	Dial      DialFunc
+	Socket    *SocketConfig
+	AuthToken string
+
+	BinaryActions bool
+	ReuseObs      bool
+	ObsHandler    ObsHandler
+	InfoMode      infoMode
+	Tracer        Tracer
+	Logger        *slog.Logger
+
+	// Stats is shared by the environments that a Client
+	// creates for the same handle, or nil.
+	Stats *envStats
+}
+
+func makeOptions(opts []Option) *options {
+	res := &options{Timeout: DefaultTimeout, Dial: defaultDial}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// envStats returns the stats tracker for a new
+// environment.
+func (o *options) envStats() *envStats {
+	if o.Stats != nil {
+		return o.Stats
+	}
+	return newEnvStats()
+}
+
+// obsDecoder creates a decoder for the byte list
+// observations of the environment at the given index in a
+// batch, or returns nil to use the default.
+func (o *options) obsDecoder(index int) byteListDecoder {
+	if o.ObsHandler != nil {
+		return streamUint8Obs(o.ObsHandler, index)
+	} else if o.ReuseObs {
+		return reuseUint8Obs()
+	}
+	return nil
+}
+
+// AutoReset makes the server reset an environment as soon
+// as an episode ends, like a vectorized environment would.
+//
+// When Step returns done=true, the observation it returns
+// is the first observation of the next episode, and the
+// last observation of the finished episode is stored in
+// the info map (see TerminalObs).
+// This saves a round trip at every episode boundary.
+func AutoReset() Option {
+	return func(o *options) {
+		o.AutoReset = true
+	}
+}
+
+// Resumable makes the server keep an environment alive
+// for a while after its connection drops, so that the
+// connection can be restored with Env.Reconnect.
+//
+// This requires a server running on Python 3.3 or later.
+func Resumable() Option {
+	return func(o *options) {
+		o.Resumable = true
+	}
+}
+
+// WithRetry retries transient network failures according
+// to a policy, rather than returning them right away.
+// If the policy is nil, DefaultRetryPolicy is used.
+//
+// Connecting to the server is always retried.
+// Commands on an environment are only retried if it is
+// Resumable, in which case the environment reconnects
+// before sending the command again.
+func WithRetry(policy *RetryPolicy) Option {
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
+	return func(o *options) {
+		o.Retry = policy
+	}
+}
+
+// WithTimeout limits how long a single request to the
+// server may take, including creating the environment.
+// If the server stalls for longer than this, the request
+// fails with a timeout error and the connection becomes
+// unusable (see ErrConnBroken).
+//
+// A timeout of 0 disables the limit.
+// By default, DefaultTimeout is used.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.Timeout = timeout
+	}
+}
+
+// WithLease gives each connection a lease, which the
+// server reclaims if it is not renewed with Env.Renew (or
+// BatchEnv.Renew) within the given duration.
+// Each call to Renew extends the lease by the same amount.
+//
+// Unlike the server's --idle-ttl flag, a lease expires
+// even if the client keeps sending commands, which cleans
+// up after rollout workers that are stuck, not just those
+// that crashed.
+// Reconnecting does not extend the lease.
+//
+// This requires a server which supports FeatureLease.
+func WithLease(ttl time.Duration) Option {
+	return func(o *options) {
+		o.Lease = ttl
+	}
+}
+
+// BinaryActions sends integer actions (for Discrete
+// spaces) and []float64 or []float32 actions (for Box
+// spaces) in a compact binary format instead of JSON.
+// This saves time in tight Step loops.
+//
+// Other kinds of actions are still sent as JSON.
+// This requires a server which supports binary actions;
+// older servers reject the connection.
+func BinaryActions() Option {
+	return func(o *options) {
+		o.BinaryActions = true
+	}
+}
+
+// ReuseObs decodes byte list observations (see Uint8Obs)
+// into the same memory every time, so that Reset and Step
+// do not allocate memory for them.
+//
+// With this option, an observation returned by Reset or
+// Step is only valid until the next Reset or Step on the
+// same Env (or BatchEnv), which overwrites it.
+// Observations that are needed for longer, e.g. in a
+// replay buffer, must be copied.
+// Observations returned by a Pipeline are never reused.
+func ReuseObs() Option {
+	return func(o *options) {
+		o.ReuseObs = true
+	}
+}
+
+// StreamObs passes byte list observations (see Uint8Obs)
+// to a handler as they arrive from the server, rather than
+// buffering them first.
+// The Obs returned by the handler is returned by Reset or
+// Step in place of the byte list.
+//
+// This is useful for very large observations, which the
+// handler can write straight to their destination (e.g. a
+// preallocated batch of frames), or reduce in size.
+//
+// This option takes precedence over ReuseObs.
+// It does not apply to a Pipeline.
+func StreamObs(handler ObsHandler) Option {
+	return func(o *options) {
+		o.ObsHandler = handler
+	}
+}
+
+// SkipInfo makes Step skip over info objects instead of
+// decoding them, and return a nil info.
+// This saves time for agents which ignore info.
+//
+// With AutoReset, this also drops the terminal observation
+// (see TerminalObs).
+func SkipInfo() Option {
+	return func(o *options) {
+		o.InfoMode = infoSkip
+	}
+}
+
+// RawInfo makes Step return each info object as an
+// undecoded json.RawMessage, so that it can be parsed
+// later (or not at all) with json.Unmarshal.
+//
+// TerminalObs works on raw info objects as well.
+func RawInfo() Option {
+	return func(o *options) {
+		o.InfoMode = infoRaw
+	}
+}
+
+// UseNumber makes Step decode the numbers in info objects
+// as json.Numbers rather than float64s, like the UseNumber
+// method of a json.Decoder.
+// This keeps large integers, such as IDs and scores above
+// 2^53, from losing precision.
+//
+// Spaces are not affected, since their fields have fixed
+// types.
+func UseNumber() Option {
+	return func(o *options) {
+		o.InfoMode = infoNumber
+	}
+}
+
+// defaultBufferSize is the default size of the client's
+// buffers for a connection.
+const defaultBufferSize = 4096
+
+// SocketConfig tunes the connection to a server.
+//
+// Zero fields keep the default settings.
+type SocketConfig struct {
+	// DelayWrites enables Nagle's algorithm, which Go
+	// disables by default.
+	// This may reduce the number of packets on a busy
+	// network, at the cost of latency for small commands.
+	DelayWrites bool
+
+	// SendBuffer and RecvBuffer set the sizes of the
+	// operating system's buffers for the socket.
+	// Large buffers help with large observations on links
+	// with a high round-trip time.
+	SendBuffer int
+	RecvBuffer int
+
+	// ReadBufferSize and WriteBufferSize set the sizes of
+	// the client's own buffers for the connection.
+	ReadBufferSize  int
+	WriteBufferSize int
+}
+
+// apply applies the operating system settings to a
+// connection.
+//
+// Connections which are not TCP connections, such as
+// those wrapped by a custom DialFunc, are left as is.
+func (s *SocketConfig) apply(conn net.Conn) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if s.DelayWrites {
+		if err := tcpConn.SetNoDelay(false); err != nil {
+			return err
+		}
+	}
+	if s.SendBuffer != 0 {
+		if err := tcpConn.SetWriteBuffer(s.SendBuffer); err != nil {
+			return err
+		}
+	}
+	if s.RecvBuffer != 0 {
+		if err := tcpConn.SetReadBuffer(s.RecvBuffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bufferSizes returns the sizes of the client's buffers.
+func (s *SocketConfig) bufferSizes() (read, write int) {
+	read, write = s.ReadBufferSize, s.WriteBufferSize
+	if read == 0 {
+		read = defaultBufferSize
+	}
+	if write == 0 {
+		write = defaultBufferSize
+	}
+	return
+}
+
+// WithSocketConfig tunes the connection to the server.
+//
+// The defaults suit small observations on a local
+// network.
+// For large observations, such as high resolution images,
+// bigger buffers may increase throughput.
+func WithSocketConfig(config *SocketConfig) Option {
+	return func(o *options) {
+		o.Socket = config
+	}
+}
+
+// A DialFunc opens a connection to a server, like net.Dial.
+type DialFunc func(network, address string) (net.Conn, error)
+
+// WithDialer uses a custom function to open connections to
+// the server, e.g. to measure or tune the traffic.
+// It is also used when the environment reconnects.
+func WithDialer(dial DialFunc) Option {
+	return func(o *options) {
+		o.Dial = dial
+	}
+}
+
+// WithLogger logs the handshake, every command, reconnects,
+// and connection failures at the debug level, which helps
+// to diagnose problems at the wire level.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.Logger = l
+	}
+}
+
+// WithTLS connects to the server over TLS, e.g. through a
+// gym-proxy which terminates TLS.
+// The config may be nil to use the defaults.
+func WithTLS(config *tls.Config) Option {
+	return WithDialer(func(network, address string) (net.Conn, error) {
+		return tls.Dial(network, address, config)
+	})
+}
+
+// WithAuthToken sends a token to authenticate the client,
+// as required by some proxies such as gym-proxy.
+//
+// Plain servers do not check tokens, and reject
+// connections which send one.
+func WithAuthToken(token string) Option {
+	return func(o *options) {
+		o.AuthToken = token
+	}
+}
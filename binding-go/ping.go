@@ -0,0 +1,81 @@
+package gym
+
+import (
+	"bufio"
+	"encoding/json"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// ServerStatus is basic information which a server
+// reports about a connection.
+type ServerStatus struct {
+	// EnvName is the ID of the environment, or "" if the
+	// connection has no environment.
+	EnvName string `json:"env_name"`
+
+	// PID is the ID of the server process handling the
+	// connection.
+	PID int `json:"pid"`
+
+	PythonVersion string `json:"python_version"`
+	GymVersion    string `json:"gym_version"`
+
+	// IdleTTL is the number of seconds a connection may go
+	// without sending a command before the server closes
+	// it and its environments.
+	// It is 0 if idle connections are never closed, which
+	// is the case after a call to Env.KeepAlive().
+	IdleTTL float64 `json:"idle_ttl"`
+
+	// LeaseTTL is the number of seconds that the lease on
+	// the connection lasts from each renewal, or 0 if the
+	// connection has no lease (see WithLease).
+	LeaseTTL float64 `json:"lease_ttl"`
+
+	// Features lists the optional features that the server
+	// supports, such as FeatureStepBlind.
+	// It is empty for older servers.
+	Features []string `json:"features"`
+}
+
+// PingResult is the result of pinging a server.
+type PingResult struct {
+	// RTT is the round-trip time of the ping.
+	RTT time.Duration
+
+	Status ServerStatus
+}
+
+// Ping checks that an API server is up and measures its
+// round-trip time without creating an environment.
+func Ping(host string) (res *PingResult, err error) {
+	defer essentials.AddCtxTo("ping", &err)
+	env, err := Make(host, "")
+	if err != nil {
+		return nil, err
+	}
+	defer env.Close()
+	return env.Ping()
+}
+
+func (c *connEnv) Ping() (res *PingResult, err error) {
+	defer essentials.AddCtxTo("ping environment", &err)
+	var start time.Time
+	err = c.command("ping", func(w *bufio.Writer) error {
+		start = time.Now()
+		return c.writeHeader(w, packetPing)
+	}, func(r *bufio.Reader) error {
+		data, err := readByteField(r)
+		if err != nil {
+			return err
+		}
+		res = &PingResult{RTT: time.Since(start)}
+		return json.Unmarshal(data, &res.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
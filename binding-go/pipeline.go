@@ -0,0 +1,231 @@
+package gym
+
+import (
+	"bufio"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A StepResult is the outcome of a pipelined step.
+type StepResult struct {
+	Obs    Obs
+	Reward float64
+	Done   bool
+	Info   interface{}
+}
+
+// A Pipeline sends steps to an environment without waiting
+// for the previous steps to finish.
+//
+// The server still runs the steps one at a time, but the
+// round trip for each step overlaps with the computation
+// of the steps before it.
+// This makes a big difference when the server is far away.
+//
+// Results are received in the order that the actions were
+// sent.
+// Send and Recv may be called from different Goroutines,
+// e.g. one which sends actions while another consumes
+// observations.
+//
+// Other commands on the environment may be used while a
+// Pipeline is open; they are run after the steps which
+// were sent before them.
+type Pipeline struct {
+	env *connEnv
+
+	// slots holds a value for each step in flight.
+	slots chan struct{}
+
+	// steps holds the steps in flight, in order.
+	steps chan *pipelineStep
+
+	sendLock sync.Mutex
+	recvLock sync.Mutex
+
+	lock   sync.Mutex
+	closed bool
+
+	// done is closed by Close, to stop Send from waiting
+	// for a slot.
+	done chan struct{}
+}
+
+type pipelineStep struct {
+	Request *request
+	Result  StepResult
+	Start   time.Time
+}
+
+// NewPipeline starts pipelining steps on an environment.
+//
+// The depth limits how many steps may be in flight at
+// once.
+// Send blocks while depth steps are awaiting Recv.
+//
+// The environment must have been created by Make, MakeN,
+// or a Client.
+// For an environment created by a Client, a connection
+// failure during pipelining makes the environment
+// reconnect on its next command.
+func NewPipeline(env Env, depth int) (p *Pipeline, err error) {
+	defer essentials.AddCtxTo("create pipeline", &err)
+	if depth < 1 {
+		return nil, errors.New("pipeline depth must be positive")
+	}
+	if c, ok := env.(*clientEnv); ok {
+		env, err = c.current()
+		if err != nil {
+			return nil, err
+		}
+	}
+	c, ok := env.(*connEnv)
+	if !ok {
+		return nil, errors.New("environment does not support pipelining")
+	}
+	if err := c.broken(); err != nil {
+		return nil, err
+	}
+	return &Pipeline{
+		env:   c,
+		slots: make(chan struct{}, depth),
+		steps: make(chan *pipelineStep, depth),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+// Pending returns the number of steps which have been sent
+// but not received.
+func (p *Pipeline) Pending() int {
+	return len(p.slots)
+}
+
+// Send sends a step to the environment.
+func (p *Pipeline) Send(action interface{}) (err error) {
+	defer essentials.AddCtxTo("send step", &err)
+	p.sendLock.Lock()
+	defer p.sendLock.Unlock()
+	if err := p.checkClosed(); err != nil {
+		return err
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-p.done:
+		return errors.New("pipeline is closed")
+	}
+
+	step := &pipelineStep{Start: time.Now()}
+	c := p.env
+	if c.Tracer != nil {
+		c.Tracer.Begin("step")
+	}
+	c.connLock.RLock()
+	step.Request, err = c.submit(func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetStep); err != nil {
+			return err
+		}
+		return writeAction(w, action, c.BinaryActions)
+	}, func(r *bufio.Reader) (err error) {
+		res := &step.Result
+		res.Obs, res.Reward, res.Done, res.Info, err = readStepResult(r, nil,
+			c.InfoMode)
+		return
+	})
+	c.connLock.RUnlock()
+	if err != nil {
+		if c.Tracer != nil {
+			c.Tracer.End("step", err, 0)
+		}
+		c.stats.Command("step", time.Since(step.Start), 0, 0, err)
+		<-p.slots
+		return err
+	}
+	p.steps <- step
+	return nil
+}
+
+// Recv receives the result of the oldest step in flight.
+//
+// If the environment fails to step, the error matches
+// ErrEnvFailed and the Pipeline remains usable.
+func (p *Pipeline) Recv() (res *StepResult, err error) {
+	defer essentials.AddCtxTo("receive step", &err)
+	p.recvLock.Lock()
+	defer p.recvLock.Unlock()
+	if err := p.checkClosed(); err != nil {
+		return nil, err
+	}
+	if len(p.slots) == 0 {
+		return nil, errors.New("no steps in flight")
+	}
+	return p.recv()
+}
+
+// Close waits for the remaining steps to finish, discarding
+// their results.
+//
+// The first error from a discarded step, if any, is
+// returned.
+// A Send which is waiting for a step to be received fails
+// instead of sending its step.
+func (p *Pipeline) Close() (err error) {
+	defer essentials.AddCtxTo("close pipeline", &err)
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.lock.Unlock()
+
+	// A Send which is waiting for a slot gives up once
+	// done is closed, releasing sendLock.
+	p.sendLock.Lock()
+	defer p.sendLock.Unlock()
+	p.recvLock.Lock()
+	defer p.recvLock.Unlock()
+
+	for len(p.slots) > 0 {
+		if _, stepErr := p.recv(); stepErr != nil && err == nil {
+			err = stepErr
+		}
+	}
+	return err
+}
+
+// recv waits for the oldest step in flight.
+//
+// The caller must hold recvLock.
+func (p *Pipeline) recv() (*StepResult, error) {
+	step := <-p.steps
+	err := <-step.Request.Done
+	<-p.slots
+	req := step.Request
+	if p.env.Tracer != nil {
+		p.env.Tracer.End("step", err, req.Sent+req.Received)
+	}
+	latency := time.Since(step.Start)
+	p.env.stats.Command("step", latency, req.Sent, req.Received, err)
+	p.env.debug("command", "op", "step", "sent", req.Sent, "received", req.Received,
+		"latency", latency, "error", err, "pipelined", true)
+	if err != nil {
+		return nil, err
+	}
+	if step.Result.Done {
+		p.env.stats.Episode()
+	}
+	return &step.Result, nil
+}
+
+func (p *Pipeline) checkClosed() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return errors.New("pipeline is closed")
+	}
+	return nil
+}
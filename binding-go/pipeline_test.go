@@ -0,0 +1,150 @@
+package gym
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	const depth = 3
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		if _, err := rw.ReadByte(); err != nil {
+			return
+		}
+		if _, err := readByteField(rw); err != nil {
+			return
+		}
+		writeUint32(rw, 0)
+		rw.Flush()
+
+		// Only respond once every step has arrived, which
+		// would deadlock a client that waited for each one.
+		var actions [][]byte
+		for i := 0; i < depth; i++ {
+			header := make([]byte, 2)
+			if _, err := io.ReadFull(rw, header); err != nil {
+				return
+			}
+			action, err := readByteField(rw)
+			if err != nil {
+				return
+			}
+			actions = append(actions, action)
+		}
+		for i, action := range actions {
+			writePacketType(rw, observationJSON)
+			writeByteField(rw, action)
+			binary.Write(rw, byteOrder, float64(i))
+			writeBool(rw, i == depth-1)
+			writeByteField(rw, []byte("{}"))
+		}
+		rw.Flush()
+	}()
+
+	env, err := Make(listener.Addr().String(), "CartPole-v0",
+		WithTimeout(time.Second*5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	p, err := NewPipeline(env, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < depth; i++ {
+		if err := p.Send(i * 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.Pending() != depth {
+		t.Errorf("expected %d pending steps but got %d", depth, p.Pending())
+	}
+	for i := 0; i < depth; i++ {
+		res, err := p.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var obs int
+		if err := res.Obs.Unmarshal(&obs); err != nil {
+			t.Fatal(err)
+		}
+		if obs != i*10 || res.Reward != float64(i) || res.Done != (i == depth-1) {
+			t.Errorf("step %d: unexpected result %+v (obs %d)", i, res, obs)
+		}
+	}
+	if _, err := p.Recv(); err == nil {
+		t.Error("expected an error with no steps in flight")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPipelineCloseDuringSend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveEchoSteps(listener)
+
+	env, err := Make(listener.Addr().String(), "CartPole-v0",
+		WithTimeout(time.Second*5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	p, err := NewPipeline(env, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Send(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pipeline is full, so this Send waits until the
+	// consumer closes the pipeline instead of receiving.
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- p.Send(2)
+	}()
+	time.Sleep(time.Millisecond * 50)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- p.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Close blocked on a waiting Send")
+	}
+	select {
+	case err := <-sendErr:
+		if err == nil {
+			t.Error("expected the waiting Send to fail")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Send did not return after Close")
+	}
+}
@@ -0,0 +1,347 @@
+package gym
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// DefaultPoolIdleTimeout is the IdleTimeout used when a
+// PoolConfig does not specify one.
+const DefaultPoolIdleTimeout = time.Minute
+
+var errPoolClosed = errors.New("pool is closed")
+
+// PoolStats summarizes the current demand on a Pool.
+type PoolStats struct {
+	// Idle is the number of live environments which are
+	// not currently borrowed.
+	Idle int
+
+	// InUse is the number of borrowed environments.
+	InUse int
+
+	// Starting is the number of environments which are
+	// currently being created.
+	Starting int
+
+	// Waiting is the number of callers blocked in Get().
+	Waiting int
+}
+
+// Live returns the number of environments which are
+// alive or being created.
+func (p PoolStats) Live() int {
+	return p.Idle + p.InUse + p.Starting
+}
+
+// A ScalePolicy decides how many environments a Pool
+// should keep alive.
+type ScalePolicy interface {
+	// TargetSize returns the desired number of live
+	// environments given the current demand.
+	//
+	// The result is clamped to the bounds of the pool, so
+	// it need not take them into account.
+	TargetSize(stats PoolStats) int
+}
+
+// DemandPolicy is a ScalePolicy which sizes a pool to
+// fit the borrowed environments and the waiting callers,
+// plus a fixed number of spare environments.
+type DemandPolicy struct {
+	// Headroom is the number of spare environments to
+	// keep ready for new demand.
+	Headroom int
+}
+
+// TargetSize returns the demand plus the headroom.
+func (d DemandPolicy) TargetSize(stats PoolStats) int {
+	return stats.InUse + stats.Waiting + d.Headroom
+}
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	// Spawn creates a new environment.
+	// It may be called from multiple Goroutines at once.
+	//
+	// See Spawner() for a typical implementation.
+	Spawn func() (Env, error)
+
+	// Min and Max bound the number of live environments.
+	// If Max is 0, there is no upper bound.
+	Min int
+	Max int
+
+	// Policy decides how many environments to keep alive.
+	// If nil, DemandPolicy{} is used.
+	Policy ScalePolicy
+
+	// IdleTimeout is how long an environment must sit idle
+	// before the pool may close it to scale down.
+	// If 0, DefaultPoolIdleTimeout is used.
+	// It may not be negative.
+	IdleTimeout time.Duration
+}
+
+// A Pool is an elastic set of environments which are
+// borrowed and returned by rollout workers.
+//
+// The pool spins up environments when demand exceeds the
+// number of idle environments, and closes environments
+// which have been idle for a while once demand drops.
+//
+// The methods on a Pool are thread-safe.
+type Pool struct {
+	config PoolConfig
+
+	lock     sync.Mutex
+	cond     *sync.Cond
+	idle     []*pooledEnv
+	inUse    int
+	starting int
+	waiting  int
+	closed   bool
+
+	stopReaper chan struct{}
+}
+
+type pooledEnv struct {
+	Env      Env
+	LastUsed time.Time
+}
+
+// Spawner returns a PoolConfig.Spawn function which
+// calls Make with the given arguments.
+//
+// Since Make resolves the host every time, passing a
+// service name spreads the pool across every server
+// behind that service.
+func Spawner(host, envName string) func() (Env, error) {
+	return func() (Env, error) {
+		return Make(host, envName)
+	}
+}
+
+// NewPool creates a pool and starts its minimum number of
+// environments.
+func NewPool(config *PoolConfig) (pool *Pool, err error) {
+	defer essentials.AddCtxTo("create pool", &err)
+	if config.Spawn == nil {
+		return nil, errors.New("missing Spawn function")
+	}
+	if config.Min < 0 || (config.Max != 0 && config.Max < config.Min) {
+		return nil, errors.New("invalid pool bounds")
+	}
+	if config.IdleTimeout < 0 {
+		return nil, errors.New("negative idle timeout")
+	}
+	p := &Pool{config: *config, stopReaper: make(chan struct{})}
+	if p.config.Policy == nil {
+		p.config.Policy = DemandPolicy{}
+	}
+	if p.config.IdleTimeout == 0 {
+		p.config.IdleTimeout = DefaultPoolIdleTimeout
+	}
+	p.cond = sync.NewCond(&p.lock)
+	for i := 0; i < p.config.Min; i++ {
+		env, err := p.config.Spawn()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.idle = append(p.idle, &pooledEnv{Env: env, LastUsed: time.Now()})
+	}
+	go p.reapLoop()
+	return p, nil
+}
+
+// Get borrows an environment from the pool.
+//
+// If no environment is idle, this creates a new one, or
+// waits for one to be returned if the pool is full.
+//
+// The environment should be returned with Put() or
+// Discard() once the caller is done with it.
+func (p *Pool) Get() (env Env, err error) {
+	defer essentials.AddCtxTo("get pooled environment", &err)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.waiting++
+	for {
+		if p.closed {
+			p.waiting--
+			return nil, errPoolClosed
+		}
+		if len(p.idle) > 0 {
+			entry := p.idle[len(p.idle)-1]
+			p.idle = p.idle[:len(p.idle)-1]
+			p.waiting--
+			p.inUse++
+			p.scaleUp()
+			return entry.Env, nil
+		}
+		if p.canGrow() && p.starting < p.waiting {
+			p.starting++
+			p.lock.Unlock()
+			env, err := p.config.Spawn()
+			p.lock.Lock()
+			p.starting--
+			p.waiting--
+			if err != nil {
+				p.cond.Broadcast()
+				return nil, err
+			}
+			p.inUse++
+			return env, nil
+		}
+		p.scaleUp()
+		p.cond.Wait()
+	}
+}
+
+// Put returns a borrowed environment to the pool.
+func (p *Pool) Put(env Env) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.inUse--
+	if p.closed {
+		env.Close()
+		return
+	}
+	p.idle = append(p.idle, &pooledEnv{Env: env, LastUsed: time.Now()})
+	p.cond.Broadcast()
+}
+
+// Discard closes a borrowed environment instead of
+// returning it to the pool.
+//
+// This should be used for environments which failed and
+// can no longer be used.
+func (p *Pool) Discard(env Env) {
+	p.lock.Lock()
+	p.inUse--
+	p.cond.Broadcast()
+	p.lock.Unlock()
+	env.Close()
+}
+
+// Stats returns the current demand on the pool.
+func (p *Pool) Stats() PoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.stats()
+}
+
+// Close closes the idle environments and stops the pool.
+//
+// Borrowed environments are closed as they are returned.
+func (p *Pool) Close() error {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	close(p.stopReaper)
+	p.cond.Broadcast()
+	p.lock.Unlock()
+
+	var firstErr error
+	for _, entry := range idle {
+		if err := entry.Env.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (p *Pool) stats() PoolStats {
+	return PoolStats{
+		Idle:     len(p.idle),
+		InUse:    p.inUse,
+		Starting: p.starting,
+		Waiting:  p.waiting,
+	}
+}
+
+func (p *Pool) canGrow() bool {
+	return p.config.Max == 0 || p.stats().Live() < p.config.Max
+}
+
+func (p *Pool) target() int {
+	target := p.config.Policy.TargetSize(p.stats())
+	if target < p.config.Min {
+		target = p.config.Min
+	}
+	if p.config.Max != 0 && target > p.config.Max {
+		target = p.config.Max
+	}
+	return target
+}
+
+// scaleUp starts environments in the background until
+// the pool reaches its target size.
+//
+// The caller must hold p.lock.
+func (p *Pool) scaleUp() {
+	for i := p.stats().Live(); i < p.target(); i++ {
+		p.starting++
+		go func() {
+			env, err := p.config.Spawn()
+			p.lock.Lock()
+			defer p.lock.Unlock()
+			p.starting--
+			if err == nil {
+				if p.closed {
+					env.Close()
+				} else {
+					p.idle = append(p.idle, &pooledEnv{Env: env, LastUsed: time.Now()})
+				}
+			}
+			p.cond.Broadcast()
+		}()
+	}
+}
+
+// scaleDown closes environments which have been idle for
+// too long, as long as the pool is above its target size.
+func (p *Pool) scaleDown() {
+	p.lock.Lock()
+	var expired []Env
+	now := time.Now()
+	for len(p.idle) > 0 && p.stats().Live() > p.target() {
+		// The oldest entries are at the front.
+		if now.Sub(p.idle[0].LastUsed) < p.config.IdleTimeout {
+			break
+		}
+		expired = append(expired, p.idle[0].Env)
+		p.idle = p.idle[1:]
+	}
+	p.lock.Unlock()
+	for _, env := range expired {
+		env.Close()
+	}
+}
+
+func (p *Pool) reapLoop() {
+	// Tickers panic for intervals below 1ns.
+	interval := p.config.IdleTimeout / 2
+	if interval < 1 {
+		interval = 1
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.scaleDown()
+		case <-p.stopReaper:
+			return
+		}
+	}
+}
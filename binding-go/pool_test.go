@@ -0,0 +1,104 @@
+package gym
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type poolTestEnv struct {
+	Env
+
+	lock   *sync.Mutex
+	closed *int
+}
+
+func (p *poolTestEnv) Close() error {
+	p.lock.Lock()
+	*p.closed++
+	p.lock.Unlock()
+	return nil
+}
+
+func TestPoolScaling(t *testing.T) {
+	var lock sync.Mutex
+	var spawned, closed int
+	pool, err := NewPool(&PoolConfig{
+		Spawn: func() (Env, error) {
+			lock.Lock()
+			spawned++
+			lock.Unlock()
+			return &poolTestEnv{lock: &lock, closed: &closed}, nil
+		},
+		Min:         1,
+		Max:         3,
+		IdleTimeout: time.Millisecond * 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	var envs []Env
+	for i := 0; i < 3; i++ {
+		env, err := pool.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		envs = append(envs, env)
+	}
+	if stats := pool.Stats(); stats.InUse != 3 || stats.Live() != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// The pool is full, so Get() must wait for a Put().
+	got := make(chan Env)
+	go func() {
+		env, _ := pool.Get()
+		got <- env
+	}()
+	select {
+	case <-got:
+		t.Fatal("Get() should block when the pool is full")
+	case <-time.After(time.Millisecond * 10):
+	}
+	pool.Put(envs[0])
+	envs[0] = <-got
+
+	for _, env := range envs {
+		pool.Put(env)
+	}
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Live() > 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool did not scale down: %+v", pool.Stats())
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if spawned != 3 {
+		t.Errorf("expected 3 spawns but got %d", spawned)
+	}
+	if closed != 2 {
+		t.Errorf("expected 2 closes but got %d", closed)
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	spawn := func() (Env, error) {
+		return &poolTestEnv{lock: &sync.Mutex{}, closed: new(int)}, nil
+	}
+	if _, err := NewPool(&PoolConfig{Spawn: spawn, IdleTimeout: -time.Second}); err == nil {
+		t.Error("expected an error for a negative idle timeout")
+	}
+
+	// The reaper must not panic for tiny timeouts.
+	pool, err := NewPool(&PoolConfig{Spawn: spawn, IdleTimeout: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 10)
+	pool.Close()
+}
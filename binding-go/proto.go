@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"sync"
 )
 
 var byteOrder = binary.LittleEndian
@@ -24,15 +26,67 @@ const (
 	packetUniverseWrap
 	packetRetroConfigure
 	packetRetroWrap
+	packetResetBatch
+	packetStepBatch
+	packetPing
+	packetKeepAlive
+	packetConfigure
+	packetSetLogLevel
+	packetEndSession
+	packetCloneState
+	packetRestoreState
+	packetListEnvs
+	packetSpec
+	packetGetAttr
+	packetCallMethod
+	packetRetroListGames
+	packetRetroListStates
+	packetRetroSaveState
+	packetRetroLoadState
+	packetRetroVariables
+	packetRetroReadRAM
+	packetRetroStartMovie
+	packetRetroStopMovie
+	packetRetroUploadIntegration
+	packetUniverseAllocateRemotes
+	packetUniverseListRemotes
+	packetUniverseReleaseRemotes
+	packetStepBlind
+	packetDescribe
+	packetStepMulti
+	packetRenderText
+	packetScreenshot
+	packetRenewLease
+)
+
+const (
+	flagBatch = 1 << iota
+	flagAutoReset
+	flagMulti
+	flagStats
+	flagSession
+	flagResume
+	flagBinaryActions
+	flagAuth
 )
 
 const (
 	observationJSON = iota
 	observationByteList
+
+	// observationNone replaces an observation which the
+	// client asked the server not to send.
+	observationNone
+
+	// observationError replaces an observation when a
+	// command fails.
+	observationError = 0xff
 )
 
 const (
 	actionJSON = iota
+	actionDiscrete
+	actionBox
 )
 
 const (
@@ -40,55 +94,279 @@ const (
 	observationSpace
 )
 
-func handshake(rw *bufio.ReadWriter, envName string) error {
-	if err := rw.WriteByte(0); err != nil {
-		return err
+type handshakeRequest struct {
+	EnvName string
+
+	// BatchSize is non-zero for batched environments.
+	BatchSize int
+
+	// NumEnvs is non-zero for multiplexed environments.
+	NumEnvs int
+
+	// Stats is set to request server statistics instead of
+	// an environment.
+	Stats bool
+
+	// ResumeToken is set to resume an existing session
+	// instead of creating an environment.
+	ResumeToken string
+
+	Options *options
+}
+
+// Flags computes the handshake flags of the request.
+func (req *handshakeRequest) Flags() byte {
+	var flags byte
+	if req.BatchSize > 0 {
+		flags |= flagBatch
 	}
-	if err := writeByteField(rw, []byte(envName)); err != nil {
-		return err
+	if req.Options.AutoReset {
+		flags |= flagAutoReset
+	}
+	if req.NumEnvs > 0 {
+		flags |= flagMulti
+	}
+	if req.Stats {
+		flags |= flagStats
+	}
+	if req.ResumeToken != "" {
+		flags |= flagResume
+	} else if req.Options.Resumable {
+		flags |= flagSession
+	}
+	if req.Options.BinaryActions && req.ResumeToken == "" {
+		flags |= flagBinaryActions
+	}
+	if req.Options.AuthToken != "" {
+		flags |= flagAuth
+	}
+	return flags
+}
+
+// handshake sends a handshake request and reads the
+// server's response.
+//
+// If the request creates a resumable session, the session
+// token is returned.
+func handshake(rw *bufio.ReadWriter, req *handshakeRequest) (token string, err error) {
+	flags := req.Flags()
+	name := req.EnvName
+	if req.ResumeToken != "" {
+		name = req.ResumeToken
+	}
+	if err := rw.WriteByte(flags); err != nil {
+		return "", err
+	}
+	if err := writeByteField(rw, []byte(name)); err != nil {
+		return "", err
+	}
+	if req.BatchSize > 0 {
+		if err := binary.Write(rw, byteOrder, uint32(req.BatchSize)); err != nil {
+			return "", err
+		}
+	}
+	if req.NumEnvs > 0 {
+		if err := binary.Write(rw, byteOrder, uint32(req.NumEnvs)); err != nil {
+			return "", err
+		}
+	}
+	if flags&flagAuth != 0 {
+		if err := writeByteField(rw, []byte(req.Options.AuthToken)); err != nil {
+			return "", err
+		}
 	}
 	if err := rw.Flush(); err != nil {
-		return err
+		return "", err
 	}
 
-	return readErrorField(rw)
+	if err := readErrorField(rw); err != nil {
+		return "", err
+	}
+	if flags&flagSession != 0 {
+		tokenData, err := readByteField(rw)
+		if err != nil {
+			return "", err
+		}
+		token = string(tokenData)
+	}
+	return token, nil
 }
 
 func writeByteField(w io.Writer, b []byte) error {
-	if err := binary.Write(w, byteOrder, uint32(len(b))); err != nil {
+	if err := writeUint32(w, uint32(len(b))); err != nil {
 		return err
 	}
 	_, err := w.Write(b)
 	return err
 }
 
+// writeUint32 writes a little-endian integer.
+// It does not allocate if w is buffered.
+func writeUint32(w io.Writer, x uint32) error {
+	bw, ok := w.(io.ByteWriter)
+	if !ok {
+		return binary.Write(w, byteOrder, x)
+	}
+	for i := 0; i < 4; i++ {
+		if err := bw.WriteByte(byte(x >> uint(8*i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaxFieldSize is the maximum size, in bytes, of a field
+// received from the server, such as an observation or an
+// info object.
+//
+// Larger fields are treated as protocol errors, which
+// keeps a corrupted length prefix from triggering a huge
+// allocation.
+var MaxFieldSize = 1 << 28
+
+// fieldChunkSize is the amount of memory allocated up
+// front for a field.
+// Longer fields are allocated as their data arrives.
+const fieldChunkSize = 1 << 20
+
+// scratchPool holds buffers for fields which are decoded
+// and then discarded, such as info objects.
+// Buffers larger than fieldChunkSize are not pooled.
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 4096)
+		return &buf
+	},
+}
+
+// readByteField reads a field into a new buffer, which the
+// caller may keep.
 func readByteField(r io.Reader) ([]byte, error) {
-	var length uint32
-	if err := binary.Read(r, byteOrder, &length); err != nil {
+	length, err := readFieldLength(r)
+	if err != nil || length == 0 {
 		return nil, err
 	}
-	if length == 0 {
-		return nil, nil
+	if length <= fieldChunkSize {
+		res := make([]byte, length)
+		if _, err := io.ReadFull(r, res); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+	return readLongField(r, length)
+}
+
+// readTempField reads a field and passes it to f.
+//
+// The data is only valid until f returns, after which its
+// buffer is reused.
+func readTempField(r io.Reader, f func(data []byte) error) error {
+	length, err := readFieldLength(r)
+	if err != nil {
+		return err
+	}
+	if length > fieldChunkSize {
+		data, err := readLongField(r, length)
+		if err != nil {
+			return err
+		}
+		return f(data)
+	}
+	bufPtr := scratchPool.Get().(*[]byte)
+	buf := *bufPtr
+	if cap(buf) < length {
+		buf = make([]byte, length)
+	}
+	buf = buf[:length]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
 	}
+	err = f(buf)
+	*bufPtr = buf[:0]
+	scratchPool.Put(bufPtr)
+	return err
+}
 
-	res := make([]byte, int(length))
-	if _, err := io.ReadFull(r, res); err != nil {
+func readFieldLength(r io.Reader) (int, error) {
+	length, err := readUint32(r)
+	if err != nil {
+		return 0, err
+	}
+	if int64(length) > int64(MaxFieldSize) {
+		return 0, fmt.Errorf("field size %d exceeds maximum of %d", length, MaxFieldSize)
+	}
+	return int(length), nil
+}
+
+// readLongField reads a large field, allocating memory as
+// the data arrives.
+func readLongField(r io.Reader, length int) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(fieldChunkSize)
+	if _, err := io.CopyN(&buf, r, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
-	return res, nil
+	return buf.Bytes(), nil
 }
 
 func readErrorField(r io.Reader) error {
-	if errBytes, err := readByteField(r); err != nil {
+	var envErr error
+	err := readTempField(r, func(data []byte) error {
+		if len(data) > 0 {
+			envErr = decodeEnvError(data)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
-	} else if len(errBytes) > 0 {
-		return errors.New(string(errBytes))
 	}
-	return nil
+	return envErr
+}
+
+// readUint32 reads a little-endian integer.
+// It does not allocate if r is buffered.
+func readUint32(r io.Reader) (uint32, error) {
+	res, err := readUint(r, 4)
+	return uint32(res), err
+}
+
+func readUint(r io.Reader, size int) (uint64, error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(r, buf[:size]); err != nil {
+			return 0, err
+		}
+		return byteOrder.Uint64(buf), nil
+	}
+	var res uint64
+	for i := 0; i < size; i++ {
+		b, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF && i > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		res |= uint64(b) << uint(8*i)
+	}
+	return res, nil
 }
 
 func writePacketType(w io.Writer, typeID int) error {
-	_, err := w.Write([]byte{byte(typeID)})
+	return writeByte(w, byte(typeID))
+}
+
+// writeByte writes a single byte.
+// It does not allocate if w is buffered.
+func writeByte(w io.Writer, b byte) error {
+	if bw, ok := w.(io.ByteWriter); ok {
+		return bw.WriteByte(b)
+	}
+	_, err := w.Write([]byte{b})
 	return err
 }
 
@@ -96,90 +374,414 @@ func writeSpaceType(w io.Writer, typeID int) error {
 	return writePacketType(w, typeID)
 }
 
-func readObservation(r io.Reader) (Obs, error) {
-	var typeID uint8
-	if err := binary.Read(r, byteOrder, &typeID); err != nil {
-		return nil, err
-	}
-	obsData, err := readByteField(r)
+// A byteListDecoder decodes a byte list observation field
+// of the given length.
+type byteListDecoder func(r io.Reader, length int) (Obs, error)
+
+// readObservation reads an observation.
+//
+// Byte lists are decoded with decode, or into a new
+// observation if decode is nil.
+func readObservation(r io.Reader, decode byteListDecoder) (Obs, error) {
+	typeID, err := readUint(r, 1)
 	if err != nil {
 		return nil, err
 	}
 	switch typeID {
 	case observationJSON:
+		obsData, err := readByteField(r)
+		if err != nil {
+			return nil, err
+		}
 		return jsonObs(obsData), nil
 	case observationByteList:
-		return decodeUint8Obs(obsData)
+		length, err := readFieldLength(r)
+		if err != nil {
+			return nil, err
+		}
+		if decode == nil {
+			decode = decodeNewUint8Obs
+		}
+		return decode(r, length)
+	case observationError:
+		obsData, err := readByteField(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(obsData) == 0 {
+			return nil, errors.New("empty error")
+		}
+		return nil, decodeEnvError(obsData)
 	default:
-		return nil, fmt.Errorf("unknown observation type: %d", typeID)
+		codec := lookupObsCodec(int(typeID))
+		if codec == nil {
+			return nil, fmt.Errorf("unknown observation type: %d", typeID)
+		}
+		obsData, err := readByteField(r)
+		if err != nil {
+			return nil, err
+		}
+		return codec(obsData)
 	}
 }
 
-func decodeUint8Obs(data []byte) (Obs, error) {
-	r := bytes.NewReader(data)
-	var numDims uint32
-	if err := binary.Read(r, byteOrder, &numDims); err != nil {
+func decodeNewUint8Obs(r io.Reader, length int) (Obs, error) {
+	obs := &uint8Obs{}
+	if err := readUint8Obs(r, length, obs); err != nil {
 		return nil, err
 	}
+	return obs, nil
+}
+
+// reuseUint8Obs creates a byteListDecoder which always
+// decodes into the same observation.
+func reuseUint8Obs() byteListDecoder {
+	obs := &uint8Obs{}
+	return func(r io.Reader, length int) (Obs, error) {
+		if err := readUint8Obs(r, length, obs); err != nil {
+			return nil, err
+		}
+		return obs, nil
+	}
+}
+
+// streamUint8Obs creates a byteListDecoder which passes
+// the values of each byte list to a handler.
+func streamUint8Obs(handler ObsHandler, index int) byteListDecoder {
+	var dims []int
+	return func(r io.Reader, length int) (Obs, error) {
+		var size int
+		var err error
+		dims, size, err = readUint8ObsDims(r, length, dims)
+		if err != nil {
+			return nil, err
+		}
+		values := &io.LimitedReader{R: r, N: int64(size)}
+		obs, err := handler(index, dims, values)
+		// Skip unread values so that the rest of the
+		// response can still be read.
+		if _, copyErr := io.Copy(io.Discard, values); copyErr != nil && err == nil {
+			err = copyErr
+		} else if values.N > 0 && err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return obs, err
+	}
+}
+
+// readUint8Obs decodes a byte list field of the given
+// length straight into dst, reusing its buffers if they
+// are large enough.
+func readUint8Obs(r io.Reader, length int, dst *uint8Obs) error {
+	dims, size, err := readUint8ObsDims(r, length, dst.Dims)
+	if err != nil {
+		return err
+	}
+	dst.Dims = dims
+	if cap(dst.Values) >= size {
+		dst.Values = dst.Values[:size]
+		_, err = io.ReadFull(r, dst.Values)
+		return err
+	}
+	if size <= fieldChunkSize {
+		dst.Values = make([]uint8, size)
+		_, err = io.ReadFull(r, dst.Values)
+		return err
+	}
+	dst.Values, err = readLongField(r, size)
+	return err
+}
+
+// readUint8ObsDims reads the header of a byte list field
+// of the given length, storing the dimensions in dims if
+// it has enough capacity.
+//
+// It returns the dimensions and the number of values
+// which follow them.
+func readUint8ObsDims(r io.Reader, length int, dims []int) ([]int, int, error) {
+	if length < 4 {
+		return nil, 0, errors.New("byte list is too short")
+	}
+	numDims, err := readUint32(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	remaining := int64(length) - 4
 	if numDims == 0 {
-		return nil, errors.New("byte list has 0 dimensions")
+		return nil, 0, errors.New("byte list has 0 dimensions")
+	} else if int64(numDims)*4 > remaining {
+		return nil, 0, errors.New("byte list has too many dimensions")
+	}
+	remaining -= int64(numDims) * 4
+	if cap(dims) < int(numDims) {
+		dims = make([]int, int(numDims))
 	}
-	dims := make([]int, int(numDims))
-	product := 1
+	dims = dims[:numDims]
+	var product int64 = 1
 	for i := range dims {
-		var dim uint32
-		if err := binary.Read(r, byteOrder, &dim); err != nil {
-			return nil, err
+		dim, err := readUint32(r)
+		if err != nil {
+			return nil, 0, err
 		}
 		dims[i] = int(dim)
-		product *= dims[i]
+		// Both factors are at most 2^32, so this cannot
+		// overflow before the size check.
+		product *= int64(dim)
+		if product > remaining {
+			return nil, 0, errors.New("incorrect byte list size")
+		}
 	}
-	if int(product) != r.Len() {
-		return nil, errors.New("incorrect byte list size")
+	if product != remaining {
+		return nil, 0, errors.New("incorrect byte list size")
 	}
-	return &uint8Obs{
-		Dims:   dims,
-		Values: data[len(data)-product:],
-	}, nil
+	return dims, int(product), nil
 }
 
 func readAction(r io.Reader, dst interface{}) error {
-	var typeID uint8
-	if err := binary.Read(r, byteOrder, &typeID); err != nil {
+	typeID, err := readUint(r, 1)
+	if err != nil {
 		return err
 	}
 	if typeID != 0 {
 		return fmt.Errorf("unsupported action type: %d", typeID)
 	}
-	jsonData, err := readByteField(r)
+	return readTempField(r, func(jsonData []byte) error {
+		return json.Unmarshal(jsonData, dst)
+	})
+}
+
+// writeAction encodes an action.
+//
+// If binaryOK is set, integers and float slices are sent
+// in a binary format rather than as JSON.
+func writeAction(w io.Writer, act interface{}, binaryOK bool) error {
+	act, err := encodeCustomAction(act)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(jsonData, dst)
-}
-
-func writeAction(w io.Writer, act interface{}) error {
+	if ok, err := writeRawAction(w, act); ok || err != nil {
+		return err
+	}
+	if binaryOK {
+		if ok, err := writeBinaryAction(w, act); ok || err != nil {
+			return err
+		}
+	}
 	jsonData, err := json.Marshal(act)
 	if err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte{actionJSON}); err != nil {
+	if err := writeByte(w, actionJSON); err != nil {
 		return err
 	}
 	return writeByteField(w, jsonData)
 }
 
+// writeBinaryAction encodes an action in a binary format,
+// if the action's type supports it.
+func writeBinaryAction(w io.Writer, act interface{}) (bool, error) {
+	switch act := act.(type) {
+	case int:
+		if act < 0 {
+			return false, nil
+		}
+		return true, writeDiscreteAction(w, uint64(act))
+	case int64:
+		if act < 0 {
+			return false, nil
+		}
+		return true, writeDiscreteAction(w, uint64(act))
+	case int32:
+		if act < 0 {
+			return false, nil
+		}
+		return true, writeDiscreteAction(w, uint64(act))
+	case uint8:
+		return true, writeDiscreteAction(w, uint64(act))
+	case uint32:
+		return true, writeDiscreteAction(w, uint64(act))
+	case uint64:
+		return true, writeDiscreteAction(w, act)
+	case []float64:
+		if err := writeBoxHeader(w, len(act)); err != nil {
+			return true, err
+		}
+		for _, x := range act {
+			if err := writeFloat64(w, x); err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	case []float32:
+		if err := writeBoxHeader(w, len(act)); err != nil {
+			return true, err
+		}
+		for _, x := range act {
+			if err := writeFloat64(w, float64(x)); err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func writeDiscreteAction(w io.Writer, act uint64) error {
+	if err := writeByte(w, actionDiscrete); err != nil {
+		return err
+	}
+	for act >= 0x80 {
+		if err := writeByte(w, byte(act&0x7f)|0x80); err != nil {
+			return err
+		}
+		act >>= 7
+	}
+	return writeByte(w, byte(act))
+}
+
+func writeBoxHeader(w io.Writer, size int) error {
+	if err := writeByte(w, actionBox); err != nil {
+		return err
+	}
+	return writeUint32(w, uint32(size))
+}
+
+func writeFloat64(w io.Writer, x float64) error {
+	bits := math.Float64bits(x)
+	if err := writeUint32(w, uint32(bits)); err != nil {
+		return err
+	}
+	return writeUint32(w, uint32(bits>>32))
+}
+
+// readStepResult reads the response to a step command.
+//
+// The decode argument is passed to readObservation.
+func readStepResult(r io.Reader, decode byteListDecoder, mode infoMode) (obs Obs,
+	reward float64, done bool, info interface{}, err error) {
+	obs, err = readObservation(r, decode)
+	if err != nil {
+		return
+	}
+	reward, err = readReward(r)
+	if err != nil {
+		return
+	}
+	done, err = readBool(r)
+	if err != nil {
+		return
+	}
+	err = readInfo(r, mode, &info)
+	return
+}
+
+// readBlindStepResult reads the response to a blind step,
+// whose observation is nil unless the episode is done.
+func readBlindStepResult(r *bufio.Reader, decode byteListDecoder, mode infoMode) (obs Obs,
+	reward float64, done bool, info interface{}, err error) {
+	typeID, err := r.Peek(1)
+	if err != nil {
+		return
+	}
+	if typeID[0] != observationNone {
+		return readStepResult(r, decode, mode)
+	}
+	r.Discard(1)
+	reward, err = readReward(r)
+	if err != nil {
+		return
+	}
+	done, err = readBool(r)
+	if err != nil {
+		return
+	}
+	if done {
+		err = errors.New("missing observation at end of episode")
+		return
+	}
+	err = readInfo(r, mode, &info)
+	return
+}
+
+// infoMode determines how info objects are decoded.
+type infoMode int
+
+const (
+	infoDecode infoMode = iota
+	infoRaw
+	infoSkip
+	infoNumber
+)
+
+// readInfo reads the info object from a step.
+func readInfo(r io.Reader, mode infoMode, info *interface{}) error {
+	return readTempField(r, func(data []byte) error {
+		switch mode {
+		case infoRaw:
+			*info = json.RawMessage(append([]byte{}, data...))
+			return nil
+		case infoSkip:
+			return nil
+		case infoNumber:
+			return unmarshalNumbers(data, info)
+		}
+		return json.Unmarshal(data, info)
+	})
+}
+
+// readBatchInfo reads the info objects from a batched
+// step.
+func readBatchInfo(r io.Reader, mode infoMode, n int) (infos []interface{}, err error) {
+	err = readTempField(r, func(data []byte) error {
+		switch mode {
+		case infoRaw:
+			var raw []json.RawMessage
+			if err := json.Unmarshal(data, &raw); err != nil {
+				return err
+			}
+			for _, info := range raw {
+				infos = append(infos, info)
+			}
+			return nil
+		case infoSkip:
+			infos = make([]interface{}, n)
+			return nil
+		case infoNumber:
+			return unmarshalNumbers(data, &infos)
+		}
+		return json.Unmarshal(data, &infos)
+	})
+	if err == nil && len(infos) != n {
+		err = fmt.Errorf("expected %d infos but got %d", n, len(infos))
+	}
+	return
+}
+
+// unmarshalNumbers is like json.Unmarshal, except that it
+// decodes numbers in interface{} values as json.Numbers.
+func unmarshalNumbers(data []byte, dst interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(dst); err != nil {
+		return err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level JSON value")
+	}
+	return nil
+}
+
 func readReward(r io.Reader) (float64, error) {
-	var res float64
-	if err := binary.Read(r, byteOrder, &res); err != nil {
+	bits, err := readUint(r, 8)
+	if err != nil {
 		return 0, err
 	}
-	return res, nil
+	return math.Float64frombits(bits), nil
 }
 
 func readBool(r io.Reader) (bool, error) {
-	var b uint8
-	if err := binary.Read(r, byteOrder, &b); err != nil {
+	b, err := readUint(r, 1)
+	if err != nil {
 		return false, err
 	}
 	if b != 0 && b != 1 {
@@ -189,11 +791,8 @@ func readBool(r io.Reader) (bool, error) {
 }
 
 func writeBool(w io.Writer, b bool) error {
-	var err error
 	if b {
-		_, err = w.Write([]byte{1})
-	} else {
-		_, err = w.Write([]byte{0})
+		return writeByte(w, 1)
 	}
-	return err
+	return writeByte(w, 0)
 }
@@ -0,0 +1,187 @@
+package gym
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestReadByteFieldLimit(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, byteOrder, uint32(MaxFieldSize+1))
+	if _, err := readByteField(&buf); err == nil {
+		t.Error("expected an error for an oversized field")
+	}
+
+	buf.Reset()
+	binary.Write(&buf, byteOrder, uint32(fieldChunkSize*3))
+	buf.Write(make([]byte, fieldChunkSize))
+	if _, err := readByteField(&buf); err == nil {
+		t.Error("expected an error for a truncated field")
+	}
+}
+
+func FuzzReadObservation(f *testing.F) {
+	f.Add([]byte{observationJSON, 3, 0, 0, 0, '[', '1', ']'})
+	f.Add([]byte{observationByteList, 14, 0, 0, 0,
+		2, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 5, 6})
+	f.Add([]byte{observationByteList, 8, 0, 0, 0,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		obs, err := readObservation(bytes.NewReader(data), nil)
+		if err != nil {
+			return
+		}
+		if u, ok := obs.(*uint8Obs); ok {
+			product := 1
+			for _, dim := range u.Dims {
+				product *= dim
+			}
+			if product != len(u.Values) {
+				t.Errorf("dims %v do not match %d values", u.Dims, len(u.Values))
+			}
+		}
+	})
+}
+
+func FuzzDecodeSpace(f *testing.F) {
+	f.Add([]byte(`{"type": "Discrete", "n": 4}`))
+	f.Add([]byte(`{"type": "Box", "low": [0, 0], "high": [1, 1], "shape": [2]}`))
+	f.Add([]byte(`{"type": "Tuple", "subspaces": [{"type": "Discrete", "n": 2}, null]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		space, err := decodeSpace(data)
+		if err != nil {
+			return
+		}
+		if space.Type == "Box" && !shapeMatches(space.Shape, len(space.Low)) {
+			t.Errorf("invalid Box space: %+v", space)
+		}
+	})
+}
+
+func TestReadObservationReuse(t *testing.T) {
+	var response bytes.Buffer
+	response.WriteByte(observationByteList)
+	writeUint32(&response, 8+84*84)
+	writeUint32(&response, 1)
+	writeUint32(&response, 84*84)
+	response.Write(make([]byte, 84*84))
+	data := response.Bytes()
+
+	reader := bytes.NewReader(data)
+	buf := bufio.NewReader(reader)
+	decode := reuseUint8Obs()
+	var lastObs Obs
+	allocs := testing.AllocsPerRun(100, func() {
+		reader.Reset(data)
+		buf.Reset(reader)
+		obs, err := readObservation(buf, decode)
+		if err != nil {
+			t.Fatal(err)
+		} else if lastObs != nil && obs != lastObs {
+			t.Fatal("observation was not reused")
+		}
+		lastObs = obs
+	})
+	if allocs != 0 {
+		t.Errorf("expected 0 allocations but got %f", allocs)
+	}
+}
+
+func TestReadObservationStream(t *testing.T) {
+	var response bytes.Buffer
+	response.WriteByte(observationByteList)
+	writeUint32(&response, 12+6)
+	writeUint32(&response, 2)
+	writeUint32(&response, 2)
+	writeUint32(&response, 3)
+	response.Write([]byte{1, 2, 3, 4, 5, 6})
+	writeBool(&response, true)
+
+	var gotDims []int
+	handler := func(index int, dims []int, values io.Reader) (Obs, error) {
+		gotDims = append([]int{}, dims...)
+		row := make([]byte, dims[1])
+		if _, err := io.ReadFull(values, row); err != nil {
+			return nil, err
+		}
+		return jsonObs(fmt.Sprint(row)), nil
+	}
+	buf := bufio.NewReader(&response)
+	obs, err := readObservation(buf, streamUint8Obs(handler, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotDims, []int{2, 3}) {
+		t.Errorf("unexpected dims: %v", gotDims)
+	}
+	if s := obs.(jsonObs).String(); s != "[1 2 3]" {
+		t.Errorf("unexpected observation: %s", s)
+	}
+	// The unread row should be skipped.
+	if done, err := readBool(buf); err != nil || !done {
+		t.Errorf("unexpected trailing data: %v %v", done, err)
+	}
+}
+
+func TestReadInfoNumbers(t *testing.T) {
+	var buf bytes.Buffer
+	writeByteField(&buf, []byte(`{"id": 9007199254740993, "score": 0.5}`))
+	var info interface{}
+	if err := readInfo(&buf, infoNumber, &info); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"id":    json.Number("9007199254740993"),
+		"score": json.Number("0.5"),
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("expected %v but got %v", expected, info)
+	}
+
+	buf.Reset()
+	writeByteField(&buf, []byte(`[{"id": 9007199254740993}, {}]`))
+	infos, err := readBatchInfo(&buf, infoNumber, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := infos[0].(map[string]interface{})["id"]; id != json.Number("9007199254740993") {
+		t.Errorf("unexpected batch info ID: %v", id)
+	}
+
+	buf.Reset()
+	writeByteField(&buf, []byte(`{} {}`))
+	if err := readInfo(&buf, infoNumber, &info); err == nil {
+		t.Error("expected an error for trailing data")
+	}
+}
+
+func BenchmarkReadStepResponse(b *testing.B) {
+	var response bytes.Buffer
+	header := []byte{2, 0, 0, 0, 84, 0, 0, 0, 84, 0, 0, 0}
+	response.WriteByte(observationByteList)
+	writeUint32(&response, uint32(len(header)+84*84))
+	response.Write(header)
+	response.Write(make([]byte, 84*84))
+	binary.Write(&response, byteOrder, 1.0)
+	writeBool(&response, false)
+	writeByteField(&response, []byte(`{"ale.lives": 3}`))
+	data := response.Bytes()
+
+	reader := bytes.NewReader(data)
+	buf := bufio.NewReader(reader)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(data)
+		buf.Reset(reader)
+		if _, _, _, _, err := readStepResult(buf, nil, infoDecode); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
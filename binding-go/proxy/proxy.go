@@ -0,0 +1,382 @@
+// Package proxy relays the gym-socket-api protocol from
+// clients to one or more backend servers.
+//
+// A Proxy reads the handshake of each client connection,
+// checks its auth token, and picks a backend by the name of
+// the requested environment.
+// After the handshake, traffic is forwarded as is, so the
+// proxy works with every command packet.
+//
+// TLS is terminated by serving on a listener from
+// crypto/tls.
+package proxy
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// Handshake flags which the proxy needs to understand.
+const (
+	flagBatch   = 0x01
+	flagMulti   = 0x04
+	flagSession = 0x10
+	flagResume  = 0x20
+	flagAuth    = 0x80
+)
+
+// maxFieldSize limits the size of handshake fields, which
+// are read before a client is authenticated.
+const maxFieldSize = 1 << 16
+
+// DefaultHandshakeTimeout is the HandshakeTimeout used
+// when a Config does not specify one.
+const DefaultHandshakeTimeout = time.Second * 30
+
+// A Route sends the environments whose names match a
+// pattern to a set of backend servers.
+type Route struct {
+	// Pattern is matched against environment names with
+	// path.Match, e.g. "*NoFrameskip-v4" or "*".
+	Pattern string
+
+	// Backends are the addresses of the servers for the
+	// route.
+	// Connections are spread over them in turn.
+	Backends []string
+
+	next uint64
+}
+
+// Config configures a Proxy.
+type Config struct {
+	// Routes are tried in order, and the first route whose
+	// pattern matches an environment name is used.
+	//
+	// Connections without an environment, such as those
+	// made by gym.Ping or gym.ServerStats, have the name
+	// "", which is matched by "*".
+	Routes []*Route
+
+	// AuthTokens are the tokens which clients may send.
+	// If empty, clients are not authenticated.
+	AuthTokens []string
+
+	// Dial connects to backends.
+	// If nil, net.Dial is used.
+	Dial gym.DialFunc
+
+	// HandshakeTimeout limits the time for a client to
+	// send its handshake.
+	// If 0, DefaultHandshakeTimeout is used.
+	HandshakeTimeout time.Duration
+
+	// Logger receives connection errors.
+	// If nil, the standard logger is used.
+	Logger *log.Logger
+}
+
+// A Proxy relays client connections to backend servers.
+type Proxy struct {
+	config Config
+
+	// backends lists every backend address, so that a
+	// session token can record the backend which owns the
+	// session.
+	backends     []string
+	backendIndex map[string]int
+}
+
+// New creates a Proxy.
+func New(config *Config) (*Proxy, error) {
+	p := &Proxy{config: *config, backendIndex: map[string]int{}}
+	if len(p.config.Routes) == 0 {
+		return nil, errors.New("create proxy: no routes")
+	}
+	for _, route := range p.config.Routes {
+		if _, err := path.Match(route.Pattern, ""); err != nil {
+			return nil, essentials.AddCtx("create proxy",
+				fmt.Errorf("route %q: %w", route.Pattern, err))
+		}
+		if len(route.Backends) == 0 {
+			return nil, fmt.Errorf("create proxy: route %q has no backends", route.Pattern)
+		}
+		for _, addr := range route.Backends {
+			if _, ok := p.backendIndex[addr]; !ok {
+				p.backendIndex[addr] = len(p.backends)
+				p.backends = append(p.backends, addr)
+			}
+		}
+	}
+	if p.config.Dial == nil {
+		p.config.Dial = net.Dial
+	}
+	if p.config.HandshakeTimeout == 0 {
+		p.config.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	return p, nil
+}
+
+// Serve accepts connections from a listener and relays
+// each one in its own Goroutine.
+//
+// It returns when the listener fails, e.g. because it was
+// closed.
+func (p *Proxy) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go p.ServeConn(conn)
+	}
+}
+
+// ServeConn relays one client connection until either
+// side closes it.
+func (p *Proxy) ServeConn(conn net.Conn) {
+	defer conn.Close()
+	if err := p.serveConn(conn); err != nil && err != io.EOF {
+		p.logf("%s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+func (p *Proxy) serveConn(conn net.Conn) error {
+	client := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	conn.SetDeadline(time.Now().Add(p.config.HandshakeTimeout))
+	hello, err := readHello(client)
+	if err != nil {
+		return err
+	}
+	if !p.authorized(hello.AuthToken) {
+		writeError(client, gym.CodeUnauthorized, "invalid auth token")
+		return errors.New("invalid auth token")
+	}
+	backend, routeErr := p.pickBackend(hello)
+	if routeErr != nil {
+		writeError(client, routeErr.Code, routeErr.Message)
+		return routeErr
+	}
+
+	backendConn, dialErr := p.config.Dial("tcp", backend)
+	if dialErr != nil {
+		writeError(client, gym.CodeMakeFailed, "backend unavailable")
+		return dialErr
+	}
+	defer backendConn.Close()
+	backendConn.SetDeadline(time.Now().Add(p.config.HandshakeTimeout))
+	server := bufio.NewReadWriter(bufio.NewReader(backendConn),
+		bufio.NewWriter(backendConn))
+	if err := writeHello(server, hello); err != nil {
+		return err
+	}
+	if err := p.relayHandshakeResponse(server, client, hello, backend); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	backendConn.SetDeadline(time.Time{})
+
+	// Forward traffic in both directions until one side
+	// closes its connection.
+	var once sync.Once
+	done := make(chan struct{})
+	finish := func() {
+		once.Do(func() {
+			conn.Close()
+			backendConn.Close()
+			close(done)
+		})
+	}
+	go func() {
+		io.Copy(backendConn, client)
+		finish()
+	}()
+	go func() {
+		io.Copy(conn, server)
+		finish()
+	}()
+	<-done
+	return nil
+}
+
+// relayHandshakeResponse forwards the backend's response
+// to a handshake, tagging the session token with the
+// backend.
+func (p *Proxy) relayHandshakeResponse(server, client *bufio.ReadWriter,
+	hello *clientHello, backend string) error {
+	errField, err := readField(server)
+	if err != nil {
+		return err
+	}
+	if err := writeField(client, errField); err != nil {
+		return err
+	}
+	if len(errField) == 0 && hello.Flags&flagSession != 0 {
+		token, err := readField(server)
+		if err != nil {
+			return err
+		}
+		tagged := strconv.Itoa(p.backendIndex[backend]) + "." + string(token)
+		if err := writeField(client, []byte(tagged)); err != nil {
+			return err
+		}
+	}
+	return client.Flush()
+}
+
+func (p *Proxy) authorized(token string) bool {
+	if len(p.config.AuthTokens) == 0 {
+		return true
+	}
+	for _, valid := range p.config.AuthTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// pickBackend chooses the backend for a handshake.
+//
+// When resuming a session, the backend is read from the
+// session token and the tag is removed from the token.
+func (p *Proxy) pickBackend(hello *clientHello) (string, *gym.EnvError) {
+	if hello.Flags&flagResume != 0 {
+		parts := strings.SplitN(hello.Name, ".", 2)
+		idx, err := strconv.Atoi(parts[0])
+		if len(parts) != 2 || err != nil || idx < 0 || idx >= len(p.backends) {
+			return "", &gym.EnvError{
+				Code:    gym.CodeUnknownSession,
+				Message: "unknown session",
+			}
+		}
+		hello.Name = parts[1]
+		return p.backends[idx], nil
+	}
+	for _, route := range p.config.Routes {
+		if ok, _ := path.Match(route.Pattern, hello.Name); ok {
+			idx := atomic.AddUint64(&route.next, 1) - 1
+			return route.Backends[idx%uint64(len(route.Backends))], nil
+		}
+	}
+	return "", &gym.EnvError{
+		Code:    gym.CodeUnknownEnv,
+		Message: "no route for environment: " + hello.Name,
+	}
+}
+
+func (p *Proxy) logf(format string, args ...interface{}) {
+	if p.config.Logger != nil {
+		p.config.Logger.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// clientHello is a handshake request.
+type clientHello struct {
+	Flags     byte
+	Name      string
+	BatchSize uint32
+	NumEnvs   uint32
+	AuthToken string
+}
+
+func readHello(r io.Reader) (*clientHello, error) {
+	var flags [1]byte
+	if _, err := io.ReadFull(r, flags[:]); err != nil {
+		return nil, err
+	}
+	hello := &clientHello{Flags: flags[0]}
+	name, err := readField(r)
+	if err != nil {
+		return nil, err
+	}
+	hello.Name = string(name)
+	if hello.Flags&flagBatch != 0 {
+		if err := binary.Read(r, binary.LittleEndian, &hello.BatchSize); err != nil {
+			return nil, err
+		}
+	}
+	if hello.Flags&flagMulti != 0 {
+		if err := binary.Read(r, binary.LittleEndian, &hello.NumEnvs); err != nil {
+			return nil, err
+		}
+	}
+	if hello.Flags&flagAuth != 0 {
+		token, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+		hello.AuthToken = string(token)
+	}
+	return hello, nil
+}
+
+// writeHello forwards a handshake request to a backend,
+// without the auth token.
+func writeHello(w *bufio.ReadWriter, hello *clientHello) error {
+	if err := w.WriteByte(hello.Flags &^ flagAuth); err != nil {
+		return err
+	}
+	if err := writeField(w, []byte(hello.Name)); err != nil {
+		return err
+	}
+	if hello.Flags&flagBatch != 0 {
+		if err := binary.Write(w, binary.LittleEndian, hello.BatchSize); err != nil {
+			return err
+		}
+	}
+	if hello.Flags&flagMulti != 0 {
+		if err := binary.Write(w, binary.LittleEndian, hello.NumEnvs); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func readField(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	if length > maxFieldSize {
+		return nil, fmt.Errorf("handshake field is too long (%d bytes)", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func writeField(w io.Writer, data []byte) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// writeError sends a failed handshake response.
+func writeError(w *bufio.ReadWriter, code, message string) {
+	data, _ := json.Marshal(map[string]string{"code": code, "message": message})
+	writeField(w, data)
+	w.Flush()
+}
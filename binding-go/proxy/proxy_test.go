@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+func TestProxyRouting(t *testing.T) {
+	backendA := startBackend(t, 1)
+	backendB := startBackend(t, 2)
+	addr := startProxy(t, &Config{
+		Routes: []*Route{
+			{Pattern: "Pong*", Backends: []string{backendB}},
+			{Pattern: "*", Backends: []string{backendA}},
+		},
+		AuthTokens: []string{"secret"},
+	})
+
+	for name, pid := range map[string]int{"Pong-v0": 2, "CartPole-v0": 1} {
+		env, err := gym.Make(addr, name, gym.WithAuthToken("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := env.Ping()
+		env.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status.EnvName != name || res.Status.PID != pid {
+			t.Errorf("%s: unexpected status %+v", name, res.Status)
+		}
+	}
+
+	_, err := gym.Make(addr, "CartPole-v0", gym.WithAuthToken("wrong"))
+	if !errors.Is(err, gym.ErrUnauthorized) {
+		t.Errorf("unexpected error for bad token: %v", err)
+	}
+	_, err = gym.Make(addr, "CartPole-v0")
+	if !errors.Is(err, gym.ErrUnauthorized) {
+		t.Errorf("unexpected error for missing token: %v", err)
+	}
+}
+
+func TestProxyNoRoute(t *testing.T) {
+	addr := startProxy(t, &Config{
+		Routes: []*Route{{Pattern: "Pong*", Backends: []string{startBackend(t, 1)}}},
+	})
+	_, err := gym.Make(addr, "CartPole-v0")
+	if !errors.Is(err, gym.ErrUnknownEnv) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestProxyResume(t *testing.T) {
+	backendA := startBackend(t, 1)
+	backendB := startBackend(t, 2)
+	addr := startProxy(t, &Config{
+		Routes: []*Route{{Pattern: "*", Backends: []string{backendA, backendB}}},
+	})
+
+	// Make sure the session lives on the second backend,
+	// so that resuming it depends on the tag.
+	env, err := gym.Make(addr, "CartPole-v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+	env, err = gym.Make(addr, "CartPole-v0", gym.Resumable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := env.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status.EnvName != "resumed CartPole-v0" || res.Status.PID != 2 {
+		t.Errorf("unexpected status: %+v", res.Status)
+	}
+}
+
+func startProxy(t *testing.T, config *Config) string {
+	config.Logger = log.New(ioutil.Discard, "", 0)
+	p, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go p.Serve(listener)
+	return listener.Addr().String()
+}
+
+// startBackend runs a fake server which answers pings
+// with the environment name and the given PID.
+//
+// Sessions get the token "token-<env name>", and resumed
+// sessions report their name as "resumed <env name>".
+func startBackend(t *testing.T, pid int) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveBackend(conn, pid)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveBackend(conn net.Conn, pid int) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second * 10))
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	hello, err := readHello(rw)
+	if err != nil || hello.Flags&flagAuth != 0 {
+		return
+	}
+	name := hello.Name
+	writeField(rw, nil)
+	if hello.Flags&flagSession != 0 {
+		writeField(rw, []byte("token-"+name))
+	} else if hello.Flags&flagResume != 0 {
+		name = "resumed " + name[len("token-"):]
+	}
+	rw.Flush()
+	for {
+		packetType, err := rw.ReadByte()
+		if err != nil {
+			return
+		}
+		if packetType != 13 {
+			io.Copy(ioutil.Discard, rw)
+			return
+		}
+		status, _ := json.Marshal(map[string]interface{}{"env_name": name, "pid": pid})
+		writeField(rw, status)
+		rw.Flush()
+	}
+}
@@ -0,0 +1,88 @@
+package record
+
+import (
+	"errors"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// A Bookmark is a checkpoint of an episode which is being
+// recorded.
+// It can be used to resume the episode later, even on a
+// different server, which is useful for environments with
+// very long episodes.
+//
+// Bookmarks can be saved and loaded with encoding/json.
+type Bookmark struct {
+	// Episode and Step are the index of the episode and
+	// the index of its next step.
+	Episode int `json:"episode"`
+	Step    int `json:"step"`
+
+	// State is the environment state from CloneState.
+	State []byte `json:"state"`
+
+	// Obs is the latest observation of the episode, which
+	// is always stored inline.
+	Obs *ObsRecord `json:"obs"`
+}
+
+// Bookmark saves the state of the current episode.
+//
+// The environment must support gym.Env.CloneState.
+func (r *Recorder) Bookmark() (b *Bookmark, err error) {
+	defer essentials.AddCtxTo("bookmark episode", &err)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.lastObs == nil {
+		return nil, errors.New("no episode in progress")
+	}
+	state, err := r.Env.CloneState()
+	if err != nil {
+		return nil, err
+	}
+	obs, err := encodeObs(r.lastObs)
+	if err != nil {
+		return nil, err
+	}
+	obs.Shape = append([]int{}, obs.Shape...)
+	obs.Data = append([]byte{}, obs.Data...)
+	return &Bookmark{Episode: r.episode, Step: r.step, State: state, Obs: obs}, nil
+}
+
+// Resume continues an episode from a Bookmark.
+//
+// The environment is reset, and then its state is
+// restored.
+// A resume entry is recorded, and later steps continue
+// the bookmarked episode's numbering.
+//
+// The returned observation is the one from when the
+// Bookmark was made, and should be used in place of the
+// observation from a Reset.
+func (r *Recorder) Resume(b *Bookmark) (obs gym.Obs, err error) {
+	defer essentials.AddCtxTo("resume episode", &err)
+	if b.Obs == nil {
+		return nil, errors.New("bookmark has no observation")
+	}
+	obs, err = b.Obs.Decode("")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Env.Reset(); err != nil {
+		return nil, err
+	}
+	if err := r.Env.RestoreState(b.State); err != nil {
+		return nil, err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.episode = b.Episode
+	r.step = b.Step
+	entry := &Entry{Type: EntryResume, Episode: b.Episode, Step: b.Step}
+	if err := r.write(entry, obs); err != nil {
+		return nil, err
+	}
+	return obs, nil
+}
@@ -0,0 +1,97 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+// stateEnv is a testEnv which supports snapshots.
+type stateEnv struct {
+	testEnv
+}
+
+func (s *stateEnv) CloneState() ([]byte, error) {
+	return []byte(strconv.Itoa(s.t)), nil
+}
+
+func (s *stateEnv) RestoreState(state []byte) (err error) {
+	s.t, err = strconv.Atoi(string(state))
+	return
+}
+
+func TestBookmark(t *testing.T) {
+	var buf bytes.Buffer
+	env, err := NewRecorder(&stateEnv{testEnv{JSON: true}}, &buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Bookmark(); err == nil {
+		t.Error("expected error before reset")
+	}
+	env.Reset()
+	env.Step(1)
+	bookmark, err := env.Bookmark()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	// Save the bookmark and resume on a new environment.
+	data, err := json.Marshal(bookmark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Bookmark
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	var buf1 bytes.Buffer
+	env, err = NewRecorder(&stateEnv{testEnv{JSON: true}}, &buf1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs, err := env.Resume(&loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]int
+	if err := obs.Unmarshal(&obj); err != nil || obj["t"] != 1 {
+		t.Errorf("unexpected observation: %v", obj)
+	}
+	_, _, done, _, err := env.Step(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !done {
+		t.Error("episode should be done after restoring its state")
+	}
+	env.Close()
+
+	dataset, err := ReadDataset(&buf1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	episode := dataset.Episodes[0]
+	if episode.Reset.Type != EntryResume || len(episode.Steps) != 1 ||
+		episode.Steps[0].Step != 1 || episode.Steps[0].Episode != 0 {
+		t.Errorf("unexpected resumed episode: %+v", episode)
+	}
+}
+
+func TestUnsupportedBookmark(t *testing.T) {
+	var buf bytes.Buffer
+	playback := NewPlayback(&Dataset{Episodes: []*EpisodeData{{Reset: &Entry{
+		Type: EntryReset,
+		Obs:  &ObsRecord{JSON: []byte("1")},
+	}}}}, nil)
+	env, err := NewRecorder(playback, &buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Reset()
+	if _, err := env.Bookmark(); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,79 @@
+package record
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A Dataset holds the episodes of a trajectory file in
+// memory.
+type Dataset struct {
+	// BlobDir is the directory of binary blobs, if there is
+	// one.
+	BlobDir string
+
+	Episodes []*EpisodeData
+}
+
+// EpisodeData holds the entries of one episode.
+//
+// An episode which was resumed from a Bookmark is split in
+// two, and the second part starts with the resume entry.
+type EpisodeData struct {
+	// Reset is the reset or resume entry that started the
+	// episode.
+	Reset *Entry
+	Steps []*Entry
+}
+
+// LoadDataset reads a Dataset from a trajectory file.
+//
+// The blobDir should be the same one that was passed to
+// NewRecorder.
+func LoadDataset(path, blobDir string) (d *Dataset, err error) {
+	defer essentials.AddCtxTo("load dataset", &err)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadDataset(f, blobDir)
+}
+
+// ReadDataset reads a Dataset from a trajectory file.
+//
+// Steps from before the first reset are skipped.
+func ReadDataset(r io.Reader, blobDir string) (*Dataset, error) {
+	res := &Dataset{BlobDir: blobDir}
+	reader := NewReader(r, blobDir)
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if entry.Type == EntryReset || entry.Type == EntryResume {
+			res.Episodes = append(res.Episodes, &EpisodeData{Reset: entry})
+		} else if len(res.Episodes) > 0 {
+			last := res.Episodes[len(res.Episodes)-1]
+			last.Steps = append(last.Steps, entry)
+		}
+	}
+	if len(res.Episodes) == 0 {
+		return nil, errors.New("no episodes")
+	}
+	return res, nil
+}
+
+// NumSteps counts the steps in every episode.
+func (d *Dataset) NumSteps() int {
+	var res int
+	for _, ep := range d.Episodes {
+		res += len(ep.Steps)
+	}
+	return res
+}
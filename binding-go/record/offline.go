@@ -0,0 +1,88 @@
+package record
+
+import (
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// offlineEnv implements the parts of gym.Env that an
+// environment without a server cannot support.
+// Its methods fail with gym.ErrUnsupported.
+type offlineEnv struct {
+	gym.UnsupportedUniverse
+	gym.UnsupportedRetro
+}
+
+func (offlineEnv) ActionSpace() (*gym.Space, error) {
+	return nil, unsupported("action space")
+}
+
+func (offlineEnv) ObservationSpace() (*gym.Space, error) {
+	return nil, unsupported("observation space")
+}
+
+func (offlineEnv) SampleAction(dst interface{}) error {
+	return unsupported("sample action")
+}
+
+func (offlineEnv) Monitor(dir string, force, resume, video bool) error {
+	return unsupported("monitor")
+}
+
+func (offlineEnv) Render() error {
+	return unsupported("render")
+}
+
+func (offlineEnv) Configure(options map[string]interface{}) error {
+	return unsupported("configure")
+}
+
+func (offlineEnv) Ping() (*gym.PingResult, error) {
+	return nil, unsupported("ping")
+}
+
+func (offlineEnv) SetLogLevel(level string) error {
+	return unsupported("set log level")
+}
+
+// Stats returns empty stats, since nothing is sent to a
+// server.
+func (offlineEnv) Stats() *gym.EnvStats {
+	return &gym.EnvStats{}
+}
+
+func (offlineEnv) CloneState() ([]byte, error) {
+	return nil, unsupported("clone state")
+}
+
+func (offlineEnv) RestoreState(state []byte) error {
+	return unsupported("restore state")
+}
+
+func (offlineEnv) GetAttr(name string, dst interface{}) error {
+	return unsupported("get attribute")
+}
+
+func (offlineEnv) CallMethod(name string, dst interface{}, args ...interface{}) error {
+	return unsupported("call method")
+}
+
+func (offlineEnv) KeepAlive() error {
+	return nil
+}
+
+func (offlineEnv) Renew() error {
+	return nil
+}
+
+func (offlineEnv) Reconnect() error {
+	return nil
+}
+
+func (offlineEnv) Close() error {
+	return nil
+}
+
+func unsupported(op string) error {
+	return essentials.AddCtx(op+" (recorded environment)", gym.ErrUnsupported)
+}
@@ -0,0 +1,408 @@
+package record
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// parquetRowGroupSize is the number of rows in each row
+// group of a Parquet file, which bounds the memory used
+// while exporting.
+const parquetRowGroupSize = 4096
+
+// ExportParquet converts a trajectory file into a Parquet
+// file, which can be loaded by tools like pandas, Arrow,
+// and DuckDB.
+//
+// Each row is a transition in the layout of D4RL, with the
+// columns:
+//
+//   - episode, step: the location of the transition.
+//   - observation: the observation before the action, as
+//     raw bytes for byte list observations or as JSON
+//     otherwise.
+//   - action: the action, as JSON.
+//   - reward: the reward for the action.
+//   - terminal: whether the episode ended after the action.
+//   - timeout: whether the episode was cut off after the
+//     action, e.g. by a time limit, without ending.
+//
+// The final observation of each episode is not stored.
+// The shape of byte list observations is stored in the
+// file's metadata under the key "observation_shape".
+func ExportParquet(w io.Writer, r *Reader) (err error) {
+	defer essentials.AddCtxTo("export Parquet", &err)
+	p := &parquetWriter{w: &countWriter{W: w}}
+	if err := p.start(); err != nil {
+		return err
+	}
+	var pending *parquetRow
+	err = readSteps(r, func(step *rldsStep) error {
+		if step.IsLast {
+			if pending != nil {
+				pending.Terminal = step.IsTerminal
+				pending.Timeout = !step.IsTerminal
+				if err := p.writeRow(pending); err != nil {
+					return err
+				}
+				pending = nil
+			}
+			return nil
+		}
+		if pending != nil {
+			if err := p.writeRow(pending); err != nil {
+				return err
+			}
+		}
+		pending = &parquetRow{
+			Episode: int64(step.Episode),
+			Step:    int64(step.Index),
+			Action:  step.Action,
+			Reward:  step.Reward,
+		}
+		pending.Obs, err = p.encodeObs(step.Obs)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if pending != nil {
+		if err := p.writeRow(pending); err != nil {
+			return err
+		}
+	}
+	return p.finish()
+}
+
+type parquetRow struct {
+	Episode  int64
+	Step     int64
+	Obs      []byte
+	Action   []byte
+	Reward   float64
+	Terminal bool
+	Timeout  bool
+}
+
+// Parquet physical types.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+var parquetColumns = []struct {
+	Name string
+	Type int
+	UTF8 bool
+}{
+	{"episode", parquetInt64, false},
+	{"step", parquetInt64, false},
+	{"observation", parquetByteArray, false},
+	{"action", parquetByteArray, true},
+	{"reward", parquetDouble, false},
+	{"terminal", parquetBoolean, false},
+	{"timeout", parquetBoolean, false},
+}
+
+// parquetWriter writes a Parquet file with one data page
+// per column chunk, using plain encoding and no
+// compression.
+type parquetWriter struct {
+	w *countWriter
+
+	obsShape []int
+	rows     []*parquetRow
+
+	numRows   int64
+	rowGroups [][]byte
+}
+
+func (p *parquetWriter) start() error {
+	_, err := p.w.Write([]byte("PAR1"))
+	return err
+}
+
+func (p *parquetWriter) encodeObs(obs gym.Obs) ([]byte, error) {
+	if u8, ok := obs.(gym.Uint8Obs); ok {
+		values := u8.Uint8Obs()
+		if p.obsShape == nil {
+			p.obsShape = []int{len(values)}
+			if shaped, ok := obs.(gym.ShapedObs); ok {
+				p.obsShape = append([]int{}, shaped.Shape()...)
+			}
+		}
+		return append([]byte{}, values...), nil
+	}
+	var data json.RawMessage
+	if err := obs.Unmarshal(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (p *parquetWriter) writeRow(row *parquetRow) error {
+	p.rows = append(p.rows, row)
+	if len(p.rows) == parquetRowGroupSize {
+		return p.flushRowGroup()
+	}
+	return nil
+}
+
+func (p *parquetWriter) flushRowGroup() error {
+	if len(p.rows) == 0 {
+		return nil
+	}
+	var columns [][]byte
+	var totalSize int64
+	for i, col := range parquetColumns {
+		data := p.encodeColumn(i)
+
+		var pageHeader thriftWriter
+		pageHeader.I32(1, 0) // DATA_PAGE
+		pageHeader.I32(2, int32(len(data)))
+		pageHeader.I32(3, int32(len(data)))
+		pageHeader.BeginStruct(5)
+		pageHeader.I32(1, int32(len(p.rows)))
+		pageHeader.I32(2, 0) // PLAIN
+		pageHeader.I32(3, 3) // RLE
+		pageHeader.I32(4, 3) // RLE
+		pageHeader.EndStruct()
+		pageHeader.Stop()
+
+		offset := p.w.N
+		if _, err := p.w.Write(pageHeader.Buf); err != nil {
+			return err
+		}
+		if _, err := p.w.Write(data); err != nil {
+			return err
+		}
+		size := p.w.N - offset
+		totalSize += size
+
+		var chunk thriftWriter
+		chunk.I64(2, offset)
+		chunk.BeginStruct(3)
+		chunk.I32(1, int32(col.Type))
+		chunk.ListHeader(2, thriftI32, 1)
+		chunk.Varint(0) // PLAIN
+		chunk.ListHeader(3, thriftBinary, 1)
+		chunk.String(col.Name)
+		chunk.I32(4, 0) // UNCOMPRESSED
+		chunk.I64(5, int64(len(p.rows)))
+		chunk.I64(6, size)
+		chunk.I64(7, size)
+		chunk.I64(9, offset)
+		chunk.EndStruct()
+		chunk.Stop()
+		columns = append(columns, chunk.Buf)
+	}
+
+	var rowGroup thriftWriter
+	rowGroup.ListHeader(1, thriftStruct, len(columns))
+	for _, col := range columns {
+		rowGroup.Raw(col)
+	}
+	rowGroup.I64(2, totalSize)
+	rowGroup.I64(3, int64(len(p.rows)))
+	rowGroup.Stop()
+	p.rowGroups = append(p.rowGroups, rowGroup.Buf)
+
+	p.numRows += int64(len(p.rows))
+	p.rows = p.rows[:0]
+	return nil
+}
+
+// encodeColumn encodes the values of a column with the
+// plain encoding.
+func (p *parquetWriter) encodeColumn(col int) []byte {
+	var res []byte
+	var bits uint
+	for i, row := range p.rows {
+		switch col {
+		case 0:
+			res = binary.LittleEndian.AppendUint64(res, uint64(row.Episode))
+		case 1:
+			res = binary.LittleEndian.AppendUint64(res, uint64(row.Step))
+		case 2, 3:
+			data := row.Obs
+			if col == 3 {
+				data = row.Action
+			}
+			res = binary.LittleEndian.AppendUint32(res, uint32(len(data)))
+			res = append(res, data...)
+		case 4:
+			res = binary.LittleEndian.AppendUint64(res, math.Float64bits(row.Reward))
+		case 5, 6:
+			if i%8 == 0 {
+				res = append(res, 0)
+				bits = 0
+			}
+			if (col == 5 && row.Terminal) || (col == 6 && row.Timeout) {
+				res[len(res)-1] |= 1 << bits
+			}
+			bits++
+		}
+	}
+	return res
+}
+
+func (p *parquetWriter) finish() error {
+	if err := p.flushRowGroup(); err != nil {
+		return err
+	}
+
+	var meta thriftWriter
+	meta.I32(1, 1)
+	meta.ListHeader(2, thriftStruct, len(parquetColumns)+1)
+	var root thriftWriter
+	root.Binary(4, "schema")
+	root.I32(5, int32(len(parquetColumns)))
+	root.Stop()
+	meta.Raw(root.Buf)
+	for _, col := range parquetColumns {
+		var elem thriftWriter
+		elem.I32(1, int32(col.Type))
+		elem.I32(3, 0) // REQUIRED
+		elem.Binary(4, col.Name)
+		if col.UTF8 {
+			elem.I32(6, 0)
+		}
+		elem.Stop()
+		meta.Raw(elem.Buf)
+	}
+	meta.I64(3, p.numRows)
+	meta.ListHeader(4, thriftStruct, len(p.rowGroups))
+	for _, rowGroup := range p.rowGroups {
+		meta.Raw(rowGroup)
+	}
+	if p.obsShape != nil {
+		shape, _ := json.Marshal(p.obsShape)
+		var kv thriftWriter
+		kv.Binary(1, "observation_shape")
+		kv.Binary(2, string(shape))
+		kv.Stop()
+		meta.ListHeader(5, thriftStruct, 1)
+		meta.Raw(kv.Buf)
+	}
+	meta.Binary(6, "gym-socket-api")
+	meta.Stop()
+
+	footer := binary.LittleEndian.AppendUint32(meta.Buf, uint32(len(meta.Buf)))
+	footer = append(footer, "PAR1"...)
+	_, err := p.w.Write(footer)
+	return err
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes a struct with the Thrift compact
+// protocol, which Parquet uses for its metadata.
+//
+// Structs in lists are encoded by separate thriftWriters
+// and added with Raw.
+type thriftWriter struct {
+	Buf []byte
+
+	lastField  int
+	fieldStack []int
+}
+
+func (t *thriftWriter) I32(field int, x int32) {
+	t.fieldHeader(field, thriftI32)
+	t.Varint(int64(x))
+}
+
+func (t *thriftWriter) I64(field int, x int64) {
+	t.fieldHeader(field, thriftI64)
+	t.Varint(x)
+}
+
+func (t *thriftWriter) Binary(field int, s string) {
+	t.fieldHeader(field, thriftBinary)
+	t.String(s)
+}
+
+// String writes a bare string, e.g. in a list.
+func (t *thriftWriter) String(s string) {
+	t.Buf = binary.AppendUvarint(t.Buf, uint64(len(s)))
+	t.Buf = append(t.Buf, s...)
+}
+
+// Varint writes a bare zigzag-encoded integer, e.g. in a
+// list.
+func (t *thriftWriter) Varint(x int64) {
+	t.Buf = binary.AppendUvarint(t.Buf, uint64((x<<1)^(x>>63)))
+}
+
+// Raw writes pre-encoded data, e.g. a struct in a list.
+func (t *thriftWriter) Raw(data []byte) {
+	t.Buf = append(t.Buf, data...)
+}
+
+// BeginStruct starts a struct field, which is ended by
+// EndStruct.
+func (t *thriftWriter) BeginStruct(field int) {
+	t.fieldHeader(field, thriftStruct)
+	t.fieldStack = append(t.fieldStack, t.lastField)
+	t.lastField = 0
+}
+
+func (t *thriftWriter) EndStruct() {
+	t.Stop()
+	t.lastField = t.fieldStack[len(t.fieldStack)-1]
+	t.fieldStack = t.fieldStack[:len(t.fieldStack)-1]
+}
+
+// ListHeader starts a list field.
+// It should be followed by exactly size elements.
+func (t *thriftWriter) ListHeader(field, elemType, size int) {
+	t.fieldHeader(field, thriftList)
+	if size < 15 {
+		t.Buf = append(t.Buf, byte(size<<4|elemType))
+	} else {
+		t.Buf = append(t.Buf, byte(0xf0|elemType))
+		t.Buf = binary.AppendUvarint(t.Buf, uint64(size))
+	}
+}
+
+// Stop ends the current struct.
+func (t *thriftWriter) Stop() {
+	t.Buf = append(t.Buf, 0)
+}
+
+func (t *thriftWriter) fieldHeader(field, fieldType int) {
+	if delta := field - t.lastField; delta > 0 && delta <= 15 {
+		t.Buf = append(t.Buf, byte(delta<<4|fieldType))
+	} else {
+		t.Buf = append(t.Buf, byte(fieldType))
+		t.Varint(int64(field))
+	}
+	t.lastField = field
+}
+
+// countWriter counts the bytes written to a Writer, which
+// gives the offsets of the pages in a Parquet file.
+type countWriter struct {
+	W io.Writer
+	N int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.W.Write(p)
+	c.N += int64(n)
+	return n, err
+}
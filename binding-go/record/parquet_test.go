@@ -0,0 +1,157 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestExportParquet(t *testing.T) {
+	var buf bytes.Buffer
+	env, err := NewRecorder(&testEnv{}, &buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Reset()
+	env.Step(3)
+	env.Step(1)
+	env.Reset()
+	env.Step(2)
+	env.Close()
+
+	var out bytes.Buffer
+	if err := ExportParquet(&out, NewReader(&buf, "")); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+	if string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatal("missing magic bytes")
+	}
+	metaSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := (&thriftReader{buf: data[len(data)-8-metaSize : len(data)-8]}).Struct()
+
+	if meta[3] != int64(3) {
+		t.Errorf("expected 3 rows but got %v", meta[3])
+	}
+	var names []string
+	for _, elem := range meta[2].([]interface{}) {
+		names = append(names, string(elem.(map[int]interface{})[4].([]byte)))
+	}
+	expectedNames := []string{"schema", "episode", "step", "observation", "action",
+		"reward", "terminal", "timeout"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("unexpected schema: %v", names)
+	}
+	kv := meta[5].([]interface{})[0].(map[int]interface{})
+	if string(kv[1].([]byte)) != "observation_shape" || string(kv[2].([]byte)) != "[1,2]" {
+		t.Errorf("unexpected metadata: %v", kv)
+	}
+
+	rowGroup := meta[4].([]interface{})[0].(map[int]interface{})
+	columns := rowGroup[1].([]interface{})
+	columnData := func(i int) []byte {
+		colMeta := columns[i].(map[int]interface{})[3].(map[int]interface{})
+		offset := colMeta[9].(int64)
+		r := &thriftReader{buf: data[offset:]}
+		header := r.Struct()
+		size := int(header[3].(int32))
+		return r.buf[r.pos : r.pos+size]
+	}
+
+	var actions []string
+	var obs [][]byte
+	for r := columnData(3); len(r) > 0; {
+		size := binary.LittleEndian.Uint32(r)
+		actions = append(actions, string(r[4:4+size]))
+		r = r[4+size:]
+	}
+	for r := columnData(2); len(r) > 0; {
+		size := binary.LittleEndian.Uint32(r)
+		obs = append(obs, r[4:4+size])
+		r = r[4+size:]
+	}
+	var rewards []float64
+	for r := columnData(4); len(r) > 0; r = r[8:] {
+		rewards = append(rewards, math.Float64frombits(binary.LittleEndian.Uint64(r)))
+	}
+	if !reflect.DeepEqual(actions, []string{"3", "1", "2"}) {
+		t.Errorf("unexpected actions: %v", actions)
+	}
+	if !reflect.DeepEqual(obs, [][]byte{{0, 7}, {1, 7}, {0, 7}}) {
+		t.Errorf("unexpected observations: %v", obs)
+	}
+	if !reflect.DeepEqual(rewards, []float64{1, 2, 1}) {
+		t.Errorf("unexpected rewards: %v", rewards)
+	}
+	if terminal := columnData(5); !reflect.DeepEqual(terminal, []byte{2}) {
+		t.Errorf("unexpected terminals: %v", terminal)
+	}
+	if timeout := columnData(6); !reflect.DeepEqual(timeout, []byte{4}) {
+		t.Errorf("unexpected timeouts: %v", timeout)
+	}
+}
+
+// thriftReader decodes the subset of the Thrift compact
+// protocol used by thriftWriter.
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (t *thriftReader) Struct() map[int]interface{} {
+	res := map[int]interface{}{}
+	var field int
+	for {
+		header := t.buf[t.pos]
+		t.pos++
+		if header == 0 {
+			return res
+		}
+		if delta := int(header >> 4); delta != 0 {
+			field += delta
+		} else {
+			field = int(t.varint())
+		}
+		res[field] = t.value(int(header & 0xf))
+	}
+}
+
+func (t *thriftReader) value(valueType int) interface{} {
+	switch valueType {
+	case thriftI32:
+		return int32(t.varint())
+	case thriftI64:
+		return t.varint()
+	case thriftBinary:
+		size, n := binary.Uvarint(t.buf[t.pos:])
+		t.pos += n
+		res := t.buf[t.pos : t.pos+int(size)]
+		t.pos += int(size)
+		return res
+	case thriftList:
+		header := t.buf[t.pos]
+		t.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			s, n := binary.Uvarint(t.buf[t.pos:])
+			t.pos += n
+			size = int(s)
+		}
+		var res []interface{}
+		for i := 0; i < size; i++ {
+			res = append(res, t.value(int(header&0xf)))
+		}
+		return res
+	case thriftStruct:
+		return t.Struct()
+	}
+	panic("unsupported type")
+}
+
+func (t *thriftReader) varint() int64 {
+	x, n := binary.Uvarint(t.buf[t.pos:])
+	t.pos += n
+	return int64(x>>1) ^ -int64(x&1)
+}
@@ -0,0 +1,160 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// PlaybackOptions configures a Playback.
+type PlaybackOptions struct {
+	// BlobDir is the directory of binary blobs, if the
+	// trajectories were recorded with one.
+	// It is only used by PlaybackEnv.
+	BlobDir string
+
+	// CheckActions makes Step fail if its action is
+	// different from the recorded one.
+	// Otherwise, actions are ignored.
+	CheckActions bool
+
+	// Loop makes Reset start over from the first episode
+	// after the last one.
+	// Otherwise, Reset fails with io.EOF once every episode
+	// has been played.
+	Loop bool
+
+	// Shuffle plays the episodes in a random order, which
+	// is determined by Seed.
+	// With Loop, the order is the same every time.
+	Shuffle bool
+	Seed    int64
+}
+
+// A Playback is an Env which replays the episodes of a
+// Dataset.
+//
+// This is useful for deterministic tests of agents, and
+// for evaluating them offline.
+// For behavior cloning, the recorded action of each step
+// is added to the info map under the key
+// "recorded_action".
+//
+// A Playback has no server, so methods like ActionSpace
+// and Render fail with gym.ErrUnsupported.
+type Playback struct {
+	offlineEnv
+	data *Dataset
+	opts PlaybackOptions
+
+	lock    sync.Mutex
+	order   []int
+	index   int
+	episode *EpisodeData
+	step    int
+}
+
+// PlaybackEnv creates a Playback from a trajectory file.
+//
+// The opts argument may be nil.
+func PlaybackEnv(path string, opts *PlaybackOptions) (env *Playback, err error) {
+	var blobDir string
+	if opts != nil {
+		blobDir = opts.BlobDir
+	}
+	data, err := LoadDataset(path, blobDir)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlayback(data, opts), nil
+}
+
+// NewPlayback creates a Playback for a Dataset.
+//
+// The opts argument may be nil.
+func NewPlayback(data *Dataset, opts *PlaybackOptions) *Playback {
+	res := &Playback{data: data, index: -1}
+	if opts != nil {
+		res.opts = *opts
+	}
+	if res.opts.Shuffle {
+		res.order = rand.New(rand.NewSource(res.opts.Seed)).Perm(len(data.Episodes))
+	} else {
+		for i := range data.Episodes {
+			res.order = append(res.order, i)
+		}
+	}
+	return res
+}
+
+func (p *Playback) Reset() (obs gym.Obs, err error) {
+	defer essentials.AddCtxTo("reset playback", &err)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.index+1 == len(p.order) {
+		if !p.opts.Loop {
+			return nil, io.EOF
+		}
+		p.index = -1
+	}
+	p.index++
+	p.episode = p.data.Episodes[p.order[p.index]]
+	p.step = 0
+	return p.obs(p.episode.Reset)
+}
+
+func (p *Playback) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	defer essentials.AddCtxTo("step playback", &err)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.episode == nil {
+		return nil, 0, false, nil, errors.New("step before reset")
+	}
+	if p.step >= len(p.episode.Steps) {
+		return nil, 0, false, nil, errors.New("end of recorded episode")
+	}
+	entry := p.episode.Steps[p.step]
+	if p.opts.CheckActions {
+		data, err := json.Marshal(action)
+		if err != nil {
+			return nil, 0, false, nil, err
+		}
+		if !bytes.Equal(data, entry.Action) {
+			return nil, 0, false, nil, fmt.Errorf("step %d: expected action %s but got %s",
+				p.step, entry.Action, data)
+		}
+	}
+	if entry.Info != nil {
+		if err := json.Unmarshal(entry.Info, &info); err != nil {
+			return nil, 0, false, nil, err
+		}
+	}
+	if infoMap, ok := info.(map[string]interface{}); ok && entry.Action != nil {
+		var recorded interface{}
+		if err := json.Unmarshal(entry.Action, &recorded); err != nil {
+			return nil, 0, false, nil, err
+		}
+		infoMap["recorded_action"] = recorded
+	}
+	obs, err = p.obs(entry)
+	if err != nil {
+		return nil, 0, false, nil, err
+	}
+	p.step++
+	return obs, entry.Reward, entry.Done, info, nil
+}
+
+func (p *Playback) obs(entry *Entry) (gym.Obs, error) {
+	if entry.Obs == nil {
+		return nil, errors.New("entry has no observation")
+	}
+	return entry.Obs.Decode(p.data.BlobDir)
+}
@@ -0,0 +1,118 @@
+package record
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+func TestPlayback(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(&testEnv{}, &buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		recorder.Reset()
+		recorder.Step(i)
+		recorder.Step(i + 1)
+	}
+	recorder.Close()
+
+	data, err := ReadDataset(&buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env gym.Env = NewPlayback(data, &PlaybackOptions{CheckActions: true})
+	for i := 0; i < 2; i++ {
+		obs, err := env.Reset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values := obs.(gym.Uint8Obs).Uint8Obs(); values[0] != 0 {
+			t.Errorf("unexpected reset observation: %v", values)
+		}
+		if _, _, _, _, err := env.Step(i + 5); err == nil {
+			t.Error("expected error for wrong action")
+		}
+		for j := 0; j < 2; j++ {
+			obs, reward, done, info, err := env.Step(i + j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if values := obs.(gym.Uint8Obs).Uint8Obs(); int(values[0]) != j+1 {
+				t.Errorf("unexpected observation: %v", values)
+			}
+			if reward != float64(j+1) || done != (j == 1) {
+				t.Errorf("unexpected reward %f and done %v", reward, done)
+			}
+			if info.(map[string]interface{})["t"] != float64(j+1) {
+				t.Errorf("unexpected info: %v", info)
+			}
+		}
+		if _, _, _, _, err := env.Step(0); err == nil {
+			t.Error("expected error at end of episode")
+		}
+	}
+	if _, err := env.Reset(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF but got %v", err)
+	}
+	if _, err := env.ActionSpace(); !errors.Is(err, gym.ErrUnsupported) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPlaybackShuffle(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(&testEnv{}, &buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		recorder.Reset()
+		recorder.Step(i)
+	}
+	recorder.Close()
+	data, err := ReadDataset(&buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.NumSteps() != 10 {
+		t.Errorf("expected 10 steps but got %d", data.NumSteps())
+	}
+
+	order := func(seed int64) []float64 {
+		env := NewPlayback(data, &PlaybackOptions{Shuffle: true, Seed: seed, Loop: true})
+		var res []float64
+		for i := 0; i < 20; i++ {
+			env.Reset()
+			_, _, _, info, err := env.Step(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res = append(res, info.(map[string]interface{})["recorded_action"].(float64))
+		}
+		return res
+	}
+	order1 := order(1)
+	if !reflect.DeepEqual(order1, order(1)) {
+		t.Error("order is not deterministic")
+	}
+	if reflect.DeepEqual(order1, order(2)) {
+		t.Error("order does not depend on seed")
+	}
+	if !reflect.DeepEqual(order1[:10], order1[10:]) {
+		t.Error("order changed after looping")
+	}
+	seen := map[float64]bool{}
+	for _, x := range order1[:10] {
+		seen[x] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("episodes were repeated: %v", order1)
+	}
+}
@@ -0,0 +1,47 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// A Reader reads the entries of a trajectory file.
+type Reader struct {
+	dec     *json.Decoder
+	blobDir string
+}
+
+// NewReader creates a Reader for a trajectory file.
+//
+// The blobDir should be the same one that was passed to
+// NewRecorder.
+func NewReader(r io.Reader, blobDir string) *Reader {
+	return &Reader{dec: json.NewDecoder(bufio.NewReader(r)), blobDir: blobDir}
+}
+
+// Next reads the next entry.
+//
+// At the end of the file, it returns io.EOF.
+func (r *Reader) Next() (*Entry, error) {
+	var entry Entry
+	if err := r.dec.Decode(&entry); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, essentials.AddCtx("read entry", err)
+	}
+	return &entry, nil
+}
+
+// Obs decodes the observation of an entry.
+func (r *Reader) Obs(entry *Entry) (gym.Obs, error) {
+	if entry.Obs == nil {
+		return nil, errors.New("decode observation: entry has no observation")
+	}
+	return entry.Obs.Decode(r.blobDir)
+}
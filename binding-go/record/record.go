@@ -0,0 +1,87 @@
+// Package record captures trajectories from environments,
+// e.g. to build datasets for offline RL or imitation
+// learning.
+//
+// Trajectories are stored as newline-delimited JSON, with
+// one Entry per line.
+// Byte list observations may be stored as separate binary
+// blobs, which keeps the JSON small.
+package record
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// Entry types.
+const (
+	EntryReset  = "reset"
+	EntryStep   = "step"
+	EntryResume = "resume"
+)
+
+// An Entry is one line of a trajectory file.
+type Entry struct {
+	// Type is EntryReset, EntryStep, or EntryResume.
+	// A resume entry continues an episode from a Bookmark.
+	Type string `json:"type"`
+
+	// Episode counts the resets before this entry, minus
+	// one.
+	Episode int `json:"episode"`
+
+	// Step is the index of a step within its episode,
+	// starting at 0.
+	// For a resume entry, it is the index of the next
+	// step.
+	Step int `json:"step,omitempty"`
+
+	// Obs is the observation after the reset, step, or
+	// resume.
+	Obs *ObsRecord `json:"obs"`
+
+	// Action, Reward, Done, and Info are only set for
+	// steps.
+	Action json.RawMessage `json:"action,omitempty"`
+	Reward float64         `json:"reward,omitempty"`
+	Done   bool            `json:"done,omitempty"`
+	Info   json.RawMessage `json:"info,omitempty"`
+}
+
+// An ObsRecord is an encoded observation.
+//
+// Byte list observations have a Shape, and either Data or
+// Blob.
+// Other observations are stored as JSON.
+type ObsRecord struct {
+	Shape []int           `json:"shape,omitempty"`
+	Data  []byte          `json:"data,omitempty"`
+	Blob  string          `json:"blob,omitempty"`
+	JSON  json.RawMessage `json:"json,omitempty"`
+}
+
+// Decode decodes the observation.
+//
+// The blobDir is the directory of binary blobs, if there
+// is one.
+func (o *ObsRecord) Decode(blobDir string) (gym.Obs, error) {
+	switch {
+	case o.JSON != nil:
+		return gym.NewJSONObs(o.JSON)
+	case o.Blob != "":
+		if blobDir == "" {
+			return nil, errors.New("decode observation: missing blob directory")
+		}
+		data, err := os.ReadFile(filepath.Join(blobDir, o.Blob))
+		if err != nil {
+			return nil, err
+		}
+		return gym.NewUint8Obs(o.Shape, data), nil
+	default:
+		return gym.NewUint8Obs(o.Shape, o.Data), nil
+	}
+}
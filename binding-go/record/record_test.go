@@ -0,0 +1,133 @@
+package record
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// testEnv is a fake environment whose observations count
+// the steps since the last reset.
+type testEnv struct {
+	gym.Env
+
+	// JSON selects JSON observations instead of byte
+	// lists.
+	JSON bool
+
+	t int
+}
+
+func (t *testEnv) Reset() (gym.Obs, error) {
+	t.t = 0
+	return t.obs(), nil
+}
+
+func (t *testEnv) Step(action interface{}) (gym.Obs, float64, bool, interface{}, error) {
+	t.t++
+	info := map[string]interface{}{"t": t.t}
+	return t.obs(), float64(t.t), t.t == 2, info, nil
+}
+
+func (t *testEnv) Close() error {
+	return nil
+}
+
+func (t *testEnv) obs() gym.Obs {
+	if t.JSON {
+		obs, _ := gym.NewJSONObs(map[string]int{"t": t.t})
+		return obs
+	}
+	return gym.NewUint8Obs([]int{1, 2}, []uint8{uint8(t.t), 7})
+}
+
+func TestRecorder(t *testing.T) {
+	for _, useJSON := range []bool{false, true} {
+		for _, useBlobs := range []bool{false, true} {
+			var blobDir string
+			if useBlobs {
+				blobDir = filepath.Join(t.TempDir(), "blobs")
+			}
+			var buf bytes.Buffer
+			env, err := NewRecorder(&testEnv{JSON: useJSON}, &buf, blobDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for episode := 0; episode < 2; episode++ {
+				env.Reset()
+				env.Step(3)
+				env.Step([]int{1, 2})
+			}
+			if err := env.Close(); err != nil {
+				t.Fatal(err)
+			}
+			checkRecording(t, &buf, blobDir, useJSON)
+			if useBlobs {
+				listing, err := os.ReadDir(blobDir)
+				if err != nil {
+					t.Fatal(err)
+				} else if !useJSON && len(listing) != 6 {
+					t.Errorf("expected 6 blobs but got %d", len(listing))
+				}
+			}
+		}
+	}
+}
+
+func checkRecording(t *testing.T, r io.Reader, blobDir string, useJSON bool) {
+	reader := NewReader(r, blobDir)
+	var entries []*Entry
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+
+		obs, err := reader.Obs(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if useJSON {
+			var obj map[string]int
+			if err := obs.Unmarshal(&obj); err != nil {
+				t.Fatal(err)
+			}
+			if expected := expectedT(entry); obj["t"] != expected {
+				t.Errorf("expected t=%d but got %d", expected, obj["t"])
+			}
+		} else {
+			values := obs.(gym.Uint8Obs).Uint8Obs()
+			shape := obs.(gym.ShapedObs).Shape()
+			expected := []uint8{uint8(expectedT(entry)), 7}
+			if !reflect.DeepEqual(values, expected) || !reflect.DeepEqual(shape, []int{1, 2}) {
+				t.Errorf("unexpected observation: %v %v", shape, values)
+			}
+		}
+	}
+	if len(entries) != 6 {
+		t.Fatalf("expected 6 entries but got %d", len(entries))
+	}
+	last := entries[5]
+	if last.Type != EntryStep || last.Episode != 1 || last.Step != 1 || last.Reward != 2 ||
+		!last.Done || string(last.Action) != "[1,2]" || string(last.Info) != `{"t":2}` {
+		t.Errorf("unexpected entry: %+v", last)
+	}
+	if first := entries[0]; first.Type != EntryReset || first.Episode != 0 || first.Action != nil {
+		t.Errorf("unexpected entry: %+v", first)
+	}
+}
+
+func expectedT(entry *Entry) int {
+	if entry.Type == EntryReset {
+		return 0
+	}
+	return entry.Step + 1
+}
@@ -0,0 +1,182 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// A Recorder is an Env which writes every reset and step
+// to a trajectory file.
+//
+// If recording fails, Reset or Step returns an error even
+// though the environment itself may have succeeded.
+//
+// Entries are buffered, so Flush or Close must be called
+// to finish writing them.
+// Close does not close the underlying io.Writer.
+type Recorder struct {
+	gym.Wrapper
+
+	lock    sync.Mutex
+	w       *bufio.Writer
+	enc     *json.Encoder
+	blobDir string
+	blobs   int
+	episode int
+	step    int
+
+	// lastObs is the latest observation, for bookmarks.
+	lastObs gym.Obs
+}
+
+// NewRecorder creates a Recorder which writes entries to
+// w.
+//
+// If blobDir is not empty, byte list observations are
+// written to files in that directory, rather than being
+// embedded in the JSON.
+// The directory is created if it does not exist.
+// New blobs are numbered after any blobs that are already
+// in the directory, so it may be shared by recordings.
+func NewRecorder(env gym.Env, w io.Writer, blobDir string) (*Recorder, error) {
+	var blobs int
+	if blobDir != "" {
+		if err := os.MkdirAll(blobDir, 0755); err != nil {
+			return nil, essentials.AddCtx("create recorder", err)
+		}
+		listing, err := os.ReadDir(blobDir)
+		if err != nil {
+			return nil, essentials.AddCtx("create recorder", err)
+		}
+		for _, entry := range listing {
+			var index int
+			if _, err := fmt.Sscanf(entry.Name(), "%08d.bin", &index); err == nil &&
+				index >= blobs {
+				blobs = index + 1
+			}
+		}
+	}
+	bufWriter := bufio.NewWriter(w)
+	return &Recorder{
+		Wrapper: gym.Wrapper{Env: env},
+		w:       bufWriter,
+		enc:     json.NewEncoder(bufWriter),
+		blobDir: blobDir,
+		blobs:   blobs,
+		episode: -1,
+	}, nil
+}
+
+func (r *Recorder) Reset() (obs gym.Obs, err error) {
+	obs, err = r.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.episode++
+	r.step = 0
+	entry := &Entry{Type: EntryReset, Episode: r.episode}
+	if err := r.write(entry, obs); err != nil {
+		return nil, essentials.AddCtx("record reset", err)
+	}
+	return obs, nil
+}
+
+func (r *Recorder) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = r.Env.Step(action)
+	if err != nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entry := &Entry{
+		Type:    EntryStep,
+		Episode: r.episode,
+		Step:    r.step,
+		Reward:  reward,
+		Done:    done,
+	}
+	r.step++
+	if entry.Action, err = json.Marshal(action); err == nil {
+		if entry.Info, err = json.Marshal(info); err == nil {
+			err = r.write(entry, obs)
+		}
+	}
+	if err != nil {
+		err = essentials.AddCtx("record step", err)
+	}
+	return
+}
+
+// Flush writes any buffered entries.
+func (r *Recorder) Flush() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.w.Flush()
+}
+
+// Close flushes the entries and closes the environment.
+func (r *Recorder) Close() error {
+	flushErr := r.Flush()
+	if err := r.Env.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+// write encodes an entry and its observation.
+//
+// The caller must hold r.lock.
+func (r *Recorder) write(entry *Entry, obs gym.Obs) error {
+	obsRecord, err := r.encodeObs(obs)
+	if err != nil {
+		return err
+	}
+	entry.Obs = obsRecord
+	r.lastObs = obs
+	return r.enc.Encode(entry)
+}
+
+func (r *Recorder) encodeObs(obs gym.Obs) (*ObsRecord, error) {
+	res, err := encodeObs(obs)
+	if err != nil || r.blobDir == "" || res.Data == nil {
+		return res, err
+	}
+	res.Blob = fmt.Sprintf("%08d.bin", r.blobs)
+	r.blobs++
+	if err := os.WriteFile(filepath.Join(r.blobDir, res.Blob), res.Data, 0644); err != nil {
+		return nil, err
+	}
+	res.Data = nil
+	return res, nil
+}
+
+// encodeObs encodes an observation inline.
+//
+// The encoded data may refer to the observation's memory.
+func encodeObs(obs gym.Obs) (*ObsRecord, error) {
+	u8, ok := obs.(gym.Uint8Obs)
+	if !ok {
+		var data json.RawMessage
+		if err := obs.Unmarshal(&data); err != nil {
+			return nil, err
+		}
+		return &ObsRecord{JSON: data}, nil
+	}
+	values := u8.Uint8Obs()
+	res := &ObsRecord{Shape: []int{len(values)}, Data: values}
+	if shaped, ok := obs.(gym.ShapedObs); ok {
+		res.Shape = shaped.Shape()
+	}
+	return res, nil
+}
@@ -0,0 +1,97 @@
+package record
+
+import (
+	"encoding/json"
+	"io"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// rldsStep is a step in the layout used by RLDS, where
+// each step pairs an observation with the action taken
+// after it and the resulting reward.
+//
+// The last step of an episode has the final observation
+// and no action.
+type rldsStep struct {
+	Episode    int
+	Index      int
+	Obs        gym.Obs
+	Action     json.RawMessage
+	Reward     float64
+	IsFirst    bool
+	IsLast     bool
+	IsTerminal bool
+}
+
+// readSteps reads every entry from r and converts them to
+// RLDS steps.
+//
+// An episode which is interrupted by a reset before it is
+// done ends with a step that is last but not terminal.
+// A resumed episode starts with a step that is not first.
+// So does an episode which is truncated by a time limit.
+func readSteps(r *Reader, f func(step *rldsStep) error) error {
+	var pending *rldsStep
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		obs, err := r.Obs(entry)
+		if err != nil {
+			return err
+		}
+		if entry.Type == EntryReset || entry.Type == EntryResume {
+			if pending != nil {
+				pending.IsLast = true
+				if err := f(pending); err != nil {
+					return err
+				}
+			}
+			pending = &rldsStep{
+				Episode: entry.Episode,
+				Index:   entry.Step,
+				Obs:     obs,
+				IsFirst: entry.Type == EntryReset,
+			}
+			continue
+		}
+		if pending == nil {
+			// Skip steps from before the first reset.
+			continue
+		}
+		pending.Action = entry.Action
+		pending.Reward = entry.Reward
+		if err := f(pending); err != nil {
+			return err
+		}
+		pending = &rldsStep{Episode: entry.Episode, Index: pending.Index + 1, Obs: obs}
+		if entry.Done {
+			pending.IsLast = true
+			pending.IsTerminal = !truncated(entry.Info)
+			if err := f(pending); err != nil {
+				return err
+			}
+			pending = nil
+		}
+	}
+	if pending != nil {
+		pending.IsLast = true
+		return f(pending)
+	}
+	return nil
+}
+
+// truncated checks if an info object says that an episode
+// ended because of a time limit.
+func truncated(info json.RawMessage) bool {
+	var infoMap map[string]interface{}
+	if json.Unmarshal(info, &infoMap) != nil {
+		return false
+	}
+	t, _ := infoMap["TimeLimit.truncated"].(bool)
+	return t
+}
@@ -0,0 +1,240 @@
+package record
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// ExportTFRecord converts a trajectory file into a TFRecord
+// file, with one tf.train.Example per step.
+//
+// The steps follow the RLDS layout, so each Example has an
+// observation, the action taken after it, and the reward
+// for that action, along with the features "is_first",
+// "is_last", "is_terminal", and "discount".
+// The features "episode_id" and "step_id" locate the step
+// in the dataset.
+//
+// Byte list observations are stored as raw bytes, with
+// their dimensions in "observation/shape".
+// Numerical observations and actions are stored as lists
+// of floats, or lists of integers if they are all whole
+// numbers.
+// Anything else is stored as JSON bytes.
+func ExportTFRecord(w io.Writer, r *Reader) (err error) {
+	defer essentials.AddCtxTo("export TFRecord", &err)
+	var lastAction *tfFeature
+	return readSteps(r, func(step *rldsStep) error {
+		features := map[string]*tfFeature{
+			"episode_id":  tfInts(int64(step.Episode)),
+			"step_id":     tfInts(int64(step.Index)),
+			"reward":      tfFloats(step.Reward),
+			"discount":    tfFloats(1),
+			"is_first":    tfBool(step.IsFirst),
+			"is_last":     tfBool(step.IsLast),
+			"is_terminal": tfBool(step.IsTerminal),
+		}
+		if step.IsTerminal {
+			features["discount"] = tfFloats(0)
+		}
+		if err := addObsFeatures(features, step.Obs); err != nil {
+			return err
+		}
+		if step.Action != nil {
+			lastAction = jsonFeature(step.Action)
+			features["action"] = lastAction
+		} else if lastAction != nil {
+			// RLDS fills in the action of the last step with
+			// zeros.
+			features["action"] = lastAction.zeros()
+		}
+		return writeTFRecord(w, encodeExample(features))
+	})
+}
+
+func addObsFeatures(features map[string]*tfFeature, obs gym.Obs) error {
+	if u8, ok := obs.(gym.Uint8Obs); ok {
+		values := u8.Uint8Obs()
+		shape := []int{len(values)}
+		if shaped, ok := obs.(gym.ShapedObs); ok {
+			shape = shaped.Shape()
+		}
+		features["observation"] = &tfFeature{Bytes: [][]byte{values}}
+		shapeFeature := &tfFeature{Ints: []int64{}}
+		for _, x := range shape {
+			shapeFeature.Ints = append(shapeFeature.Ints, int64(x))
+		}
+		features["observation/shape"] = shapeFeature
+		return nil
+	}
+	var data json.RawMessage
+	if err := obs.Unmarshal(&data); err != nil {
+		return err
+	}
+	features["observation"] = jsonFeature(data)
+	return nil
+}
+
+// A tfFeature is a tf.train.Feature, which holds exactly
+// one of its lists.
+type tfFeature struct {
+	Bytes  [][]byte
+	Floats []float32
+	Ints   []int64
+}
+
+func tfInts(x ...int64) *tfFeature {
+	return &tfFeature{Ints: x}
+}
+
+func tfFloats(x float64) *tfFeature {
+	return &tfFeature{Floats: []float32{float32(x)}}
+}
+
+func tfBool(b bool) *tfFeature {
+	if b {
+		return tfInts(1)
+	}
+	return tfInts(0)
+}
+
+// jsonFeature encodes a JSON value as numbers if possible,
+// or as JSON bytes otherwise.
+func jsonFeature(data json.RawMessage) *tfFeature {
+	var obj interface{}
+	if json.Unmarshal(data, &obj) == nil {
+		if nums, ok := flattenNumbers(obj, nil); ok {
+			integral := true
+			for _, x := range nums {
+				if x != math.Trunc(x) || math.Abs(x) > 1<<53 {
+					integral = false
+				}
+			}
+			res := &tfFeature{}
+			for _, x := range nums {
+				if integral {
+					res.Ints = append(res.Ints, int64(x))
+				} else {
+					res.Floats = append(res.Floats, float32(x))
+				}
+			}
+			if res.Ints == nil && res.Floats == nil {
+				res.Floats = []float32{}
+			}
+			return res
+		}
+	}
+	return &tfFeature{Bytes: [][]byte{data}}
+}
+
+func flattenNumbers(obj interface{}, res []float64) ([]float64, bool) {
+	switch obj := obj.(type) {
+	case float64:
+		return append(res, obj), true
+	case []interface{}:
+		for _, x := range obj {
+			var ok bool
+			res, ok = flattenNumbers(x, res)
+			if !ok {
+				return nil, false
+			}
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+// zeros creates a feature of the same kind and size, filled
+// with zeros.
+func (t *tfFeature) zeros() *tfFeature {
+	switch {
+	case t.Ints != nil:
+		return &tfFeature{Ints: make([]int64, len(t.Ints))}
+	case t.Floats != nil:
+		return &tfFeature{Floats: make([]float32, len(t.Floats))}
+	default:
+		return &tfFeature{Bytes: [][]byte{[]byte("null")}}
+	}
+}
+
+// encodeExample encodes a tf.train.Example protobuf.
+func encodeExample(features map[string]*tfFeature) []byte {
+	keys := make([]string, 0, len(features))
+	for key := range features {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// message Features { map<string, Feature> feature = 1; }
+	var featuresMsg []byte
+	for _, key := range keys {
+		var entry []byte
+		entry = protoBytes(entry, 1, []byte(key))
+		entry = protoBytes(entry, 2, features[key].encode())
+		featuresMsg = protoBytes(featuresMsg, 1, entry)
+	}
+
+	// message Example { Features features = 1; }
+	return protoBytes(nil, 1, featuresMsg)
+}
+
+// encode encodes a tf.train.Feature protobuf.
+func (t *tfFeature) encode() []byte {
+	var list []byte
+	switch {
+	case t.Ints != nil:
+		var packed []byte
+		for _, x := range t.Ints {
+			packed = binary.AppendUvarint(packed, uint64(x))
+		}
+		list = protoBytes(nil, 1, packed)
+		return protoBytes(nil, 3, list)
+	case t.Floats != nil:
+		packed := make([]byte, 0, 4*len(t.Floats))
+		for _, x := range t.Floats {
+			packed = binary.LittleEndian.AppendUint32(packed, math.Float32bits(x))
+		}
+		list = protoBytes(nil, 1, packed)
+		return protoBytes(nil, 2, list)
+	default:
+		for _, x := range t.Bytes {
+			list = protoBytes(list, 1, x)
+		}
+		return protoBytes(nil, 1, list)
+	}
+}
+
+// protoBytes appends a length-delimited protobuf field.
+func protoBytes(dst []byte, field int, data []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(field<<3|2))
+	dst = binary.AppendUvarint(dst, uint64(len(data)))
+	return append(dst, data...)
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// writeTFRecord writes one record of a TFRecord file.
+func writeTFRecord(w io.Writer, data []byte) error {
+	header := binary.LittleEndian.AppendUint64(nil, uint64(len(data)))
+	header = binary.LittleEndian.AppendUint32(header, maskedCRC(header))
+	footer := binary.LittleEndian.AppendUint32(nil, maskedCRC(data))
+	for _, chunk := range [][]byte{header, data, footer} {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func maskedCRC(data []byte) uint32 {
+	crc := crc32.Checksum(data, crc32c)
+	return ((crc >> 15) | (crc << 17)) + 0xa282ead8
+}
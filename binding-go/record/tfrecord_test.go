@@ -0,0 +1,152 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestExportTFRecord(t *testing.T) {
+	var buf bytes.Buffer
+	env, err := NewRecorder(&testEnv{}, &buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Reset()
+	env.Step(3)
+	env.Step(1)
+	env.Reset()
+	env.Step(2)
+	env.Close()
+
+	var out bytes.Buffer
+	if err := ExportTFRecord(&out, NewReader(&buf, "")); err != nil {
+		t.Fatal(err)
+	}
+	var examples []map[string]*tfFeature
+	for {
+		data, err := readTFRecord(&out)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		examples = append(examples, decodeExample(t, data))
+	}
+
+	// The first episode is done after two steps, and the
+	// second one is interrupted after one step.
+	expected := []struct {
+		Episode, Step, Action int64
+		Reward                float32
+		First, Last, Terminal int64
+	}{
+		{0, 0, 3, 1, 1, 0, 0},
+		{0, 1, 1, 2, 0, 0, 0},
+		{0, 2, 0, 0, 0, 1, 1},
+		{1, 0, 2, 1, 1, 0, 0},
+		{1, 1, 0, 0, 0, 1, 0},
+	}
+	if len(examples) != len(expected) {
+		t.Fatalf("expected %d examples but got %d", len(expected), len(examples))
+	}
+	for i, x := range expected {
+		ex := examples[i]
+		actual := []interface{}{ex["episode_id"].Ints, ex["step_id"].Ints,
+			ex["action"].Ints, ex["reward"].Floats, ex["is_first"].Ints,
+			ex["is_last"].Ints, ex["is_terminal"].Ints}
+		exp := []interface{}{[]int64{x.Episode}, []int64{x.Step}, []int64{x.Action},
+			[]float32{x.Reward}, []int64{x.First}, []int64{x.Last}, []int64{x.Terminal}}
+		if !reflect.DeepEqual(actual, exp) {
+			t.Errorf("example %d: expected %v but got %v", i, exp, actual)
+		}
+		if !reflect.DeepEqual(ex["observation/shape"].Ints, []int64{1, 2}) {
+			t.Errorf("example %d: bad shape %v", i, ex["observation/shape"].Ints)
+		}
+		obs := []byte{byte(x.Step), 7}
+		if !reflect.DeepEqual(ex["observation"].Bytes, [][]byte{obs}) {
+			t.Errorf("example %d: bad observation %v", i, ex["observation"].Bytes)
+		}
+	}
+}
+
+func TestMaskedCRC(t *testing.T) {
+	// Checksum of "123456789" from the CRC-32C spec.
+	crc := uint32(0xe3069283)
+	expected := ((crc >> 15) | (crc << 17)) + 0xa282ead8
+	if actual := maskedCRC([]byte("123456789")); actual != expected {
+		t.Errorf("expected %x but got %x", expected, actual)
+	}
+}
+
+func readTFRecord(r io.Reader) ([]byte, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if maskedCRC(header[:8]) != binary.LittleEndian.Uint32(header[8:]) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, binary.LittleEndian.Uint64(header[:8])+4)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if maskedCRC(data[:len(data)-4]) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data[:len(data)-4], nil
+}
+
+func decodeExample(t *testing.T, data []byte) map[string]*tfFeature {
+	res := map[string]*tfFeature{}
+	features := protoFields(t, data)[1][0]
+	for _, entry := range protoFields(t, features)[1] {
+		fields := protoFields(t, entry)
+		feature := &tfFeature{}
+		for kind, lists := range protoFields(t, fields[2][0]) {
+			list := protoFields(t, lists[0])[1]
+			switch kind {
+			case 1:
+				feature.Bytes = list
+			case 2:
+				for i := 0; i < len(list[0]); i += 4 {
+					bits := binary.LittleEndian.Uint32(list[0][i:])
+					feature.Floats = append(feature.Floats, math.Float32frombits(bits))
+				}
+			case 3:
+				for r := bytes.NewReader(list[0]); r.Len() > 0; {
+					x, _ := binary.ReadUvarint(r)
+					feature.Ints = append(feature.Ints, int64(x))
+				}
+			}
+		}
+		res[string(fields[1][0])] = feature
+	}
+	return res
+}
+
+// protoFields decodes a protobuf message with only
+// length-delimited fields.
+func protoFields(t *testing.T, data []byte) map[int][][]byte {
+	res := map[int][][]byte{}
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		tag, err := binary.ReadUvarint(r)
+		if err != nil || tag&7 != 2 {
+			t.Fatal("bad protobuf tag")
+		}
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		field := make([]byte, size)
+		if _, err := io.ReadFull(r, field); err != nil {
+			t.Fatal(err)
+		}
+		res[int(tag>>3)] = append(res[int(tag>>3)], field)
+	}
+	return res
+}
@@ -0,0 +1,127 @@
+package gym
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+
+	"github.com/unixpickle/essentials"
+)
+
+// EnvSpec describes an environment which is registered on
+// a server.
+type EnvSpec struct {
+	// ID is the name of the environment, as passed to Make.
+	ID string `json:"id"`
+
+	// EntryPoint is the Python class or function which
+	// creates the environment.
+	EntryPoint string `json:"entry_point"`
+
+	// MaxEpisodeSteps is the episode length limit, or 0 if
+	// episodes are not limited.
+	MaxEpisodeSteps int `json:"max_episode_steps"`
+
+	// RewardThreshold is the average return at which the
+	// environment is considered solved, or nil if there is
+	// no such threshold.
+	RewardThreshold *float64 `json:"reward_threshold"`
+
+	Nondeterministic bool `json:"nondeterministic"`
+
+	// Kwargs are the arguments passed to the entry point.
+	Kwargs map[string]interface{} `json:"kwargs"`
+
+	ActionSpace      *Space `json:"action_space"`
+	ObservationSpace *Space `json:"observation_space"`
+}
+
+// ListEnvs gets the sorted names of the environments which
+// are registered on an API server.
+func ListEnvs(host string) (names []string, err error) {
+	defer essentials.AddCtxTo("list environments", &err)
+	if err := queryNoEnv(host, "list_envs", packetListEnvs, &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// Spec describes an environment which is registered on an
+// API server, including its spaces.
+//
+// The server creates an instance of the environment to
+// find its spaces, so this can be slow for environments
+// which take a while to start.
+func Spec(host, envName string) (spec *EnvSpec, err error) {
+	defer essentials.AddCtxTo("get environment spec", &err)
+	if err := queryNoEnv(host, "spec", packetSpec, &spec, envName); err != nil {
+		return nil, err
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// validate checks that a spec from the server is
+// complete.
+func (e *EnvSpec) validate() error {
+	if e == nil || e.ActionSpace == nil || e.ObservationSpace == nil {
+		return errors.New("incomplete environment spec")
+	}
+	for _, space := range []*Space{e.ActionSpace, e.ObservationSpace} {
+		if err := space.validate(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy creates a deep copy of the spec's spaces.
+func (e *EnvSpec) copy() *EnvSpec {
+	res := *e
+	res.ActionSpace = e.ActionSpace.copy()
+	res.ObservationSpace = e.ObservationSpace.copy()
+	return &res
+}
+
+// queryNoEnv sends a packet with string arguments on a
+// connection with no environment, and decodes the JSON
+// response into dst.
+func queryNoEnv(host, op string, packetType int, dst interface{}, args ...string) error {
+	env, err := makeNoEnv(host)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	return env.command(op, func(w *bufio.Writer) error {
+		if err := env.writeHeader(w, packetType); err != nil {
+			return err
+		}
+		for _, arg := range args {
+			if err := writeByteField(w, []byte(arg)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		data, err := readByteField(r)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, dst)
+	})
+}
+
+// makeNoEnv connects to a server without creating an
+// environment.
+func makeNoEnv(host string) (*connEnv, error) {
+	conn, err := dialEnvConn(host, &handshakeRequest{Options: makeOptions(nil)})
+	if err != nil {
+		return nil, err
+	}
+	return &connEnv{envConn: conn, ID: -1}, nil
+}
@@ -0,0 +1,168 @@
+package gym
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestListEnvs(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveRegistry(listener)
+
+	names, err := ListEnvs(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"CartPole-v0", "Pong-v0"}) {
+		t.Errorf("unexpected names: %v", names)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveRegistry(listener)
+	go serveRegistry(listener)
+
+	spec, err := Spec(listener.Addr().String(), "CartPole-v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.ID != "CartPole-v0" || spec.MaxEpisodeSteps != 200 ||
+		spec.RewardThreshold == nil || *spec.RewardThreshold != 195 {
+		t.Errorf("unexpected spec: %+v", spec)
+	}
+	if spec.ActionSpace.Type != "Discrete" || spec.ActionSpace.N != 2 {
+		t.Errorf("unexpected action space: %+v", spec.ActionSpace)
+	}
+	if spec.ObservationSpace.Type != "Box" ||
+		!reflect.DeepEqual(spec.ObservationSpace.Shape, []int{1}) {
+		t.Errorf("unexpected observation space: %+v", spec.ObservationSpace)
+	}
+
+	_, err = Spec(listener.Addr().String(), "Nope-v0")
+	if !errors.Is(err, ErrUnknownEnv) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestListRetro(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	for i := 0; i < 3; i++ {
+		go serveRegistry(listener)
+	}
+
+	games, err := ListRetroGames(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(games, []string{"Airstriker-Genesis"}) {
+		t.Errorf("unexpected games: %v", games)
+	}
+	states, err := ListRetroStates(listener.Addr().String(), "Airstriker-Genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(states, []string{"Level1"}) {
+		t.Errorf("unexpected states: %v", states)
+	}
+	_, err = ListRetroStates(listener.Addr().String(), "Nope-Genesis")
+	if !errors.Is(err, ErrRetroFailed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUniverseRemotes(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveRegistry(listener)
+
+	remotes, err := UniverseRemotes(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*UniverseRemote{{ID: "abc", Image: "flashgames",
+		Address: "vnc://127.0.0.1:32768+32769"}}
+	if !reflect.DeepEqual(remotes, expected) {
+		t.Errorf("unexpected remotes: %v", remotes)
+	}
+}
+
+// serveRegistry accepts one connection and answers a
+// registry, Retro, or Universe listing packet with fake
+// data.
+func serveRegistry(listener net.Listener) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	if _, err := rw.ReadByte(); err != nil {
+		return
+	}
+	if _, err := readByteField(rw); err != nil {
+		return
+	}
+	writeUint32(rw, 0)
+	rw.Flush()
+	packetType, err := rw.ReadByte()
+	if err != nil {
+		return
+	}
+	switch packetType {
+	case packetListEnvs:
+		writeUint32(rw, 0)
+		writeByteField(rw, []byte(`["CartPole-v0","Pong-v0"]`))
+	case packetSpec:
+		name, err := readByteField(rw)
+		if err != nil {
+			return
+		}
+		if string(name) != "CartPole-v0" {
+			writeByteField(rw, []byte(`{"code":"unknown_env","message":"no such env"}`))
+			break
+		}
+		writeUint32(rw, 0)
+		writeByteField(rw, []byte(`{"id":"CartPole-v0","entry_point":"x:Y",`+
+			`"max_episode_steps":200,"reward_threshold":195.0,"kwargs":{},`+
+			`"action_space":{"type":"Discrete","n":2},`+
+			`"observation_space":{"type":"Box","shape":[1],"low":[0],"high":[1]}}`))
+	case packetUniverseListRemotes:
+		writeUint32(rw, 0)
+		writeByteField(rw, []byte(`[{"id":"abc","image":"flashgames",`+
+			`"address":"vnc://127.0.0.1:32768+32769"}]`))
+	case packetRetroListGames:
+		writeUint32(rw, 0)
+		writeByteField(rw, []byte(`["Airstriker-Genesis"]`))
+	case packetRetroListStates:
+		game, err := readByteField(rw)
+		if err != nil {
+			return
+		}
+		if string(game) != "Airstriker-Genesis" {
+			writeByteField(rw, []byte(`{"code":"retro_failed","message":"unknown game"}`))
+			break
+		}
+		writeUint32(rw, 0)
+		writeByteField(rw, []byte(`["Level1"]`))
+	}
+	rw.Flush()
+}
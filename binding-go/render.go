@@ -0,0 +1,93 @@
+package gym
+
+import (
+	"bufio"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A TextRenderer is an Env which can render itself as
+// text, using Gym's "ansi" render mode.
+//
+// Environments created by Make and Client implement
+// TextRenderer, although only some environments, such as
+// FrozenLake and Taxi, support the mode.
+type TextRenderer interface {
+	// RenderText renders the environment as text.
+	//
+	// If the environment does not support text rendering,
+	// this fails with ErrUnsupported.
+	RenderText() (string, error)
+}
+
+// RenderText renders an environment as text, so that it
+// can be shown on the client's terminal rather than the
+// server's console.
+//
+// Wrappers are unwrapped to find the original Env.
+// If it is not a TextRenderer, this fails with
+// ErrUnsupported.
+func RenderText(env Env) (string, error) {
+	if r, ok := Innermost(env).(TextRenderer); ok {
+		return r.RenderText()
+	}
+	return "", unsupported("render text")
+}
+
+func (c *connEnv) RenderText() (text string, err error) {
+	defer essentials.AddCtxTo("render text", &err)
+	err = c.command("render_text", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetRenderText)
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		data, err := readByteField(r)
+		text = string(data)
+		return err
+	})
+	return
+}
+
+// A Screenshotter is an Env which can take a screenshot of
+// its current frame.
+//
+// Environments created by Make and Client implement
+// Screenshotter, although only environments which support
+// Gym's "rgb_array" render mode can take screenshots.
+type Screenshotter interface {
+	// Screenshot renders the current frame and encodes it
+	// as a PNG image.
+	//
+	// If the environment cannot render frames, this fails
+	// with ErrUnsupported.
+	Screenshot() ([]byte, error)
+}
+
+// Screenshot gets a PNG image of the current frame of an
+// environment, which is cheaper to ship to a dashboard or
+// attach to a bug report than raw pixels.
+//
+// Wrappers are unwrapped to find the original Env.
+// If it is not a Screenshotter, this fails with
+// ErrUnsupported.
+func Screenshot(env Env) ([]byte, error) {
+	if s, ok := Innermost(env).(Screenshotter); ok {
+		return s.Screenshot()
+	}
+	return nil, unsupported("take screenshot")
+}
+
+func (c *connEnv) Screenshot() (data []byte, err error) {
+	defer essentials.AddCtxTo("take screenshot", &err)
+	err = c.command("screenshot", func(w *bufio.Writer) error {
+		return c.writeHeader(w, packetScreenshot)
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		data, err = readByteField(r)
+		return err
+	})
+	return
+}
@@ -0,0 +1,100 @@
+package gym
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestRenderText(t *testing.T) {
+	env := makeResultEnv(t, []byte("\nS\x1b[41mF\x1b[0mFF\nFHFH\n"))
+	defer env.Close()
+
+	text, err := RenderText(env)
+	if err != nil {
+		t.Fatal(err)
+	} else if text != "\nS\x1b[41mF\x1b[0mFF\nFHFH\n" {
+		t.Errorf("unexpected text: %q", text)
+	}
+	if _, err := RenderText(env); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported but got: %v", err)
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	env := makeResultEnv(t, buf.Bytes())
+	defer env.Close()
+
+	data, err := Screenshot(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := decoded.At(1, 1).RGBA(); decoded.Bounds().Dx() != 3 || r != 0xffff {
+		t.Errorf("unexpected image: %v", decoded.Bounds())
+	}
+	if _, err := Screenshot(env); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported but got: %v", err)
+	}
+}
+
+// makeResultEnv connects to a server which answers the
+// first command with an empty error and the result, and
+// rejects the rest as unsupported.
+// Commands must not have any fields.
+func makeResultEnv(t *testing.T, result []byte) Env {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		if _, err := rw.ReadByte(); err != nil {
+			return
+		}
+		if _, err := readByteField(rw); err != nil {
+			return
+		}
+		writeUint32(rw, 0)
+		rw.Flush()
+		for first := true; ; first = false {
+			header := make([]byte, 1)
+			if _, err := io.ReadFull(rw, header); err != nil {
+				return
+			}
+			if first {
+				writeByteField(rw, nil)
+				writeByteField(rw, result)
+			} else {
+				writeByteField(rw, []byte(`{"code":"unsupported","message":"unsupported mode"}`))
+			}
+			rw.Flush()
+		}
+	}()
+	env, err := Make(listener.Addr().String(), "FrozenLake-v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
@@ -0,0 +1,143 @@
+package gym
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+const consulTimeout = time.Second * 10
+
+// lookupSRV looks up SRV records, and is replaced by tests.
+var lookupSRV = net.LookupSRV
+
+// ResolveHost turns an API server address into a
+// host:port pair which can be dialed.
+//
+// Plain "host:port" addresses are returned unchanged, as
+// are the tcp and tls addresses described in Make, minus
+// their schemes.
+// Service names can be resolved in one of two ways:
+//
+//	srv://_gym._tcp.example.com
+//	    Look up a DNS SRV record and pick a target
+//	    according to its priority and weight.
+//
+//	consul://localhost:8500/gym-server
+//	    Ask a Consul agent for a random healthy
+//	    instance of the named service.
+//
+// Since resolution happens every time, a service with
+// many servers will spread environments across them.
+func ResolveHost(host string) (addr string, err error) {
+	defer essentials.AddCtxTo("resolve host", &err)
+	switch {
+	case strings.HasPrefix(host, "srv://"):
+		return resolveSRV(strings.TrimPrefix(host, "srv://"))
+	case strings.HasPrefix(host, "consul://"):
+		return resolveConsul(host)
+	case strings.HasPrefix(host, "tcp://"):
+		return strings.TrimPrefix(host, "tcp://"), nil
+	case strings.HasPrefix(host, "tls://"):
+		return strings.TrimPrefix(host, "tls://"), nil
+	default:
+		return host, nil
+	}
+}
+
+// dialHost opens a connection to an API server address,
+// using the transport picked by the address's scheme.
+func dialHost(host string, dial DialFunc) (net.Conn, error) {
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		return dial("unix", strings.TrimPrefix(host, "unix://"))
+	case strings.HasPrefix(host, "ws://"), strings.HasPrefix(host, "wss://"):
+		return dialWebSocket(host, dial)
+	}
+	addr, err := ResolveHost(host)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dial("tcp", addr)
+	if err != nil || !strings.HasPrefix(host, "tls://") {
+		return conn, err
+	}
+	serverName, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tls.Client(conn, &tls.Config{ServerName: serverName}), nil
+}
+
+func resolveSRV(name string) (string, error) {
+	_, records, err := lookupSRV("", "", name)
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		return "", errors.New("no SRV records for " + name)
+	}
+
+	// LookupSRV sorts by priority and shuffles by weight.
+	target := strings.TrimSuffix(records[0].Target, ".")
+	return net.JoinHostPort(target, strconv.Itoa(int(records[0].Port))), nil
+}
+
+func resolveConsul(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	service := strings.Trim(u.Path, "/")
+	if service == "" {
+		return "", errors.New("missing Consul service name")
+	}
+	query := url.URL{
+		Scheme:   "http",
+		Host:     u.Host,
+		Path:     "/v1/health/service/" + service,
+		RawQuery: "passing=1",
+	}
+	client := http.Client{Timeout: consulTimeout}
+	resp, err := client.Get(query.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("consul: unexpected status %s", resp.Status)
+	}
+
+	var entries []struct {
+		Node struct {
+			Address string
+		}
+		Service struct {
+			Address string
+			Port    int
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", errors.New("no healthy instances of " + service)
+	}
+	entry := entries[rand.Intn(len(entries))]
+	addr := entry.Service.Address
+	if addr == "" {
+		addr = entry.Node.Address
+	}
+	return net.JoinHostPort(addr, strconv.Itoa(entry.Service.Port)), nil
+}
@@ -0,0 +1,101 @@
+package gym
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveSRV(t *testing.T) {
+	defer func(lookup func(service, proto, name string) (string, []*net.SRV, error)) {
+		lookupSRV = lookup
+	}(lookupSRV)
+
+	records := map[string][]*net.SRV{
+		"_gym._tcp.example.com": {
+			{Target: "gpu1.example.com.", Port: 5001},
+			{Target: "gpu2.example.com.", Port: 5002},
+		},
+		"_gym._tcp.empty.com": {},
+	}
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		if service != "" || proto != "" {
+			t.Errorf("unexpected service %q and proto %q", service, proto)
+		}
+		res, ok := records[name]
+		if !ok {
+			return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return name, res, nil
+	}
+
+	for _, test := range []struct {
+		Host     string
+		Expected string
+	}{
+		{"srv://_gym._tcp.example.com", "gpu1.example.com:5001"},
+		{"srv://_gym._tcp.empty.com", ""},
+		{"srv://_gym._tcp.missing.com", ""},
+		{"srv://", ""},
+	} {
+		addr, err := ResolveHost(test.Host)
+		if test.Expected == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error but got %s", test.Host, addr)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.Host, err)
+		} else if addr != test.Expected {
+			t.Errorf("%s: expected %s but got %s", test.Host, test.Expected, addr)
+		}
+	}
+}
+
+func TestResolveConsul(t *testing.T) {
+	responses := map[string]string{
+		"/v1/health/service/healthy": `[{"Node": {"Address": "10.0.0.1"},
+			"Service": {"Address": "", "Port": 5001}}]`,
+		"/v1/health/service/override": `[{"Node": {"Address": "10.0.0.1"},
+			"Service": {"Address": "10.0.0.2", "Port": 5002}}]`,
+		"/v1/health/service/empty":     `[]`,
+		"/v1/health/service/malformed": `[{"Service": `,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("passing") != "1" {
+			t.Errorf("expected only passing instances to be requested: %s", r.URL)
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	agent := "consul://" + strings.TrimPrefix(server.URL, "http://")
+
+	for _, test := range []struct {
+		Service  string
+		Expected string
+	}{
+		{"healthy", "10.0.0.1:5001"},
+		{"override", "10.0.0.2:5002"},
+		{"empty", ""},
+		{"malformed", ""},
+		{"unknown", ""},
+		{"", ""},
+	} {
+		addr, err := ResolveHost(agent + "/" + test.Service)
+		if test.Expected == "" {
+			if err == nil {
+				t.Errorf("service %q: expected an error but got %s", test.Service, addr)
+			}
+		} else if err != nil {
+			t.Errorf("service %q: %v", test.Service, err)
+		} else if addr != test.Expected {
+			t.Errorf("service %q: expected %s but got %s", test.Service, test.Expected, addr)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package gym
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+
+	"github.com/unixpickle/essentials"
+)
+
+// ListRetroGames gets the sorted names of the Retro games
+// whose ROMs are installed on an API server.
+//
+// The server must be started with --retro.
+func ListRetroGames(host string) (games []string, err error) {
+	defer essentials.AddCtxTo("list Retro games", &err)
+	if err := queryNoEnv(host, "retro_list_games", packetRetroListGames, &games); err != nil {
+		return nil, err
+	}
+	return games, nil
+}
+
+// ListRetroStates gets the sorted names of the start
+// states of a Retro game on an API server.
+//
+// Unknown games fail with ErrRetroFailed.
+func ListRetroStates(host, game string) (states []string, err error) {
+	defer essentials.AddCtxTo("list Retro states", &err)
+	err = queryNoEnv(host, "retro_list_states", packetRetroListStates, &states, game)
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// UploadRetroIntegration sends a custom Retro game
+// integration to an API server, so that the game can be
+// made without access to the server's filesystem.
+//
+// The directory should hold the files of the integration,
+// such as rom.sha, data.json, scenario.json, and start
+// states; subdirectories are ignored.
+// Any previous integration for the game is replaced.
+//
+// The server must be started with --retro-integrations.
+func UploadRetroIntegration(host, game, dir string) (err error) {
+	defer essentials.AddCtxTo("upload Retro integration", &err)
+	listing, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	var contents [][]byte
+	for _, entry := range listing {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		names = append(names, entry.Name())
+		contents = append(contents, data)
+	}
+
+	env, err := makeNoEnv(host)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	return env.command("retro_upload_integration", func(w *bufio.Writer) error {
+		if err := env.writeHeader(w, packetRetroUploadIntegration); err != nil {
+			return err
+		}
+		if err := writeByteField(w, []byte(game)); err != nil {
+			return err
+		}
+		if err := writeUint32(w, uint32(len(names))); err != nil {
+			return err
+		}
+		for i, name := range names {
+			if err := writeByteField(w, []byte(name)); err != nil {
+				return err
+			}
+			if err := writeByteField(w, contents[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
+}
@@ -0,0 +1,80 @@
+package gym
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// DefaultRetryPolicy is a reasonable RetryPolicy for
+// long-running jobs on unreliable networks.
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts:  8,
+	InitialDelay: time.Millisecond * 100,
+	MaxDelay:     time.Second * 10,
+	Jitter:       0.5,
+}
+
+// A RetryPolicy determines how transient network failures
+// are retried.
+//
+// A failure is transient if a connection could not be
+// established (e.g. because it was refused), or if a
+// command could not be sent (e.g. because of a broken
+// pipe).
+// Failures after a command was sent are never retried,
+// since the server may have already run the command.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts,
+	// including the first one.
+	MaxAttempts int
+
+	// InitialDelay is the delay before the first retry.
+	InitialDelay time.Duration
+
+	// MaxDelay is the maximum delay between attempts.
+	// If 0, there is no maximum.
+	MaxDelay time.Duration
+
+	// Multiplier scales the delay after every retry.
+	// If 0, the delay is doubled.
+	Multiplier float64
+
+	// Jitter is the fraction of every delay which is
+	// randomized, between 0 and 1.
+	// Jitter prevents many clients from retrying at once.
+	Jitter float64
+}
+
+// delay computes the delay before the given retry, where
+// the first retry is 1.
+func (r *RetryPolicy) delay(retry int) time.Duration {
+	multiplier := r.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	delay := float64(r.InitialDelay)
+	for i := 1; i < retry; i++ {
+		delay *= multiplier
+		if r.MaxDelay != 0 && delay > float64(r.MaxDelay) {
+			break
+		}
+	}
+	if r.MaxDelay != 0 && delay > float64(r.MaxDelay) {
+		delay = float64(r.MaxDelay)
+	}
+	delay *= 1 - r.Jitter*rand.Float64()
+	return time.Duration(delay)
+}
+
+// isTransient checks if an error is a network failure
+// which happened before the server could receive a
+// request.
+func isTransient(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Op == "dial" || opErr.Op == "write"
+	}
+	return false
+}
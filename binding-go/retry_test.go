@@ -0,0 +1,45 @@
+package gym
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialDelay: time.Second,
+		MaxDelay:     time.Second * 5,
+		Jitter:       0.5,
+	}
+	expected := []time.Duration{time.Second, time.Second * 2, time.Second * 4,
+		time.Second * 5, time.Second * 5}
+	for i, max := range expected {
+		for j := 0; j < 10; j++ {
+			delay := policy.delay(i + 1)
+			if delay > max || delay < max/2 {
+				t.Errorf("retry %d: delay %v should be in [%v, %v]", i+1, delay,
+					max/2, max)
+			}
+		}
+	}
+}
+
+func TestRetryDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond * 10}
+	start := time.Now()
+	_, err = Make(addr, "CartPole-v0", WithRetry(policy))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*30 {
+		t.Errorf("expected two retries, but only waited %v", elapsed)
+	}
+}
@@ -0,0 +1,40 @@
+package gym
+
+import (
+	"errors"
+
+	"github.com/unixpickle/essentials"
+)
+
+// An ROI is a region of interest of image observations.
+type ROI struct {
+	// X and Y are the column and row of the top-left
+	// corner of the region.
+	X int `json:"x"`
+	Y int `json:"y"`
+
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Subsample keeps every Subsample-th row and column of
+	// the region.
+	// If 0, every row and column is kept.
+	Subsample int `json:"subsample,omitempty"`
+}
+
+// SetObservationROI makes the server crop (and optionally
+// subsample) image observations before sending them, which
+// shrinks payloads when an agent only uses part of the
+// screen.
+// The observation space is cropped the same way.
+//
+// A nil roi makes the server send whole observations
+// again.
+func SetObservationROI(env Env, roi *ROI) (err error) {
+	defer essentials.AddCtxTo("set observation ROI", &err)
+	if roi != nil && (roi.X < 0 || roi.Y < 0 || roi.Width < 1 || roi.Height < 1 ||
+		roi.Subsample < 0) {
+		return errors.New("invalid region")
+	}
+	return env.Configure(map[string]interface{}{"observation_roi": roi})
+}
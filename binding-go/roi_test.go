@@ -0,0 +1,38 @@
+package gym
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type configureEnv struct {
+	Env
+	options []map[string]interface{}
+}
+
+func (c *configureEnv) Configure(options map[string]interface{}) error {
+	c.options = append(c.options, options)
+	return nil
+}
+
+func TestSetObservationROI(t *testing.T) {
+	env := &configureEnv{}
+	if err := SetObservationROI(env, &ROI{X: 8, Y: 16, Width: 64, Height: 32}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetObservationROI(env, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetObservationROI(env, &ROI{Width: 0, Height: 1}); err == nil {
+		t.Error("expected error for empty region")
+	}
+	data, err := json.Marshal(env.options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"observation_roi":{"x":8,"y":16,"width":64,"height":32}},` +
+		`{"observation_roi":null}]`
+	if string(data) != expected {
+		t.Errorf("expected %s but got %s", expected, data)
+	}
+}
@@ -1,5 +1,11 @@
 package gym
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
 // Space defines an action or observation space.
 type Space struct {
 	// Space type, such as "Discrete", "Tuple", "MultiBinary",
@@ -21,3 +27,83 @@ type Space struct {
 	// Subspaces for Tuple spaces.
 	Subspaces []*Space `json:"subspaces"`
 }
+
+// maxSpaceDepth limits the nesting of Tuple spaces.
+const maxSpaceDepth = 32
+
+// decodeSpace decodes and validates a space from the
+// server.
+func decodeSpace(data []byte) (*Space, error) {
+	var space *Space
+	if err := json.Unmarshal(data, &space); err != nil {
+		return nil, err
+	}
+	if space == nil {
+		return nil, errors.New("missing space")
+	}
+	if err := space.validate(0); err != nil {
+		return nil, err
+	}
+	return space, nil
+}
+
+// copy creates a deep copy of the space, so that cached
+// spaces cannot be modified by callers.
+func (s *Space) copy() *Space {
+	res := *s
+	res.Low = append([]float64(nil), s.Low...)
+	res.High = append([]float64(nil), s.High...)
+	res.Shape = append([]int(nil), s.Shape...)
+	if s.Subspaces != nil {
+		res.Subspaces = make([]*Space, len(s.Subspaces))
+		for i, sub := range s.Subspaces {
+			res.Subspaces[i] = sub.copy()
+		}
+	}
+	return &res
+}
+
+func (s *Space) validate(depth int) error {
+	if depth > maxSpaceDepth {
+		return errors.New("space is nested too deeply")
+	}
+	if s.N < 0 {
+		return fmt.Errorf("invalid %s space size: %d", s.Type, s.N)
+	}
+	if len(s.Low) != len(s.High) {
+		return fmt.Errorf("%s space has %d low bounds but %d high bounds", s.Type,
+			len(s.Low), len(s.High))
+	}
+	if s.Type == "Box" && !shapeMatches(s.Shape, len(s.Low)) {
+		return errors.New("Box shape does not match its bounds")
+	}
+	for _, sub := range s.Subspaces {
+		if sub == nil {
+			return errors.New("missing subspace")
+		}
+		if err := sub.validate(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shapeMatches checks if a shape has the given number of
+// elements, without overflowing for huge shapes.
+func shapeMatches(shape []int, size int) bool {
+	product := 1
+	for _, dim := range shape {
+		if dim < 0 {
+			return false
+		} else if dim == 0 {
+			return size == 0
+		}
+	}
+	for _, dim := range shape {
+		if product > size/dim {
+			return false
+		}
+		product *= dim
+	}
+	return product == size
+}
@@ -0,0 +1,62 @@
+package gym
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// ServerStatsResult describes the load on an API server.
+type ServerStatsResult struct {
+	// ActiveConnections is the number of open connections.
+	ActiveConnections int `json:"active_connections"`
+
+	// TotalConnections is the number of connections since
+	// the server started.
+	TotalConnections int `json:"total_connections"`
+
+	// Envs counts the live environment instances by name.
+	Envs map[string]int `json:"envs"`
+
+	// UptimeSeconds is the time since the server started.
+	UptimeSeconds float64 `json:"uptime"`
+
+	// MemoryBytes is the resident memory of the server and
+	// all of its connection processes, or 0 if unknown.
+	MemoryBytes int64 `json:"memory_bytes"`
+}
+
+// Uptime returns the time since the server started.
+func (s *ServerStatsResult) Uptime() time.Duration {
+	return time.Duration(s.UptimeSeconds * float64(time.Second))
+}
+
+// ServerStats gets usage statistics from an API server.
+//
+// The options configure the connection as they do for
+// Make, e.g. with WithTLS or WithAuthToken.
+// Options which only apply to environments, such as
+// Resumable and WithLease, are ignored.
+func ServerStats(host string, opts ...Option) (stats *ServerStatsResult, err error) {
+	defer essentials.AddCtxTo("get server stats", &err)
+	config := makeOptions(opts)
+	config.Resumable = false
+	config.Lease = 0
+	conn, err := dialEnvConn(host, &handshakeRequest{
+		Stats:   true,
+		Options: config,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.release()
+	data, err := readByteField(conn.Buf)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
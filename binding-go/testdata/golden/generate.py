@@ -0,0 +1,135 @@
+"""
+Generate the golden fixtures used by golden_test.go.
+
+Each fixture holds bytes encoded by the server's proto
+module for a reference environment, along with digests of
+the values they should decode to. Observations are fixed
+values from the environment's observation space, rather
+than rollouts, so that the fixtures do not depend on the
+environment's dynamics or random number generator.
+
+Run this from the root of the repository:
+
+    python binding-go/testdata/golden/generate.py
+"""
+
+import base64
+import gzip
+import hashlib
+import io
+import json
+import os
+import sys
+
+ROOT = os.path.abspath(os.path.join(os.path.dirname(__file__), '..', '..', '..'))
+sys.path.insert(0, ROOT)
+
+# pylint: disable=C0413
+import gym
+import numpy as np
+
+import handler
+import proto
+
+def cartpole_observations(_space):
+    """
+    Produce observations for CartPole, including a negative
+    zero and a subnormal float32.
+    """
+    return [
+        np.array([0.0273956, -0.00611216, 0.03585979, 0.0197368], dtype=np.float32),
+        np.array([0.02727336, 0.18847767, 0.03625453, -0.26141977], dtype=np.float32),
+        np.array([-0.0, 1e-40, -0.20943952, 2.5e20], dtype=np.float32),
+    ]
+
+def pong_observations(space):
+    """
+    Produce distinct frames for Pong.
+    """
+    size = int(np.prod(space.shape))
+    return [((np.arange(size) + i) % 251).astype(np.uint8).reshape(space.shape)
+            for i in range(3)]
+
+FIXTURES = [
+    ('CartPole-v0', 'cartpole.json', cartpole_observations, [1.0, 1.0]),
+    ('Pong-v0', 'pong.json.gz', pong_observations, [-1.0, 1.0]),
+]
+
+def main():
+    """
+    Write every fixture next to this script.
+    """
+    for env_name, filename, make_obs, rewards in FIXTURES:
+        env = gym.make(env_name)
+        fixture = make_fixture(env_name, env, make_obs(env.observation_space), rewards)
+        data = (json.dumps(fixture, indent=2, sort_keys=True) + '\n').encode('utf-8')
+        path = os.path.join(os.path.dirname(__file__), filename)
+        if filename.endswith('.gz'):
+            with gzip.GzipFile(path, 'wb', mtime=0) as out_file:
+                out_file.write(data)
+        else:
+            with open(path, 'wb') as out_file:
+                out_file.write(data)
+        env.close()
+
+def make_fixture(env_name, env, observations, rewards):
+    """
+    Encode the spaces, a reset, and steps for an
+    environment.
+    """
+    for obs in observations:
+        assert env.observation_space.contains(obs)
+    fixture = {
+        'env': env_name,
+        'action_space': space_fixture(env.action_space),
+        'observation_space': space_fixture(env.observation_space),
+        'reset': {
+            'response': encode(lambda buf: proto.write_obs(buf, env, observations[0])),
+            'obs_sha256': digest(observations[0]),
+        },
+        'steps': [],
+    }
+    for i, (obs, rew) in enumerate(zip(observations[1:], rewards)):
+        done = i == len(rewards) - 1
+        def write_step(buf, obs=obs, rew=rew, done=done):
+            proto.write_obs(buf, env, obs)
+            proto.write_reward(buf, rew)
+            proto.write_bool(buf, done)
+            proto.write_field_str(buf, handler.dump_info(env, {}))
+        fixture['steps'].append({
+            'response': encode(write_step),
+            'obs_sha256': digest(obs),
+            'reward': rew,
+            'done': done,
+        })
+    return fixture
+
+def space_fixture(space):
+    """
+    Encode a space along with the bounds it should decode
+    to.
+    """
+    res = {'response': encode(lambda buf: proto.write_space(buf, space))}
+    if isinstance(space, gym.spaces.Box):
+        bound = 1e30
+        res['low_sha256'] = digest(np.clip(space.low, -bound, bound))
+        res['high_sha256'] = digest(np.clip(space.high, -bound, bound))
+    return res
+
+def encode(write):
+    """
+    Capture the bytes written by a function as base64.
+    """
+    buf = io.BytesIO()
+    write(buf)
+    return base64.b64encode(buf.getvalue()).decode('ascii')
+
+def digest(values):
+    """
+    Hash values as flattened, little-endian float64s.
+    """
+    arr = np.asarray(values, dtype='<f8').flatten()
+    return hashlib.sha256(arr.tobytes()).hexdigest()
+
+if __name__ == '__main__':
+    main()
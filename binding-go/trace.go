@@ -0,0 +1,39 @@
+package gym
+
+// A Tracer is notified before and after every command that
+// an environment sends to the server.
+//
+// The op names the command, such as "reset", "step",
+// "get_space", or "step_batch".
+// The bytes passed to End count the data sent and received
+// for the command, including retries.
+//
+// Tracers can be used to collect metrics, to add pprof
+// labels, or to record spans for distributed tracing.
+// The metrics and gymotel packages provide Tracers for
+// Prometheus and OpenTelemetry.
+// Since commands may run concurrently, a Tracer should be
+// thread-safe, and it should match up Begin and End calls
+// by Goroutine if it needs to.
+// For pipelined steps, Begin is called by Pipeline.Send
+// and End by Pipeline.Recv (or Pipeline.Close).
+type Tracer interface {
+	Begin(op string)
+	End(op string, err error, bytes int)
+}
+
+// A ReconnectTracer is a Tracer which is also notified
+// when an environment reconnects to resume its session,
+// either on its own or through Env.Reconnect.
+// The err is nil if the reconnect succeeded.
+type ReconnectTracer interface {
+	Tracer
+	Reconnect(err error)
+}
+
+// WithTracer notifies a Tracer of every command.
+func WithTracer(t Tracer) Option {
+	return func(o *options) {
+		o.Tracer = t
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !js
+
+package gym
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+
+	"github.com/unixpickle/gym-socket-api/binding-go/wsconn"
+)
+
+// defaultDial is the DialFunc used without WithDialer.
+var defaultDial DialFunc = net.Dial
+
+// dialWebSocket connects to a ws or wss URL through the
+// dialer.
+func dialWebSocket(rawURL string, dial DialFunc) (net.Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dial("tcp", wsconn.HostPort(u))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "wss" {
+		conn = tls.Client(conn, wsconn.TLSConfig(u, nil))
+	}
+	return wsconn.Client(conn, u)
+}
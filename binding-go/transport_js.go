@@ -0,0 +1,24 @@
+package gym
+
+import (
+	"errors"
+	"net"
+
+	"github.com/unixpickle/gym-socket-api/binding-go/wsconn"
+)
+
+// defaultDial is the DialFunc used without WithDialer.
+//
+// WebAssembly programs cannot open raw sockets, so only
+// WebSocket addresses work without a custom dialer.
+func defaultDial(network, address string) (net.Conn, error) {
+	return nil, errors.New("cannot dial " + address + " from WebAssembly: " +
+		"use a ws:// or wss:// address")
+}
+
+// dialWebSocket connects to a ws or wss URL with the
+// WebSocket API of the JavaScript host.
+// The dialer is not used.
+func dialWebSocket(rawURL string, dial DialFunc) (net.Conn, error) {
+	return wsconn.DialBrowser(rawURL)
+}
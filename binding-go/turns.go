@@ -0,0 +1,82 @@
+package gym
+
+import "github.com/unixpickle/essentials"
+
+// CurrentAgent gets the agent whose turn is next in a
+// turn-based multi-agent environment, such as a classic
+// PettingZoo game.
+//
+// The next Step is taken by this agent.
+// If the agent is done, it must step with a nil action.
+// Once every agent is done, the name is empty.
+//
+// The agent is read from the environment's agent_selection
+// attribute with GetAttr.
+func CurrentAgent(env Env) (agent string, err error) {
+	var selection *string
+	if err := env.GetAttr("agent_selection", &selection); err != nil {
+		return "", essentials.AddCtx("get current agent", err)
+	}
+	if selection == nil {
+		return "", nil
+	}
+	return *selection, nil
+}
+
+// An AgentIterator iterates over the turns of a turn-based
+// environment, like the agent_iter method of a PettingZoo
+// environment.
+//
+// Each step's info holds "agent_done", which tells if the
+// agent whose turn is next is done and must pass.
+// For example:
+//
+//	obs, err := env.Reset()
+//	// Handle error.
+//	agentDone := false
+//	iter := gym.AgentIter(env)
+//	for iter.Next() {
+//		var action, info interface{}
+//		if !agentDone {
+//			action = policy(iter.Agent(), obs)
+//		}
+//		obs, _, _, info, err = env.Step(action)
+//		// Handle error.
+//		agentDone = info.(map[string]interface{})["agent_done"] == true
+//	}
+//	if err := iter.Err(); err != nil {
+//		// Handle error.
+//	}
+type AgentIterator struct {
+	env   Env
+	agent string
+	err   error
+}
+
+// AgentIter creates an AgentIterator for the environment's
+// current episode.
+// The environment should already be reset.
+func AgentIter(env Env) *AgentIterator {
+	return &AgentIterator{env: env}
+}
+
+// Next finds the agent whose turn is next.
+// It returns false once every agent is done, or if there
+// was an error.
+func (a *AgentIterator) Next() bool {
+	if a.err != nil {
+		return false
+	}
+	a.agent, a.err = CurrentAgent(a.env)
+	return a.err == nil && a.agent != ""
+}
+
+// Agent gets the agent found by the last call to Next.
+func (a *AgentIterator) Agent() string {
+	return a.agent
+}
+
+// Err gets the error which stopped the iteration, if any.
+func (a *AgentIterator) Err() error {
+	return a.err
+}
@@ -0,0 +1,66 @@
+package gym
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// turnEnv mimics the agent_selection attribute of a
+// turn-based environment, which advances on every step.
+type turnEnv struct {
+	Env
+
+	turns []interface{}
+}
+
+func (t *turnEnv) GetAttr(name string, dst interface{}) error {
+	if name != "agent_selection" {
+		return ErrInvalidArgument
+	}
+	var selection interface{}
+	if len(t.turns) > 0 {
+		selection = t.turns[0]
+	}
+	data, err := json.Marshal(selection)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func (t *turnEnv) Step(action interface{}) (Obs, float64, bool, interface{}, error) {
+	t.turns = t.turns[1:]
+	return nil, 0, len(t.turns) == 0, nil, nil
+}
+
+func TestAgentIter(t *testing.T) {
+	env := &turnEnv{turns: []interface{}{"player_0", "player_1", "player_0"}}
+	var agents []string
+	iter := AgentIter(env)
+	for iter.Next() {
+		agents = append(agents, iter.Agent())
+		if _, _, _, _, err := env.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"player_0", "player_1", "player_0"}; !reflect.DeepEqual(agents, expected) {
+		t.Errorf("expected agents %v but got %v", expected, agents)
+	}
+	if agent, err := CurrentAgent(env); err != nil || agent != "" {
+		t.Errorf("expected no agent but got %q (error %v)", agent, err)
+	}
+
+	iter = AgentIter(&turnEnv{turns: []interface{}{1}})
+	if iter.Next() {
+		t.Error("expected iteration to stop on a bad agent name")
+	}
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(iter.Err(), &typeErr) {
+		t.Errorf("unexpected error: %v", iter.Err())
+	}
+}
@@ -0,0 +1,113 @@
+package gym
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A UniverseRemote is a Docker container on an API server
+// which runs a VNC environment for Universe.
+type UniverseRemote struct {
+	// ID is the Docker container ID.
+	ID string `json:"id"`
+
+	// Image is the runtime ID or Docker image which the
+	// remote was allocated with.
+	Image string `json:"image"`
+
+	// Address can be passed to UniverseConfigure in the
+	// "remotes" option, like
+	//
+	//	env.UniverseConfigure(map[string]interface{}{
+	//		"remotes": remote.Address,
+	//	})
+	//
+	// Addresses can also be joined with commas to
+	// configure several remotes at once.
+	Address string `json:"address"`
+}
+
+// UniverseAllocateRemotes starts n Docker containers on an
+// API server to serve as remotes for Universe
+// environments.
+//
+// The image may be a Universe runtime ID, such as
+// "flashgames", or a Docker image.
+//
+// The remotes outlive the connection, so they should be
+// released with UniverseReleaseRemotes when they are no
+// longer needed.
+// The server must be started with --universe.
+func UniverseAllocateRemotes(host string, n int,
+	image string) (remotes []*UniverseRemote, err error) {
+	defer essentials.AddCtxTo("allocate Universe remotes", &err)
+	if n < 0 {
+		return nil, fmt.Errorf("invalid remote count: %d", n)
+	}
+	env, err := makeNoEnv(host)
+	if err != nil {
+		return nil, err
+	}
+	defer env.Close()
+	err = env.command("universe_allocate_remotes", func(w *bufio.Writer) error {
+		if err := env.writeHeader(w, packetUniverseAllocateRemotes); err != nil {
+			return err
+		}
+		if err := writeUint32(w, uint32(n)); err != nil {
+			return err
+		}
+		return writeByteField(w, []byte(image))
+	}, func(r *bufio.Reader) error {
+		if err := readErrorField(r); err != nil {
+			return err
+		}
+		return readJSONResult(r, &remotes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return remotes, nil
+}
+
+// UniverseRemotes describes the remotes which are
+// allocated on an API server.
+func UniverseRemotes(host string) (remotes []*UniverseRemote, err error) {
+	defer essentials.AddCtxTo("list Universe remotes", &err)
+	err = queryNoEnv(host, "universe_list_remotes", packetUniverseListRemotes, &remotes)
+	if err != nil {
+		return nil, err
+	}
+	return remotes, nil
+}
+
+// UniverseReleaseRemotes stops and removes remotes on an
+// API server by ID.
+//
+// If any ID is not an allocated remote, no remotes are
+// released.
+func UniverseReleaseRemotes(host string, ids ...string) (err error) {
+	defer essentials.AddCtxTo("release Universe remotes", &err)
+	if ids == nil {
+		ids = []string{}
+	}
+	idsData, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	env, err := makeNoEnv(host)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	return env.command("universe_release_remotes", func(w *bufio.Writer) error {
+		if err := env.writeHeader(w, packetUniverseReleaseRemotes); err != nil {
+			return err
+		}
+		return writeByteField(w, idsData)
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
+}
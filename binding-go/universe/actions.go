@@ -0,0 +1,93 @@
+package universe
+
+import "encoding/json"
+
+// Button masks for PointerEvent.
+const (
+	LeftButton   = 1 << 0
+	MiddleButton = 1 << 1
+	RightButton  = 1 << 2
+)
+
+// An Action is a list of VNC events which can be passed to
+// gym.Env.Step for a Universe environment.
+//
+// Actions can be combined with append, like
+//
+//	action := append(universe.KeyPress("ArrowUp"),
+//		universe.PointerMove(100, 200, universe.LeftButton)...)
+type Action []Event
+
+// An Event is a VNC event, either a KeyEvent or a
+// PointerEvent.
+type Event interface {
+	json.Marshaler
+
+	vncEvent()
+}
+
+// A KeyEvent presses or releases a key.
+//
+// Keys are named like in Universe, such as "ArrowUp",
+// "space", or "a".
+type KeyEvent struct {
+	Key  string
+	Down bool
+}
+
+// MarshalJSON encodes the event in Universe's format.
+func (k KeyEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"KeyEvent", k.Key, k.Down})
+}
+
+func (k KeyEvent) vncEvent() {}
+
+// A PointerEvent moves the mouse to a point on the screen
+// while holding down the buttons in a button mask.
+type PointerEvent struct {
+	X       int
+	Y       int
+	Buttons int
+}
+
+// MarshalJSON encodes the event in Universe's format.
+func (p PointerEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"PointerEvent", p.X, p.Y, p.Buttons})
+}
+
+func (p PointerEvent) vncEvent() {}
+
+// KeyPress creates an Action which presses keys.
+//
+// The keys stay down until they are released, for example
+// with KeyRelease.
+func KeyPress(keys ...string) Action {
+	return keyAction(keys, true)
+}
+
+// KeyRelease creates an Action which releases keys.
+func KeyRelease(keys ...string) Action {
+	return keyAction(keys, false)
+}
+
+// PointerMove creates an Action which moves the mouse to
+// (x, y) with the given buttons held down.
+//
+// A buttons value of 0 releases all of the buttons.
+func PointerMove(x, y, buttons int) Action {
+	return Action{PointerEvent{X: x, Y: y, Buttons: buttons}}
+}
+
+// Click creates an Action which clicks the left mouse
+// button at (x, y).
+func Click(x, y int) Action {
+	return append(PointerMove(x, y, LeftButton), PointerMove(x, y, 0)...)
+}
+
+func keyAction(keys []string, down bool) Action {
+	action := Action{}
+	for _, key := range keys {
+		action = append(action, KeyEvent{Key: key, Down: down})
+	}
+	return action
+}
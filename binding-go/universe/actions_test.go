@@ -0,0 +1,34 @@
+package universe
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestActionJSON(t *testing.T) {
+	actions := []Action{
+		KeyPress("ArrowUp"),
+		KeyRelease("ArrowUp", "space"),
+		PointerMove(10, 20, LeftButton|RightButton),
+		Click(3, 4),
+		append(KeyPress("a"), PointerMove(1, 2, 0)...),
+		KeyPress(),
+	}
+	expected := []string{
+		`[["KeyEvent","ArrowUp",true]]`,
+		`[["KeyEvent","ArrowUp",false],["KeyEvent","space",false]]`,
+		`[["PointerEvent",10,20,5]]`,
+		`[["PointerEvent",3,4,1],["PointerEvent",3,4,0]]`,
+		`[["KeyEvent","a",true],["PointerEvent",1,2,0]]`,
+		`[]`,
+	}
+	for i, action := range actions {
+		data, err := json.Marshal(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected[i] {
+			t.Errorf("action %d: expected %s but got %s", i, expected[i], data)
+		}
+	}
+}
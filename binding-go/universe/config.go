@@ -0,0 +1,184 @@
+// Package universe provides typed helpers for OpenAI
+// Universe environments, so that configurations and
+// actions do not have to be built as raw JSON.
+package universe
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// Crop is a region of the screen, in pixels.
+type Crop struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Config configures a Universe environment.
+//
+// Zero fields leave the server's defaults in place.
+type Config struct {
+	// Remotes is the remotes option of Universe, such as
+	// "1" to start one local Docker remote, or a list of
+	// comma-separated VNC addresses like those from
+	// gym.UniverseAllocateRemotes.
+	Remotes string
+
+	// FPS is the frame rate of the environment.
+	FPS float64
+
+	// Crop limits observations to a region of the screen.
+	Crop *Crop
+
+	// AutoCrop limits observations to the game screen of
+	// known environments, such as Flash games.
+	// It cannot be combined with Crop.
+	AutoCrop bool
+
+	// Subsample is the factor by which the VNC server
+	// downsamples the screen: 1, 2, 4, or 8.
+	Subsample int
+
+	// FineQuality is the JPEG quality of the VNC stream,
+	// from 1 to 100.
+	FineQuality int
+
+	// Encoding is the VNC encoding, either "tight" or
+	// "zrle".
+	Encoding string
+
+	// StartTimeout limits how long to wait for remotes to
+	// start.
+	StartTimeout time.Duration
+
+	// Extra holds other options for Universe's configure,
+	// which are checked against the known options.
+	Extra map[string]interface{}
+}
+
+// knownOptions are the options of Universe's configure.
+var knownOptions = map[string]bool{
+	"remotes": true, "client_id": true, "start_timeout": true,
+	"docker_image": true, "ignore_clock_skew": true,
+	"disable_action_probes": true, "vnc_driver": true, "vnc_kwargs": true,
+	"rewarder_driver": true, "replace_on_crash": true, "allocate_sync": true,
+	"observer": true, "api_key": true, "record": true, "sample_env_ids": true,
+	"fps": true,
+}
+
+// Options converts the Config to options for
+// gym.Env.UniverseConfigure.
+//
+// The Crop and AutoCrop fields are not included, since
+// cropping is done by a wrapper.
+func (c *Config) Options() (options map[string]interface{}, err error) {
+	defer essentials.AddCtxTo("universe config", &err)
+	options = map[string]interface{}{}
+	var unknown []string
+	for key, value := range c.Extra {
+		if !knownOptions[key] {
+			unknown = append(unknown, key)
+		}
+		options[key] = value
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown options: %s", strings.Join(unknown, ", "))
+	}
+	if c.Remotes != "" {
+		options["remotes"] = c.Remotes
+	}
+	if c.FPS < 0 {
+		return nil, fmt.Errorf("invalid FPS: %f", c.FPS)
+	} else if c.FPS > 0 {
+		options["fps"] = c.FPS
+	}
+	if c.StartTimeout < 0 {
+		return nil, fmt.Errorf("invalid start timeout: %v", c.StartTimeout)
+	} else if c.StartTimeout > 0 {
+		options["start_timeout"] = c.StartTimeout.Seconds()
+	}
+	vncOptions, err := c.vncOptions()
+	if err != nil {
+		return nil, err
+	}
+	if len(vncOptions) > 0 {
+		options["vnc_kwargs"] = vncOptions
+	}
+	return options, nil
+}
+
+func (c *Config) vncOptions() (map[string]interface{}, error) {
+	options := map[string]interface{}{}
+	if extra, ok := c.Extra["vnc_kwargs"].(map[string]interface{}); ok {
+		for key, value := range extra {
+			options[key] = value
+		}
+	}
+	switch c.Subsample {
+	case 0:
+	case 1, 2, 4, 8:
+		level := 0
+		for 1<<level < c.Subsample {
+			level++
+		}
+		options["subsample_level"] = level
+	default:
+		return nil, fmt.Errorf("invalid subsample factor: %d", c.Subsample)
+	}
+	if c.FineQuality < 0 || c.FineQuality > 100 {
+		return nil, fmt.Errorf("invalid fine quality: %d", c.FineQuality)
+	} else if c.FineQuality > 0 {
+		options["fine_quality_level"] = c.FineQuality
+	}
+	switch c.Encoding {
+	case "":
+	case "tight", "zrle":
+		options["encoding"] = c.Encoding
+	default:
+		return nil, fmt.Errorf("invalid encoding: %s", c.Encoding)
+	}
+	return options, nil
+}
+
+// Configure crops and configures a Universe environment.
+//
+// Like UniverseConfigure, this must be called before the
+// environment is used, and only once.
+func Configure(env gym.Env, config *Config) (err error) {
+	defer essentials.AddCtxTo("configure Universe environment", &err)
+	options, err := config.Options()
+	if err != nil {
+		return err
+	}
+	if crop := config.Crop; crop != nil {
+		if config.AutoCrop {
+			return errors.New("cannot use both Crop and AutoCrop")
+		}
+		if crop.X < 0 || crop.Y < 0 || crop.Width <= 0 || crop.Height <= 0 {
+			return errors.New("invalid crop region")
+		}
+		err := env.UniverseWrap("CropRegion", map[string]interface{}{
+			"x":      crop.X,
+			"y":      crop.Y,
+			"width":  crop.Width,
+			"height": crop.Height,
+		})
+		if err != nil {
+			return err
+		}
+	} else if config.AutoCrop {
+		if err := env.UniverseWrap("CropObservations", nil); err != nil {
+			return err
+		}
+	}
+	return env.UniverseConfigure(options)
+}
@@ -0,0 +1,118 @@
+package universe
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+type configEnv struct {
+	gym.Env
+
+	calls []string
+	args  []map[string]interface{}
+}
+
+func (c *configEnv) UniverseWrap(name string, options map[string]interface{}) error {
+	c.calls = append(c.calls, name)
+	c.args = append(c.args, options)
+	return nil
+}
+
+func (c *configEnv) UniverseConfigure(options map[string]interface{}) error {
+	c.calls = append(c.calls, "configure")
+	c.args = append(c.args, options)
+	return nil
+}
+
+func TestConfigOptions(t *testing.T) {
+	config := &Config{
+		Remotes:      "1",
+		FPS:          15,
+		Subsample:    4,
+		FineQuality:  50,
+		Encoding:     "tight",
+		StartTimeout: time.Minute,
+		Extra: map[string]interface{}{
+			"vnc_kwargs": map[string]interface{}{"compress_level": 0},
+			"observer":   true,
+		},
+	}
+	actual, err := config.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"remotes":       "1",
+		"fps":           15.0,
+		"start_timeout": 60.0,
+		"observer":      true,
+		"vnc_kwargs": map[string]interface{}{
+			"compress_level":     0,
+			"subsample_level":    2,
+			"fine_quality_level": 50,
+			"encoding":           "tight",
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+func TestConfigInvalid(t *testing.T) {
+	configs := []*Config{
+		{FPS: -1},
+		{Subsample: 3},
+		{FineQuality: 101},
+		{Encoding: "raw"},
+		{StartTimeout: -time.Second},
+		{Extra: map[string]interface{}{"fps": 5, "subsample": 2}},
+	}
+	for i, config := range configs {
+		if _, err := config.Options(); err == nil {
+			t.Errorf("config %d: expected error", i)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	env := &configEnv{}
+	err := Configure(env, &Config{
+		Remotes: "1",
+		Crop:    &Crop{X: 18, Y: 84, Width: 160, Height: 210},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env.calls, []string{"CropRegion", "configure"}) {
+		t.Fatalf("unexpected calls: %v", env.calls)
+	}
+	crop := map[string]interface{}{"x": 18, "y": 84, "width": 160, "height": 210}
+	if !reflect.DeepEqual(env.args[0], crop) {
+		t.Errorf("unexpected crop options: %v", env.args[0])
+	}
+
+	env = &configEnv{}
+	if err := Configure(env, &Config{AutoCrop: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env.calls, []string{"CropObservations", "configure"}) {
+		t.Fatalf("unexpected calls: %v", env.calls)
+	}
+
+	invalid := []*Config{
+		{Crop: &Crop{Width: 0, Height: 10}},
+		{Crop: &Crop{Width: 10, Height: 10}, AutoCrop: true},
+	}
+	for i, config := range invalid {
+		env = &configEnv{}
+		if Configure(env, config) == nil {
+			t.Errorf("config %d: expected error", i)
+		}
+		if len(env.calls) != 0 {
+			t.Errorf("config %d: unexpected calls: %v", i, env.calls)
+		}
+	}
+}
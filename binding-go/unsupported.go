@@ -0,0 +1,63 @@
+package gym
+
+import "github.com/unixpickle/essentials"
+
+// UnsupportedUniverse implements UniverseEnv for
+// environments which do not support Universe.
+// Its methods fail with ErrUnsupported.
+//
+// Embed it in an Env implementation to avoid stubbing the
+// Universe methods by hand.
+type UnsupportedUniverse struct{}
+
+func (UnsupportedUniverse) UniverseConfigure(options map[string]interface{}) error {
+	return unsupported("configure Universe")
+}
+
+func (UnsupportedUniverse) UniverseWrap(wrapper string, options map[string]interface{}) error {
+	return unsupported("wrap Universe")
+}
+
+// UnsupportedRetro implements RetroEnv for environments
+// which do not support Retro.
+// Its methods fail with ErrUnsupported.
+//
+// Embed it in an Env implementation to avoid stubbing the
+// Retro methods by hand.
+type UnsupportedRetro struct{}
+
+func (UnsupportedRetro) RetroConfigure(options map[string]interface{}) error {
+	return unsupported("configure Retro")
+}
+
+func (UnsupportedRetro) RetroWrap(wrapper string, options map[string]interface{}) error {
+	return unsupported("wrap Retro")
+}
+
+func (UnsupportedRetro) RetroSaveState() ([]byte, error) {
+	return nil, unsupported("save Retro state")
+}
+
+func (UnsupportedRetro) RetroLoadState(state []byte) error {
+	return unsupported("load Retro state")
+}
+
+func (UnsupportedRetro) RetroVariables() (map[string]int, error) {
+	return nil, unsupported("get Retro variables")
+}
+
+func (UnsupportedRetro) RetroReadRAM(offset, size int) ([]byte, error) {
+	return nil, unsupported("read Retro RAM")
+}
+
+func (UnsupportedRetro) RetroStartMovie(path string) (string, error) {
+	return "", unsupported("start Retro movie")
+}
+
+func (UnsupportedRetro) RetroStopMovie() (string, error) {
+	return "", unsupported("stop Retro movie")
+}
+
+func unsupported(op string) error {
+	return essentials.AddCtx(op, ErrUnsupported)
+}
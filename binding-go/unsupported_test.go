@@ -0,0 +1,21 @@
+package gym
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnsupported(t *testing.T) {
+	var env struct {
+		UnsupportedUniverse
+		UnsupportedRetro
+	}
+	var universe UniverseEnv = env
+	var retro RetroEnv = env
+	if err := universe.UniverseWrap("Vision", nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported but got %v", err)
+	}
+	if _, err := retro.RetroReadRAM(0, 1); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported but got %v", err)
+	}
+}
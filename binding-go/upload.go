@@ -1,9 +1,14 @@
 package gym
 
 import (
-	"errors"
+	"bufio"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/unixpickle/essentials"
 )
@@ -16,6 +21,9 @@ import (
 //
 // If the directory is a relative path, it should be
 // relative to the current working directory.
+//
+// The Gym website no longer accepts uploads, so
+// UploadMonitor should be used for other services.
 func Upload(apiHost, dir, apiKey, algorithmID string) (err error) {
 	essentials.AddCtxTo("upload monitor", &err)
 	env, err := Make(apiHost, "")
@@ -33,23 +41,93 @@ func Upload(apiHost, dir, apiKey, algorithmID string) (err error) {
 		return err
 	}
 
-	if err := writePacketType(c.Buf, packetUpload); err != nil {
-		return err
-	}
-	for _, str := range []string{absDir, apiKey, algorithmID} {
-		if err := writeByteField(c.Buf, []byte(str)); err != nil {
+	return c.command("upload", func(w *bufio.Writer) error {
+		if err := c.writeHeader(w, packetUpload); err != nil {
 			return err
 		}
-	}
-	if err := c.Buf.Flush(); err != nil {
+		for _, str := range []string{absDir, apiKey, algorithmID} {
+			if err := writeByteField(w, []byte(str)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(r *bufio.Reader) error {
+		return readErrorField(r)
+	})
+}
+
+// UploadMonitor sends the files in a monitor directory to
+// an HTTP endpoint.
+// This replaces Upload for services other than the Gym
+// website, which no longer accepts uploads.
+//
+// The files are sent in a multipart/form-data POST request,
+// with one "file" part per file and an "algorithm_id"
+// field.
+// If the API key is not "", it is sent as a bearer token
+// in the Authorization header.
+// Any response other than a 2xx status is an error.
+//
+// Unlike Upload, the directory is read by the client, so
+// it must be on the same machine as the server that wrote
+// it, or on a shared filesystem.
+func UploadMonitor(endpoint, dir, apiKey, algorithmID string) (err error) {
+	defer essentials.AddCtxTo("upload monitor", &err)
+	listing, err := os.ReadDir(dir)
+	if err != nil {
 		return err
 	}
 
-	if errMsg, err := readByteField(c.Buf); err != nil {
+	body, bodyWriter := io.Pipe()
+	form := multipart.NewWriter(bodyWriter)
+	go func() {
+		bodyWriter.CloseWithError(writeMonitorForm(form, dir, listing, algorithmID))
+	}()
+	defer body.Close()
+
+	req, err := http.NewRequest("POST", endpoint, body)
+	if err != nil {
 		return err
-	} else if len(errMsg) > 0 {
-		return errors.New(string(errMsg))
 	}
-
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w: %s: %s", ErrUploadFailed, resp.Status,
+			strings.TrimSpace(string(msg)))
+	}
 	return nil
 }
+
+func writeMonitorForm(form *multipart.Writer, dir string, listing []os.DirEntry,
+	algorithmID string) error {
+	if err := form.WriteField("algorithm_id", algorithmID); err != nil {
+		return err
+	}
+	for _, entry := range listing {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		part, err := form.CreateFormFile("file", entry.Name())
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return form.Close()
+}
@@ -0,0 +1,72 @@
+package gym
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUploadMonitor(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"openaigym.manifest.0.json":    `{"stats": "x"}`,
+		"openaigym.video.0.000000.mp4": "video data",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Mkdir(filepath.Join(dir, "subdir"), 0755)
+
+	received := map[string]string{}
+	var algorithmID, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(part)
+			if part.FormName() == "algorithm_id" {
+				algorithmID = string(data)
+			} else {
+				received[part.FileName()] = string(data)
+			}
+		}
+		auth = r.Header.Get("Authorization")
+		if auth == "" {
+			http.Error(w, "missing key", http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	if err := UploadMonitor(server.URL, dir, "secret", "alg1"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(received, files) {
+		t.Errorf("unexpected files: %v", received)
+	}
+	if algorithmID != "alg1" || auth != "Bearer secret" {
+		t.Errorf("unexpected algorithm %q and authorization %q", algorithmID, auth)
+	}
+
+	err := UploadMonitor(server.URL, dir, "", "alg1")
+	if !errors.Is(err, ErrUploadFailed) {
+		t.Errorf("expected upload failure but got %v", err)
+	}
+}
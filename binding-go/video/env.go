@@ -0,0 +1,96 @@
+package video
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// An Env records a video of each episode of an
+// environment, using its observations as frames.
+type Env struct {
+	gym.Wrapper
+
+	dir  string
+	opts *Options
+
+	lock     sync.Mutex
+	recorder *Recorder
+	episode  int
+}
+
+// NewEnv creates an Env which saves videos to a directory,
+// named "episode-000000.mp4" and so on.
+//
+// The directory is created if it does not exist.
+// The opts argument may be nil.
+func NewEnv(env gym.Env, dir string, opts *Options) (*Env, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, essentials.AddCtx("create video env", err)
+	}
+	return &Env{Wrapper: gym.Wrapper{Env: env}, dir: dir, opts: opts}, nil
+}
+
+func (e *Env) Reset() (obs gym.Obs, err error) {
+	obs, err = e.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if err := e.finishEpisode(); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("episode-%06d.mp4", e.episode)
+	e.episode++
+	e.recorder = NewRecorder(filepath.Join(e.dir, name), e.opts)
+	if err := e.recorder.AddFrame(obs); err != nil {
+		return nil, err
+	}
+	return obs, nil
+}
+
+func (e *Env) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = e.Env.Step(action)
+	if err != nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.recorder == nil {
+		return
+	}
+	if err = e.recorder.AddFrame(obs); err == nil && done {
+		err = e.finishEpisode()
+	}
+	return
+}
+
+// Close finishes the current video and closes the
+// environment.
+func (e *Env) Close() error {
+	e.lock.Lock()
+	videoErr := e.finishEpisode()
+	e.lock.Unlock()
+	if err := e.Env.Close(); err != nil {
+		return err
+	}
+	return videoErr
+}
+
+// finishEpisode closes the current video, if there is one.
+//
+// The caller must hold e.lock.
+func (e *Env) finishEpisode() error {
+	if e.recorder == nil {
+		return nil
+	}
+	err := e.recorder.Close()
+	e.recorder = nil
+	return err
+}
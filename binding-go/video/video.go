@@ -0,0 +1,167 @@
+// Package video encodes observations from environments as
+// videos, using an ffmpeg process.
+//
+// This works on the client, so videos can be made even if
+// the server has no display or cannot run a Monitor.
+package video
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// Options configures a Recorder.
+type Options struct {
+	// FPS is the frame rate of the video.
+	// If 0, it defaults to 30.
+	FPS int
+
+	// FFmpeg is the path to the ffmpeg executable.
+	// If empty, "ffmpeg" is found in the PATH.
+	FFmpeg string
+
+	// ExtraArgs are passed to ffmpeg before the output
+	// path, e.g. to set the quality.
+	ExtraArgs []string
+}
+
+// A Recorder encodes frames into a video file.
+//
+// The format is chosen from the file extension: ".webm"
+// uses VP9, and anything else (such as ".mp4") uses H.264.
+//
+// Frames are RGB byte list observations with shape HxWx3,
+// such as the observations of Atari environments.
+// Every frame must have the same shape.
+type Recorder struct {
+	path string
+	opts Options
+
+	lock   sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr strings.Builder
+	shape  []int
+	closed bool
+}
+
+// NewRecorder creates a Recorder which writes to a file.
+//
+// The ffmpeg process is started when the first frame is
+// added, since it needs to know the size of the frames.
+//
+// The opts argument may be nil.
+func NewRecorder(path string, opts *Options) *Recorder {
+	res := &Recorder{path: path}
+	if opts != nil {
+		res.opts = *opts
+	}
+	if res.opts.FPS == 0 {
+		res.opts.FPS = 30
+	}
+	if res.opts.FFmpeg == "" {
+		res.opts.FFmpeg = "ffmpeg"
+	}
+	return res
+}
+
+// AddFrame encodes a frame.
+func (r *Recorder) AddFrame(frame gym.Obs) (err error) {
+	defer essentials.AddCtxTo("add video frame", &err)
+	u8, ok := frame.(gym.Uint8Obs)
+	shaped, ok1 := frame.(gym.ShapedObs)
+	if !ok || !ok1 {
+		return errors.New("expected a byte list frame")
+	}
+	shape := shaped.Shape()
+	if len(shape) != 3 || shape[2] != 3 {
+		return fmt.Errorf("expected an HxWx3 frame but got shape %v", shape)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return errors.New("recorder is closed")
+	}
+	if r.cmd == nil {
+		if err := r.start(shape); err != nil {
+			return err
+		}
+	} else if shape[0] != r.shape[0] || shape[1] != r.shape[1] {
+		return fmt.Errorf("frame shape changed from %v to %v", r.shape, shape)
+	}
+	_, err = r.stdin.Write(u8.Uint8Obs())
+	return err
+}
+
+// Close finishes the video and waits for ffmpeg to exit.
+//
+// If no frames were added, no file is created.
+func (r *Recorder) Close() (err error) {
+	defer essentials.AddCtxTo("close video", &err)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.cmd == nil {
+		return nil
+	}
+	r.stdin.Close()
+	if err := r.cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(r.stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *Recorder) start(shape []int) error {
+	cmd := exec.Command(r.opts.FFmpeg, r.args(shape)...)
+	cmd.Stderr = &r.stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	r.cmd = cmd
+	r.stdin = stdin
+	r.shape = append([]int{}, shape...)
+	return nil
+}
+
+// args creates the ffmpeg arguments for frames of a given
+// shape.
+func (r *Recorder) args(shape []int) []string {
+	args := []string{
+		"-y", "-loglevel", "error",
+		"-f", "rawvideo",
+		"-pix_fmt", "rgb24",
+		"-s", fmt.Sprintf("%dx%d", shape[1], shape[0]),
+		"-r", strconv.Itoa(r.opts.FPS),
+		"-i", "-",
+		// Most codecs need even dimensions.
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2",
+		"-pix_fmt", "yuv420p",
+	}
+	if strings.EqualFold(filepath.Ext(r.path), ".webm") {
+		args = append(args, "-c:v", "libvpx-vp9")
+	} else {
+		args = append(args, "-c:v", "libx264")
+	}
+	args = append(args, r.opts.ExtraArgs...)
+	return append(args, r.path)
+}
@@ -0,0 +1,110 @@
+package video
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+type testEnv struct {
+	gym.Env
+	t int
+}
+
+func (t *testEnv) Reset() (gym.Obs, error) {
+	t.t = 0
+	return t.obs(), nil
+}
+
+func (t *testEnv) Step(action interface{}) (gym.Obs, float64, bool, interface{}, error) {
+	t.t++
+	return t.obs(), 0, t.t == 3, nil, nil
+}
+
+func (t *testEnv) Close() error {
+	return nil
+}
+
+func (t *testEnv) obs() gym.Obs {
+	values := make([]uint8, 2*3*3)
+	for i := range values {
+		values[i] = uint8(t.t)
+	}
+	return gym.NewUint8Obs([]int{2, 3, 3}, values)
+}
+
+// fakeFFmpeg creates a script which saves its input to the
+// output path, in place of ffmpeg.
+func fakeFFmpeg(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\nfor last; do :; done\ncat > \"$last\"\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnv(t *testing.T) {
+	dir := t.TempDir()
+	env, err := NewEnv(&testEnv{}, dir, &Options{FFmpeg: fakeFFmpeg(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		env.Reset()
+		for j := 0; j < 3; j++ {
+			if _, _, _, _, err := env.Step(0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := env.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"episode-000000.mp4", "episode-000001.mp4"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected []byte
+		for i := 0; i < 4; i++ {
+			for j := 0; j < 18; j++ {
+				expected = append(expected, uint8(i))
+			}
+		}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("%s: unexpected frames: %v", name, data)
+		}
+	}
+}
+
+func TestRecorderArgs(t *testing.T) {
+	args := NewRecorder("out.webm", &Options{FPS: 15}).args([]int{210, 160, 3})
+	joined := strings.Join(args, " ")
+	for _, expected := range []string{"-s 160x210", "-r 15", "-c:v libvpx-vp9"} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("missing %q in %q", expected, joined)
+		}
+	}
+	if args[len(args)-1] != "out.webm" {
+		t.Errorf("unexpected output path: %s", args[len(args)-1])
+	}
+}
+
+func TestRecorderShape(t *testing.T) {
+	r := NewRecorder(filepath.Join(t.TempDir(), "x.mp4"), &Options{FFmpeg: fakeFFmpeg(t)})
+	defer r.Close()
+	if err := r.AddFrame(gym.NewUint8Obs([]int{2, 2}, make([]uint8, 4))); err == nil {
+		t.Error("expected error for grayscale frame")
+	}
+	if err := r.AddFrame(gym.NewUint8Obs([]int{1, 1, 3}, make([]uint8, 3))); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddFrame(gym.NewUint8Obs([]int{2, 1, 3}, make([]uint8, 6))); err == nil {
+		t.Error("expected error for changed shape")
+	}
+}
@@ -0,0 +1,65 @@
+package gym
+
+// A Wrapper is an Env which wraps another Env.
+//
+// By itself, a Wrapper forwards every method to the Env
+// it wraps.
+// To change some of the Env's behavior, embed a Wrapper in
+// a struct and override the corresponding methods:
+//
+//	type negateReward struct {
+//		gym.Wrapper
+//	}
+//
+//	func (n *negateReward) Step(action interface{}) (obs gym.Obs,
+//		reward float64, done bool, info interface{}, err error) {
+//		obs, reward, done, info, err = n.Env.Step(action)
+//		return obs, -reward, done, info, err
+//	}
+//
+// Embedding a Wrapper, rather than the Env directly, lets
+// Unwrap find the wrapped Env.
+type Wrapper struct {
+	Env
+}
+
+// Unwrap returns the wrapped Env.
+func (w Wrapper) Unwrap() Env {
+	return w.Env
+}
+
+// A WrapperFunc wraps an Env.
+type WrapperFunc func(env Env) Env
+
+// Chain applies wrappers to an Env in order, so that the
+// first wrapper is the innermost one.
+func Chain(env Env, wrappers ...WrapperFunc) Env {
+	for _, wrapper := range wrappers {
+		env = wrapper(env)
+	}
+	return env
+}
+
+// Unwrap returns the Env wrapped by env, or nil if env is
+// not a wrapper.
+//
+// An Env is a wrapper if it has an Unwrap method, such as
+// the one provided by Wrapper.
+func Unwrap(env Env) Env {
+	if w, ok := env.(interface{ Unwrap() Env }); ok {
+		return w.Unwrap()
+	}
+	return nil
+}
+
+// Innermost unwraps an Env until it is no longer a
+// wrapper, returning the original Env.
+func Innermost(env Env) Env {
+	for {
+		inner := Unwrap(env)
+		if inner == nil {
+			return env
+		}
+		env = inner
+	}
+}
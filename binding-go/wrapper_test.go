@@ -0,0 +1,53 @@
+package gym
+
+import "testing"
+
+type negateReward struct {
+	Wrapper
+}
+
+func (n *negateReward) Step(action interface{}) (obs Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = n.Env.Step(action)
+	return obs, -reward, done, info, err
+}
+
+type constRewardEnv struct {
+	Env
+}
+
+func (c *constRewardEnv) Step(action interface{}) (Obs, float64, bool, interface{}, error) {
+	return nil, 1, false, nil, nil
+}
+
+func TestChain(t *testing.T) {
+	base := &constRewardEnv{}
+	var wrappers []WrapperFunc
+	for i := 0; i < 3; i++ {
+		wrappers = append(wrappers, func(env Env) Env {
+			return &negateReward{Wrapper{env}}
+		})
+	}
+	env := Chain(base, wrappers...)
+	_, reward, _, _, err := env.Step(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reward != -1 {
+		t.Errorf("expected reward -1 but got %f", reward)
+	}
+
+	var depth int
+	for e := env; Unwrap(e) != nil; e = Unwrap(e) {
+		depth++
+	}
+	if depth != 3 {
+		t.Errorf("expected 3 wrappers but got %d", depth)
+	}
+	if Innermost(env) != base {
+		t.Error("unexpected innermost environment")
+	}
+	if Unwrap(base) != nil {
+		t.Error("unwrapped a non-wrapper")
+	}
+}
@@ -0,0 +1,88 @@
+package wrappers
+
+import gym "github.com/unixpickle/gym-socket-api/binding-go"
+
+// AtariOptions configures AtariPreprocessing.
+//
+// The zero value gives the settings from the DQN paper.
+type AtariOptions struct {
+	// NoopMax is the maximum number of no-ops after a
+	// reset.
+	// If 0, it defaults to 30.
+	// If negative, no-ops are disabled.
+	NoopMax int
+
+	// FrameSkip is the number of frames per action.
+	// If 0, it defaults to 4.
+	FrameSkip int
+
+	// ScreenSize is the width and height of the resized
+	// frames.
+	// If 0, it defaults to AtariFrameSize.
+	ScreenSize int
+
+	// FrameStack is the number of stacked frames.
+	// If 0, it defaults to 4.
+	FrameStack int
+
+	// NoClipReward disables reward clipping.
+	NoClipReward bool
+
+	// EpisodicLife ends episodes when a life is lost, as
+	// in EpisodicLife.
+	// This is usually enabled for training, but not for
+	// evaluation.
+	EpisodicLife bool
+}
+
+// AtariPreprocessing applies the standard preprocessing
+// for Atari games from DQN and Rainbow, so that results
+// can be compared with published ones.
+//
+// The env should have no frame skipping of its own, e.g.
+// "PongNoFrameskip-v4", and should produce RGB
+// observations.
+// The wrappers are applied in the following order:
+//
+//   - NoopReset
+//   - MaxAndSkip
+//   - EpisodicLife, if enabled
+//   - Grayscale and Resize
+//   - ClipReward, to [-1, 1]
+//   - FrameStack
+//
+// The opts argument may be nil.
+func AtariPreprocessing(env gym.Env, opts *AtariOptions) gym.Env {
+	var o AtariOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.NoopMax == 0 {
+		o.NoopMax = 30
+	}
+	if o.FrameSkip == 0 {
+		o.FrameSkip = 4
+	}
+	if o.ScreenSize == 0 {
+		o.ScreenSize = AtariFrameSize
+	}
+	if o.FrameStack == 0 {
+		o.FrameStack = 4
+	}
+
+	if o.NoopMax > 0 {
+		env = NoopReset(env, o.NoopMax)
+	}
+	env = MaxAndSkip(env, o.FrameSkip)
+	if o.EpisodicLife {
+		env = EpisodicLife(env)
+	}
+	env = Resize(Grayscale(env), o.ScreenSize, o.ScreenSize)
+	if !o.NoClipReward {
+		env = ClipReward(env, -1, 1)
+	}
+	if o.FrameStack > 1 {
+		env = FrameStack(env, o.FrameStack)
+	}
+	return env
+}
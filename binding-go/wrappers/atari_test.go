@@ -0,0 +1,54 @@
+package wrappers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAtariPreprocessing(t *testing.T) {
+	inner := &testEnv{Shape: []int{210, 160, 3}, EpisodeLen: 1000}
+	env := AtariPreprocessing(inner, nil)
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shape, _, _ := uint8Frame(obs); !reflect.DeepEqual(shape, []int{84, 84, 4}) {
+		t.Errorf("unexpected shape: %v", shape)
+	}
+	space, err := env.ObservationSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(space.Shape, []int{84, 84, 4}) {
+		t.Errorf("unexpected space shape: %v", space.Shape)
+	}
+
+	before := len(inner.Actions)
+	if before < 1 || before > 30 {
+		t.Errorf("unexpected number of no-ops: %d", before)
+	}
+	_, reward, _, _, err := env.Step(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reward != 1 {
+		t.Errorf("expected clipped reward 1 but got %f", reward)
+	}
+	if len(inner.Actions)-before != 4 {
+		t.Errorf("expected 4 frames per step but got %d", len(inner.Actions)-before)
+	}
+
+	env = AtariPreprocessing(&testEnv{Shape: []int{210, 160, 3}, EpisodeLen: 1000},
+		&AtariOptions{NoopMax: -1, FrameSkip: 1, ScreenSize: 42, FrameStack: 1,
+			NoClipReward: true})
+	obs, err = env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shape, _, _ := uint8Frame(obs); !reflect.DeepEqual(shape, []int{42, 42, 1}) {
+		t.Errorf("unexpected shape: %v", shape)
+	}
+	if _, reward, _, _, _ := env.Step(3); reward != 3 {
+		t.Errorf("expected unclipped reward 3 but got %f", reward)
+	}
+}
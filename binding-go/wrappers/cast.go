@@ -0,0 +1,100 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"errors"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// ToFloat32 creates an Env which casts byte list
+// observations to Float32Obs in the range [0, 1].
+//
+// This is the last step of the typical image
+// preprocessing, since most models take float32 inputs.
+func ToFloat32(env gym.Env) gym.Env {
+	return ToFloat32Range(env, 0, 1)
+}
+
+// ToFloat32Range is like ToFloat32, but it maps the byte
+// values 0 and 255 to low and high, such as -1 and 1.
+func ToFloat32Range(env gym.Env, low, high float32) gym.Env {
+	return &obsTransform{
+		Wrapper: gym.Wrapper{Env: env},
+		name:    "cast to float32",
+		Obs: func(obs gym.Obs) (gym.Obs, error) {
+			return CastFloat32(obs, low, high)
+		},
+		Space: func(space *gym.Space) (*gym.Space, error) {
+			if space.Type != "Box" {
+				return nil, errors.New("expected a Box space")
+			}
+			res := *space
+			res.Low = constFloats(len(space.Low), float64(low))
+			res.High = constFloats(len(space.High), float64(high))
+			return &res, nil
+		},
+	}
+}
+
+// CastFloat32 converts a byte list observation to a
+// Float32Obs, mapping the byte values 0 and 255 to low and
+// high.
+func CastFloat32(obs gym.Obs, low, high float32) (*Float32Obs, error) {
+	shape, values, ok := uint8Frame(obs)
+	if !ok {
+		return nil, errors.New("cast: expected a byte list observation")
+	}
+	scale := (float64(high) - float64(low)) / 255
+	res := make([]float32, len(values))
+	for i, x := range values {
+		res[i] = float32(float64(low) + float64(x)*scale)
+	}
+	return &Float32Obs{Dims: append([]int{}, shape...), Values: res}, nil
+}
+
+// Float32Obs is a tensor observation of float32 values,
+// as produced by ToFloat32.
+type Float32Obs struct {
+	// Dims is the shape of the tensor.
+	Dims []int
+
+	// Values holds the flattened, row-major tensor.
+	Values []float32
+}
+
+// Unmarshal produces a JSON-compatible multi-dimensional
+// array for the observation.
+func (f *Float32Obs) Unmarshal(dst interface{}) error {
+	data, err := json.Marshal(f.jsonObject())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func (f *Float32Obs) Shape() []int {
+	return f.Dims
+}
+
+func (f *Float32Obs) jsonObject() interface{} {
+	if len(f.Dims) <= 1 {
+		if f.Values == nil {
+			// Empty tensors are lists, not null.
+			return []float32{}
+		}
+		return f.Values
+	} else if f.Dims[0] == 0 {
+		return []interface{}{}
+	}
+	chunkSize := len(f.Values) / f.Dims[0]
+	var res []interface{}
+	for i := 0; i < f.Dims[0]; i++ {
+		chunk := &Float32Obs{
+			Dims:   f.Dims[1:],
+			Values: f.Values[i*chunkSize : (i+1)*chunkSize],
+		}
+		res = append(res, chunk.jsonObject())
+	}
+	return res
+}
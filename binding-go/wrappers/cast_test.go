@@ -0,0 +1,72 @@
+package wrappers
+
+import (
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+func TestCastFloat32(t *testing.T) {
+	obs, err := CastFloat32(gym.NewUint8Obs([]int{1, 3}, []uint8{0, 51, 255}), -1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Float32Obs{Dims: []int{1, 3}, Values: []float32{-1, -0.6, 1}}
+	if !reflect.DeepEqual(obs, expected) {
+		t.Errorf("expected %v but got %v", expected, obs)
+	}
+	var nested [][]float64
+	if err := obs.Unmarshal(&nested); err != nil {
+		t.Fatal(err)
+	} else if len(nested) != 1 || len(nested[0]) != 3 {
+		t.Errorf("unexpected unmarshaled value: %v", nested)
+	}
+	if _, err := CastFloat32(obs, 0, 1); err == nil {
+		t.Error("expected error for float observation")
+	}
+}
+
+func TestToFloat32(t *testing.T) {
+	env := ToFloat32(&testEnv{Shape: []int{2, 2, 1}, EpisodeLen: 10})
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	obs, _, _, _, err := env.Step(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := obs.(*Float32Obs)
+	if !ok {
+		t.Fatalf("unexpected observation type: %T", obs)
+	}
+	if !reflect.DeepEqual(f.Dims, []int{2, 2, 1}) || f.Values[0] != float32(1)/255 {
+		t.Errorf("unexpected observation: %v", f)
+	}
+	space, err := env.ObservationSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if space.High[0] != 1 || space.Low[0] != 0 || len(space.High) != 4 {
+		t.Errorf("unexpected space: %+v", space)
+	}
+
+	chw, err := HWCToCHW(f)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(chw.(*Float32Obs).Dims, []int{1, 2, 2}) {
+		t.Errorf("unexpected transposed observation: %v", chw)
+	}
+}
+
+func TestFloat32ObsEmpty(t *testing.T) {
+	for _, dims := range [][]int{{0}, {0, 3}, {2, 0}} {
+		var obj []interface{}
+		if err := (&Float32Obs{Dims: dims}).Unmarshal(&obj); err != nil {
+			t.Fatal(err)
+		}
+		if len(obj) != dims[0] {
+			t.Errorf("dims %v: unexpected result %v", dims, obj)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// SonicCombos are the button combinations used by the
+// Retro Contest baselines for the Sonic games.
+var SonicCombos = [][]string{
+	{"LEFT"}, {"RIGHT"}, {"LEFT", "DOWN"}, {"RIGHT", "DOWN"}, {"DOWN"},
+	{"DOWN", "B"}, {"B"},
+}
+
+type discretizer struct {
+	gym.Wrapper
+	combos [][]string
+
+	lock sync.Mutex
+
+	// buttons are the names of the buttons in the
+	// MultiBinary action space, or nil if they have not
+	// been fetched yet.
+	buttons []string
+
+	// actions holds the MultiBinary action for each combo.
+	actions [][]int
+}
+
+// Discretizer creates an Env with a Discrete action space,
+// whose actions press combinations of buttons in the
+// MultiBinary action space of a Retro game.
+// Action i presses every button in combos[i], so an empty
+// combo presses nothing.
+//
+// This mirrors the Discretizer wrapper of the Retro
+// Contest baselines, since most button combinations are
+// useless for any given game.
+//
+// The button names are read from the game's buttons
+// attribute the first time they are needed.
+func Discretizer(env gym.Env, combos [][]string) gym.Env {
+	return DiscretizerButtons(env, nil, combos)
+}
+
+// DiscretizerButtons is like Discretizer, but the button
+// names of the MultiBinary action space are given, so the
+// environment does not have to be a Retro game.
+func DiscretizerButtons(env gym.Env, buttons []string, combos [][]string) gym.Env {
+	if len(combos) == 0 {
+		panic("discretizer needs at least one combo")
+	}
+	return &discretizer{
+		Wrapper: gym.Wrapper{Env: env},
+		combos:  combos,
+		buttons: buttons,
+	}
+}
+
+func (d *discretizer) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	index, ok := actionIndex(action)
+	if !ok || index < 0 || index >= len(d.combos) {
+		return nil, 0, false, nil, fmt.Errorf("discretize action: invalid action %v", action)
+	}
+	actions, err := d.buttonActions()
+	if err != nil {
+		return nil, 0, false, nil, err
+	}
+	return d.Env.Step(actions[index])
+}
+
+func (d *discretizer) ActionSpace() (*gym.Space, error) {
+	return &gym.Space{Type: "Discrete", N: len(d.combos)}, nil
+}
+
+func (d *discretizer) SampleAction(dst interface{}) error {
+	data, err := json.Marshal(rand.Intn(len(d.combos)))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// buttonActions gets the MultiBinary action for each
+// combo, fetching the button names if necessary.
+func (d *discretizer) buttonActions() (actions [][]int, err error) {
+	defer essentials.AddCtxTo("discretize action", &err)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.actions != nil {
+		return d.actions, nil
+	}
+	if d.buttons == nil {
+		if err := d.Env.GetAttr("buttons", &d.buttons); err != nil {
+			return nil, err
+		}
+	}
+	indices := map[string]int{}
+	for i, button := range d.buttons {
+		if button != "" {
+			indices[button] = i
+		}
+	}
+	for _, combo := range d.combos {
+		action := make([]int, len(d.buttons))
+		for _, button := range combo {
+			index, ok := indices[button]
+			if !ok {
+				return nil, fmt.Errorf("unknown button: %s", button)
+			}
+			action[index] = 1
+		}
+		actions = append(actions, action)
+	}
+	d.actions = actions
+	return actions, nil
+}
+
+// actionIndex converts an integer action to an int.
+func actionIndex(action interface{}) (int, bool) {
+	switch action := action.(type) {
+	case int:
+		return action, true
+	case int64:
+		return int(action), true
+	case int32:
+		return int(action), true
+	case uint8:
+		return int(action), true
+	case uint32:
+		return int(action), true
+	case uint64:
+		return int(action), true
+	case float64:
+		if action == math.Floor(action) {
+			return int(action), true
+		}
+	}
+	return 0, false
+}
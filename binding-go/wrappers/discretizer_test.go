@@ -0,0 +1,54 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// buttonEnv is a testEnv with Retro-style button names.
+type buttonEnv struct {
+	testEnv
+}
+
+func (b *buttonEnv) GetAttr(name string, dst interface{}) error {
+	data, _ := json.Marshal([]interface{}{"B", nil, "DOWN", "LEFT", "RIGHT"})
+	return json.Unmarshal(data, dst)
+}
+
+func TestDiscretizer(t *testing.T) {
+	inner := &buttonEnv{testEnv{Shape: []int{1}, EpisodeLen: 100}}
+	env := Discretizer(inner, [][]string{{}, {"LEFT"}, {"DOWN", "B"}})
+	if space, err := env.ActionSpace(); err != nil {
+		t.Fatal(err)
+	} else if space.Type != "Discrete" || space.N != 3 {
+		t.Errorf("unexpected action space: %+v", space)
+	}
+	env.Reset()
+	for _, action := range []interface{}{2, 0, float64(1)} {
+		if _, _, _, _, err := env.Step(action); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []interface{}{[]int{1, 0, 1, 0, 0}, []int{0, 0, 0, 0, 0}, []int{0, 0, 0, 1, 0}}
+	if !reflect.DeepEqual(inner.Actions, expected) {
+		t.Errorf("unexpected actions: %v", inner.Actions)
+	}
+	if _, _, _, _, err := env.Step(3); err == nil {
+		t.Error("expected error for out-of-range action")
+	}
+
+	var sample int
+	for i := 0; i < 10; i++ {
+		if err := env.SampleAction(&sample); err != nil {
+			t.Fatal(err)
+		} else if sample < 0 || sample >= 3 {
+			t.Errorf("sample out of range: %d", sample)
+		}
+	}
+
+	env = DiscretizerButtons(inner, []string{"A", "B"}, [][]string{{"C"}})
+	if _, _, _, _, err := env.Step(0); err == nil {
+		t.Error("expected error for unknown button")
+	}
+}
@@ -0,0 +1,134 @@
+package wrappers
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// An Episode summarizes a finished episode.
+type Episode struct {
+	// Return is the sum of the episode's rewards.
+	Return float64
+
+	// Length is the number of steps in the episode.
+	Length int
+
+	// Duration is the time from the reset to the last
+	// step.
+	Duration time.Duration
+}
+
+// EpisodeSummary aggregates a list of episodes.
+type EpisodeSummary struct {
+	Episodes   int
+	MeanReturn float64
+	MinReturn  float64
+	MaxReturn  float64
+	MeanLength float64
+}
+
+// An EpisodeTracker is an Env which records statistics
+// about every episode.
+//
+// When an episode ends, its statistics are added to the
+// info map under the key "episode", as an object with the
+// keys "r" (return), "l" (length), and "t" (duration in
+// seconds), like the Monitor wrapper in Stable Baselines.
+// This only works when info is a map or nil, so it does
+// not apply to environments created with the RawInfo or
+// SkipInfo options.
+//
+// The statistics are based on the rewards that the
+// EpisodeTracker sees, so it should usually wrap the Env
+// before any reward clipping or normalization.
+type EpisodeTracker struct {
+	gym.Wrapper
+
+	lock     sync.Mutex
+	ret      float64
+	length   int
+	start    time.Time
+	episodes []Episode
+}
+
+// TrackEpisodes creates an EpisodeTracker for an Env.
+func TrackEpisodes(env gym.Env) *EpisodeTracker {
+	return &EpisodeTracker{Wrapper: gym.Wrapper{Env: env}}
+}
+
+func (e *EpisodeTracker) Reset() (gym.Obs, error) {
+	obs, err := e.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.ret = 0
+	e.length = 0
+	e.start = time.Now()
+	return obs, nil
+}
+
+func (e *EpisodeTracker) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = e.Env.Step(action)
+	if err != nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.ret += reward
+	e.length++
+	if !done {
+		return
+	}
+	episode := Episode{Return: e.ret, Length: e.length, Duration: time.Since(e.start)}
+	e.episodes = append(e.episodes, episode)
+	if info == nil {
+		info = map[string]interface{}{}
+	}
+	if infoMap, ok := info.(map[string]interface{}); ok {
+		infoMap["episode"] = map[string]interface{}{
+			"r": episode.Return,
+			"l": float64(episode.Length),
+			"t": episode.Duration.Seconds(),
+		}
+	}
+	return
+}
+
+// Episodes returns every finished episode, oldest first.
+func (e *EpisodeTracker) Episodes() []Episode {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]Episode{}, e.episodes...)
+}
+
+// Summary aggregates the last n finished episodes, or all
+// of them if n is 0.
+func (e *EpisodeTracker) Summary(n int) *EpisodeSummary {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	episodes := e.episodes
+	if n > 0 && n < len(episodes) {
+		episodes = episodes[len(episodes)-n:]
+	}
+	res := &EpisodeSummary{Episodes: len(episodes)}
+	if len(episodes) == 0 {
+		return res
+	}
+	res.MinReturn = math.Inf(1)
+	res.MaxReturn = math.Inf(-1)
+	for _, ep := range episodes {
+		res.MeanReturn += ep.Return
+		res.MeanLength += float64(ep.Length)
+		res.MinReturn = math.Min(res.MinReturn, ep.Return)
+		res.MaxReturn = math.Max(res.MaxReturn, ep.Return)
+	}
+	res.MeanReturn /= float64(len(episodes))
+	res.MeanLength /= float64(len(episodes))
+	return res
+}
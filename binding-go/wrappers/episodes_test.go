@@ -0,0 +1,43 @@
+package wrappers
+
+import "testing"
+
+func TestEpisodeTracker(t *testing.T) {
+	env := TrackEpisodes(&testEnv{Shape: []int{1}, EpisodeLen: 3})
+	for _, action := range []int{1, 2} {
+		if _, err := env.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		for {
+			_, _, done, info, err := env.Step(action)
+			if err != nil {
+				t.Fatal(err)
+			}
+			episode, ok := info.(map[string]interface{})["episode"]
+			if ok != done {
+				t.Fatalf("episode info present=%v but done=%v", ok, done)
+			}
+			if done {
+				epMap := episode.(map[string]interface{})
+				if epMap["r"] != float64(3*action) || epMap["l"] != 3.0 {
+					t.Errorf("unexpected episode info: %v", epMap)
+				}
+				break
+			}
+		}
+	}
+
+	episodes := env.Episodes()
+	if len(episodes) != 2 || episodes[0].Return != 3 || episodes[1].Return != 6 {
+		t.Errorf("unexpected episodes: %v", episodes)
+	}
+	summary := env.Summary(0)
+	expected := EpisodeSummary{Episodes: 2, MeanReturn: 4.5, MinReturn: 3, MaxReturn: 6,
+		MeanLength: 3}
+	if *summary != expected {
+		t.Errorf("expected %v but got %v", expected, *summary)
+	}
+	if summary := env.Summary(1); summary.Episodes != 1 || summary.MeanReturn != 6 {
+		t.Errorf("unexpected summary of last episode: %v", summary)
+	}
+}
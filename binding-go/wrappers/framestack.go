@@ -0,0 +1,218 @@
+package wrappers
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+type frameStack struct {
+	gym.Wrapper
+	k int
+
+	lock sync.Mutex
+
+	// frames holds the last k observations, oldest first.
+	frames []gym.Obs
+
+	// boxShape is the shape of the observation space if it
+	// is a Box, or nil otherwise.
+	boxShape []int
+}
+
+// FrameStack creates an Env whose observations contain the
+// last k observations of env.
+// After a reset, the first observation is repeated k
+// times.
+//
+// Byte list observations are concatenated along their
+// last dimension, in the order they were observed, so a
+// stack of four 84x84x1 frames is 84x84x4.
+// 2-dimensional frames get a new last dimension, so a
+// stack of four 84x84 frames is also 84x84x4.
+//
+// Other observations from Box spaces, such as CartPole's,
+// are concatenated in the same way, producing a FloatObs.
+// Observations from other spaces are combined into a list
+// of k observations.
+func FrameStack(env gym.Env, k int) gym.Env {
+	if k < 1 {
+		panic("frame stack size must be positive")
+	}
+	return &frameStack{Wrapper: gym.Wrapper{Env: env}, k: k}
+}
+
+func (f *frameStack) Reset() (obs gym.Obs, err error) {
+	obs, err = f.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	// Without a space, frames are combined into a list,
+	// and ObservationSpace fails anyway.
+	var boxShape []int
+	if space, err := f.Env.ObservationSpace(); err == nil && space.Type == "Box" {
+		boxShape = space.Shape
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	frame := copyFrame(obs)
+	f.frames = f.frames[:0]
+	for i := 0; i < f.k; i++ {
+		f.frames = append(f.frames, frame)
+	}
+	f.boxShape = boxShape
+	return stackFrames(f.frames, f.boxShape)
+}
+
+func (f *frameStack) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = f.Env.Step(action)
+	if err != nil {
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.frames) == 0 {
+		err = errors.New("frame stack: step before reset")
+		return
+	}
+	copy(f.frames, f.frames[1:])
+	f.frames[len(f.frames)-1] = copyFrame(obs)
+	obs, err = stackFrames(f.frames, f.boxShape)
+	return
+}
+
+func (f *frameStack) ObservationSpace() (space *gym.Space, err error) {
+	defer essentials.AddCtxTo("frame stack", &err)
+	space, err = f.Env.ObservationSpace()
+	if err != nil {
+		return nil, err
+	}
+	if space.Type != "Box" {
+		res := &gym.Space{Type: "Tuple"}
+		for i := 0; i < f.k; i++ {
+			res.Subspaces = append(res.Subspaces, space)
+		}
+		return res, nil
+	}
+	res := *space
+	res.Shape = stackShape(space.Shape, f.k)
+	res.Low = stackFloats(space.Low, space.Shape, f.k)
+	res.High = stackFloats(space.High, space.Shape, f.k)
+	return &res, nil
+}
+
+// stackFrames combines a list of observations.
+//
+// The boxShape is the shape of each observation if they
+// come from a Box space, or nil otherwise.
+func stackFrames(frames []gym.Obs, boxShape []int) (obs gym.Obs, err error) {
+	defer essentials.AddCtxTo("frame stack", &err)
+	shape, _, ok := uint8Frame(frames[0])
+	if !ok && len(boxShape) > 0 {
+		return stackTensors(frames, boxShape)
+	} else if !ok {
+		var objs []interface{}
+		for _, frame := range frames {
+			var obj interface{}
+			if err := frame.Unmarshal(&obj); err != nil {
+				return nil, err
+			}
+			objs = append(objs, obj)
+		}
+		return gym.NewJSONObs(objs)
+	}
+
+	inner := stackInner(shape)
+	var size int
+	for _, frame := range frames {
+		_, values, ok := uint8Frame(frame)
+		if !ok || (size != 0 && len(values) != size) {
+			return nil, errors.New("mismatching frames")
+		}
+		size = len(values)
+	}
+	res := make([]uint8, size*len(frames))
+	for i, frame := range frames {
+		_, values, _ := uint8Frame(frame)
+		for p := 0; p*inner < size; p++ {
+			dst := (p*len(frames) + i) * inner
+			copy(res[dst:dst+inner], values[p*inner:(p+1)*inner])
+		}
+	}
+	return gym.NewUint8Obs(stackShape(shape, len(frames)), res), nil
+}
+
+// stackTensors concatenates tensor observations of the
+// given shape in the same way as byte lists.
+func stackTensors(frames []gym.Obs, shape []int) (gym.Obs, error) {
+	size := 1
+	for _, x := range shape {
+		size *= x
+	}
+	var values [][]float64
+	for _, frame := range frames {
+		frameValues, err := gym.Flatten(frame)
+		if err != nil {
+			return nil, err
+		}
+		if len(frameValues) != size {
+			return nil, errors.New("mismatching frames")
+		}
+		values = append(values, frameValues)
+	}
+	return &FloatObs{
+		Dims:   stackShape(shape, len(frames)),
+		Values: interleave(values, shape),
+	}, nil
+}
+
+// stackFloats stacks k copies of a flattened tensor in the
+// same way as stackFrames.
+func stackFloats(values []float64, shape []int, k int) []float64 {
+	if len(values) == 0 {
+		return values
+	}
+	copies := make([][]float64, k)
+	for i := range copies {
+		copies[i] = values
+	}
+	return interleave(copies, shape)
+}
+
+// interleave concatenates flattened tensors of the given
+// shape along the dimension that stackShape extends.
+func interleave(tensors [][]float64, shape []int) []float64 {
+	inner := stackInner(shape)
+	size := len(tensors[0])
+	res := make([]float64, 0, size*len(tensors))
+	for p := 0; p*inner < size; p++ {
+		for _, tensor := range tensors {
+			res = append(res, tensor[p*inner:(p+1)*inner]...)
+		}
+	}
+	return res
+}
+
+// stackShape computes the shape of k stacked frames.
+func stackShape(shape []int, k int) []int {
+	if len(shape) == 0 {
+		return []int{k}
+	} else if len(shape) == 2 {
+		return []int{shape[0], shape[1], k}
+	}
+	res := append([]int{}, shape...)
+	res[len(res)-1] *= k
+	return res
+}
+
+// stackInner computes the number of consecutive values
+// which are copied from each frame at a time.
+func stackInner(shape []int) int {
+	if len(shape) == 0 || len(shape) == 2 {
+		return 1
+	}
+	return shape[len(shape)-1]
+}
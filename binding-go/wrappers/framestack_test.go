@@ -0,0 +1,105 @@
+package wrappers
+
+import (
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+func TestFrameStack(t *testing.T) {
+	env := FrameStack(&testEnv{Shape: []int{2, 1, 2}, EpisodeLen: 10}, 3)
+
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStack(t, obs, []int{2, 1, 6}, []uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+
+	env.Step(0)
+	obs, _, _, _, err = env.Step(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStack(t, obs, []int{2, 1, 6}, []uint8{0, 0, 1, 1, 2, 2, 0, 0, 1, 1, 2, 2})
+
+	space, err := env.ObservationSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(space.Shape, []int{2, 1, 6}) || len(space.High) != 12 {
+		t.Errorf("unexpected space: %+v", space)
+	}
+}
+
+func TestFrameStack2D(t *testing.T) {
+	env := FrameStack(&testEnv{Shape: []int{1, 2}, EpisodeLen: 10}, 2)
+	env.Reset()
+	obs, _, _, _, err := env.Step(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStack(t, obs, []int{1, 2, 2}, []uint8{0, 1, 0, 1})
+}
+
+func TestFrameStackJSON(t *testing.T) {
+	env := FrameStack(&jsonEnv{&testEnv{Shape: []int{4}, EpisodeLen: 10}}, 3)
+	env.Reset()
+	env.Step(0)
+	obs, _, _, _, err := env.Step(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	space, err := env.ObservationSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := obs.(gym.ShapedObs).Shape(); !reflect.DeepEqual(actual, space.Shape) {
+		t.Errorf("observation shape %v does not match space shape %v", actual, space.Shape)
+	}
+	var values []float64
+	if err := obs.Unmarshal(&values); err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected values %v but got %v", expected, values)
+	}
+}
+
+// jsonEnv sends the observations of a testEnv as JSON,
+// like CartPole does.
+type jsonEnv struct {
+	*testEnv
+}
+
+func (j *jsonEnv) Reset() (gym.Obs, error) {
+	obs, _ := j.testEnv.Reset()
+	return jsonObs(obs)
+}
+
+func (j *jsonEnv) Step(action interface{}) (gym.Obs, float64, bool, interface{}, error) {
+	obs, reward, done, info, _ := j.testEnv.Step(action)
+	jsonObs, err := jsonObs(obs)
+	return jsonObs, reward, done, info, err
+}
+
+func jsonObs(obs gym.Obs) (gym.Obs, error) {
+	values, err := gym.Flatten(obs)
+	if err != nil {
+		return nil, err
+	}
+	return gym.NewJSONObs(values)
+}
+
+func checkStack(t *testing.T, obs gym.Obs, shape []int, values []uint8) {
+	t.Helper()
+	actualShape := obs.(gym.ShapedObs).Shape()
+	actual := obs.(gym.Uint8Obs).Uint8Obs()
+	if !reflect.DeepEqual(actualShape, shape) {
+		t.Errorf("expected shape %v but got %v", shape, actualShape)
+	}
+	if !reflect.DeepEqual(actual, values) {
+		t.Errorf("expected values %v but got %v", values, actual)
+	}
+}
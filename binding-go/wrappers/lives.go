@@ -0,0 +1,117 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+type episodicLife struct {
+	gym.Wrapper
+
+	lock     sync.Mutex
+	lives    int
+	gameOver bool
+	noop     interface{}
+}
+
+// EpisodicLife creates an Env which ends an episode
+// whenever a life is lost, as in most Atari baselines.
+// This makes it easier for an agent to learn that losing
+// a life is bad.
+//
+// The environment is only truly reset once the game is
+// over.
+// After a life is lost, Reset takes a no-op action to
+// continue the game instead.
+//
+// The number of lives is read from the "ale.lives" or
+// "lives" key of the info, which Gym's Atari environments
+// provide, so env must not use the SkipInfo option.
+//
+// To track the scores of whole games, EpisodicLife should
+// wrap an EpisodeTracker rather than the other way around.
+func EpisodicLife(env gym.Env) gym.Env {
+	return &episodicLife{Wrapper: gym.Wrapper{Env: env}, gameOver: true}
+}
+
+func (e *episodicLife) Reset() (obs gym.Obs, err error) {
+	defer essentials.AddCtxTo("episodic life", &err)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if !e.gameOver {
+		if e.noop == nil {
+			space, err := e.Env.ActionSpace()
+			if err != nil {
+				return nil, err
+			}
+			e.noop, err = zeroAction(space)
+			if err != nil {
+				return nil, err
+			}
+		}
+		obs, _, done, info, err := e.Env.Step(e.noop)
+		if err != nil {
+			return nil, err
+		}
+		if !done {
+			e.lives, err = infoLives(info)
+			return obs, err
+		}
+	}
+	obs, err = e.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	e.gameOver = false
+	e.lives = -1
+	return obs, nil
+}
+
+func (e *episodicLife) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = e.Env.Step(action)
+	if err != nil {
+		return
+	}
+	lives, err := infoLives(info)
+	if err != nil {
+		err = essentials.AddCtx("episodic life", err)
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.gameOver = done
+	if lives < e.lives && lives > 0 {
+		done = true
+	}
+	e.lives = lives
+	return
+}
+
+// infoLives gets the number of lives from an Atari info
+// object.
+func infoLives(info interface{}) (int, error) {
+	if raw, ok := info.(json.RawMessage); ok {
+		var obj interface{}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return 0, err
+		}
+		info = obj
+	}
+	if infoMap, ok := info.(map[string]interface{}); ok {
+		for _, key := range []string{"ale.lives", "lives"} {
+			switch lives := infoMap[key].(type) {
+			case float64:
+				return int(lives), nil
+			case json.Number:
+				n, err := lives.Int64()
+				return int(n), err
+			}
+		}
+	}
+	return 0, errors.New("no lives in info")
+}
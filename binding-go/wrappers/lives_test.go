@@ -0,0 +1,67 @@
+package wrappers
+
+import (
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// livesEnv is a fake Atari game with three lives, which
+// loses a life every two steps.
+type livesEnv struct {
+	testEnv
+}
+
+func (l *livesEnv) Step(action interface{}) (gym.Obs, float64, bool, interface{}, error) {
+	obs, reward, _, info, err := l.testEnv.Step(action)
+	lives := 3 - l.t/2
+	info.(map[string]interface{})["ale.lives"] = float64(lives)
+	return obs, reward, lives == 0, info, err
+}
+
+func TestEpisodicLife(t *testing.T) {
+	inner := &livesEnv{testEnv{Shape: []int{1}}}
+	env := EpisodicLife(inner)
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	var episodeLens []int
+	for len(episodeLens) < 4 {
+		var steps int
+		for {
+			_, _, done, _, err := env.Step(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			steps++
+			if done {
+				break
+			}
+		}
+		episodeLens = append(episodeLens, steps)
+		if _, err := env.Reset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// After the first life is lost, each pseudo-reset takes
+	// a step of its own.
+	expected := []int{2, 1, 1, 2}
+	for i, x := range expected {
+		if episodeLens[i] != x {
+			t.Fatalf("expected episode lengths %v but got %v", expected, episodeLens)
+		}
+	}
+	if inner.Resets != 2 {
+		t.Errorf("expected 2 real resets but got %d", inner.Resets)
+	}
+	for i, action := range inner.Actions {
+		if i == 2 || i == 4 || i == 8 {
+			if action != 0 {
+				t.Errorf("step %d: expected no-op but got %v", i, action)
+			}
+		} else if action != 1 {
+			t.Errorf("step %d: unexpected action %v", i, action)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package wrappers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+type maxPool struct {
+	gym.Wrapper
+
+	lock sync.Mutex
+	last gym.Obs
+}
+
+// MaxPool creates an Env whose observations are the
+// element-wise maximum of the current and previous frames.
+// The first observation after a reset is returned as is.
+//
+// This removes the flicker of Atari games which draw
+// objects on alternating frames, for environments which
+// do not need action repeats, or which repeat actions on
+// the server.
+// To repeat actions on the client, use MaxAndSkip instead.
+// Observations must be byte lists.
+func MaxPool(env gym.Env) gym.Env {
+	return &maxPool{Wrapper: gym.Wrapper{Env: env}}
+}
+
+func (m *maxPool) Reset() (obs gym.Obs, err error) {
+	obs, err = m.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.last = copyFrame(obs)
+	return obs, nil
+}
+
+func (m *maxPool) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = m.Env.Step(action)
+	if err != nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.last == nil {
+		err = errors.New("max pool: step before reset")
+		return
+	}
+	last := m.last
+	m.last = copyFrame(obs)
+	obs, err = MaxFrames(last, obs)
+	return
+}
+
+// MaxFrames computes the element-wise maximum of two byte
+// list observations with the same shape.
+//
+// The result is a new observation, so it is safe to use
+// with observations from an Env with the ReuseObs option.
+func MaxFrames(obs1, obs2 gym.Obs) (gym.Obs, error) {
+	shape, values1, ok1 := uint8Frame(obs1)
+	shape2, values2, ok2 := uint8Frame(obs2)
+	if !ok1 || !ok2 || len(values1) != len(values2) || !reflect.DeepEqual(shape, shape2) {
+		return nil, errors.New("max pool: expected matching byte list observations")
+	}
+	res := make([]uint8, len(values1))
+	maxBytes(res, values1, values2)
+	return gym.NewUint8Obs(append([]int{}, shape...), res), nil
+}
+
+// MaxStackedFrames computes the element-wise maximum of
+// the last two frames of a FrameStack observation, whose
+// frames each have the given number of channels.
+//
+// For example, for a stack of 84x84x1 frames, the result
+// is the 84x84x1 maximum of the two newest frames.
+// Use 1 for a stack of 2-dimensional frames.
+func MaxStackedFrames(obs gym.Obs, channels int) (gym.Obs, error) {
+	shape, values, ok := uint8Frame(obs)
+	if !ok || len(shape) == 0 {
+		return nil, errors.New("max pool: expected byte list observation")
+	}
+	depth := shape[len(shape)-1]
+	if channels < 1 || depth%channels != 0 || depth/channels < 2 {
+		return nil, fmt.Errorf("max pool: last dimension %d is not a stack of two or "+
+			"more frames with %d channels", depth, channels)
+	}
+	pixels := len(values) / depth
+	res := make([]uint8, pixels*channels)
+	for i := 0; i < pixels; i++ {
+		stack := values[i*depth : (i+1)*depth]
+		maxBytes(res[i*channels:(i+1)*channels], stack[depth-2*channels:depth-channels],
+			stack[depth-channels:])
+	}
+	newShape := append(append([]int{}, shape[:len(shape)-1]...), channels)
+	return gym.NewUint8Obs(newShape, res), nil
+}
+
+// maxBytes stores the element-wise maximum of a and b in
+// dst.
+func maxBytes(dst, a, b []uint8) {
+	for i, x := range a {
+		if y := b[i]; y > x {
+			x = y
+		}
+		dst[i] = x
+	}
+}
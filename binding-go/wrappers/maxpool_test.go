@@ -0,0 +1,84 @@
+package wrappers
+
+import (
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// flickerEnv is a fake environment whose single pixel is
+// lit on every other step.
+type flickerEnv struct {
+	testEnv
+}
+
+func (f *flickerEnv) Reset() (gym.Obs, error) {
+	f.testEnv.Reset()
+	return gym.NewUint8Obs([]int{1}, []uint8{0}), nil
+}
+
+func (f *flickerEnv) Step(action interface{}) (gym.Obs, float64, bool, interface{}, error) {
+	_, reward, done, info, err := f.testEnv.Step(action)
+	return gym.NewUint8Obs([]int{1}, []uint8{uint8(255 * (f.t % 2))}), reward, done, info, err
+}
+
+func TestMaxPool(t *testing.T) {
+	env := MaxPool(&flickerEnv{testEnv{EpisodeLen: 10}})
+	if _, _, _, _, err := env.Step(0); err == nil {
+		t.Error("expected error before reset")
+	}
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, values, _ := uint8Frame(obs); values[0] != 0 {
+		t.Errorf("unexpected reset observation: %v", values)
+	}
+	for i := 0; i < 4; i++ {
+		obs, _, _, _, err := env.Step(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, values, _ := uint8Frame(obs); values[0] != 255 {
+			t.Errorf("step %d: unexpected observation: %v", i, values)
+		}
+	}
+}
+
+func TestMaxFrames(t *testing.T) {
+	obs, err := MaxFrames(gym.NewUint8Obs([]int{3}, []uint8{1, 5, 3}),
+		gym.NewUint8Obs([]int{3}, []uint8{4, 2, 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, values, _ := uint8Frame(obs); !reflect.DeepEqual(values, []uint8{4, 5, 3}) {
+		t.Errorf("unexpected max pool: %v", values)
+	}
+	_, err = MaxFrames(gym.NewUint8Obs([]int{1, 2}, []uint8{1, 2}),
+		gym.NewUint8Obs([]int{2, 1}, []uint8{1, 2}))
+	if err == nil {
+		t.Error("expected error for mismatched shapes")
+	}
+}
+
+func TestMaxStackedFrames(t *testing.T) {
+	// A 1x2 image with three stacked frames of two
+	// channels each.
+	stacked := gym.NewUint8Obs([]int{1, 2, 6}, []uint8{
+		9, 9, 1, 7, 3, 2,
+		9, 9, 8, 0, 5, 6,
+	})
+	obs, err := MaxStackedFrames(stacked, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shape, values, _ := uint8Frame(obs)
+	if !reflect.DeepEqual(shape, []int{1, 2, 2}) ||
+		!reflect.DeepEqual(values, []uint8{3, 7, 8, 6}) {
+		t.Errorf("unexpected result: shape %v values %v", shape, values)
+	}
+	if _, err := MaxStackedFrames(stacked, 4); err == nil {
+		t.Error("expected error for bad channel count")
+	}
+}
@@ -0,0 +1,113 @@
+package wrappers
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+type noopReset struct {
+	gym.Wrapper
+	maxNoops int
+
+	lock sync.Mutex
+	rng  *rand.Rand
+
+	// noop is the no-op action, found from the action
+	// space on the first reset.
+	noop interface{}
+}
+
+// NoopReset creates an Env which takes a random number of
+// no-op actions, between 1 and maxNoops, after every
+// reset.
+// This is the standard evaluation protocol for DQN, since
+// it makes Atari games start in different states.
+//
+// The no-op action is the zero action of the action space,
+// which is NOOP for Atari games.
+// The action space is only fetched once.
+func NoopReset(env gym.Env, maxNoops int) gym.Env {
+	return NoopResetSeed(env, maxNoops, time.Now().UnixNano())
+}
+
+// NoopResetSeed is like NoopReset, but the random choices
+// are determined by a seed, making runs reproducible.
+func NoopResetSeed(env gym.Env, maxNoops int, seed int64) gym.Env {
+	if maxNoops < 1 {
+		panic("maximum no-op count must be positive")
+	}
+	return &noopReset{
+		Wrapper:  gym.Wrapper{Env: env},
+		maxNoops: maxNoops,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (n *noopReset) Reset() (obs gym.Obs, err error) {
+	defer essentials.AddCtxTo("noop reset", &err)
+	noop, err := n.noopAction()
+	if err != nil {
+		return nil, err
+	}
+	n.lock.Lock()
+	numNoops := n.rng.Intn(n.maxNoops) + 1
+	n.lock.Unlock()
+
+	obs, err = n.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < numNoops; i++ {
+		var done bool
+		obs, _, done, _, err = n.Env.Step(noop)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			obs, err = n.Env.Reset()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return obs, nil
+}
+
+func (n *noopReset) noopAction() (interface{}, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.noop != nil {
+		return n.noop, nil
+	}
+	space, err := n.Env.ActionSpace()
+	if err != nil {
+		return nil, err
+	}
+	n.noop, err = zeroAction(space)
+	if err != nil {
+		return nil, err
+	}
+	return n.noop, nil
+}
+
+// zeroAction gets the action of all zeros in an action
+// space, which is usually a no-op.
+func zeroAction(space *gym.Space) (interface{}, error) {
+	switch space.Type {
+	case "Discrete":
+		return 0, nil
+	case "MultiBinary":
+		return make([]int, space.N), nil
+	case "MultiDiscrete":
+		return make([]int, len(space.Low)), nil
+	case "Box":
+		return make([]float64, len(space.Low)), nil
+	default:
+		return nil, errors.New("no zero action for space type: " + space.Type)
+	}
+}
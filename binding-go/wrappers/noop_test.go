@@ -0,0 +1,34 @@
+package wrappers
+
+import "testing"
+
+func TestNoopReset(t *testing.T) {
+	inner := &testEnv{Shape: []int{1}, EpisodeLen: 100}
+	env := NoopResetSeed(inner, 5, 1337)
+	counts := map[int]int{}
+	for i := 0; i < 100; i++ {
+		before := len(inner.Actions)
+		obs, err := env.Reset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		numNoops := len(inner.Actions) - before
+		counts[numNoops]++
+		if _, values, _ := uint8Frame(obs); int(values[0]) != numNoops {
+			t.Errorf("observation %d does not follow %d no-ops", values[0], numNoops)
+		}
+		for _, action := range inner.Actions[before:] {
+			if action != 0 {
+				t.Errorf("unexpected no-op action: %v", action)
+			}
+		}
+	}
+	for i := 1; i <= 5; i++ {
+		if counts[i] == 0 {
+			t.Errorf("never took %d no-ops", i)
+		}
+	}
+	if len(counts) != 5 {
+		t.Errorf("unexpected no-op counts: %v", counts)
+	}
+}
@@ -0,0 +1,342 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// NormalizeConfig configures a Normalizer.
+//
+// The zero value disables normalization, so at least one
+// of Obs and Reward should be set.
+type NormalizeConfig struct {
+	// Obs enables observation normalization.
+	Obs bool
+
+	// Reward enables reward normalization, which scales
+	// rewards by the standard deviation of the discounted
+	// return.
+	Reward bool
+
+	// ClipObs and ClipReward bound the normalized values.
+	// If 0, they default to 10.
+	ClipObs    float64
+	ClipReward float64
+
+	// Gamma is the discount factor for the returns.
+	// If 0, it defaults to 0.99.
+	Gamma float64
+
+	// Epsilon is added to variances to avoid dividing by
+	// zero.
+	// If 0, it defaults to 1e-8.
+	Epsilon float64
+}
+
+// RunningStats tracks the mean and variance of a stream of
+// vectors.
+type RunningStats struct {
+	Mean  []float64 `json:"mean"`
+	Var   []float64 `json:"var"`
+	Count float64   `json:"count"`
+}
+
+// NewRunningStats creates statistics for vectors of the
+// given size.
+//
+// The statistics start with a tiny count, so the first
+// update mostly replaces the initial mean of 0 and
+// variance of 1.
+func NewRunningStats(size int) *RunningStats {
+	return &RunningStats{
+		Mean:  make([]float64, size),
+		Var:   constFloats(size, 1),
+		Count: 1e-4,
+	}
+}
+
+// Update adds a vector to the statistics.
+func (r *RunningStats) Update(x []float64) {
+	count := r.Count + 1
+	for i, value := range x {
+		delta := value - r.Mean[i]
+		r.Mean[i] += delta / count
+		r.Var[i] = (r.Var[i]*r.Count + delta*delta*r.Count/count) / count
+	}
+	r.Count = count
+}
+
+// A Normalizer is an Env which normalizes observations and
+// rewards with running statistics, like VecNormalize in
+// Stable Baselines.
+//
+// Normalized observations are FloatObs with the shape of
+// the original observations.
+// The observation space is a Box bounded by ClipObs.
+//
+// The statistics should usually be saved along with a
+// trained agent, since the agent will not work without
+// them.
+type Normalizer struct {
+	gym.Wrapper
+
+	config NormalizeConfig
+
+	lock     sync.Mutex
+	training bool
+	obsStats *RunningStats
+	retStats *RunningStats
+	ret      float64
+}
+
+// Normalize creates a Normalizer for an Env.
+//
+// The Normalizer starts in training mode.
+func Normalize(env gym.Env, config *NormalizeConfig) *Normalizer {
+	res := &Normalizer{
+		Wrapper:  gym.Wrapper{Env: env},
+		config:   *config,
+		training: true,
+		retStats: NewRunningStats(1),
+	}
+	if res.config.ClipObs == 0 {
+		res.config.ClipObs = 10
+	}
+	if res.config.ClipReward == 0 {
+		res.config.ClipReward = 10
+	}
+	if res.config.Gamma == 0 {
+		res.config.Gamma = 0.99
+	}
+	if res.config.Epsilon == 0 {
+		res.config.Epsilon = 1e-8
+	}
+	return res
+}
+
+// NormalizeReward creates a Normalizer which only scales
+// rewards, dividing them by a running estimate of the
+// standard deviation of the discounted return, as is
+// standard for PPO.
+// If gamma is 0, it defaults to 0.99.
+//
+// Like any Normalizer, the statistics can be saved with
+// Save and restored with Load, so that rewards are scaled
+// the same way when evaluating an agent.
+func NormalizeReward(env gym.Env, gamma float64) *Normalizer {
+	return Normalize(env, &NormalizeConfig{Reward: true, Gamma: gamma})
+}
+
+// SetTraining determines if the statistics are updated.
+//
+// Training should be disabled when evaluating an agent,
+// so that the statistics stay the same as they were
+// during training.
+func (n *Normalizer) SetTraining(training bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.training = training
+}
+
+func (n *Normalizer) Reset() (obs gym.Obs, err error) {
+	obs, err = n.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.ret = 0
+	return n.normalizeObs(obs)
+}
+
+func (n *Normalizer) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = n.Env.Step(action)
+	if err != nil {
+		return
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	reward = n.normalizeReward(reward, done)
+	obs, err = n.normalizeObs(obs)
+	return
+}
+
+func (n *Normalizer) ObservationSpace() (space *gym.Space, err error) {
+	space, err = n.Env.ObservationSpace()
+	if err != nil || !n.config.Obs {
+		return
+	}
+	size := len(space.Low)
+	if space.Type != "Box" || size == 0 {
+		return nil, errors.New("normalize: expected a Box space")
+	}
+	return &gym.Space{
+		Type:  "Box",
+		Shape: space.Shape,
+		Low:   constFloats(size, -n.config.ClipObs),
+		High:  constFloats(size, n.config.ClipObs),
+	}, nil
+}
+
+// Stats gets a copy of the observation and return
+// statistics.
+//
+// The observation statistics are nil until the first
+// observation.
+func (n *Normalizer) Stats() (obsStats, retStats *RunningStats) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.obsStats.copy(), n.retStats.copy()
+}
+
+// Save encodes the statistics as JSON.
+func (n *Normalizer) Save(w io.Writer) (err error) {
+	defer essentials.AddCtxTo("save normalizer", &err)
+	obsStats, retStats := n.Stats()
+	return json.NewEncoder(w).Encode(&normalizerState{Obs: obsStats, Return: retStats})
+}
+
+// Load decodes statistics which were saved by Save.
+func (n *Normalizer) Load(r io.Reader) (err error) {
+	defer essentials.AddCtxTo("load normalizer", &err)
+	var state normalizerState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return err
+	}
+	if state.Return == nil || len(state.Return.Mean) != 1 {
+		return errors.New("missing return statistics")
+	}
+	for _, stats := range []*RunningStats{state.Obs, state.Return} {
+		if stats != nil && len(stats.Mean) != len(stats.Var) {
+			return errors.New("mismatching mean and variance")
+		}
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.obsStats = state.Obs
+	n.retStats = state.Return
+	return nil
+}
+
+// normalizeObs normalizes an observation, updating the
+// statistics if necessary.
+//
+// The caller must hold n.lock.
+func (n *Normalizer) normalizeObs(obs gym.Obs) (res gym.Obs, err error) {
+	if !n.config.Obs {
+		return obs, nil
+	}
+	defer essentials.AddCtxTo("normalize", &err)
+	values, err := gym.Flatten(obs)
+	if err != nil {
+		return nil, err
+	}
+	shape := []int{len(values)}
+	if shaped, ok := obs.(gym.ShapedObs); ok {
+		shape = append([]int{}, shaped.Shape()...)
+	}
+	if n.obsStats == nil {
+		n.obsStats = NewRunningStats(len(values))
+	} else if len(n.obsStats.Mean) != len(values) {
+		return nil, errors.New("observation size changed")
+	}
+	if n.training {
+		n.obsStats.Update(values)
+	}
+	for i, x := range values {
+		x = (x - n.obsStats.Mean[i]) / math.Sqrt(n.obsStats.Var[i]+n.config.Epsilon)
+		values[i] = clip(x, n.config.ClipObs)
+	}
+	return &FloatObs{Dims: shape, Values: values}, nil
+}
+
+// normalizeReward scales a reward, updating the return
+// statistics if necessary.
+//
+// The caller must hold n.lock.
+func (n *Normalizer) normalizeReward(reward float64, done bool) float64 {
+	if !n.config.Reward {
+		return reward
+	}
+	n.ret = n.ret*n.config.Gamma + reward
+	if n.training {
+		n.retStats.Update([]float64{n.ret})
+	}
+	if done {
+		n.ret = 0
+	}
+	return clip(reward/math.Sqrt(n.retStats.Var[0]+n.config.Epsilon), n.config.ClipReward)
+}
+
+type normalizerState struct {
+	Obs    *RunningStats `json:"obs"`
+	Return *RunningStats `json:"return"`
+}
+
+func (r *RunningStats) copy() *RunningStats {
+	if r == nil {
+		return nil
+	}
+	return &RunningStats{
+		Mean:  append([]float64{}, r.Mean...),
+		Var:   append([]float64{}, r.Var...),
+		Count: r.Count,
+	}
+}
+
+func clip(x, limit float64) float64 {
+	return math.Max(-limit, math.Min(limit, x))
+}
+
+// FloatObs is a tensor observation of floating-point
+// values, as produced by Normalizer.
+type FloatObs struct {
+	// Dims is the shape of the tensor.
+	Dims []int
+
+	// Values holds the flattened, row-major tensor.
+	Values []float64
+}
+
+// Unmarshal produces a JSON-compatible multi-dimensional
+// array for the observation.
+func (f *FloatObs) Unmarshal(dst interface{}) error {
+	data, err := json.Marshal(f.jsonObject())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func (f *FloatObs) Shape() []int {
+	return f.Dims
+}
+
+func (f *FloatObs) jsonObject() interface{} {
+	if len(f.Dims) <= 1 {
+		if f.Values == nil {
+			// Empty tensors are lists, not null.
+			return []float64{}
+		}
+		return f.Values
+	} else if f.Dims[0] == 0 {
+		return []interface{}{}
+	}
+	chunkSize := len(f.Values) / f.Dims[0]
+	var res []interface{}
+	for i := 0; i < f.Dims[0]; i++ {
+		chunk := &FloatObs{
+			Dims:   f.Dims[1:],
+			Values: f.Values[i*chunkSize : (i+1)*chunkSize],
+		}
+		res = append(res, chunk.jsonObject())
+	}
+	return res
+}
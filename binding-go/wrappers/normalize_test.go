@@ -0,0 +1,140 @@
+package wrappers
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestRunningStats(t *testing.T) {
+	stats := NewRunningStats(2)
+	data := [][]float64{{1, 10}, {2, 10}, {3, 10}, {6, 10}}
+	for _, x := range data {
+		stats.Update(x)
+	}
+	expectedMean := []float64{3, 10}
+	expectedVar := []float64{3.5, 0}
+	for i := range expectedMean {
+		if math.Abs(stats.Mean[i]-expectedMean[i]) > 1e-2 {
+			t.Errorf("mean %d: expected %f but got %f", i, expectedMean[i], stats.Mean[i])
+		}
+		if math.Abs(stats.Var[i]-expectedVar[i]) > 1e-2 {
+			t.Errorf("var %d: expected %f but got %f", i, expectedVar[i], stats.Var[i])
+		}
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	env := Normalize(&testEnv{Shape: []int{2, 2}, EpisodeLen: 3}, &NormalizeConfig{
+		Obs:    true,
+		Reward: true,
+	})
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		obs, reward, done, _, err := env.Step(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		floatObs := obs.(*FloatObs)
+		if len(floatObs.Values) != 4 || len(floatObs.Shape()) != 2 {
+			t.Fatalf("unexpected observation: %v", floatObs)
+		}
+		if reward <= 0 || reward > 10 {
+			t.Errorf("unexpected reward: %f", reward)
+		}
+		if done {
+			if _, err := env.Reset(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var nested [][]float64
+	obs, _ := env.Reset()
+	if err := obs.Unmarshal(&nested); err != nil {
+		t.Fatal(err)
+	} else if len(nested) != 2 || len(nested[0]) != 2 {
+		t.Errorf("unexpected nested observation: %v", nested)
+	}
+
+	var saved bytes.Buffer
+	if err := env.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	env1 := Normalize(&testEnv{Shape: []int{2, 2}, EpisodeLen: 3}, &NormalizeConfig{
+		Obs:    true,
+		Reward: true,
+	})
+	if err := env1.Load(&saved); err != nil {
+		t.Fatal(err)
+	}
+	env.SetTraining(false)
+	env1.SetTraining(false)
+	obs, _ = env.Reset()
+	obs1, _ := env1.Reset()
+	for i, x := range obs.(*FloatObs).Values {
+		if y := obs1.(*FloatObs).Values[i]; x != y {
+			t.Errorf("value %d: expected %f but got %f", i, x, y)
+		}
+	}
+	_, r, _, _, _ := env.Step(1)
+	_, r1, _, _, _ := env1.Step(1)
+	if r != r1 {
+		t.Errorf("expected reward %f but got %f", r, r1)
+	}
+}
+
+func TestNormalizeReward(t *testing.T) {
+	env := NormalizeReward(&testEnv{Shape: []int{2}, EpisodeLen: 5}, 0.9)
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obs.(*FloatObs); ok {
+		t.Error("observations should not be normalized")
+	}
+	var rewards []float64
+	for i := 0; i < 5; i++ {
+		_, reward, _, _, err := env.Step(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rewards = append(rewards, reward)
+	}
+	// The return grows during the episode, so its standard
+	// deviation grows and the scaled rewards shrink.
+	for i := 2; i < len(rewards); i++ {
+		if rewards[i] >= rewards[i-1] {
+			t.Errorf("expected decreasing rewards but got %v", rewards)
+			break
+		}
+	}
+	_, retStats := env.Stats()
+	if retStats.Count < 5 {
+		t.Errorf("unexpected return statistics: %+v", retStats)
+	}
+}
+
+func TestFloatObsEmpty(t *testing.T) {
+	for _, test := range []struct {
+		Obs      *FloatObs
+		Expected string
+	}{
+		{&FloatObs{Dims: []int{0}}, "[]"},
+		{&FloatObs{Dims: []int{0, 3}}, "[]"},
+		{&FloatObs{Dims: []int{2, 0}}, "[[],[]]"},
+		{&FloatObs{Dims: []int{1, 2}, Values: []float64{1, 2}}, "[[1,2]]"},
+	} {
+		var obj interface{}
+		if err := test.Obs.Unmarshal(&obj); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(obj)
+		if string(data) != test.Expected {
+			t.Errorf("dims %v: expected %s but got %s", test.Obs.Dims, test.Expected, data)
+		}
+	}
+}
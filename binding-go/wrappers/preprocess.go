@@ -0,0 +1,216 @@
+package wrappers
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// AtariFrameSize is the width and height of the frames in
+// the standard Atari preprocessing.
+const AtariFrameSize = 84
+
+// Grayscale creates an Env which converts RGB byte list
+// observations (with shape HxWx3) to grayscale.
+// The resulting observations have shape HxWx1.
+//
+// The conversion uses the same weights as OpenCV's
+// COLOR_RGB2GRAY, like the standard Atari preprocessing.
+func Grayscale(env gym.Env) gym.Env {
+	return &obsTransform{
+		Wrapper: gym.Wrapper{Env: env},
+		name:    "grayscale",
+		Obs:     grayscaleObs,
+		Space: func(space *gym.Space) (*gym.Space, error) {
+			if space.Type != "Box" || len(space.Shape) != 3 || space.Shape[2] != 3 {
+				return nil, errors.New("expected an HxWx3 Box space")
+			}
+			return uint8Box(space.Shape[0], space.Shape[1], 1), nil
+		},
+	}
+}
+
+func grayscaleObs(obs gym.Obs) (gym.Obs, error) {
+	shape, values, ok := uint8Frame(obs)
+	if !ok || len(shape) != 3 || shape[2] != 3 {
+		return nil, errors.New("expected an HxWx3 byte list observation")
+	}
+	res := make([]uint8, len(values)/3)
+	for i := range res {
+		r, g, b := int(values[i*3]), int(values[i*3+1]), int(values[i*3+2])
+		res[i] = uint8((299*r + 587*g + 114*b + 500) / 1000)
+	}
+	return gym.NewUint8Obs([]int{shape[0], shape[1], 1}, res), nil
+}
+
+// Resize creates an Env which resizes image observations
+// (with shape HxWxC or HxW) to the given height and width.
+//
+// Pixels are averaged over the area they cover, like
+// OpenCV's INTER_AREA interpolation in the standard Atari
+// preprocessing.
+func Resize(env gym.Env, height, width int) gym.Env {
+	if height < 1 || width < 1 {
+		panic("resize dimensions must be positive")
+	}
+	r := &resizer{height: height, width: width}
+	return &obsTransform{
+		Wrapper: gym.Wrapper{Env: env},
+		name:    "resize",
+		Obs:     r.Resize,
+		Space: func(space *gym.Space) (*gym.Space, error) {
+			if space.Type != "Box" || (len(space.Shape) != 2 && len(space.Shape) != 3) {
+				return nil, errors.New("expected an image Box space")
+			}
+			channels := 1
+			if len(space.Shape) == 3 {
+				channels = space.Shape[2]
+			}
+			res := uint8Box(height, width, channels)
+			res.Shape = append([]int{height, width}, space.Shape[2:]...)
+			return res, nil
+		},
+	}
+}
+
+type resizer struct {
+	height int
+	width  int
+
+	lock sync.Mutex
+
+	// The weights are cached for the last input size.
+	inHeight int
+	inWidth  int
+	rows     [][]areaWeight
+	cols     [][]areaWeight
+}
+
+// An areaWeight is the fraction of an output pixel which
+// is covered by an input pixel.
+type areaWeight struct {
+	Index  int
+	Weight float64
+}
+
+func (r *resizer) Resize(obs gym.Obs) (gym.Obs, error) {
+	shape, values, ok := uint8Frame(obs)
+	if !ok || (len(shape) != 2 && len(shape) != 3) {
+		return nil, errors.New("expected an image byte list observation")
+	}
+	channels := 1
+	if len(shape) == 3 {
+		channels = shape[2]
+	}
+	rows, cols := r.weights(shape[0], shape[1])
+
+	res := make([]uint8, r.height*r.width*channels)
+	sums := make([]float64, channels)
+	for y, rowWeights := range rows {
+		for x, colWeights := range cols {
+			for c := range sums {
+				sums[c] = 0
+			}
+			for _, rw := range rowWeights {
+				for _, cw := range colWeights {
+					weight := rw.Weight * cw.Weight
+					offset := (rw.Index*shape[1] + cw.Index) * channels
+					for c := 0; c < channels; c++ {
+						sums[c] += weight * float64(values[offset+c])
+					}
+				}
+			}
+			offset := (y*r.width + x) * channels
+			for c, sum := range sums {
+				res[offset+c] = uint8(math.Min(255, math.Round(sum)))
+			}
+		}
+	}
+	return gym.NewUint8Obs(append([]int{r.height, r.width}, shape[2:]...), res), nil
+}
+
+func (r *resizer) weights(inHeight, inWidth int) (rows, cols [][]areaWeight) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.inHeight != inHeight || r.inWidth != inWidth {
+		r.inHeight, r.inWidth = inHeight, inWidth
+		r.rows = areaWeights(inHeight, r.height)
+		r.cols = areaWeights(inWidth, r.width)
+	}
+	return r.rows, r.cols
+}
+
+// areaWeights computes, for each output pixel along one
+// axis, the input pixels it covers and how much of it
+// they cover.
+func areaWeights(inSize, outSize int) [][]areaWeight {
+	scale := float64(inSize) / float64(outSize)
+	res := make([][]areaWeight, outSize)
+	for i := range res {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < inSize && float64(j) < end; j++ {
+			overlap := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if overlap > 0 {
+				res[i] = append(res[i], areaWeight{Index: j, Weight: overlap / scale})
+			}
+		}
+	}
+	return res
+}
+
+// uint8Box creates a Box space for images.
+func uint8Box(height, width, channels int) *gym.Space {
+	size := height * width * channels
+	return &gym.Space{
+		Type:  "Box",
+		Shape: []int{height, width, channels},
+		Low:   make([]float64, size),
+		High:  constFloats(size, 255),
+	}
+}
+
+// obsTransform is an Env which transforms every
+// observation with a function.
+type obsTransform struct {
+	gym.Wrapper
+	name  string
+	Obs   func(obs gym.Obs) (gym.Obs, error)
+	Space func(space *gym.Space) (*gym.Space, error)
+}
+
+func (o *obsTransform) Reset() (obs gym.Obs, err error) {
+	obs, err = o.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	obs, err = o.Obs(obs)
+	if err != nil {
+		return nil, essentials.AddCtx(o.name, err)
+	}
+	return obs, nil
+}
+
+func (o *obsTransform) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = o.Env.Step(action)
+	if err != nil {
+		return
+	}
+	obs, err = o.Obs(obs)
+	if err != nil {
+		err = essentials.AddCtx(o.name, err)
+	}
+	return
+}
+
+func (o *obsTransform) ObservationSpace() (space *gym.Space, err error) {
+	defer essentials.AddCtxTo(o.name, &err)
+	space, err = o.Env.ObservationSpace()
+	if err != nil {
+		return nil, err
+	}
+	return o.Space(space)
+}
@@ -0,0 +1,99 @@
+package wrappers
+
+import (
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+func TestGrayscale(t *testing.T) {
+	env := Grayscale(&testEnv{Shape: []int{2, 3, 3}, EpisodeLen: 10})
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs, _, _, _, err = env.Step(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shape, values, ok := uint8Frame(obs)
+	if !ok {
+		t.Fatal("expected byte list observation")
+	}
+	if !reflect.DeepEqual(shape, []int{2, 3, 1}) {
+		t.Errorf("unexpected shape: %v", shape)
+	}
+	for i, x := range values {
+		if x != 1 {
+			t.Errorf("value %d: expected 1 but got %d", i, x)
+		}
+	}
+
+	space, err := env.ObservationSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(space.Shape, []int{2, 3, 1}) || len(space.High) != 6 {
+		t.Errorf("unexpected space: %v", space)
+	}
+
+	if _, err := Grayscale(&testEnv{Shape: []int{2, 3}}).Reset(); err == nil {
+		t.Error("expected error for non-RGB observation")
+	}
+}
+
+func TestResize(t *testing.T) {
+	// Downsample a 4x4 checkerboard of 2x2 blocks.
+	values := []uint8{
+		0, 0, 100, 100,
+		0, 0, 100, 100,
+		200, 200, 50, 50,
+		200, 200, 50, 50,
+	}
+	r := &resizer{height: 2, width: 2}
+	obs, err := r.Resize(gym.NewUint8Obs([]int{4, 4}, values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shape, actual, _ := uint8Frame(obs)
+	if !reflect.DeepEqual(shape, []int{2, 2}) {
+		t.Errorf("unexpected shape: %v", shape)
+	}
+	if !reflect.DeepEqual(actual, []uint8{0, 100, 200, 50}) {
+		t.Errorf("unexpected values: %v", actual)
+	}
+
+	// Downsample by a non-integer factor.
+	r = &resizer{height: 1, width: 2}
+	obs, err = r.Resize(gym.NewUint8Obs([]int{1, 3, 1}, []uint8{0, 90, 30}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shape, actual, _ = uint8Frame(obs)
+	if !reflect.DeepEqual(shape, []int{1, 2, 1}) {
+		t.Errorf("unexpected shape: %v", shape)
+	}
+	if !reflect.DeepEqual(actual, []uint8{30, 50}) {
+		t.Errorf("unexpected values: %v", actual)
+	}
+}
+
+func TestGrayscaleResize(t *testing.T) {
+	env := Resize(Grayscale(&testEnv{Shape: []int{210, 160, 3}, EpisodeLen: 10}),
+		AtariFrameSize, AtariFrameSize)
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shape, _, _ := uint8Frame(obs); !reflect.DeepEqual(shape, []int{84, 84, 1}) {
+		t.Errorf("unexpected shape: %v", shape)
+	}
+	space, err := env.ObservationSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(space.Shape, []int{84, 84, 1}) || len(space.Low) != 84*84 {
+		t.Errorf("unexpected space shape: %v", space.Shape)
+	}
+}
@@ -0,0 +1,74 @@
+package wrappers
+
+import gym "github.com/unixpickle/gym-socket-api/binding-go"
+
+type actionRepeat struct {
+	gym.Wrapper
+	n       int
+	maxPool bool
+}
+
+// ActionRepeat creates an Env which repeats each action n
+// times, also known as frame skipping.
+//
+// The rewards of the repeated steps are summed.
+// If an episode ends early, the action is not repeated
+// any more.
+// The observation and info are from the last step.
+//
+// The observations of the other steps are not sent by the
+// server, as with gym.StepBlind.
+func ActionRepeat(env gym.Env, n int) gym.Env {
+	if n < 1 {
+		panic("action repeat count must be positive")
+	}
+	return &actionRepeat{Wrapper: gym.Wrapper{Env: env}, n: n}
+}
+
+// MaxAndSkip is like ActionRepeat, except that each
+// observation is the element-wise maximum of the last two
+// frames.
+//
+// This is the standard Atari preprocessing, since some
+// Atari games draw objects on alternating frames.
+// Observations must be byte lists.
+func MaxAndSkip(env gym.Env, n int) gym.Env {
+	if n < 1 {
+		panic("action repeat count must be positive")
+	}
+	return &actionRepeat{
+		Wrapper: gym.Wrapper{Env: env},
+		n:       n,
+		maxPool: true,
+	}
+}
+
+func (a *actionRepeat) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	// Only the last one or two observations are used, so
+	// the other steps do not transfer them.
+	numBlind := a.n - 1
+	if a.maxPool {
+		numBlind--
+	}
+	var lastObs gym.Obs
+	for i := 0; i < a.n && !done; i++ {
+		if a.maxPool && i > 0 {
+			lastObs = copyFrame(obs)
+		}
+		var r float64
+		if i < numBlind {
+			obs, r, done, info, err = gym.StepBlind(a.Env, action)
+		} else {
+			obs, r, done, info, err = a.Env.Step(action)
+		}
+		if err != nil {
+			return nil, 0, false, nil, err
+		}
+		reward += r
+	}
+	if lastObs != nil {
+		obs, err = MaxFrames(lastObs, obs)
+	}
+	return
+}
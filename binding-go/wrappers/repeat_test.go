@@ -0,0 +1,52 @@
+package wrappers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestActionRepeat(t *testing.T) {
+	inner := &testEnv{Shape: []int{2}, EpisodeLen: 5}
+	env := ActionRepeat(inner, 3)
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	obs, reward, done, info, err := env.Step(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, values, _ := uint8Frame(obs); values[0] != 3 {
+		t.Errorf("expected last frame but got %v", values)
+	}
+	if reward != 6 || done || info.(map[string]interface{})["t"] != 3.0 {
+		t.Errorf("unexpected step: reward=%f done=%v info=%v", reward, done, info)
+	}
+
+	// The episode ends after two more steps.
+	_, reward, done, _, err = env.Step(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reward != 2 || !done || len(inner.Actions) != 5 {
+		t.Errorf("unexpected step: reward=%f done=%v actions=%d", reward, done,
+			len(inner.Actions))
+	}
+}
+
+func TestMaxAndSkip(t *testing.T) {
+	env := MaxAndSkip(&testEnv{Shape: []int{2}, EpisodeLen: 10}, 4)
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	obs, reward, _, _, err := env.Step(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, values, _ := uint8Frame(obs); !reflect.DeepEqual(values, []uint8{4, 4}) {
+		t.Errorf("unexpected observation: %v", values)
+	}
+	if reward != 4 {
+		t.Errorf("expected reward 4 but got %f", reward)
+	}
+}
@@ -0,0 +1,53 @@
+package wrappers
+
+import (
+	"math"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// ClipReward creates an Env which clips rewards to the
+// range [min, max].
+//
+// The standard Atari preprocessing uses ClipReward(env,
+// -1, 1), which turns the integer scores of Atari games
+// into rewards of -1, 0, or 1.
+func ClipReward(env gym.Env, min, max float64) gym.Env {
+	if min > max {
+		panic("reward clipping range is empty")
+	}
+	return &rewardTransform{
+		Wrapper: gym.Wrapper{Env: env},
+		Reward: func(r float64) float64 {
+			return math.Max(min, math.Min(max, r))
+		},
+	}
+}
+
+// ScaleReward creates an Env which multiplies rewards by a
+// constant factor.
+func ScaleReward(env gym.Env, factor float64) gym.Env {
+	return &rewardTransform{
+		Wrapper: gym.Wrapper{Env: env},
+		Reward: func(r float64) float64 {
+			return r * factor
+		},
+	}
+}
+
+// rewardTransform is an Env which transforms every reward
+// with a function.
+type rewardTransform struct {
+	gym.Wrapper
+	Reward func(r float64) float64
+}
+
+func (r *rewardTransform) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	obs, reward, done, info, err = r.Env.Step(action)
+	if err != nil {
+		return
+	}
+	reward = r.Reward(reward)
+	return
+}
@@ -0,0 +1,33 @@
+package wrappers
+
+import "testing"
+
+func TestClipReward(t *testing.T) {
+	env := ClipReward(&testEnv{Shape: []int{1}, EpisodeLen: 10}, -1, 1)
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range [][2]float64{{3, 1}, {0, 0}, {-7, -1}, {0.5, 0.5}} {
+		_, reward, _, _, err := env.Step(x[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reward != x[1] {
+			t.Errorf("action %f: expected reward %f but got %f", x[0], x[1], reward)
+		}
+	}
+}
+
+func TestScaleReward(t *testing.T) {
+	env := ScaleReward(ClipReward(&testEnv{Shape: []int{1}, EpisodeLen: 10}, -1, 1), 0.5)
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	_, reward, _, _, err := env.Step(-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reward != -0.5 {
+		t.Errorf("expected reward -0.5 but got %f", reward)
+	}
+}
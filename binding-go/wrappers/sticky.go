@@ -0,0 +1,69 @@
+package wrappers
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+type stickyActions struct {
+	gym.Wrapper
+	p float64
+
+	lock       sync.Mutex
+	rng        *rand.Rand
+	lastAction interface{}
+	hasLast    bool
+}
+
+// StickyActions creates an Env which repeats the previous
+// action with probability p instead of taking the given
+// action, as in the evaluation protocol of Machado et al.
+// (2018) with p = 0.25.
+//
+// The ALE applies sticky actions to every emulator frame,
+// so StickyActions should be used inside of ActionRepeat
+// or MaxAndSkip to get the same behavior:
+//
+//	env = wrappers.MaxAndSkip(wrappers.StickyActions(env, 0.25), 4)
+//
+// The first action of an episode is never replaced.
+func StickyActions(env gym.Env, p float64) gym.Env {
+	return StickyActionsSeed(env, p, time.Now().UnixNano())
+}
+
+// StickyActionsSeed is like StickyActions, but the random
+// choices are determined by a seed, making runs
+// reproducible.
+func StickyActionsSeed(env gym.Env, p float64, seed int64) gym.Env {
+	if p < 0 || p > 1 {
+		panic("sticky action probability must be in [0, 1]")
+	}
+	return &stickyActions{
+		Wrapper: gym.Wrapper{Env: env},
+		p:       p,
+		rng:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (s *stickyActions) Reset() (gym.Obs, error) {
+	s.lock.Lock()
+	s.lastAction = nil
+	s.hasLast = false
+	s.lock.Unlock()
+	return s.Env.Reset()
+}
+
+func (s *stickyActions) Step(action interface{}) (obs gym.Obs, reward float64,
+	done bool, info interface{}, err error) {
+	s.lock.Lock()
+	if s.hasLast && s.rng.Float64() < s.p {
+		action = s.lastAction
+	}
+	s.lastAction = action
+	s.hasLast = true
+	s.lock.Unlock()
+	return s.Env.Step(action)
+}
@@ -0,0 +1,44 @@
+package wrappers
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStickyActions(t *testing.T) {
+	inner := &testEnv{Shape: []int{1}, EpisodeLen: 1000000}
+	env := StickyActionsSeed(inner, 0.25, 1337)
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	const numSteps = 10000
+	for i := 0; i < numSteps; i++ {
+		if _, _, _, _, err := env.Step(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inner.Actions[0] != 0 {
+		t.Errorf("first action should not be sticky")
+	}
+	var repeats int
+	for i, action := range inner.Actions {
+		if action != i {
+			repeats++
+		}
+	}
+	if frac := float64(repeats) / numSteps; math.Abs(frac-0.25) > 0.02 {
+		t.Errorf("expected 25%% repeats but got %f", frac)
+	}
+
+	// Reset forgets the previous action.
+	env = StickyActionsSeed(inner, 1, 1337)
+	env.Reset()
+	env.Step(3)
+	env.Step(2)
+	env.Reset()
+	env.Step(1)
+	actions := inner.Actions[len(inner.Actions)-3:]
+	if actions[0] != 3 || actions[1] != 3 || actions[2] != 1 {
+		t.Errorf("unexpected actions: %v", actions)
+	}
+}
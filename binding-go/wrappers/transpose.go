@@ -0,0 +1,120 @@
+package wrappers
+
+import (
+	"errors"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// ChannelsFirst creates an Env which transposes image
+// observations from HxWxC to CxHxW, the layout expected by
+// most inference runtimes, such as ONNX models exported
+// from PyTorch.
+//
+// Observations must be byte lists, FloatObs, or
+// Float32Obs.
+func ChannelsFirst(env gym.Env) gym.Env {
+	return &obsTransform{
+		Wrapper: gym.Wrapper{Env: env},
+		name:    "channels first",
+		Obs:     HWCToCHW,
+		Space: func(space *gym.Space) (*gym.Space, error) {
+			if space.Type != "Box" || len(space.Shape) != 3 {
+				return nil, errors.New("expected an HxWxC Box space")
+			}
+			h, w, c := space.Shape[0], space.Shape[1], space.Shape[2]
+			if len(space.Low) != h*w*c || len(space.High) != h*w*c {
+				return nil, errors.New("space bounds do not match shape")
+			}
+			res := *space
+			res.Shape = []int{c, h, w}
+			res.Low = make([]float64, len(space.Low))
+			res.High = make([]float64, len(space.High))
+			transposeFloats(res.Low, space.Low, h*w, c)
+			transposeFloats(res.High, space.High, h*w, c)
+			return &res, nil
+		},
+	}
+}
+
+// HWCToCHW transposes an image observation from HxWxC to
+// CxHxW.
+//
+// The observation must be a byte list, FloatObs, or
+// Float32Obs, and the result has the same type.
+func HWCToCHW(obs gym.Obs) (gym.Obs, error) {
+	return transposeObs(obs, func(shape []int) (outShape []int, rows, cols int) {
+		h, w, c := shape[0], shape[1], shape[2]
+		return []int{c, h, w}, h * w, c
+	})
+}
+
+// CHWToHWC transposes an image observation from CxHxW to
+// HxWxC, undoing HWCToCHW.
+//
+// The observation must be a byte list, FloatObs, or
+// Float32Obs, and the result has the same type.
+func CHWToHWC(obs gym.Obs) (gym.Obs, error) {
+	return transposeObs(obs, func(shape []int) (outShape []int, rows, cols int) {
+		c, h, w := shape[0], shape[1], shape[2]
+		return []int{h, w, c}, c, h * w
+	})
+}
+
+// transposeObs transposes a 3-dimensional observation,
+// viewing it as a rows x cols matrix.
+func transposeObs(obs gym.Obs, layout func(shape []int) ([]int, int, int)) (gym.Obs, error) {
+	if f, ok := obs.(*FloatObs); ok {
+		if len(f.Dims) != 3 {
+			return nil, errors.New("transpose: expected a 3-dimensional observation")
+		}
+		shape, rows, cols := layout(f.Dims)
+		if len(f.Values) != rows*cols {
+			return nil, errors.New("transpose: observation does not match its shape")
+		}
+		res := make([]float64, len(f.Values))
+		transposeFloats(res, f.Values, rows, cols)
+		return &FloatObs{Dims: shape, Values: res}, nil
+	}
+	if f, ok := obs.(*Float32Obs); ok {
+		if len(f.Dims) != 3 {
+			return nil, errors.New("transpose: expected a 3-dimensional observation")
+		}
+		shape, rows, cols := layout(f.Dims)
+		if len(f.Values) != rows*cols {
+			return nil, errors.New("transpose: observation does not match its shape")
+		}
+		res := make([]float32, len(f.Values))
+		for i := 0; i < rows; i++ {
+			for j, x := range f.Values[i*cols : (i+1)*cols] {
+				res[j*rows+i] = x
+			}
+		}
+		return &Float32Obs{Dims: shape, Values: res}, nil
+	}
+	inShape, values, ok := uint8Frame(obs)
+	if !ok || len(inShape) != 3 {
+		return nil, errors.New("transpose: expected a 3-dimensional byte list observation")
+	}
+	shape, rows, cols := layout(inShape)
+	if len(values) != rows*cols {
+		return nil, errors.New("transpose: observation does not match its shape")
+	}
+	res := make([]uint8, len(values))
+	for i := 0; i < rows; i++ {
+		for j, x := range values[i*cols : (i+1)*cols] {
+			res[j*rows+i] = x
+		}
+	}
+	return gym.NewUint8Obs(shape, res), nil
+}
+
+// transposeFloats transposes a row-major rows x cols
+// matrix into dst.
+func transposeFloats(dst, src []float64, rows, cols int) {
+	for i := 0; i < rows; i++ {
+		for j, x := range src[i*cols : (i+1)*cols] {
+			dst[j*rows+i] = x
+		}
+	}
+}
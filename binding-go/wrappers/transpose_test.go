@@ -0,0 +1,70 @@
+package wrappers
+
+import (
+	"reflect"
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+func TestHWCToCHW(t *testing.T) {
+	// A 2x3 RGB image, where each value is 100*y + 10*x + c.
+	hwc := []uint8{
+		0, 1, 2, 10, 11, 12, 20, 21, 22,
+		100, 101, 102, 110, 111, 112, 120, 121, 122,
+	}
+	chw := []uint8{
+		0, 10, 20, 100, 110, 120,
+		1, 11, 21, 101, 111, 121,
+		2, 12, 22, 102, 112, 122,
+	}
+	obs, err := HWCToCHW(gym.NewUint8Obs([]int{2, 3, 3}, hwc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shape, values, _ := uint8Frame(obs)
+	if !reflect.DeepEqual(shape, []int{3, 2, 3}) || !reflect.DeepEqual(values, chw) {
+		t.Errorf("unexpected result: shape %v values %v", shape, values)
+	}
+
+	obs, err = CHWToHWC(obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shape, values, _ = uint8Frame(obs)
+	if !reflect.DeepEqual(shape, []int{2, 3, 3}) || !reflect.DeepEqual(values, hwc) {
+		t.Errorf("unexpected inverse: shape %v values %v", shape, values)
+	}
+
+	floats := &FloatObs{Dims: []int{1, 2, 2}, Values: []float64{1, 2, 3, 4}}
+	obs, err = HWCToCHW(floats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &FloatObs{Dims: []int{2, 1, 2}, Values: []float64{1, 3, 2, 4}}
+	if !reflect.DeepEqual(obs, expected) {
+		t.Errorf("expected %v but got %v", expected, obs)
+	}
+
+	if _, err := HWCToCHW(gym.NewUint8Obs([]int{2, 2}, []uint8{1, 2, 3, 4})); err == nil {
+		t.Error("expected error for 2-dimensional observation")
+	}
+}
+
+func TestChannelsFirst(t *testing.T) {
+	env := ChannelsFirst(&testEnv{Shape: []int{4, 5, 3}, EpisodeLen: 10})
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shape, _, _ := uint8Frame(obs); !reflect.DeepEqual(shape, []int{3, 4, 5}) {
+		t.Errorf("unexpected observation shape: %v", shape)
+	}
+	space, err := env.ObservationSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(space.Shape, []int{3, 4, 5}) || len(space.Low) != 60 {
+		t.Errorf("unexpected space: %+v", space)
+	}
+}
@@ -0,0 +1,52 @@
+// Package wrappers provides Env wrappers which transform
+// observations, actions, and rewards on the client side,
+// such as the standard preprocessing for Atari games.
+//
+// Wrappers keep some state between calls, such as the
+// frames of a frame stack, so an Env should not be used
+// directly once it has been wrapped.
+//
+// Every wrapper embeds gym.Wrapper, so wrappers can be
+// combined with gym.Chain and inspected with gym.Unwrap.
+package wrappers
+
+import gym "github.com/unixpickle/gym-socket-api/binding-go"
+
+// uint8Frame gets the shape and values of a byte list
+// observation.
+//
+// If the observation has no known shape, it is treated as
+// a vector.
+func uint8Frame(obs gym.Obs) (shape []int, values []uint8, ok bool) {
+	u8, ok := obs.(gym.Uint8Obs)
+	if !ok {
+		return nil, nil, false
+	}
+	values = u8.Uint8Obs()
+	if shaped, ok := obs.(gym.ShapedObs); ok {
+		shape = shaped.Shape()
+	} else {
+		shape = []int{len(values)}
+	}
+	return shape, values, true
+}
+
+// copyFrame copies a byte list observation, so that it is
+// not overwritten if the Env reuses its observations.
+// Other observations are returned as is.
+func copyFrame(obs gym.Obs) gym.Obs {
+	shape, values, ok := uint8Frame(obs)
+	if !ok {
+		return obs
+	}
+	return gym.NewUint8Obs(append([]int{}, shape...), append([]uint8{}, values...))
+}
+
+// constFloats creates a slice filled with x.
+func constFloats(n int, x float64) []float64 {
+	res := make([]float64, n)
+	for i := range res {
+		res[i] = x
+	}
+	return res
+}
@@ -0,0 +1,88 @@
+package wrappers
+
+import (
+	"testing"
+
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// testEnv is a fake environment.
+//
+// Each observation is a byte list filled with the number
+// of steps since the last reset, and the reward for each
+// step is the action.
+// Episodes end after EpisodeLen steps.
+type testEnv struct {
+	gym.Env
+
+	Shape      []int
+	EpisodeLen int
+
+	Resets  int
+	Actions []interface{}
+	t       int
+}
+
+func (t *testEnv) Reset() (gym.Obs, error) {
+	t.Resets++
+	t.t = 0
+	return t.obs(), nil
+}
+
+func (t *testEnv) Step(action interface{}) (gym.Obs, float64, bool, interface{}, error) {
+	t.Actions = append(t.Actions, action)
+	t.t++
+	info := map[string]interface{}{"t": float64(t.t)}
+	return t.obs(), toFloat(action), t.t >= t.EpisodeLen, info, nil
+}
+
+func (t *testEnv) ObservationSpace() (*gym.Space, error) {
+	size := 1
+	for _, x := range t.Shape {
+		size *= x
+	}
+	return &gym.Space{
+		Type:  "Box",
+		Shape: t.Shape,
+		Low:   make([]float64, size),
+		High:  constFloats(size, 255),
+	}, nil
+}
+
+func (t *testEnv) ActionSpace() (*gym.Space, error) {
+	return &gym.Space{Type: "Discrete", N: 4}, nil
+}
+
+func (t *testEnv) obs() gym.Obs {
+	size := 1
+	for _, x := range t.Shape {
+		size *= x
+	}
+	values := make([]uint8, size)
+	for i := range values {
+		values[i] = uint8(t.t)
+	}
+	return gym.NewUint8Obs(t.Shape, values)
+}
+
+func toFloat(action interface{}) float64 {
+	switch action := action.(type) {
+	case int:
+		return float64(action)
+	case float64:
+		return action
+	}
+	return 0
+}
+
+func TestUnwrap(t *testing.T) {
+	inner := &testEnv{Shape: []int{4, 4, 3}}
+	env := gym.Chain(inner,
+		func(env gym.Env) gym.Env { return Grayscale(env) },
+		func(env gym.Env) gym.Env { return FrameStack(env, 4) },
+		func(env gym.Env) gym.Env { return ClipReward(env, -1, 1) },
+	)
+	if gym.Innermost(env) != inner {
+		t.Error("unexpected innermost environment")
+	}
+}
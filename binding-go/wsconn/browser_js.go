@@ -0,0 +1,246 @@
+package wsconn
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+// BrowserConn is a net.Conn which uses the WebSocket API
+// of a JavaScript host, such as a web browser.
+//
+// WebAssembly programs cannot open raw sockets, so the
+// host performs the handshake and framing instead.
+type BrowserConn struct {
+	ws    js.Value
+	url   string
+	funcs []js.Func
+
+	lock         sync.Mutex
+	messages     [][]byte
+	pending      []byte
+	err          error
+	readDeadline time.Time
+	notify       chan struct{}
+
+	closeOnce sync.Once
+}
+
+// DialBrowser connects to a WebSocket URL using the
+// WebSocket API of the JavaScript host.
+// It must not be called from a JavaScript callback, since
+// it waits for the host to open the connection.
+//
+// TLS for wss URLs is handled by the host, so there is
+// no TLS configuration.
+func DialBrowser(rawURL string) (conn *BrowserConn, err error) {
+	constructor := js.Global().Get("WebSocket")
+	if constructor.IsUndefined() {
+		return nil, errors.New("wsconn: JavaScript host has no WebSocket API")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			// The constructor throws for malformed URLs.
+			conn, err = nil, errors.New("wsconn: invalid WebSocket URL: "+rawURL)
+		}
+	}()
+	c := &BrowserConn{
+		ws:     constructor.New(rawURL),
+		url:    rawURL,
+		notify: make(chan struct{}, 1),
+	}
+	c.ws.Set("binaryType", "arraybuffer")
+
+	opened := make(chan bool, 1)
+	c.handle("onopen", func(event js.Value) {
+		select {
+		case opened <- true:
+		default:
+		}
+	})
+	c.handle("onerror", func(event js.Value) {
+		select {
+		case opened <- false:
+		default:
+		}
+	})
+	c.handle("onclose", func(event js.Value) {
+		select {
+		case opened <- false:
+		default:
+		}
+		c.fail(io.EOF)
+	})
+	c.handle("onmessage", func(event js.Value) {
+		c.receive(event.Get("data"))
+	})
+	if !<-opened {
+		c.Close()
+		// Browsers do not say why a connection failed.
+		return nil, errors.New("wsconn: failed to connect to " + rawURL)
+	}
+	return c, nil
+}
+
+// handle sets an event handler on the WebSocket.
+// JavaScript event handlers must not block.
+func (c *BrowserConn) handle(name string, f func(event js.Value)) {
+	fn := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		f(args[0])
+		return nil
+	})
+	c.funcs = append(c.funcs, fn)
+	c.ws.Set(name, fn)
+}
+
+func (c *BrowserConn) receive(data js.Value) {
+	var payload []byte
+	if data.Type() == js.TypeString {
+		payload = []byte(data.String())
+	} else {
+		array := js.Global().Get("Uint8Array").New(data)
+		payload = make([]byte, array.Get("length").Int())
+		js.CopyBytesToGo(payload, array)
+	}
+	c.lock.Lock()
+	c.messages = append(c.messages, payload)
+	c.lock.Unlock()
+	c.wake()
+}
+
+func (c *BrowserConn) fail(err error) {
+	c.lock.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.lock.Unlock()
+	c.wake()
+}
+
+func (c *BrowserConn) wake() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Read reads from the payloads of incoming messages.
+//
+// Once the WebSocket closes, Read returns io.EOF.
+func (c *BrowserConn) Read(p []byte) (int, error) {
+	for {
+		c.lock.Lock()
+		if len(c.pending) == 0 && len(c.messages) > 0 {
+			c.pending = c.messages[0]
+			c.messages = c.messages[1:]
+		}
+		if len(c.pending) > 0 {
+			n := copy(p, c.pending)
+			c.pending = c.pending[n:]
+			c.lock.Unlock()
+			return n, nil
+		}
+		err, deadline := c.err, c.readDeadline
+		c.lock.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		if !c.wait(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// wait waits for a message, an error, or a new deadline.
+// It returns false if the deadline passes first.
+func (c *BrowserConn) wait(deadline time.Time) bool {
+	if deadline.IsZero() {
+		<-c.notify
+		return true
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return false
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-c.notify:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Write sends the data as a single binary message.
+//
+// The host buffers outgoing messages, so Write does not
+// block, and write deadlines have no effect.
+func (c *BrowserConn) Write(p []byte) (int, error) {
+	c.lock.Lock()
+	err := c.err
+	c.lock.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	array := js.Global().Get("Uint8Array").New(len(p))
+	js.CopyBytesToJS(array, p)
+	c.ws.Call("send", array)
+	return len(p), nil
+}
+
+// Close closes the WebSocket.
+func (c *BrowserConn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		for _, name := range []string{"onopen", "onerror", "onclose", "onmessage"} {
+			c.ws.Set(name, js.Null())
+		}
+		c.ws.Call("close")
+		for _, fn := range c.funcs {
+			fn.Release()
+		}
+		c.fail(net.ErrClosed)
+		err = nil
+	})
+	return err
+}
+
+func (c *BrowserConn) LocalAddr() net.Addr {
+	return browserAddr("")
+}
+
+func (c *BrowserConn) RemoteAddr() net.Addr {
+	return browserAddr(c.url)
+}
+
+func (c *BrowserConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *BrowserConn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.readDeadline = t
+	c.lock.Unlock()
+	c.wake()
+	return nil
+}
+
+func (c *BrowserConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// browserAddr is the URL of a BrowserConn.
+type browserAddr string
+
+func (b browserAddr) Network() string {
+	return "websocket"
+}
+
+func (b browserAddr) String() string {
+	return string(b)
+}
@@ -0,0 +1,119 @@
+package wsconn
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/unixpickle/essentials"
+)
+
+// Dial connects to a WebSocket URL, such as
+// "ws://localhost:5001/" or "wss://example.com/gym".
+//
+// The tlsConfig is used for wss URLs, and may be nil to
+// use the defaults.
+func Dial(rawURL string, tlsConfig *tls.Config) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, essentials.AddCtx("dial WebSocket", err)
+	}
+	conn, err := net.Dial("tcp", HostPort(u))
+	if err != nil {
+		return nil, essentials.AddCtx("dial WebSocket", err)
+	}
+	if u.Scheme == "wss" {
+		conn = tls.Client(conn, TLSConfig(u, tlsConfig))
+	}
+	return Client(conn, u)
+}
+
+// Client performs the opening handshake of a WebSocket
+// over an existing connection to the server of a ws or wss
+// URL.
+//
+// For wss URLs, conn should already use TLS.
+// If the handshake fails, conn is closed.
+func Client(conn net.Conn, u *url.URL) (ws *Conn, err error) {
+	defer essentials.AddCtxTo("WebSocket handshake", &err)
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+
+	var keyData [16]byte
+	if _, err := rand.Read(keyData[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyData[:])
+	reqURL := *u
+	reqURL.Scheme = "http"
+	if u.Scheme == "wss" {
+		reqURL.Scheme = "https"
+	}
+	req, err := http.NewRequest("GET", reqURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("incorrect Sec-WebSocket-Accept header")
+	}
+	return newConn(conn, r, true), nil
+}
+
+// HostPort gets the host:port address of a ws or wss URL,
+// using the default port for the scheme if the URL has
+// none.
+func HostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "wss" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// TLSConfig creates the TLS config for a wss URL from a
+// base config, which may be nil.
+// If the base config has no ServerName, it is set to the
+// URL's host name.
+func TLSConfig(u *url.URL, base *tls.Config) *tls.Config {
+	var config *tls.Config
+	if base == nil {
+		config = &tls.Config{}
+	} else {
+		config = base.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = u.Hostname()
+	}
+	return config
+}
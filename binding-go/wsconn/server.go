@@ -0,0 +1,130 @@
+package wsconn
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Upgrade performs the server side of the opening
+// handshake for an HTTP request.
+//
+// If the request is not a valid WebSocket handshake, an
+// error response is written and an error is returned.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	fail := func(message string) (*Conn, error) {
+		http.Error(w, message, http.StatusBadRequest)
+		return nil, errors.New("wsconn: " + message)
+	}
+	if r.Method != "GET" {
+		return fail("WebSocket handshake must use GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		return fail("not a WebSocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail("unsupported WebSocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return fail("missing Sec-WebSocket-Key")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("wsconn: response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newConn(conn, rw.Reader, false), nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// A Listener accepts WebSocket connections from an HTTP
+// server on an underlying listener.
+//
+// Requests for any path are accepted.
+type Listener struct {
+	inner  net.Listener
+	server *http.Server
+	conns  chan net.Conn
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewListener starts serving WebSocket handshakes on a
+// listener, such as one from net.Listen or tls.NewListener.
+func NewListener(inner net.Listener) *Listener {
+	l := &Listener{
+		inner: inner,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	l.server = &http.Server{Handler: http.HandlerFunc(l.serveHTTP)}
+	go func() {
+		l.server.Serve(inner)
+		l.Close()
+	}()
+	return l
+}
+
+func (l *Listener) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// Accept waits for the next WebSocket connection.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops the listener.
+// Connections which were already accepted stay open.
+func (l *Listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		err = l.inner.Close()
+	})
+	return err
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.inner.Addr()
+}
@@ -0,0 +1,268 @@
+// Package wsconn carries a byte stream, such as a
+// gym-socket-api connection, over a WebSocket.
+//
+// This allows clients to reach a server through HTTP
+// infrastructure, like load balancers and proxies which
+// only forward HTTP traffic, or from a web browser.
+//
+// Each Write is sent as one binary message, and Read
+// returns the payloads of the incoming messages as a
+// continuous stream, so a Conn can be used anywhere a
+// net.Conn is expected.
+// Only the parts of RFC 6455 needed for that are
+// implemented: there are no extensions or subprotocols,
+// and text messages are treated like binary ones.
+//
+// Under GOOS=js, DialBrowser connects with the WebSocket
+// API of the JavaScript host instead, since WebAssembly
+// programs cannot open sockets of their own.
+package wsconn
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Frame opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// maxControlSize is the largest payload of a control frame.
+const maxControlSize = 125
+
+// acceptGUID is appended to a handshake key to compute the
+// server's accept value.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Conn is a net.Conn which sends and receives WebSocket
+// messages.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	// client is true for the client side of a connection,
+	// which must mask the frames it sends.
+	client bool
+
+	readLock  sync.Mutex
+	remaining uint64
+	masked    bool
+	mask      [4]byte
+	maskPos   int
+	readErr   error
+
+	writeLock sync.Mutex
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, r *bufio.Reader, client bool) *Conn {
+	return &Conn{conn: conn, r: r, client: client}
+}
+
+// Read reads from the payloads of incoming data messages.
+//
+// Pings are answered automatically.
+// Once the other end closes the WebSocket, Read returns
+// io.EOF.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+	for c.remaining == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		if err := c.nextFrame(); err != nil {
+			c.readErr = err
+			return 0, err
+		}
+	}
+	if uint64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	if c.masked {
+		for i := range p[:n] {
+			p[i] ^= c.mask[c.maskPos&3]
+			c.maskPos++
+		}
+	}
+	c.remaining -= uint64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// nextFrame reads frame headers until the start of a data
+// frame, handling any control frames along the way.
+func (c *Conn) nextFrame() error {
+	for {
+		opcode, length, err := c.readHeader()
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case opContinuation, opText, opBinary:
+			c.remaining = length
+			if length > 0 {
+				return nil
+			}
+		case opClose, opPing, opPong:
+			if length > maxControlSize {
+				return errors.New("wsconn: control frame is too large")
+			}
+			payload := make([]byte, length)
+			if _, err := io.ReadFull(c.r, payload); err != nil {
+				return err
+			}
+			if c.masked {
+				for i := range payload {
+					payload[i] ^= c.mask[i&3]
+				}
+			}
+			if opcode == opClose {
+				c.writeFrame(opClose, nil)
+				return io.EOF
+			} else if opcode == opPing {
+				if err := c.writeFrame(opPong, payload); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("wsconn: unknown opcode: %d", opcode)
+		}
+	}
+}
+
+func (c *Conn) readHeader() (opcode int, length uint64, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, 0, err
+	}
+	if header[0]&0x70 != 0 {
+		return 0, 0, errors.New("wsconn: unexpected extension bits")
+	}
+	opcode = int(header[0] & 0x0f)
+	c.masked = header[1]&0x80 != 0
+	if c.masked == c.client {
+		return 0, 0, errors.New("wsconn: incorrect frame masking")
+	}
+	length = uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return 0, 0, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return 0, 0, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	c.maskPos = 0
+	if c.masked {
+		if _, err := io.ReadFull(c.r, c.mask[:]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return
+}
+
+// Write sends the data as a single binary message.
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(opBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	header := make([]byte, 2, 14+len(payload))
+	header[0] = 0x80 | byte(opcode)
+	switch {
+	case len(payload) < 126:
+		header[1] = byte(len(payload))
+	case len(payload) <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+	frame := header
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame[1] |= 0x80
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i&3])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close sends a close message, if possible, and closes the
+// underlying connection.
+func (c *Conn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.writeFrame(opClose, nil)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for a
+// Sec-WebSocket-Key.
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,92 @@
+package wsconn
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+)
+
+func TestConnRoundTrip(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := NewListener(inner)
+	defer listener.Close()
+
+	// The server echoes everything back.
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	client, err := Dial("ws://"+listener.Addr().String()+"/path", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// Exercise every length encoding.
+	for _, size := range []int{1, 125, 126, 1000, 70000} {
+		data := make([]byte, size)
+		rand.Read(data)
+		if _, err := client.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		actual := make([]byte, size)
+		if _, err := io.ReadFull(client, actual); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, data) {
+			t.Errorf("size %d: echoed data does not match", size)
+		}
+	}
+}
+
+func TestConnControlFrames(t *testing.T) {
+	clientSide, serverSide := net.Pipe()
+	client := newConn(clientSide, bufio.NewReader(clientSide), true)
+	server := newConn(serverSide, bufio.NewReader(serverSide), false)
+
+	go func() {
+		server.writeFrame(opPing, []byte("hi"))
+		server.Write([]byte("data"))
+		server.writeFrame(opClose, nil)
+	}()
+	pong := make(chan []byte, 1)
+	go func() {
+		opcode, length, err := server.readHeader()
+		if err != nil || opcode != opPong {
+			pong <- nil
+			return
+		}
+		payload := make([]byte, length)
+		io.ReadFull(server.r, payload)
+		for i := range payload {
+			payload[i] ^= server.mask[i&3]
+		}
+		pong <- payload
+	}()
+
+	data := make([]byte, 4)
+	if _, err := io.ReadFull(client, data); err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("unexpected data: %q", data)
+	}
+	if payload := <-pong; string(payload) != "hi" {
+		t.Errorf("unexpected pong: %q", payload)
+	}
+	go io.Copy(io.Discard, serverSide)
+	if _, err := client.Read(data); err != io.EOF {
+		t.Errorf("expected EOF but got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+"""
+APIs for configuring plain Gym environments at runtime.
+"""
+
+from gym import spaces
+
+ROI_ATTR = 'gym_socket_api_roi'
+
+class ConfigureException(Exception):
+    """
+    Exception type used for all configuration errors.
+    """
+    pass
+
+def configure(env, options):
+    """
+    Apply a dict of configuration options to a live
+    environment.
+
+    Supported options are:
+
+      render_fps: the frame rate used for rendering and
+        monitor videos.
+      frameskip: the frameskip of an Atari environment.
+      max_episode_steps: the time limit of the environment.
+      ale: a dict of ALE settings, such as
+        {"repeat_action_probability": 0.25}.
+      observation_roi: a region of image observations to
+        send, as a dict with keys "x", "y", "width",
+        "height", and optionally "subsample", or None to
+        send whole observations again.
+
+    Any other option is set as an attribute of the
+    unwrapped environment, if it already has that
+    attribute.
+    """
+    unwrapped = env.unwrapped
+    for key, value in options.items():
+        if key == 'render_fps':
+            for sub_env in _env_chain(env):
+                sub_env.metadata = dict(sub_env.metadata)
+                sub_env.metadata['video.frames_per_second'] = value
+        elif key == 'frameskip':
+            if not hasattr(unwrapped, 'frameskip'):
+                raise ConfigureException('environment has no frameskip')
+            unwrapped.frameskip = value
+        elif key == 'max_episode_steps':
+            if not hasattr(env, '_max_episode_steps'):
+                raise ConfigureException('environment has no time limit')
+            # pylint: disable=W0212
+            env._max_episode_steps = value
+        elif key == 'ale':
+            _configure_ale(unwrapped, value)
+        elif key == 'observation_roi':
+            _configure_roi(env, value)
+        elif hasattr(unwrapped, key):
+            setattr(unwrapped, key, value)
+        else:
+            raise ConfigureException('unknown option: ' + key)
+
+def _configure_ale(env, settings):
+    if not hasattr(env, 'ale'):
+        raise ConfigureException('not an Atari environment')
+    if not isinstance(settings, dict):
+        raise ConfigureException('ALE settings must be an object')
+    for key, value in settings.items():
+        if isinstance(value, bool):
+            env.ale.setBool(key, value)
+        elif isinstance(value, int):
+            env.ale.setInt(key, value)
+        elif isinstance(value, float):
+            env.ale.setFloat(key, value)
+        else:
+            raise ConfigureException('unsupported ALE setting: ' + key)
+
+def crop_observation(env, obs):
+    """
+    Apply the region of interest of an environment, if it
+    has one, to a numpy observation.
+
+    Other observations are returned as is.
+    """
+    roi = getattr(env, ROI_ATTR, None)
+    if roi is None or not hasattr(obs, 'shape') or len(obs.shape) < 2:
+        return obs
+    return obs[roi_slices(roi)]
+
+def crop_space(env, space):
+    """
+    Apply the region of interest of an environment, if it
+    has one, to its observation space.
+    """
+    roi = getattr(env, ROI_ATTR, None)
+    if roi is None:
+        return space
+    slices = roi_slices(roi)
+    return spaces.Box(low=space.low[slices], high=space.high[slices], dtype=space.dtype)
+
+def roi_slices(roi):
+    """
+    Get the row and column slices of a region of interest.
+    """
+    step = roi['subsample']
+    return (slice(roi['y'], roi['y']+roi['height'], step),
+            slice(roi['x'], roi['x']+roi['width'], step))
+
+def _configure_roi(env, roi):
+    if roi is None:
+        if hasattr(env, ROI_ATTR):
+            delattr(env, ROI_ATTR)
+        return
+    if not isinstance(roi, dict):
+        raise ConfigureException('observation ROI must be an object')
+    roi = dict(roi)
+    roi.setdefault('subsample', 1)
+    for key in ['x', 'y', 'width', 'height', 'subsample']:
+        value = roi.get(key)
+        if not isinstance(value, int) or isinstance(value, bool) or value < 0:
+            raise ConfigureException('observation ROI needs a non-negative integer ' + key)
+    if roi['width'] == 0 or roi['height'] == 0 or roi['subsample'] == 0:
+        raise ConfigureException('observation ROI must not be empty')
+    shape = env.observation_space.shape
+    if not isinstance(env.observation_space, spaces.Box) or len(shape) < 2:
+        raise ConfigureException('observation ROI needs image observations')
+    if roi['y'] + roi['height'] > shape[0] or roi['x'] + roi['width'] > shape[1]:
+        raise ConfigureException('observation ROI is outside of the %dx%d image' %
+                                 (shape[1], shape[0]))
+    setattr(env, ROI_ATTR, roi)
+
+def _env_chain(env):
+    while True:
+        yield env
+        if not hasattr(env, 'env'):
+            break
+        env = env.env
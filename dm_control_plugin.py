@@ -0,0 +1,101 @@
+"""
+APIs for DeepMind Control Suite environments.
+"""
+
+import numpy as np
+import gym
+from gym import spaces
+
+import registry
+
+ENV_PREFIX = 'dm_control/'
+
+class DMControl:
+    """
+    DMControl registers the DeepMind Control Suite tasks as
+    Gym environments.
+
+    Each task is registered as 'dm_control/DOMAIN-TASK-v0',
+    so that it can be made, batched, listed, and described
+    like any other Gym environment.
+    """
+    def __init__(self, enabled):
+        self.enabled = enabled
+        if enabled:
+            from dm_control import suite
+            register(suite.ALL_TASKS)
+
+def register(tasks):
+    """
+    Register (domain, task) pairs with Gym.
+    """
+    for domain, task in tasks:
+        env_id = '%s%s-%s-v0' % (ENV_PREFIX, domain, task)
+        registry.register_env(env_id, 'dm_control_plugin:DMControlEnv',
+                              {'domain': domain, 'task': task})
+
+class DMControlEnv(gym.Env):
+    """
+    A Gym environment wrapping a dm_env task.
+
+    Observations are flattened into a single vector, in the
+    order of the task's observation spec.
+    A step is done when its TimeStep is the last of the
+    episode, and the TimeStep's discount is stored in the
+    info under 'discount'.
+    A discount of 0 means the episode terminated, while a
+    discount of 1 means it was cut off by a time limit.
+    """
+    metadata = {'render.modes': ['rgb_array']}
+
+    def __init__(self, domain, task):
+        from dm_control import suite
+        self.env = suite.load(domain_name=domain, task_name=task)
+        action_spec = self.env.action_spec()
+        self.action_space = spaces.Box(
+            low=np.asarray(action_spec.minimum, dtype=np.float32),
+            high=np.asarray(action_spec.maximum, dtype=np.float32),
+            dtype=np.float32
+        )
+        obs_size = sum(int(np.prod(spec.shape))
+                       for spec in self.env.observation_spec().values())
+        self.observation_space = spaces.Box(low=-np.inf, high=np.inf,
+                                            shape=(obs_size,), dtype=np.float64)
+
+    def reset(self):
+        """
+        Start a new episode.
+        """
+        return flatten_obs(self.env.reset().observation)
+
+    def step(self, action):
+        """
+        Take a step in the environment.
+        """
+        time_step = self.env.step(action)
+        reward = time_step.reward or 0.0
+        info = {'discount': float(time_step.discount)}
+        return flatten_obs(time_step.observation), reward, time_step.last(), info
+
+    def render(self, mode='rgb_array'):
+        """
+        Render the first camera as an RGB array.
+
+        Other modes are not supported.
+        """
+        if mode == 'rgb_array':
+            return self.env.physics.render(camera_id=0)
+        return None
+
+    def close(self):
+        """
+        Close the environment.
+        """
+        self.env.close()
+
+def flatten_obs(observation):
+    """
+    Concatenate the arrays in a dm_env observation.
+    """
+    return np.concatenate([np.asarray(value, dtype=np.float64).ravel()
+                           for value in observation.values()])
@@ -0,0 +1,194 @@
+"""
+APIs for batched environments, optionally backed by envpool
+or Stable-Baselines3.
+"""
+
+import numpy as np
+import gym
+
+class EnvPoolException(Exception):
+    """
+    Exception type used for all envpool-related errors.
+    """
+    pass
+
+class EnvPool:
+    """
+    EnvPool creates batched environments.
+
+    When enabled, batches are backed by envpool.
+    If vec_env is 'dummy' or 'subproc', batches are backed
+    by the corresponding SB3 VecEnv.
+    Otherwise, they are backed by regular Gym environments.
+    """
+    def __init__(self, enabled, vec_env=None):
+        self.enabled = enabled
+        if enabled:
+            import envpool
+            self.envpool = envpool
+        self.vec_env = None
+        if vec_env:
+            import sb3_plugin
+            self.vec_env = sb3_plugin.VecEnvMaker(vec_env)
+
+    def make(self, env_name):
+        """
+        Create a single (unbatched) environment.
+        """
+        if not self.enabled:
+            return gym.make(env_name)
+        return UnbatchedEnv(self.make_batch(env_name, 1))
+
+    def make_batch(self, env_name, num_envs, auto_reset=False):
+        """
+        Create a batch of environments.
+
+        If auto_reset is set, environments are reset as soon
+        as they finish an episode, and their final
+        observations are stored in the infos under
+        'terminal_observation'.
+        Otherwise, they are reset by the following step.
+        """
+        if self.vec_env is not None:
+            return self.vec_env.make_batch(env_name, num_envs, auto_reset)
+        if not self.enabled:
+            envs = [gym.make(env_name) for _ in range(num_envs)]
+            return SerialBatchEnv(envs, auto_reset)
+        try:
+            env = self.envpool.make(env_name, env_type='gym', num_envs=num_envs)
+        # pylint: disable=W0703
+        except Exception as exc:
+            raise EnvPoolException('failed to make envpool: ' + str(exc))
+        return PoolBatchEnv(env, num_envs, auto_reset)
+
+class PoolBatchEnv:
+    """
+    A batch of environments backed by envpool.
+
+    Like envpool itself, environments which finish an
+    episode are reset by the following step, unless
+    auto_reset is set.
+    """
+    def __init__(self, env, num_envs, auto_reset):
+        self.env = env
+        self.num_envs = num_envs
+        self.auto_reset = auto_reset
+        self.action_space = env.action_space
+        self.observation_space = env.observation_space
+
+    def reset(self):
+        """
+        Reset every environment and return a list of
+        observations.
+        """
+        return list(self.env.reset())
+
+    def step(self, actions):
+        """
+        Step every environment and return lists of
+        observations, rewards, dones, and infos.
+        """
+        obs, rews, dones, info = self.env.step(np.array(actions))
+        obs = list(obs)
+        infos = [{} for _ in range(self.num_envs)]
+        for key, values in info.items():
+            for i, value in enumerate(np.asarray(values).tolist()):
+                infos[i][key] = value
+        if self.auto_reset and dones.any():
+            env_ids = np.nonzero(dones)[0]
+            for env_id, reset_obs in zip(env_ids, self.env.reset(env_ids)):
+                infos[env_id]['terminal_observation'] = obs[env_id]
+                obs[env_id] = reset_obs
+        return obs, rews.tolist(), dones.tolist(), infos
+
+    def close(self):
+        """
+        Close the environments.
+        """
+        self.env.close()
+
+class SerialBatchEnv:
+    """
+    A batch of Gym environments which are stepped one after
+    another.
+
+    This mimics the auto-reset behavior of PoolBatchEnv.
+    """
+    def __init__(self, envs, auto_reset):
+        self.envs = envs
+        self.auto_reset = auto_reset
+        self.num_envs = len(envs)
+        self.action_space = envs[0].action_space
+        self.observation_space = envs[0].observation_space
+        self.needs_reset = [False] * len(envs)
+
+    def reset(self):
+        """
+        Reset every environment and return a list of
+        observations.
+        """
+        self.needs_reset = [False] * self.num_envs
+        return [env.reset() for env in self.envs]
+
+    def step(self, actions):
+        """
+        Step every environment and return lists of
+        observations, rewards, dones, and infos.
+        """
+        obses, rews, dones, infos = [], [], [], []
+        for i, (env, action) in enumerate(zip(self.envs, actions)):
+            if self.needs_reset[i]:
+                obs, rew, done, info = env.reset(), 0.0, False, {}
+            else:
+                obs, rew, done, info = env.step(action)
+            if self.auto_reset and done:
+                info = dict(info, terminal_observation=obs)
+                obs = env.reset()
+            else:
+                self.needs_reset[i] = done
+            obses.append(obs)
+            rews.append(rew)
+            dones.append(done)
+            infos.append(info)
+        return obses, rews, dones, infos
+
+    def close(self):
+        """
+        Close the environments.
+        """
+        for env in self.envs:
+            env.close()
+
+class UnbatchedEnv:
+    """
+    A pseudo environment wrapping a batch of one.
+    """
+    def __init__(self, batch):
+        self.batch = batch
+        self.action_space = batch.action_space
+        self.observation_space = batch.observation_space
+
+    def reset(self):
+        """
+        Reset the environment.
+        """
+        return self.batch.reset()[0]
+
+    def step(self, action):
+        """
+        Take a step in the environment.
+        """
+        obs, rews, dones, infos = self.batch.step([action])
+        return obs[0], rews[0], dones[0], infos[0]
+
+    def render(self):
+        """
+        Rendering is not supported by envpool.
+        """
+        pass
+
+    def close(self):
+        """
+        Close the environment.
+        """
+        self.batch.close()
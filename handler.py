@@ -7,14 +7,42 @@ This treats stdin/stdout as a connection to a client.
 from argparse import ArgumentParser
 import io
 import json
+import logging
+import math
+import os
+import socket
 import sys
 
 import proto
 import gym
 from gym import wrappers
+import configure
+import dm_control_plugin
+import envpool_plugin
+import pettingzoo_plugin
+import registry
 import retro_plugin
+import rpc
+import screenshot
+import session
+import snapshot
+import unity_plugin
 import universe_plugin
 
+LOGGER = logging.getLogger('gym-socket-api')
+
+# Errors raised by gym.make() for unknown environments.
+UNKNOWN_ENV_ERRORS = tuple(getattr(gym.error, name) for name in
+                           ['UnregisteredEnv', 'DeprecatedEnv']
+                           if hasattr(gym.error, name))
+
+LOG_LEVELS = {
+    'debug': logging.DEBUG,
+    'info': logging.INFO,
+    'warning': logging.WARNING,
+    'error': logging.ERROR
+}
+
 def main():
     """
     Executable entry-point.
@@ -23,114 +51,474 @@ def main():
     parser.add_argument('--addr', action='store', type=str, dest='addr')
     parser.add_argument('--fd', action='store', type=int, dest='fd')
     parser.add_argument('--retro', action='store_true', dest='retro')
+    parser.add_argument('--retro-integrations', action='store', type=str,
+                        dest='retro_integrations', default='')
     parser.add_argument('--universe', action='store_true', dest='universe')
+    parser.add_argument('--envpool', action='store_true', dest='envpool')
+    parser.add_argument('--sb3', action='store', type=str, dest='sb3')
+    parser.add_argument('--dm-control', action='store_true', dest='dm_control')
+    parser.add_argument('--unity', action='store', type=str, dest='unity', default='')
+    parser.add_argument('--pettingzoo', action='store_true', dest='pettingzoo')
     parser.add_argument('--setup', action='store', type=str, dest='setup_code')
+    parser.add_argument('--idle-ttl', action='store', type=float, dest='idle_ttl',
+                        default=0)
+    parser.add_argument('--session-token', action='store', type=str,
+                        dest='session_token', default='')
+    parser.add_argument('--control-fd', action='store', type=int, dest='control_fd')
+    parser.add_argument('--session-ttl', action='store', type=float,
+                        dest='session_ttl', default=0)
     options = parser.parse_args()
 
     # pylint: disable=W0122
     exec(options.setup_code)
 
-    in_file = io.open(options.fd, 'rb', buffering=0)
+    log_format = '%(levelname)s ' + options.addr.replace('%', '%%') + ': %(message)s'
+    logging.basicConfig(format=log_format)
+    LOGGER.setLevel(logging.WARNING)
+
+    in_file = proto.IdleReader(options.fd, options.idle_ttl, wake_fd=options.control_fd)
     out_file = io.open(options.fd, 'wb', buffering=0)
-    handle(io.BufferedRWPair(in_file, out_file), options)
+    handle(io.BufferedRWPair(in_file, out_file), in_file, options)
 
-def handle(sock_file, info):
+def handle(sock_file, reader, info):
     """
     Handle a connection from a client.
+
+    The reader is the IdleReader underlying sock_file.
     """
     try:
         uni = universe_plugin.Universe(info.universe)
-        retro = retro_plugin.Retro(info.retro)
-        env = handshake(sock_file)
+        retro = retro_plugin.Retro(info.retro, info.retro_integrations)
+        pool = envpool_plugin.EnvPool(info.envpool, info.sb3)
+        dm_control_plugin.DMControl(info.dm_control)
+        unity_plugin.Unity(info.unity)
+        pettingzoo_plugin.PettingZoo(info.pettingzoo)
+        envs, flags = handshake(sock_file, pool, info.session_token)
+        features = server_features(info)
         try:
-            loop(sock_file, uni, env)
+            while True:
+                try:
+                    loop(sock_file, uni, retro, envs, flags, reader, features)
+                    break
+                except (proto.ProtoException, IOError) as exc:
+                    if (not flags & proto.FLAG_SESSION or
+                            isinstance(exc, proto.IdleException)):
+                        raise
+                    LOGGER.info('connection lost: %s', exc)
+                    sock_file, reader = resume_session(sock_file, reader, info)
         finally:
-            if not env is None:
-                env.close()
+            for env in envs:
+                if not env is None:
+                    env.close()
     except proto.ProtoException as exc:
         if str(exc) != 'EOF':
-            log('%s gave error: %s' % (info.addr, str(exc)))
+            LOGGER.error('client gave error: %s', str(exc))
+
+def server_features(info):
+    """
+    List the optional features that the server supports,
+    for clients to check with a Ping packet.
+    """
+    features = ['batch', 'multiplex', 'binary_actions', 'step_blind',
+                'describe', 'clone_state', 'get_attr', 'call_method',
+                'observation_roi', 'step_multi', 'render_text',
+                'screenshot', 'lease']
+    if info.session_token:
+        features.append('session')
+    for name in ['universe', 'retro', 'envpool', 'sb3', 'dm_control', 'unity',
+                 'pettingzoo']:
+        if getattr(info, name):
+            features.append(name)
+    return features
 
-def handshake(sock):
+def handshake(sock, pool, session_token):
     """
     Perform the initial handshake and return the resulting
-    Gym environment.
+    list of Gym environments and the handshake flags.
+
+    The list has one element, unless the client asked for a
+    multiplexed connection.
+
+    The session_token is sent to clients which request a
+    resumable session. It is empty if the server cannot
+    resume sessions.
     """
     flags = proto.read_flags(sock)
-    if flags != 0:
+    if flags & ~proto.SUPPORTED_FLAGS != 0:
         raise proto.ProtoException('unsupported flags: ' + str(flags))
+    if flags & proto.FLAG_BATCH and flags & proto.FLAG_MULTI:
+        raise proto.ProtoException('cannot multiplex batched environments')
+    if flags & proto.FLAG_SESSION and session_token == '':
+        proto.read_field_str(sock)
+        proto.write_error(sock, proto.ERROR_UNSUPPORTED, 'server does not support sessions')
+        sock.flush()
+        raise proto.ProtoException('sessions are not supported')
     env_name = proto.read_field_str(sock)
+    batch_size = 0
+    if flags & proto.FLAG_BATCH:
+        batch_size = proto.read_uint32(sock)
+    num_envs = 1
+    if flags & proto.FLAG_MULTI:
+        num_envs = proto.read_uint32(sock)
 
     # Special no-environment mode.
     if env_name == '':
         proto.write_field_str(sock, '')
+        write_session_token(sock, flags, session_token)
         sock.flush()
-        return None
+        return [None] * num_envs, flags
 
+    LOGGER.info('creating %s (flags=%d, batch_size=%d, num_envs=%d)', env_name,
+                flags, batch_size, num_envs)
+    envs = []
     try:
-        env = gym.make(env_name)
+        if batch_size > 0:
+            auto_reset = (flags & proto.FLAG_AUTO_RESET) != 0
+            envs.append(pool.make_batch(env_name, batch_size, auto_reset))
+        else:
+            for _ in range(num_envs):
+                envs.append(pool.make(env_name))
         proto.write_field_str(sock, '')
+        write_session_token(sock, flags, session_token)
         sock.flush()
-        return env
-    except gym.error.Error as gym_exc:
-        proto.write_field_str(sock, str(gym_exc))
+        return envs, flags
+    except (gym.error.Error, envpool_plugin.EnvPoolException) as exc:
+        for env in envs:
+            env.close()
+        LOGGER.error('failed to create %s: %s', env_name, exc)
+        code = proto.ERROR_MAKE_FAILED
+        if isinstance(exc, UNKNOWN_ENV_ERRORS):
+            code = proto.ERROR_UNKNOWN_ENV
+        proto.write_error(sock, code, str(exc), exc)
         sock.flush()
-        raise gym_exc
+        raise exc
+
+def write_session_token(sock, flags, session_token):
+    """
+    Send the session token if the client asked for one.
+    """
+    if flags & proto.FLAG_SESSION:
+        proto.write_field_str(sock, session_token)
+
+def resume_session(sock, reader, info):
+    """
+    Close a lost connection and wait for the client to
+    resume the session on a new one.
+
+    Returns a (sock, reader) tuple for the new connection.
+    Raises a ProtoException if the session expires first.
+    """
+    try:
+        sock.close()
+    except (IOError, ValueError):
+        pass
+    control = socket.fromfd(info.control_fd, socket.AF_UNIX, socket.SOCK_STREAM)
+    # The lease keeps running while the client is away.
+    timeout = info.session_ttl
+    if reader.lease_remaining() is not None:
+        timeout = min(timeout, reader.lease_remaining())
+    try:
+        new_fd = session.recv_fd(control, timeout)
+    finally:
+        control.close()
+    if new_fd is None:
+        raise proto.ProtoException('session expired')
+    LOGGER.info('resuming session')
+    new_reader = proto.IdleReader(new_fd, reader.timeout, wake_fd=info.control_fd)
+    new_reader.lease_ttl = reader.lease_ttl
+    new_reader.lease_deadline = reader.lease_deadline
+    new_sock = io.BufferedRWPair(new_reader, io.open(new_fd, 'wb', buffering=0))
+
+    # The server only peeked at the resume handshake.
+    proto.read_flags(new_sock)
+    if proto.read_field_str(new_sock) != info.session_token:
+        raise proto.ProtoException('wrong session token')
+    proto.write_field_str(new_sock, '')
+    new_sock.flush()
+    return new_sock, new_reader
 
-def loop(sock, uni, env):
+def loop(sock, uni, retro, envs, flags, reader, features):
     """
     Handle commands from the client as they come in and
-    apply them to the given Gym environment.
+    apply them to the given Gym environments.
+
+    The features are reported by Ping packets.
+
+    Returns when the client ends its session.
     """
+    auto_reset = (flags & proto.FLAG_AUTO_RESET) != 0
+    multi = (flags & proto.FLAG_MULTI) != 0
     while True:
+        env_id = 0
+        if multi:
+            env_id = proto.read_env_id(sock, len(envs))
+        env = envs[env_id]
         pack_type = proto.read_packet_type(sock)
-        if pack_type == 'reset':
-            handle_reset(sock, env)
-        elif pack_type == 'step':
-            handle_step(sock, env)
-        elif pack_type == 'get_space':
-            handle_get_space(sock, env)
-        elif pack_type == 'sample_action':
-            handle_sample_action(sock, env)
-        elif pack_type == 'monitor':
-            env = handle_monitor(sock, env)
-        elif pack_type == 'render':
-            handle_render(env)
-        elif pack_type == 'upload':
-            handle_upload(sock)
-        elif pack_type == 'universe_configure':
-            env = handle_universe_configure(sock, uni, env)
-        elif pack_type == 'universe_wrap':
-            env = handle_universe_wrap(sock, uni, env)
-        elif pack_type == 'retro_configure':
-            env = handle_retro_configure(sock, retro, env)
-        elif pack_type == 'retro_wrap':
-            env = handle_retro_wrap(sock, retro, env)
+        LOGGER.debug('%s packet for %s', pack_type, describe_env(env, env_id))
+        try:
+            if pack_type == 'reset':
+                handle_reset(sock, env)
+            elif pack_type == 'step':
+                handle_step(sock, env, auto_reset)
+            elif pack_type == 'step_blind':
+                handle_step(sock, env, auto_reset, blind=True)
+            elif pack_type == 'step_multi':
+                handle_step_multi(sock, env)
+            elif pack_type == 'get_space':
+                handle_get_space(sock, env)
+            elif pack_type == 'sample_action':
+                handle_sample_action(sock, env)
+            elif pack_type == 'monitor':
+                env = handle_monitor(sock, env)
+            elif pack_type == 'render':
+                handle_render(env)
+            elif pack_type == 'render_text':
+                handle_render_text(sock, env)
+            elif pack_type == 'screenshot':
+                handle_screenshot(sock, env)
+            elif pack_type == 'upload':
+                handle_upload(sock)
+            elif pack_type == 'universe_configure':
+                env = handle_universe_configure(sock, uni, env)
+            elif pack_type == 'universe_wrap':
+                env = handle_universe_wrap(sock, uni, env)
+            elif pack_type == 'retro_configure':
+                env = handle_retro_configure(sock, retro, env)
+            elif pack_type == 'retro_wrap':
+                env = handle_retro_wrap(sock, retro, env)
+            elif pack_type == 'reset_batch':
+                handle_reset_batch(sock, env)
+            elif pack_type == 'step_batch':
+                handle_step_batch(sock, env)
+            elif pack_type == 'ping':
+                handle_ping(sock, env, reader, features)
+            elif pack_type == 'keep_alive':
+                reader.timeout = None
+            elif pack_type == 'renew_lease':
+                handle_renew_lease(sock, reader)
+            elif pack_type == 'configure':
+                handle_configure(sock, env)
+            elif pack_type == 'set_log_level':
+                handle_set_log_level(sock)
+            elif pack_type == 'clone_state':
+                handle_clone_state(sock, env)
+            elif pack_type == 'restore_state':
+                handle_restore_state(sock, env)
+            elif pack_type == 'list_envs':
+                handle_list_envs(sock)
+            elif pack_type == 'spec':
+                handle_spec(sock)
+            elif pack_type == 'describe':
+                handle_describe(sock, env)
+            elif pack_type == 'get_attr':
+                handle_get_attr(sock, env)
+            elif pack_type == 'call_method':
+                handle_call_method(sock, env)
+            elif pack_type == 'retro_list_games':
+                handle_retro_list_games(sock, retro)
+            elif pack_type == 'retro_list_states':
+                handle_retro_list_states(sock, retro)
+            elif pack_type == 'retro_save_state':
+                handle_retro_save_state(sock, retro, env)
+            elif pack_type == 'retro_load_state':
+                handle_retro_load_state(sock, retro, env)
+            elif pack_type == 'retro_variables':
+                handle_retro_variables(sock, retro, env)
+            elif pack_type == 'retro_read_ram':
+                handle_retro_read_ram(sock, retro, env)
+            elif pack_type == 'retro_start_movie':
+                handle_retro_start_movie(sock, retro, env)
+            elif pack_type == 'retro_stop_movie':
+                handle_retro_stop_movie(sock, retro, env)
+            elif pack_type == 'retro_upload_integration':
+                handle_retro_upload_integration(sock, retro)
+            elif pack_type == 'universe_allocate_remotes':
+                handle_universe_allocate_remotes(sock, uni)
+            elif pack_type == 'universe_list_remotes':
+                handle_universe_list_remotes(sock, uni)
+            elif pack_type == 'universe_release_remotes':
+                handle_universe_release_remotes(sock, uni)
+            elif pack_type == 'end_session':
+                return
+        except proto.ProtoException as exc:
+            if str(exc) == 'EOF':
+                raise
+            raise proto.ProtoException('%s packet for %s: %s' %
+                                       (pack_type, describe_env(env, env_id), exc))
+        # pylint: disable=W0703
+        except Exception:
+            LOGGER.exception('%s packet for %s failed', pack_type,
+                             describe_env(env, env_id))
+            raise
+        envs[env_id] = env
+
+def describe_env(env, env_id):
+    """
+    Get a human-readable description of an environment for
+    log and error messages.
+    """
+    spec = getattr(env, 'spec', None)
+    name = getattr(spec, 'id', None) or type(env).__name__
+    return '%s (index %d)' % (name, env_id)
 
 def handle_reset(sock, env):
     """
     Reset the environment and send the result.
     """
-    proto.write_obs(sock, env, env.reset())
+    try:
+        obs = env.reset()
+    # pylint: disable=W0703
+    except Exception as exc:
+        send_env_failure(sock, 'reset', exc)
+        return
+    proto.write_obs(sock, env, obs)
     sock.flush()
 
-def handle_step(sock, env):
+def handle_step(sock, env, auto_reset, blind=False):
     """
     Step the environment and send the result.
+
+    If auto_reset is set, finished episodes are reset right
+    away and the final observation is stored in the info.
+
+    If blind is set, the observation is only sent at the
+    end of an episode, saving bandwidth for steps whose
+    observations the client would ignore.
     """
     action = proto.read_action(sock, env)
-    obs, rew, done, info = env.step(action)
-    # print('GML: obs=%s, rew=%s, done=%s, info=%s' % (obs, rew, done, info))
-    proto.write_obs(sock, env, obs)
+    try:
+        obs, rew, done, info = env.step(action)
+        # print('GML: obs=%s, rew=%s, done=%s, info=%s' % (obs, rew, done, info))
+        if auto_reset and done:
+            if isinstance(info, dict):
+                info['terminal_observation'] = obs
+            obs = env.reset()
+    # pylint: disable=W0703
+    except Exception as exc:
+        send_env_failure(sock, 'step', exc)
+        return
+    if blind and not done:
+        proto.write_obs_none(sock)
+    else:
+        proto.write_obs(sock, env, obs)
     proto.write_reward(sock, rew)
     proto.write_bool(sock, done)
+    proto.write_field_str(sock, dump_info(env, info))
+    sock.flush()
+
+def handle_step_multi(sock, env):
+    """
+    Step a multi-agent environment with a map from agents
+    to actions, and send the per-agent results.
+
+    The environment must have a step_multi method, like
+    the PettingZoo environments. Server-side Gym wrappers
+    are bypassed.
+    """
+    actions_json = proto.read_field_str(sock)
+    step_multi = getattr(env.unwrapped, 'step_multi', None)
+    if not callable(step_multi):
+        proto.write_error(sock, proto.ERROR_UNSUPPORTED,
+                          'environment is not multi-agent')
+        sock.flush()
+        return
     try:
-        dumped_info = json.dumps(info)
-    except TypeError:
-        dumped_info = '{}'
-    proto.write_field_str(sock, dumped_info)
+        actions = json.loads(actions_json)
+        if not isinstance(actions, dict):
+            raise ValueError('actions must be an object')
+    except ValueError as exc:
+        proto.write_error(sock, proto.ERROR_INVALID_ARGUMENT, str(exc), exc)
+        sock.flush()
+        return
+    try:
+        actions = {agent: rpc.from_json(action) for agent, action in actions.items()}
+        observations, rewards, dones, infos = step_multi(actions)
+        result = {
+            'observations': rpc.to_json(observations),
+            'rewards': rpc.to_json(rewards),
+            'dones': rpc.to_json(dones),
+            'agents': rpc.to_json(getattr(env.unwrapped, 'agents', list(dones)))
+        }
+        try:
+            result['infos'] = rpc.to_json(infos)
+        except rpc.RPCException:
+            result['infos'] = {}
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(result))
+    # pylint: disable=W0703
+    except Exception as exc:
+        LOGGER.exception('failed to step environment')
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_reset_batch(sock, env):
+    """
+    Reset a batch of environments and send the result.
+    """
+    try:
+        obses = env.reset()
+    # pylint: disable=W0703
+    except Exception as exc:
+        send_env_failure(sock, 'reset', exc)
+        return
+    for obs in obses:
+        proto.write_obs(sock, env, obs)
+    sock.flush()
+
+def handle_step_batch(sock, env):
+    """
+    Step a batch of environments and send the result.
+    """
+    num_actions = proto.read_uint32(sock)
+    if num_actions != env.num_envs:
+        raise proto.ProtoException('expected %d actions but got %d' %
+                                   (env.num_envs, num_actions))
+    actions = [proto.read_action(sock, env) for _ in range(num_actions)]
+    try:
+        obses, rews, dones, infos = env.step(actions)
+    # pylint: disable=W0703
+    except Exception as exc:
+        send_env_failure(sock, 'step', exc)
+        return
+    for obs in obses:
+        proto.write_obs(sock, env, obs)
+    for rew in rews:
+        proto.write_reward(sock, rew)
+    for done in dones:
+        proto.write_bool(sock, done)
+    dumped_infos = [dump_info(env, info) for info in infos]
+    proto.write_field_str(sock, '[' + ','.join(dumped_infos) + ']')
     sock.flush()
 
+def send_env_failure(sock, action, exc):
+    """
+    Report an exception from an environment to the client
+    in place of the observation it expected.
+
+    The environment is kept, so that the client may try to
+    recover (e.g. by resetting it).
+    """
+    LOGGER.exception('failed to %s environment', action)
+    proto.write_obs_error(sock, proto.ERROR_ENV_FAILED, str(exc) or type(exc).__name__,
+                          exc)
+    sock.flush()
+
+def dump_info(env, info):
+    """
+    Encode an info object as JSON.
+
+    Terminal observations from auto-resets are converted to
+    JSON using the observation space.
+    """
+    if isinstance(info, dict) and 'terminal_observation' in info:
+        info = dict(info)
+        terminal_obs = configure.crop_observation(env, info['terminal_observation'])
+        info['terminal_observation'] = proto.to_jsonable(env.observation_space, terminal_obs)
+    try:
+        return json.dumps(info)
+    except TypeError:
+        return '{}'
+
 def handle_get_space(sock, env):
     """
     Get information about the action or observation space.
@@ -139,7 +527,7 @@ def handle_get_space(sock, env):
     if space_id == 'action':
         proto.write_space(sock, env.action_space)
     elif space_id == 'observation':
-        proto.write_space(sock, env.observation_space)
+        proto.write_space(sock, configure.crop_space(env, env.observation_space))
     sock.flush()
 
 def handle_sample_action(sock, env):
@@ -168,7 +556,7 @@ def handle_monitor(sock, env):
         sock.flush()
         return res
     except gym.error.Error as exc:
-        proto.write_field_str(sock, str(exc))
+        proto.write_error(sock, proto.ERROR_MONITOR_FAILED, str(exc), exc)
         sock.flush()
         return env
 
@@ -178,6 +566,65 @@ def handle_render(env):
     """
     env.render()
 
+def handle_render_text(sock, env):
+    """
+    Render the environment as text and send the result.
+    """
+    try:
+        text = render_ansi(env)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+        sock.flush()
+        return
+    if text is None:
+        proto.write_error(sock, proto.ERROR_UNSUPPORTED,
+                          'environment does not support ansi rendering')
+    else:
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, text)
+    sock.flush()
+
+def handle_screenshot(sock, env):
+    """
+    Render the environment as a PNG and send the result.
+    """
+    try:
+        data = screenshot.take_screenshot(env)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+        sock.flush()
+        return
+    if data is None:
+        proto.write_error(sock, proto.ERROR_UNSUPPORTED,
+                          'environment does not support rgb_array rendering')
+    else:
+        proto.write_field_str(sock, '')
+        proto.write_field(sock, data)
+    sock.flush()
+
+def render_ansi(env):
+    """
+    Render an environment with the 'ansi' mode, returning
+    the text or None if the mode is not supported.
+
+    Environments which take their render mode when they are
+    made only render text if they were made with 'ansi'.
+    """
+    if getattr(env, 'render_mode', None) == 'ansi':
+        out = env.render()
+    else:
+        modes = env.metadata.get('render.modes', env.metadata.get('render_modes', []))
+        if 'ansi' not in modes:
+            return None
+        out = env.render(mode='ansi')
+    if out is None:
+        return None
+    if hasattr(out, 'getvalue'):
+        out = out.getvalue()
+    return str(out)
+
 def handle_upload(sock):
     """
     Upload a monitor to the Gym website.
@@ -191,7 +638,179 @@ def handle_upload(sock):
         gym.upload(dir_path, api_key=api_key, algorithm_id=alg_id)
         proto.write_field_str(sock, '')
     except gym.error.Error as exc:
-        proto.write_field_str(sock, str(exc))
+        proto.write_error(sock, proto.ERROR_UPLOAD_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_ping(sock, env, reader, features):
+    """
+    Send basic status information, including the optional
+    features that the server supports.
+    """
+    spec = getattr(env, 'spec', None)
+    status = {
+        'env_name': getattr(spec, 'id', ''),
+        'pid': os.getpid(),
+        'python_version': sys.version.split(' ')[0],
+        'gym_version': gym.__version__,
+        'idle_ttl': reader.timeout or 0,
+        'lease_ttl': reader.lease_ttl or 0,
+        'features': features
+    }
+    proto.write_field_str(sock, json.dumps(status))
+    sock.flush()
+
+def handle_renew_lease(sock, reader):
+    """
+    Extend the connection's lease, after which the server
+    reclaims its environments.
+    """
+    ttl = proto.read_float64(sock)
+    if math.isnan(ttl) or ttl < 0:
+        proto.write_error(sock, proto.ERROR_INVALID_ARGUMENT,
+                          'invalid lease TTL: %g' % ttl)
+    else:
+        reader.renew_lease(ttl)
+        proto.write_field_str(sock, '')
+    sock.flush()
+
+def handle_set_log_level(sock):
+    """
+    Change the logging verbosity for the connection.
+    """
+    level_name = proto.read_field_str(sock)
+    if level_name in LOG_LEVELS:
+        LOGGER.setLevel(LOG_LEVELS[level_name])
+        proto.write_field_str(sock, '')
+    else:
+        proto.write_error(sock, proto.ERROR_INVALID_ARGUMENT,
+                          'unknown log level: ' + level_name)
+    sock.flush()
+
+def handle_configure(sock, env):
+    """
+    Configure a plain Gym environment.
+    """
+    config_json = proto.read_field_str(sock)
+    try:
+        configure.configure(env, json.loads(config_json))
+        proto.write_field_str(sock, '')
+    except configure.ConfigureException as exc:
+        proto.write_error(sock, proto.ERROR_CONFIGURE_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_clone_state(sock, env):
+    """
+    Save the state of an environment.
+    """
+    try:
+        state = snapshot.clone_state(env)
+        proto.write_field_str(sock, '')
+        proto.write_field(sock, state)
+    except snapshot.SnapshotException as exc:
+        proto.write_error(sock, proto.ERROR_UNSUPPORTED, str(exc), exc)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_restore_state(sock, env):
+    """
+    Restore a state saved by handle_clone_state.
+    """
+    state = proto.read_field(sock)
+    try:
+        snapshot.restore_state(env, state)
+        proto.write_field_str(sock, '')
+    except snapshot.SnapshotException as exc:
+        proto.write_error(sock, proto.ERROR_INVALID_ARGUMENT, str(exc), exc)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_list_envs(sock):
+    """
+    Send the IDs of the registered environments.
+    """
+    try:
+        env_ids = registry.list_envs()
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(env_ids))
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_UNSUPPORTED,
+                          'cannot list environments: ' + str(exc), exc)
+    sock.flush()
+
+def handle_spec(sock):
+    """
+    Describe a registered environment.
+    """
+    env_name = proto.read_field_str(sock)
+    try:
+        spec = registry.describe_env(env_name)
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(spec, default=str))
+    except UNKNOWN_ENV_ERRORS as exc:
+        proto.write_error(sock, proto.ERROR_UNKNOWN_ENV, str(exc), exc)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_MAKE_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_describe(sock, env):
+    """
+    Describe the connection's environment, including both
+    of its spaces.
+    """
+    try:
+        spec = registry.describe_live_env(env)
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(spec, default=str))
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_get_attr(sock, env):
+    """
+    Send an attribute of an environment.
+    """
+    name = proto.read_field_str(sock)
+    try:
+        value = rpc.get_attr(env, name)
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(value))
+    except rpc.RPCException as exc:
+        proto.write_error(sock, proto.ERROR_INVALID_ARGUMENT, str(exc), exc)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_call_method(sock, env):
+    """
+    Call a method of an environment and send the result.
+    """
+    name = proto.read_field_str(sock)
+    args_json = proto.read_field_str(sock)
+    try:
+        args = json.loads(args_json)
+        if not isinstance(args, list):
+            raise ValueError('arguments must be an array')
+    except ValueError as exc:
+        proto.write_error(sock, proto.ERROR_INVALID_ARGUMENT, str(exc), exc)
+        sock.flush()
+        return
+    try:
+        result = rpc.call_method(env, name, args)
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(result))
+    except rpc.RPCException as exc:
+        proto.write_error(sock, proto.ERROR_INVALID_ARGUMENT, str(exc), exc)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
     sock.flush()
 
 def handle_universe_configure(sock, uni, env):
@@ -203,7 +822,7 @@ def handle_universe_configure(sock, uni, env):
         env = uni.configure(env, json.loads(config_json))
         proto.write_field_str(sock, '')
     except universe_plugin.UniverseException as exc:
-        proto.write_field_str(sock, str(exc))
+        proto.write_error(sock, proto.ERROR_UNIVERSE_FAILED, str(exc), exc)
     sock.flush()
     return env
 
@@ -217,10 +836,48 @@ def handle_universe_wrap(sock, uni, env):
         env = uni.wrap(env, wrapper_name, json.loads(config_json))
         proto.write_field_str(sock, '')
     except universe_plugin.UniverseException as exc:
-        proto.write_field_str(sock, str(exc))
+        proto.write_error(sock, proto.ERROR_UNIVERSE_FAILED, str(exc), exc)
     sock.flush()
     return env
 
+def handle_universe_allocate_remotes(sock, uni):
+    """
+    Start Docker remotes for Universe environments.
+    """
+    num_remotes = proto.read_uint32(sock)
+    image = proto.read_field_str(sock)
+    try:
+        remotes = uni.allocate_remotes(num_remotes, image)
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(remotes))
+    except universe_plugin.UniverseException as exc:
+        proto.write_error(sock, proto.ERROR_UNIVERSE_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_universe_list_remotes(sock, uni):
+    """
+    Describe the allocated Docker remotes.
+    """
+    try:
+        remotes = uni.list_remotes()
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(remotes))
+    except universe_plugin.UniverseException as exc:
+        proto.write_error(sock, proto.ERROR_UNIVERSE_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_universe_release_remotes(sock, uni):
+    """
+    Stop and remove Docker remotes.
+    """
+    ids_json = proto.read_field_str(sock)
+    try:
+        uni.release_remotes(json.loads(ids_json))
+        proto.write_field_str(sock, '')
+    except universe_plugin.UniverseException as exc:
+        proto.write_error(sock, proto.ERROR_UNIVERSE_FAILED, str(exc), exc)
+    sock.flush()
+
 def handle_retro_configure(sock, retro, env):
     """
     Configure a Retro environment.
@@ -230,7 +887,7 @@ def handle_retro_configure(sock, retro, env):
         env = retro.configure(env, json.loads(config_json))
         proto.write_field_str(sock, '')
     except retro_plugin.RetroException as exc:
-        proto.write_field_str(sock, str(exc))
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
     sock.flush()
     return env
 
@@ -244,15 +901,138 @@ def handle_retro_wrap(sock, retro, env):
         env = retro.wrap(env, wrapper_name, json.loads(config_json))
         proto.write_field_str(sock, '')
     except retro_plugin.RetroException as exc:
-        proto.write_field_str(sock, str(exc))
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
     sock.flush()
     return env
 
-def log(msg):
+def handle_retro_list_games(sock, retro):
+    """
+    Send the names of the installed Retro games.
+    """
+    try:
+        games = retro.list_games()
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(games))
+    except retro_plugin.RetroException as exc:
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_retro_list_states(sock, retro):
+    """
+    Send the names of a Retro game's start states.
+    """
+    game = proto.read_field_str(sock)
+    try:
+        states = retro.list_states(game)
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(states))
+    except retro_plugin.RetroException as exc:
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_retro_save_state(sock, retro, env):
+    """
+    Save the emulator state of a Retro game.
+    """
+    try:
+        state = retro.save_state(env)
+        proto.write_field_str(sock, '')
+        proto.write_field(sock, state)
+    except retro_plugin.RetroException as exc:
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_retro_load_state(sock, retro, env):
+    """
+    Load an emulator state into a Retro game.
+    """
+    state = proto.read_field(sock)
+    try:
+        retro.load_state(env, state)
+        proto.write_field_str(sock, '')
+    except retro_plugin.RetroException as exc:
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_retro_variables(sock, retro, env):
+    """
+    Send the data.json variables of a Retro game.
+    """
+    try:
+        variables = retro.variables(env)
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, json.dumps(variables))
+    except retro_plugin.RetroException as exc:
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_retro_read_ram(sock, retro, env):
     """
-    Log logs a message to the console.
+    Send a range of a Retro game's RAM.
     """
-    sys.stderr.write(msg + '\n')
+    offset = proto.read_uint32(sock)
+    size = proto.read_uint32(sock)
+    try:
+        data = retro.read_ram(env, offset, size)
+        proto.write_field_str(sock, '')
+        proto.write_field(sock, data)
+    except retro_plugin.RetroException as exc:
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
+    # pylint: disable=W0703
+    except Exception as exc:
+        proto.write_error(sock, proto.ERROR_ENV_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_retro_start_movie(sock, retro, env):
+    """
+    Start recording a movie of a Retro game.
+    """
+    path = proto.read_field_str(sock)
+    try:
+        path = retro.start_movie(env, path)
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, path)
+    except retro_plugin.RetroException as exc:
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_retro_stop_movie(sock, retro, env):
+    """
+    Stop recording a movie of a Retro game.
+    """
+    try:
+        path = retro.stop_movie(env)
+        proto.write_field_str(sock, '')
+        proto.write_field_str(sock, path)
+    except retro_plugin.RetroException as exc:
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
+    sock.flush()
+
+def handle_retro_upload_integration(sock, retro):
+    """
+    Save a custom Retro game integration.
+    """
+    game = proto.read_field_str(sock)
+    num_files = proto.read_uint32(sock)
+    files = {}
+    for _ in range(num_files):
+        name = proto.read_field_str(sock)
+        files[name] = proto.read_field(sock)
+    try:
+        retro.add_integration(game, files)
+        proto.write_field_str(sock, '')
+    except retro_plugin.RetroException as exc:
+        proto.write_error(sock, proto.ERROR_RETRO_FAILED, str(exc), exc)
+    sock.flush()
 
 if __name__ == '__main__':
     main()
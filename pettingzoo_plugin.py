@@ -0,0 +1,244 @@
+"""
+APIs for PettingZoo multi-agent environments.
+"""
+
+import gym
+from gym import spaces
+
+import registry
+
+ENV_PREFIX = 'pettingzoo/'
+
+class PettingZoo:
+    """
+    PettingZoo registers the PettingZoo environments as Gym
+    environments.
+
+    An environment like 'butterfly/pistonball_v6' is
+    registered as 'pettingzoo/butterfly-pistonball-v6', so
+    that it can be made, listed, and described like any
+    other Gym environment.
+
+    Environments with a parallel API are wrapped with
+    PettingZooEnv, and turn-based environments, such as
+    the classic board and card games, are wrapped with
+    PettingZooAECEnv.
+    """
+    def __init__(self, enabled):
+        self.enabled = enabled
+        if enabled:
+            from pettingzoo.utils.all_modules import all_environments
+            register(all_environments)
+
+def register(environments):
+    """
+    Register a map of PettingZoo names to modules with Gym.
+    """
+    for name in sorted(environments):
+        family, _, base = name.rpartition('/')
+        base, _, version = base.rpartition('_v')
+        if not base or not version.isdigit():
+            continue
+        env_id = '%s%s-v%s' % (ENV_PREFIX, '-'.join(filter(None, [family, base])), version)
+        module = environments[name]
+        entry_point = 'pettingzoo_plugin:PettingZooEnv'
+        if not hasattr(module, 'parallel_env'):
+            entry_point = 'pettingzoo_plugin:PettingZooAECEnv'
+        registry.register_env(env_id, entry_point, {'module': module.__name__})
+
+class PettingZooEnv(gym.Env):
+    """
+    A Gym environment wrapping a PettingZoo parallel
+    environment.
+
+    As a plain Gym environment, actions and observations are
+    tuples with one entry per agent, in the order of
+    possible_agents, and the reward is the sum of the
+    agents' rewards.
+    Agents which are gone have None observations and ignore
+    their actions, and a step is done once every agent is.
+
+    Clients can use step_multi to exchange per-agent maps
+    instead.
+    """
+    metadata = {'render.modes': ['rgb_array', 'ansi']}
+
+    def __init__(self, module):
+        import importlib
+        self.env = importlib.import_module(module).parallel_env()
+        self.possible_agents = list(self.env.possible_agents)
+        self.action_space = spaces.Tuple([self.agent_action_space(agent)
+                                          for agent in self.possible_agents])
+        self.observation_space = spaces.Tuple([self.agent_observation_space(agent)
+                                               for agent in self.possible_agents])
+
+    @property
+    def agents(self):
+        """
+        Get the agents which are still active.
+        """
+        return list(self.env.agents)
+
+    def agent_action_space(self, agent):
+        """
+        Get the action space of an agent.
+        """
+        if callable(getattr(self.env, 'action_space', None)):
+            return self.env.action_space(agent)
+        return self.env.action_spaces[agent]
+
+    def agent_observation_space(self, agent):
+        """
+        Get the observation space of an agent.
+        """
+        if callable(getattr(self.env, 'observation_space', None)):
+            return self.env.observation_space(agent)
+        return self.env.observation_spaces[agent]
+
+    def reset(self):
+        """
+        Start a new episode.
+        """
+        result = self.env.reset()
+        if isinstance(result, tuple) and len(result) == 2:
+            result = result[0]
+        return self._obs_tuple(result)
+
+    def step(self, action):
+        """
+        Take a step with a tuple of actions.
+        """
+        live = set(self.env.agents)
+        actions = {agent: act for agent, act in zip(self.possible_agents, action)
+                   if agent in live}
+        observations, rewards, dones, infos = self.step_multi(actions)
+        return (self._obs_tuple(observations), sum(rewards.values()),
+                not self.env.agents, infos)
+
+    def step_multi(self, actions):
+        """
+        Take a step with a map from agents to actions.
+
+        Returns maps from agents to observations, rewards,
+        dones, and infos.
+        Done is true for agents which were terminated or
+        truncated.
+        """
+        result = self.env.step(actions)
+        if len(result) == 5:
+            observations, rewards, terms, truncs, infos = result
+            dones = {agent: bool(terms.get(agent) or truncs.get(agent))
+                     for agent in set(terms) | set(truncs)}
+        else:
+            observations, rewards, dones, infos = result
+        return observations, rewards, dones, infos
+
+    def render(self, mode='rgb_array'):
+        """
+        Render the environment.
+        """
+        return self.env.render()
+
+    def close(self):
+        """
+        Close the environment.
+        """
+        self.env.close()
+
+    def _obs_tuple(self, observations):
+        return tuple(observations.get(agent) for agent in self.possible_agents)
+
+class PettingZooAECEnv(gym.Env):
+    """
+    A Gym environment wrapping a turn-based PettingZoo
+    environment, which uses the agent environment cycle
+    (AEC) API.
+
+    Each step is taken by the current agent, which is
+    found in the agent_selection attribute. An agent whose
+    turn comes after it is done must take a None action.
+    The spaces are those of the first agent, since the
+    agents of turn-based games share their spaces.
+
+    The results of reset and step are from the point of
+    view of the agent whose turn is next, like the results
+    of the AEC last() method: the reward is that agent's
+    reward since its previous turn, and the info holds its
+    name under 'agent' and whether it is done under
+    'agent_done'.
+    A step is done once every agent is.
+    """
+    metadata = {'render.modes': ['rgb_array', 'ansi']}
+
+    def __init__(self, module):
+        import importlib
+        self.env = importlib.import_module(module).env()
+        self.possible_agents = list(self.env.possible_agents)
+        first = self.possible_agents[0]
+        if callable(getattr(self.env, 'action_space', None)):
+            self.action_space = self.env.action_space(first)
+            self.observation_space = self.env.observation_space(first)
+        else:
+            self.action_space = self.env.action_spaces[first]
+            self.observation_space = self.env.observation_spaces[first]
+        self._last_obs = None
+
+    @property
+    def agents(self):
+        """
+        Get the agents which are still active.
+        """
+        return list(self.env.agents)
+
+    @property
+    def agent_selection(self):
+        """
+        Get the agent whose turn is next, or None once
+        every agent is done.
+        """
+        if not self.env.agents:
+            return None
+        return self.env.agent_selection
+
+    def reset(self):
+        """
+        Start a new episode.
+        """
+        self.env.reset()
+        obs, _, _ = self._last()
+        return obs
+
+    def step(self, action):
+        """
+        Take a step for the current agent.
+        """
+        self.env.step(action)
+        obs, reward, info = self._last()
+        return obs, reward, not self.env.agents, info
+
+    def render(self, mode='rgb_array'):
+        """
+        Render the environment.
+        """
+        return self.env.render()
+
+    def close(self):
+        """
+        Close the environment.
+        """
+        self.env.close()
+
+    def _last(self):
+        if not self.env.agents:
+            return self._last_obs, 0.0, {'agent': None, 'agent_done': True}
+        result = self.env.last()
+        if len(result) == 5:
+            obs, reward, term, trunc, info = result
+            done = term or trunc
+        else:
+            obs, reward, done, info = result
+        info = dict(info)
+        info['agent'] = self.env.agent_selection
+        info['agent_done'] = bool(done)
+        self._last_obs = obs
+        return obs, reward, info
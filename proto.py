@@ -2,17 +2,137 @@
 Low-level API for protocol-specific encoding/decoding.
 """
 
+import io
+import os
+import select
 import struct
 import json
+import time
+import traceback
 from gym import spaces
 import numpy as np
 
+import configure
+
+FLAG_BATCH = 1
+FLAG_AUTO_RESET = 2
+FLAG_MULTI = 4
+FLAG_STATS = 8
+FLAG_SESSION = 16
+FLAG_RESUME = 32
+FLAG_BINARY_ACTIONS = 64
+SUPPORTED_FLAGS = (FLAG_BATCH | FLAG_AUTO_RESET | FLAG_MULTI | FLAG_SESSION |
+                   FLAG_BINARY_ACTIONS)
+
+ACTION_JSON = 0
+ACTION_DISCRETE = 1
+ACTION_BOX = 2
+
+ERROR_UNKNOWN_ENV = 'unknown_env'
+ERROR_MAKE_FAILED = 'make_failed'
+ERROR_UNSUPPORTED = 'unsupported'
+ERROR_UNKNOWN_SESSION = 'unknown_session'
+ERROR_INVALID_ARGUMENT = 'invalid_argument'
+ERROR_MONITOR_FAILED = 'monitor_failed'
+ERROR_UPLOAD_FAILED = 'upload_failed'
+ERROR_CONFIGURE_FAILED = 'configure_failed'
+ERROR_UNIVERSE_FAILED = 'universe_failed'
+ERROR_RETRO_FAILED = 'retro_failed'
+ERROR_ENV_FAILED = 'env_failed'
+
+OBS_NONE = 2
+OBS_ERROR = 0xff
+
 class ProtoException(Exception):
     """
     Exception type used for all protocol-related errors.
     """
     pass
 
+class IdleException(ProtoException):
+    """
+    Exception raised when a client is idle for too long.
+    """
+    pass
+
+class LeaseException(IdleException):
+    """
+    Exception raised when a client does not renew its
+    lease in time.
+    """
+    pass
+
+class IdleReader(io.RawIOBase):
+    """
+    A raw reader for a file descriptor which raises an
+    IdleException if no data arrives within a timeout.
+
+    The timeout is in seconds, and may be changed at any
+    time. A timeout of None or 0 disables it.
+
+    Reads also raise a LeaseException once the client's
+    lease (see renew_lease) runs out, even if data is
+    still arriving.
+
+    If wake_fd is set, reads are interrupted with an EOF
+    as soon as wake_fd becomes readable.
+    """
+    def __init__(self, fd, timeout, wake_fd=None):
+        super(IdleReader, self).__init__()
+        self.fd = fd
+        self.timeout = timeout or None
+        self.wake_fd = wake_fd
+        self.lease_ttl = None
+        self.lease_deadline = None
+
+    def readable(self):
+        return True
+
+    def renew_lease(self, ttl):
+        """
+        Extend the lease to ttl seconds from now.
+
+        A ttl of 0 cancels the lease.
+        """
+        self.lease_ttl = ttl or None
+        self.lease_deadline = None
+        if ttl:
+            self.lease_deadline = time.time() + ttl
+
+    def lease_remaining(self):
+        """
+        Get the number of seconds left on the lease, or None
+        if there is no lease.
+        """
+        if self.lease_deadline is None:
+            return None
+        return max(0, self.lease_deadline - time.time())
+
+    def readinto(self, buf):
+        timeout = self.timeout
+        lease_limited = False
+        remaining = self.lease_remaining()
+        if remaining is not None:
+            if remaining == 0:
+                raise LeaseException('lease of %g seconds expired' % self.lease_ttl)
+            if timeout is None or remaining < timeout:
+                timeout = remaining
+                lease_limited = True
+        if timeout is not None or self.wake_fd is not None:
+            fds = [self.fd]
+            if self.wake_fd is not None:
+                fds.append(self.wake_fd)
+            ready, _, _ = select.select(fds, [], [], timeout)
+            if not ready and lease_limited:
+                raise LeaseException('lease of %g seconds expired' % self.lease_ttl)
+            if not ready:
+                raise IdleException('idle for more than %g seconds' % self.timeout)
+            if self.fd not in ready:
+                return 0
+        data = os.read(self.fd, len(buf))
+        buf[:len(data)] = data
+        return len(data)
+
 def read_byte(sock):
     """
     Read a byte from the socket.
@@ -28,6 +148,24 @@ def read_flags(sock):
     """
     return read_byte(sock)
 
+def read_uint32(sock):
+    """
+    Read a 32-bit unsigned integer from the socket.
+    """
+    data = sock.read(4)
+    if len(data) != 4:
+        raise ProtoException('EOF')
+    return struct.unpack('<I', data)[0]
+
+def read_env_id(sock, num_envs):
+    """
+    Read the environment index of a multiplexed packet.
+    """
+    env_id = read_uint32(sock)
+    if env_id >= num_envs:
+        raise ProtoException('environment index out of range: ' + str(env_id))
+    return env_id
+
 def read_packet_type(sock):
     """
     Read packet type from the socket and turn it into a
@@ -37,7 +175,20 @@ def read_packet_type(sock):
     mapping = {0: 'reset', 1: 'step', 2: 'get_space', 3: 'sample_action',
                4: 'monitor', 5: 'render', 6: 'upload', 7: 'universe_configure',
                8: 'universe_wrap', 9: 'retro_configure',
-               10: 'retro_wrap'}
+               10: 'retro_wrap', 11: 'reset_batch', 12: 'step_batch',
+               13: 'ping', 14: 'keep_alive', 15: 'configure',
+               16: 'set_log_level', 17: 'end_session', 18: 'clone_state',
+               19: 'restore_state', 20: 'list_envs', 21: 'spec',
+               22: 'get_attr', 23: 'call_method', 24: 'retro_list_games',
+               25: 'retro_list_states', 26: 'retro_save_state',
+               27: 'retro_load_state', 28: 'retro_variables',
+               29: 'retro_read_ram', 30: 'retro_start_movie',
+               31: 'retro_stop_movie', 32: 'retro_upload_integration',
+               33: 'universe_allocate_remotes', 34: 'universe_list_remotes',
+               35: 'universe_release_remotes', 36: 'step_blind',
+               37: 'describe', 38: 'step_multi',
+               39: 'render_text', 40: 'screenshot',
+               41: 'renew_lease'}
     if not type_id in mapping.keys():
         raise ProtoException('unknown packet type: ' + str(type_id))
     return mapping[type_id]
@@ -69,6 +220,33 @@ def write_field(sock, field):
     sock.write(struct.pack('<I', len(field)))
     sock.write(field)
 
+def write_error(sock, code, message, exc=None):
+    """
+    Write a non-empty error field.
+
+    The code is one of the ERROR_* constants, allowing
+    clients to tell errors apart.
+
+    If the error was caused by an exception, exc should be
+    the exception, and this should be called from the
+    except block which caught it, so that the traceback
+    can be sent.
+    """
+    obj = {'code': code, 'message': message}
+    if exc is not None:
+        obj['type'] = exception_type(exc)
+        obj['traceback'] = traceback.format_exc()
+    write_field_str(sock, json.dumps(obj))
+
+def exception_type(exc):
+    """
+    Get the qualified name of an exception's class.
+    """
+    cls = type(exc)
+    if cls.__module__ in ['builtins', 'exceptions']:
+        return cls.__name__
+    return cls.__module__ + '.' + cls.__name__
+
 def write_field_str(sock, field):
     """
     Write a variable length string field.
@@ -80,6 +258,7 @@ def write_obs(sock, env, obs):
     Encode and send an observation.
     """
     if isinstance(obs, np.ndarray):
+        obs = configure.crop_observation(env, obs)
         if obs.dtype == 'uint8':
             write_obs_byte_list(sock, obs)
             return
@@ -109,12 +288,39 @@ def write_obs_byte_list(sock, arr):
     sock.write(header)
     sock.write(payload)
 
+def write_obs_none(sock):
+    """
+    Write a placeholder for an observation which the client
+    asked the server not to send.
+    """
+    sock.write(struct.pack('<B', OBS_NONE))
+
+def write_obs_error(sock, code, message, exc=None):
+    """
+    Write an error in place of an observation, indicating
+    that the request which should have produced the
+    observation failed.
+
+    See write_error() for details on the arguments.
+    """
+    sock.write(struct.pack('<B', OBS_ERROR))
+    write_error(sock, code, message, exc)
+
 def write_reward(sock, rew):
     """
     Write a reward value.
     """
     sock.write(struct.pack('<d', rew))
 
+def read_float64(sock):
+    """
+    Read a 64-bit floating point number.
+    """
+    data = sock.read(8)
+    if len(data) != 8:
+        raise ProtoException('EOF')
+    return struct.unpack('<d', data)[0]
+
 def read_bool(sock):
     """
     Read a boolean.
@@ -140,11 +346,44 @@ def read_action(sock, env):
     Read an action object.
     """
     type_id = read_byte(sock)
-    if type_id == 0:
+    if type_id == ACTION_JSON:
         obj = json.loads(read_field_str(sock))
         return from_jsonable(env.action_space, obj)
+    elif type_id == ACTION_DISCRETE:
+        action = read_uvarint(sock)
+        if isinstance(env.action_space, spaces.Discrete):
+            return action
+        return from_jsonable(env.action_space, action)
+    elif type_id == ACTION_BOX:
+        count = read_uint32(sock)
+        data = sock.read(count * 8)
+        if len(data) != count * 8:
+            raise ProtoException('EOF')
+        values = struct.unpack('<%dd' % count, data)
+        space = env.action_space
+        if isinstance(space, spaces.Box):
+            if count != int(np.prod(space.shape)):
+                raise ProtoException('expected %d action values but got %d' %
+                                     (int(np.prod(space.shape)), count))
+            return np.array(values, dtype=space.dtype).reshape(space.shape)
+        return from_jsonable(space, list(values))
     raise ProtoException('unknown action type: ' + str(type_id))
 
+def read_uvarint(sock):
+    """
+    Read an unsigned LEB128 varint.
+    """
+    result = 0
+    shift = 0
+    while True:
+        byte = read_byte(sock)
+        result |= (byte & 0x7f) << shift
+        if byte < 0x80:
+            return result
+        shift += 7
+        if shift > 63:
+            raise ProtoException('varint is too long')
+
 def write_action(sock, env, action):
     """
     Write an action object.
@@ -211,7 +450,12 @@ def read_space_id(sock):
 def from_jsonable(space, obj):
     """
     Decode a space element from JSON.
+
+    A null element is decoded as None, which turn-based
+    environments take as the action of a finished agent.
     """
+    if obj is None:
+        return None
     if isinstance(space, spaces.Tuple):
         return tuple(
             [from_jsonable(space, obj[i]) for i, space in enumerate(space.spaces)]
@@ -0,0 +1,86 @@
+"""
+Queries about the environments registered with Gym.
+"""
+
+import gym
+
+import configure
+import proto
+
+def list_envs():
+    """
+    Get the sorted IDs of the registered environments.
+    """
+    registry = gym.envs.registry
+    if hasattr(registry, 'all'):
+        specs = registry.all()
+    else:
+        specs = registry.values()
+    return sorted(spec.id for spec in specs)
+
+def describe_env(env_name):
+    """
+    Get a JSON-compatible description of a registered
+    environment, including its spaces.
+
+    This creates (and closes) an instance of the
+    environment. Errors from gym.make() are passed on.
+    """
+    spec = gym.spec(env_name)
+    env = gym.make(env_name)
+    try:
+        return spec_json(spec, env.action_space, env.observation_space)
+    finally:
+        env.close()
+
+def describe_live_env(env):
+    """
+    Get a JSON-compatible description of a running
+    environment, in the same format as describe_env().
+
+    The spaces are the environment's current spaces, so
+    they reflect any wrappers or configuration.
+    Environments without a spec have an empty ID.
+    """
+    obs_space = configure.crop_space(env, env.observation_space)
+    return spec_json(getattr(env, 'spec', None), env.action_space, obs_space)
+
+def spec_json(spec, action_space, observation_space):
+    """
+    Encode an environment spec and spaces as a
+    JSON-compatible object.
+
+    The spec may be None.
+    """
+    entry_point = getattr(spec, 'entry_point', None) or ''
+    if not isinstance(entry_point, str):
+        entry_point = '%s:%s' % (entry_point.__module__, entry_point.__name__)
+    max_steps = getattr(spec, 'max_episode_steps', None)
+    if max_steps is None:
+        max_steps = getattr(spec, 'timestep_limit', None)
+    kwargs = getattr(spec, 'kwargs', None)
+    if kwargs is None:
+        kwargs = getattr(spec, '_kwargs', None)
+    return {
+        'id': getattr(spec, 'id', None) or '',
+        'entry_point': entry_point,
+        'max_episode_steps': max_steps or 0,
+        'reward_threshold': getattr(spec, 'reward_threshold', None),
+        'nondeterministic': bool(getattr(spec, 'nondeterministic', False)),
+        'kwargs': kwargs or {},
+        'action_space': proto.space_json(action_space),
+        'observation_space': proto.space_json(observation_space)
+    }
+
+def register_env(env_id, entry_point, kwargs):
+    """
+    Register an environment with Gym, unless an environment
+    with the same ID is already registered.
+    """
+    try:
+        gym.spec(env_id)
+        return
+    except gym.error.Error:
+        pass
+    gym.envs.registration.register(id=env_id, entry_point=entry_point,
+                                   kwargs=kwargs)
@@ -2,6 +2,12 @@
 APIs for Retro-specific environment manipulations.
 """
 
+import gzip
+import io
+import os
+import shutil
+import tempfile
+
 import numpy as np
 import gym
 
@@ -14,12 +20,22 @@ class RetroException(Exception):
 class Retro:
     """
     Retro wraps access to OpenAI Retro.
+
+    If integrations_dir is not empty, custom game
+    integrations are loaded from it, and clients may upload
+    integrations to it.
     """
-    def __init__(self, enabled):
+    def __init__(self, enabled, integrations_dir=''):
         self.enabled = enabled
+        self.integrations_dir = integrations_dir
+        self.movie_paths = {}
         if enabled:
             import retro
             self.retro = retro
+            if integrations_dir:
+                if not os.path.isdir(integrations_dir):
+                    os.makedirs(integrations_dir)
+                self._data_module().Integrations.add_custom_path(integrations_dir)
 
     def wrap(self, env, wrapper_name, options):
         """
@@ -57,6 +73,158 @@ class Retro:
         wrappers = self.retro.wrappers
         return RetroEnv(wrappers.Unvectorize(wrappers.BlockingReset(env)))
 
+    def list_games(self):
+        """
+        Get the sorted names of the games with installed
+        ROMs.
+        """
+        self._check_enabled()
+        return sorted(self._data_module().list_games())
+
+    def list_states(self, game):
+        """
+        Get the sorted names of a game's start states.
+        """
+        self._check_enabled()
+        data = self._data_module()
+        if game not in data.list_games():
+            raise RetroException('unknown game: ' + game)
+        return sorted(data.list_states(game))
+
+    def save_state(self, env):
+        """
+        Save the emulator state of a Retro game.
+
+        The state is gzipped, like the .state files that
+        Retro uses for start states.
+        """
+        self._check_enabled()
+        buf = io.BytesIO()
+        with gzip.GzipFile(fileobj=buf, mode='wb') as out_file:
+            out_file.write(self._emulator(env).get_state())
+        return buf.getvalue()
+
+    def load_state(self, env, data):
+        """
+        Load an emulator state from save_state or from a
+        .state file.
+
+        Uncompressed states are also accepted.
+        """
+        self._check_enabled()
+        emulator = self._emulator(env)
+        if data[:2] == b'\x1f\x8b':
+            try:
+                with gzip.GzipFile(fileobj=io.BytesIO(data), mode='rb') as in_file:
+                    data = in_file.read()
+            except (IOError, EOFError) as exc:
+                raise RetroException('malformed state: ' + str(exc))
+        emulator.set_state(data)
+
+    def variables(self, env):
+        """
+        Get the current values of a Retro game's data.json
+        variables, such as its score and lives.
+        """
+        self._check_enabled()
+        data = getattr(env.unwrapped, 'data', None)
+        if data is None or not hasattr(data, 'lookup_all'):
+            raise RetroException('not a Retro game')
+        return {name: int(value) for name, value in data.lookup_all().items()}
+
+    def read_ram(self, env, offset, size):
+        """
+        Read a range of a Retro game's RAM.
+        """
+        self._check_enabled()
+        if not hasattr(env.unwrapped, 'get_ram'):
+            raise RetroException('not a Retro game')
+        ram = np.asarray(env.unwrapped.get_ram(), dtype='uint8')
+        if offset + size > len(ram):
+            raise RetroException('range [%d, %d) is outside of %d bytes of RAM' %
+                                 (offset, offset+size, len(ram)))
+        return ram[offset:offset+size].tobytes()
+
+    def start_movie(self, env, path):
+        """
+        Start recording a .bk2 movie of a Retro game.
+
+        If the path is empty, the movie is saved to a new
+        temporary file.
+        Returns the path of the movie.
+        """
+        self._check_enabled()
+        unwrapped = env.unwrapped
+        if not hasattr(unwrapped, 'record_movie'):
+            raise RetroException('not a Retro game')
+        if id(unwrapped) in self.movie_paths:
+            raise RetroException('already recording a movie')
+        if path == '':
+            fd, path = tempfile.mkstemp(prefix='gym-socket-api-', suffix='.bk2')
+            os.close(fd)
+        try:
+            unwrapped.record_movie(path)
+        except (IOError, RuntimeError) as exc:
+            raise RetroException('cannot record movie: ' + str(exc))
+        self.movie_paths[id(unwrapped)] = path
+        return path
+
+    def stop_movie(self, env):
+        """
+        Stop recording a movie and return its path.
+        """
+        self._check_enabled()
+        unwrapped = env.unwrapped
+        if id(unwrapped) not in self.movie_paths:
+            raise RetroException('not recording a movie')
+        unwrapped.stop_record()
+        return self.movie_paths.pop(id(unwrapped))
+
+    def add_integration(self, game, files):
+        """
+        Save a custom game integration, replacing any
+        previous integration for the game.
+
+        The files map file names, such as 'data.json', to
+        their contents.
+        """
+        self._check_enabled()
+        if not self.integrations_dir:
+            raise RetroException('server does not accept integrations')
+        for name in [game] + list(files.keys()):
+            if name in ('', '.', '..') or '/' in name or os.sep in name:
+                raise RetroException('invalid integration name: ' + repr(name))
+        tmp_dir = tempfile.mkdtemp(prefix='.upload-', dir=self.integrations_dir)
+        try:
+            os.chmod(tmp_dir, 0o755)
+            for name, data in files.items():
+                with open(os.path.join(tmp_dir, name), 'wb') as out_file:
+                    out_file.write(data)
+            game_dir = os.path.join(self.integrations_dir, game)
+            if os.path.exists(game_dir):
+                shutil.rmtree(game_dir)
+            os.rename(tmp_dir, game_dir)
+        except (IOError, OSError) as exc:
+            shutil.rmtree(tmp_dir, ignore_errors=True)
+            raise RetroException('cannot save integration: ' + str(exc))
+
+    @staticmethod
+    def _emulator(env):
+        emulator = getattr(env.unwrapped, 'em', None)
+        if emulator is None or not hasattr(emulator, 'get_state'):
+            raise RetroException('not a Retro game')
+        return emulator
+
+    def _data_module(self):
+        # Newer versions of Retro moved the game listings
+        # into retro.data.
+        data = getattr(self.retro, 'data', None)
+        if data is not None and hasattr(data, 'list_games'):
+            return data
+        if not hasattr(self.retro, 'list_games'):
+            raise RetroException('Retro cannot list games')
+        return self.retro
+
     def _check_enabled(self):
         if not self.enabled:
             raise RetroException('Retro is not enabled')
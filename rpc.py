@@ -0,0 +1,92 @@
+"""
+APIs for reading attributes and calling methods of
+environments on behalf of clients.
+
+Values are exchanged as JSON. Results are converted to
+JSON-compatible types, and arguments which are (nested)
+JSON arrays of numbers are passed as numpy arrays.
+"""
+
+import numpy as np
+
+class RPCException(Exception):
+    """
+    Exception type used for bad attribute names and
+    unencodable values.
+    """
+    pass
+
+def get_attr(env, name):
+    """
+    Get an attribute of an environment as a JSON-compatible
+    value.
+    """
+    return to_json(_lookup(env, name))
+
+def call_method(env, name, args):
+    """
+    Call a method of an environment and get the result as
+    a JSON-compatible value.
+
+    Errors raised by the method are passed on.
+    """
+    method = _lookup(env, name)
+    if not callable(method):
+        raise RPCException('not a method: ' + name)
+    return to_json(method(*[from_json(arg) for arg in args]))
+
+def to_json(value):
+    """
+    Convert a value to JSON-compatible types.
+    """
+    if value is None or isinstance(value, (bool, int, float, str)):
+        return value
+    elif isinstance(value, np.ndarray):
+        return value.tolist()
+    elif isinstance(value, np.generic):
+        return value.item()
+    elif isinstance(value, (list, tuple)):
+        return [to_json(elem) for elem in value]
+    elif isinstance(value, dict):
+        return {str(key): to_json(elem) for key, elem in value.items()}
+    raise RPCException('cannot encode value of type ' + type(value).__name__)
+
+def from_json(value):
+    """
+    Convert a decoded JSON argument for a method call.
+    """
+    if isinstance(value, list) and _numeric_shape(value) is not None:
+        return np.array(value)
+    elif isinstance(value, list):
+        return [from_json(elem) for elem in value]
+    elif isinstance(value, dict):
+        return {key: from_json(elem) for key, elem in value.items()}
+    return value
+
+def _numeric_shape(value):
+    """
+    Get the shape of a number or a non-empty, rectangular
+    array of numbers, or None for any other value.
+    """
+    if isinstance(value, (int, float)) and not isinstance(value, bool):
+        return ()
+    if not isinstance(value, list) or not value:
+        return None
+    shapes = set(_numeric_shape(elem) for elem in value)
+    if len(shapes) != 1 or None in shapes:
+        return None
+    return (len(value),) + shapes.pop()
+
+def _lookup(env, name):
+    """
+    Find an attribute on an environment or on the
+    environment it wraps.
+
+    Private attributes are off limits.
+    """
+    if name == '' or name.startswith('_'):
+        raise RPCException('invalid attribute name: ' + repr(name))
+    for target in (env, env.unwrapped):
+        if hasattr(target, name):
+            return getattr(target, name)
+    raise RPCException('no such attribute: ' + name)
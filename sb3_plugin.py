@@ -0,0 +1,93 @@
+"""
+APIs for batched environments backed by Stable-Baselines3
+vectorized environments.
+"""
+
+import numpy as np
+import gym
+
+from envpool_plugin import EnvPoolException
+
+VEC_ENV_KINDS = ['dummy', 'subproc']
+
+class VecEnvMaker:
+    """
+    VecEnvMaker creates batches backed by SB3 VecEnvs.
+
+    The kind is 'subproc' to step each environment in its
+    own process, or 'dummy' to step them one after another
+    in the handler process.
+    """
+    def __init__(self, kind):
+        if kind not in VEC_ENV_KINDS:
+            raise ValueError('unknown VecEnv kind: ' + kind)
+        from stable_baselines3.common import vec_env
+        if kind == 'subproc':
+            self.vec_env_cls = vec_env.SubprocVecEnv
+        else:
+            self.vec_env_cls = vec_env.DummyVecEnv
+
+    def make_batch(self, env_name, num_envs, auto_reset):
+        """
+        Create a batch of environments.
+
+        SB3 always resets environments as soon as they
+        finish an episode, so auto_reset must be set.
+        """
+        if not auto_reset:
+            raise EnvPoolException('SB3 batches require the auto-reset flag')
+        env_fns = [lambda: gym.make(env_name) for _ in range(num_envs)]
+        try:
+            env = self.vec_env_cls(env_fns)
+        # pylint: disable=W0703
+        except Exception as exc:
+            raise EnvPoolException('failed to make VecEnv: ' + str(exc))
+        return VecBatchEnv(env)
+
+class VecBatchEnv:
+    """
+    A batch of environments backed by an SB3 VecEnv.
+
+    Environments which finish an episode are reset right
+    away, and their final observations are stored in the
+    infos under 'terminal_observation'.
+    """
+    def __init__(self, env):
+        self.env = env
+        self.num_envs = env.num_envs
+        self.action_space = env.action_space
+        self.observation_space = env.observation_space
+
+    def reset(self):
+        """
+        Reset every environment and return a list of
+        observations.
+        """
+        return self.split_obs(self.env.reset())
+
+    def step(self, actions):
+        """
+        Step every environment and return lists of
+        observations, rewards, dones, and infos.
+        """
+        obs, rews, dones, infos = self.env.step(np.array(actions))
+        return (self.split_obs(obs), np.asarray(rews).tolist(),
+                np.asarray(dones).tolist(), [dict(info) for info in infos])
+
+    def split_obs(self, obs):
+        """
+        Split stacked VecEnv observations into a list with
+        one observation per environment.
+        """
+        if isinstance(obs, dict):
+            return [{key: value[i] for key, value in obs.items()}
+                    for i in range(self.num_envs)]
+        if isinstance(obs, tuple):
+            return [tuple(value[i] for value in obs) for i in range(self.num_envs)]
+        return list(obs)
+
+    def close(self):
+        """
+        Close the environments.
+        """
+        self.env.close()
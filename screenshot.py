@@ -0,0 +1,63 @@
+"""
+APIs for taking PNG screenshots of environments.
+
+The PNG encoder is built in, so that screenshots do not
+depend on an imaging library.
+"""
+
+import struct
+import zlib
+
+import numpy as np
+
+# PNG color types for each number of channels.
+COLOR_TYPES = {1: 0, 2: 4, 3: 2, 4: 6}
+
+def take_screenshot(env):
+    """
+    Render an environment with the 'rgb_array' mode and
+    encode the frame as a PNG.
+
+    Returns None if the mode is not supported.
+    Environments which take their render mode when they are
+    made only render frames if they were made with
+    'rgb_array'.
+    """
+    if getattr(env, 'render_mode', None) == 'rgb_array':
+        frame = env.render()
+    else:
+        modes = env.metadata.get('render.modes', env.metadata.get('render_modes', []))
+        if 'rgb_array' not in modes:
+            return None
+        frame = env.render(mode='rgb_array')
+    if frame is None:
+        return None
+    return encode_png(frame)
+
+def encode_png(frame):
+    """
+    Encode an image as a PNG.
+
+    The image is a uint8 array of shape [height, width] for
+    grayscale, or [height, width, channels] with 1 to 4
+    channels (gray, gray and alpha, RGB, or RGBA).
+    """
+    frame = np.asarray(frame)
+    if frame.ndim == 2:
+        frame = frame[:, :, None]
+    if frame.ndim != 3 or frame.shape[2] not in COLOR_TYPES:
+        raise ValueError('unsupported frame shape: ' + str(frame.shape))
+    if frame.dtype != np.uint8:
+        frame = np.clip(frame, 0, 255).astype(np.uint8)
+    height, width, channels = frame.shape
+    rows = np.zeros((height, width * channels + 1), dtype=np.uint8)
+    rows[:, 1:] = frame.reshape(height, width * channels)
+    header = struct.pack('>IIBBBBB', width, height, 8, COLOR_TYPES[channels], 0, 0, 0)
+    return (b'\x89PNG\r\n\x1a\n' +
+            _chunk(b'IHDR', header) +
+            _chunk(b'IDAT', zlib.compress(rows.tobytes())) +
+            _chunk(b'IEND', b''))
+
+def _chunk(kind, data):
+    crc = zlib.crc32(kind + data) & 0xffffffff
+    return struct.pack('>I', len(data)) + kind + data + struct.pack('>I', crc)
@@ -2,41 +2,174 @@
 Listen for client connections and dispatch handlers.
 """
 
+import json
 import os
+import struct
 import sys
 import subprocess
 import socket
+import threading
+import time
+
+import proto
+import session
 
 if sys.version_info >= (3, 0):
     import socketserver
 else:
     import SocketServer as socketserver
 
-def serve(port=5001, universe=False, retro=False, setup_code=''):
+def serve(port=5001, universe=False, retro=False, retro_integrations='',
+          envpool=False, sb3=None, dm_control=False, unity='', pettingzoo=False,
+          setup_code='', idle_ttl=0, session_ttl=300, unix=''):
     """
     Run a server on the given port.
+
+    If unix is a path, the server listens on a Unix domain
+    socket at that path instead.
+
+    If idle_ttl is non-zero, connections which send nothing
+    for idle_ttl seconds are closed, unless the client opts
+    out with a Keep Alive packet.
+
+    The environments of a resumable session are kept alive
+    for session_ttl seconds after the connection drops.
+    If session_ttl is 0, sessions are disabled.
+
+    If retro_integrations is a directory, custom Retro
+    game integrations are loaded from it, and clients may
+    upload new ones to it.
+
+    If sb3 is 'dummy' or 'subproc', batched environments
+    are backed by the corresponding Stable-Baselines3
+    VecEnv.
+
+    If dm_control is set, the DeepMind Control Suite tasks
+    are available as 'dm_control/DOMAIN-TASK-v0'.
+
+    If unity is a directory, the Unity ML-Agents builds in
+    it are available as 'unity/NAME-v0'.
+
+    If pettingzoo is set, the PettingZoo environments are
+    available as 'pettingzoo/FAMILY-NAME-vN'.
     """
-    server = Server(('127.0.0.1', port), Handler)
+    if unix:
+        if os.path.exists(unix):
+            os.remove(unix)
+        server = UnixServer(unix, Handler)
+    else:
+        server = Server(('127.0.0.1', port), Handler)
     server.universe = universe
     server.retro = retro
+    server.retro_integrations = retro_integrations
+    server.envpool = envpool
+    server.sb3 = sb3
+    server.dm_control = dm_control
+    server.unity = unity
+    server.pettingzoo = pettingzoo
+    server.idle_ttl = idle_ttl
+    server.session_ttl = session_ttl
     server.setup_code = setup_code
-    print('Listening on port ' + str(port) + '...')
+    server.stats = Stats()
+    server.sessions = session.Sessions()
+    if unix:
+        print('Listening on ' + unix + '...')
+    else:
+        print('Listening on port ' + str(port) + '...')
     server.serve_forever()
 
-class Server(socketserver.ThreadingMixIn, socketserver.TCPServer):
+class ServerSettings(object):
     """
-    The connection server.
+    The settings shared by every kind of server.
     """
-    allow_reuse_address = True
     universe = False
     retro = False
+    retro_integrations = ''
+    envpool = False
+    sb3 = None
+    dm_control = False
+    unity = ''
+    pettingzoo = False
     setup_code = ''
+    idle_ttl = 0
+    session_ttl = 0
+    stats = None
+    sessions = None
+
+class Server(ServerSettings, socketserver.ThreadingMixIn, socketserver.TCPServer):
+    """
+    The connection server.
+    """
+    allow_reuse_address = True
+
+if hasattr(socketserver, 'UnixStreamServer'):
+    class UnixServer(ServerSettings, socketserver.ThreadingMixIn,
+                     socketserver.UnixStreamServer):
+        """
+        The connection server for Unix domain sockets.
+        """
+        pass
+
+class Stats:
+    """
+    Thread-safe usage statistics for a server.
+    """
+    def __init__(self):
+        self.lock = threading.Lock()
+        self.start_time = time.time()
+        self.total_connections = 0
+        self.connections = {}
+
+    def add(self, key, env_name, num_envs, pid):
+        """
+        Record a new connection.
+        """
+        with self.lock:
+            self.total_connections += 1
+            self.connections[key] = (env_name, num_envs, pid)
+
+    def remove(self, key):
+        """
+        Record the end of a connection.
+        """
+        with self.lock:
+            del self.connections[key]
+
+    def to_json(self):
+        """
+        Encode the statistics as JSON.
+        """
+        with self.lock:
+            envs = {}
+            memory = process_memory(os.getpid())
+            for env_name, num_envs, pid in self.connections.values():
+                if env_name != '':
+                    envs[env_name] = envs.get(env_name, 0) + num_envs
+                memory += process_memory(pid)
+            return json.dumps({
+                'active_connections': len(self.connections),
+                'total_connections': self.total_connections,
+                'envs': envs,
+                'uptime': time.time() - self.start_time,
+                'memory_bytes': memory
+            })
 
 class Handler(socketserver.BaseRequestHandler):
     """
     The connection handler.
     """
     def handle(self):
+        handshake = peek_handshake(self.request)
+        if handshake is None:
+            return
+        flags, env_name, num_envs = handshake
+        if flags & proto.FLAG_STATS:
+            self.handle_stats(flags, env_name)
+            return
+        if flags & proto.FLAG_RESUME:
+            self.handle_resume(env_name)
+            return
+
         script_file = os.path.join(os.path.dirname(__file__), 'handler.py')
         args = [
             sys.executable,
@@ -46,17 +179,43 @@ class Handler(socketserver.BaseRequestHandler):
             '--fd',
             str(self.request.fileno()),
             '--setup',
-            str(self.server.setup_code)
+            str(self.server.setup_code),
+            '--idle-ttl',
+            str(self.server.idle_ttl)
         ]
 
         if self.server.universe:
             args.append('--universe')
         if self.server.retro:
             args.append('--retro')
+            if self.server.retro_integrations:
+                args.extend(['--retro-integrations',
+                             os.path.abspath(self.server.retro_integrations)])
+        if self.server.envpool:
+            args.append('--envpool')
+        if self.server.sb3:
+            args.extend(['--sb3', self.server.sb3])
+        if self.server.dm_control:
+            args.append('--dm-control')
+        if self.server.unity:
+            args.extend(['--unity', self.server.unity])
+        if self.server.pettingzoo:
+            args.append('--pettingzoo')
+
+        token = None
+        control = None
+        child_control = None
+        pass_fds = [self.request.fileno()]
+        if (flags & proto.FLAG_SESSION and self.server.session_ttl > 0 and
+                session.supported()):
+            token = session.new_token()
+            control, child_control = socket.socketpair(socket.AF_UNIX, socket.SOCK_STREAM)
+            pass_fds.append(child_control.fileno())
+            args.extend(['--session-token', token,
+                         '--control-fd', str(child_control.fileno()),
+                         '--session-ttl', str(self.server.session_ttl)])
 
-        # Greatly reduces latency on Linux.
-        if sys.platform in ['linux', 'linux2', 'darwin']:
-            self.request.setsockopt(socket.IPPROTO_TCP, socket.TCP_NODELAY, 1)
+        set_no_delay(self.request)
 
         try:
             print('Connection from ' + str(self.client_address))
@@ -65,13 +224,110 @@ class Handler(socketserver.BaseRequestHandler):
                                         stdin=sys.stdin,
                                         stdout=sys.stdout,
                                         stderr=sys.stderr,
-                                        pass_fds=(self.request.fileno(),))
+                                        pass_fds=pass_fds)
             else:
                 proc = subprocess.Popen(args,
                                         stdin=sys.stdin,
                                         stdout=sys.stdout,
                                         stderr=sys.stderr,
                                         close_fds=False)
-            proc.wait()
+            self.server.stats.add(self, env_name, num_envs, proc.pid)
+            if token is not None:
+                child_control.close()
+                self.server.sessions.add(token, control, proc)
+            try:
+                proc.wait()
+            finally:
+                self.server.stats.remove(self)
+                if token is not None:
+                    self.server.sessions.remove(token)
+                    control.close()
         finally:
             print('Disconnected from ' + str(self.client_address))
+
+    def handle_resume(self, token):
+        """
+        Pass a resumed connection to the handler process of
+        its session.
+
+        The handshake is left for the handler to consume.
+        """
+        info = self.server.sessions.get(token)
+        if info is None:
+            self.request.recv(5 + len(token.encode('utf-8')), socket.MSG_WAITALL)
+            message = json.dumps({'code': proto.ERROR_UNKNOWN_SESSION,
+                                  'message': 'unknown session'}).encode('utf-8')
+            self.request.sendall(struct.pack('<I', len(message)) + message)
+            return
+        control, proc = info
+        set_no_delay(self.request)
+        print('Resuming session from ' + str(self.client_address))
+        try:
+            session.send_fd(control, self.request.fileno())
+        except (IOError, OSError):
+            return
+        proc.wait()
+        print('Disconnected from ' + str(self.client_address))
+
+    def handle_stats(self, flags, env_name):
+        """
+        Consume a stats handshake and send the statistics.
+        """
+        size = 5 + len(env_name.encode('utf-8'))
+        if flags & (proto.FLAG_BATCH | proto.FLAG_MULTI):
+            size += 4
+        self.request.recv(size, socket.MSG_WAITALL)
+        data = self.server.stats.to_json().encode('utf-8')
+        self.request.sendall(struct.pack('<II', 0, len(data)) + data)
+
+def set_no_delay(sock):
+    """
+    Disable Nagle's algorithm on a TCP socket, which greatly
+    reduces latency on Linux.
+    """
+    if sock.family == socket.AF_UNIX:
+        return
+    if sys.platform in ['linux', 'linux2', 'darwin']:
+        sock.setsockopt(socket.IPPROTO_TCP, socket.TCP_NODELAY, 1)
+
+def peek_handshake(sock):
+    """
+    Read the flags, environment name, and number of
+    environments of a handshake without consuming them.
+
+    Returns None if the connection closes early.
+    """
+    header = _peek(sock, 5)
+    if header is None:
+        return None
+    flags, name_len = struct.unpack('<BI', header)
+    data = _peek(sock, 5 + name_len)
+    if data is None:
+        return None
+    env_name = data[5:].decode('utf-8')
+    num_envs = 1
+    if flags & (proto.FLAG_BATCH | proto.FLAG_MULTI):
+        data = _peek(sock, 9 + name_len)
+        if data is None:
+            return None
+        num_envs = struct.unpack('<I', data[-4:])[0]
+    return flags, env_name, num_envs
+
+def _peek(sock, size):
+    data = sock.recv(size, socket.MSG_PEEK | socket.MSG_WAITALL)
+    if len(data) != size:
+        return None
+    return data
+
+def process_memory(pid):
+    """
+    Get the resident memory of a process in bytes, or 0 if
+    it cannot be determined, as on platforms without /proc.
+    """
+    try:
+        # This module only exists on Unix.
+        import resource
+        with open('/proc/%d/statm' % pid) as statm:
+            return int(statm.read().split()[1]) * resource.getpagesize()
+    except (ImportError, IOError, OSError, ValueError, IndexError):
+        return 0
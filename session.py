@@ -0,0 +1,91 @@
+"""
+Support for resuming a connection's environments on a new
+connection after the old one drops.
+
+The server keeps a control socket for every resumable
+connection handler. When a client resumes a session, the
+server sends the new connection's file descriptor to the
+handler over the control socket.
+"""
+
+import array
+import binascii
+import os
+import select
+import socket
+import threading
+
+def supported():
+    """
+    Check if sessions are supported on this platform.
+
+    Passing file descriptors requires Python 3.3 or later.
+    """
+    return hasattr(socket, 'AF_UNIX') and hasattr(socket, 'CMSG_LEN')
+
+def new_token():
+    """
+    Generate a random session token.
+    """
+    return binascii.hexlify(os.urandom(16)).decode('ascii')
+
+def send_fd(sock, fd):
+    """
+    Send a file descriptor over a Unix socket.
+    """
+    fds = array.array('i', [fd])
+    sock.sendmsg([b'\0'], [(socket.SOL_SOCKET, socket.SCM_RIGHTS, fds.tobytes())])
+
+def recv_fd(sock, timeout):
+    """
+    Receive a file descriptor from a Unix socket.
+
+    Returns None if no file descriptor arrives within the
+    timeout (in seconds), or if the socket is closed.
+    """
+    ready, _, _ = select.select([sock], [], [], timeout)
+    if not ready:
+        return None
+    fds = array.array('i')
+    msg, ancdata, _, _ = sock.recvmsg(1, socket.CMSG_LEN(fds.itemsize))
+    if not msg:
+        return None
+    for level, kind, data in ancdata:
+        if level == socket.SOL_SOCKET and kind == socket.SCM_RIGHTS:
+            fds.frombytes(data[:len(data) - (len(data) % fds.itemsize)])
+    if len(fds) == 0:
+        return None
+    return fds[0]
+
+class Sessions:
+    """
+    Thread-safe registry of resumable sessions.
+
+    Each session maps a token to the control socket and
+    process of a connection handler.
+    """
+    def __init__(self):
+        self.lock = threading.Lock()
+        self.sessions = {}
+
+    def add(self, token, control, proc):
+        """
+        Register a session.
+        """
+        with self.lock:
+            self.sessions[token] = (control, proc)
+
+    def remove(self, token):
+        """
+        Unregister a session.
+        """
+        with self.lock:
+            del self.sessions[token]
+
+    def get(self, token):
+        """
+        Get the (control, proc) tuple for a session, or None
+        if there is no such session.
+        """
+        with self.lock:
+            return self.sessions.get(token)
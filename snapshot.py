@@ -0,0 +1,107 @@
+"""
+APIs for saving and restoring the state of environments.
+
+A state is encoded as a uint32 header length, a JSON
+header, and a binary payload. The header describes how to
+decode the payload, so states never have to be unpickled.
+"""
+
+import json
+import struct
+
+import numpy as np
+
+class SnapshotException(Exception):
+    """
+    Exception type used for all snapshot errors.
+    """
+    pass
+
+def clone_state(env):
+    """
+    Encode the state of an environment as bytes.
+
+    Supported environments are Retro games, Atari games,
+    and environments with a numerical `state` attribute,
+    such as the classic control tasks.
+    """
+    unwrapped = env.unwrapped
+    header = {'elapsed_steps': _elapsed_steps(env)}
+    if hasattr(unwrapped, 'em') and hasattr(unwrapped.em, 'get_state'):
+        header['kind'] = 'retro'
+        payload = unwrapped.em.get_state()
+    elif hasattr(unwrapped, 'clone_full_state'):
+        state = unwrapped.clone_full_state()
+        if not isinstance(state, np.ndarray):
+            raise SnapshotException('unsupported ALE state type: ' + type(state).__name__)
+        header['kind'] = 'ale'
+        payload = state.astype('uint8').tobytes()
+    elif isinstance(getattr(unwrapped, 'state', None), (np.ndarray, list, tuple)):
+        state = np.array(unwrapped.state)
+        if state.dtype.kind not in 'biuf':
+            raise SnapshotException('state is not numerical')
+        header['kind'] = 'array'
+        header['dtype'] = state.dtype.str
+        header['shape'] = list(state.shape)
+        header['list'] = not isinstance(unwrapped.state, np.ndarray)
+        payload = state.tobytes()
+    else:
+        raise SnapshotException('environment does not support snapshots')
+    header_data = json.dumps(header).encode('utf-8')
+    return struct.pack('<I', len(header_data)) + header_data + bytes(payload)
+
+def restore_state(env, data):
+    """
+    Restore a state which was encoded by clone_state.
+    """
+    try:
+        header_len = struct.unpack('<I', data[:4])[0]
+        header = json.loads(data[4:4+header_len].decode('utf-8'))
+        kind = header['kind']
+    except (struct.error, ValueError, KeyError, TypeError):
+        raise SnapshotException('malformed state')
+    payload = data[4+header_len:]
+    unwrapped = env.unwrapped
+    if kind == 'retro':
+        if not hasattr(unwrapped, 'em'):
+            raise SnapshotException('not a Retro environment')
+        unwrapped.em.set_state(payload)
+    elif kind == 'ale':
+        if not hasattr(unwrapped, 'restore_full_state'):
+            raise SnapshotException('not an Atari environment')
+        unwrapped.restore_full_state(np.frombuffer(payload, dtype='uint8').copy())
+    elif kind == 'array':
+        if not hasattr(unwrapped, 'state'):
+            raise SnapshotException('environment has no state attribute')
+        try:
+            state = np.frombuffer(payload, dtype=np.dtype(header['dtype']))
+            state = state.reshape(header['shape']).copy()
+        except (KeyError, TypeError, ValueError):
+            raise SnapshotException('malformed state')
+        unwrapped.state = tuple(state.tolist()) if header.get('list') else state
+    else:
+        raise SnapshotException('unknown state kind: ' + str(kind))
+    steps = header.get('elapsed_steps')
+    time_limit = _time_limit(env)
+    if isinstance(steps, int) and time_limit is not None:
+        # pylint: disable=W0212
+        time_limit._elapsed_steps = steps
+
+def _elapsed_steps(env):
+    """
+    Get the step count of the TimeLimit wrapper, if there
+    is one.
+    """
+    time_limit = _time_limit(env)
+    if time_limit is None:
+        return None
+    # pylint: disable=W0212
+    return time_limit._elapsed_steps
+
+def _time_limit(env):
+    while True:
+        if isinstance(getattr(env, '_elapsed_steps', None), int):
+            return env
+        if not hasattr(env, 'env'):
+            return None
+        env = env.env
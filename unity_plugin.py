@@ -0,0 +1,63 @@
+"""
+APIs for Unity ML-Agents environments.
+"""
+
+import os
+import socket
+
+import registry
+
+ENV_PREFIX = 'unity/'
+
+class Unity:
+    """
+    Unity registers the Unity builds in a directory as Gym
+    environments.
+
+    A build at DIR/NAME or DIR/NAME.EXT is registered as
+    'unity/NAME-v0', so that it can be made, batched,
+    listed, and described like any other Gym environment.
+    """
+    def __init__(self, build_dir):
+        self.build_dir = build_dir
+        if build_dir:
+            register(build_dir)
+
+def register(build_dir):
+    """
+    Register the executables in a directory with Gym.
+    """
+    for entry in sorted(os.listdir(build_dir)):
+        path = os.path.join(build_dir, entry)
+        if not os.path.isfile(path) or not os.access(path, os.X_OK):
+            continue
+        env_id = '%s%s-v0' % (ENV_PREFIX, os.path.splitext(entry)[0])
+        registry.register_env(env_id, 'unity_plugin:make_env', {'file_name': path})
+
+def make_env(file_name):
+    """
+    Launch a Unity build and wrap it as a Gym environment.
+
+    Visual observations are sent as uint8 arrays, and
+    branched discrete actions are flattened into a single
+    Discrete space.
+    """
+    from mlagents_envs.environment import UnityEnvironment
+    from mlagents_envs.envs.unity_gym_env import UnityToGymWrapper
+    unity_env = UnityEnvironment(file_name=file_name, base_port=free_port(),
+                                 worker_id=0, no_graphics=True)
+    return UnityToGymWrapper(unity_env, uint8_visual=True, flatten_branched=True)
+
+def free_port():
+    """
+    Find a port which the Unity build can listen on.
+
+    Every build needs its own port, and there may be many
+    builds running for different connections.
+    """
+    sock = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
+    try:
+        sock.bind(('127.0.0.1', 0))
+        return sock.getsockname()[1]
+    finally:
+        sock.close()
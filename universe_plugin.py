@@ -2,9 +2,18 @@
 APIs for Universe-specific environment manipulations.
 """
 
+import subprocess
+
 import numpy as np
 import gym
 
+# Docker label of the remotes allocated by clients.
+REMOTE_LABEL = 'gym-socket-api.universe-remote'
+
+# Ports of the VNC server and the rewarder in a remote.
+VNC_PORT = 5900
+REWARDER_PORT = 15900
+
 class UniverseException(Exception):
     """
     Exception type used for all Universe-related errors.
@@ -33,6 +42,7 @@ class Universe:
         wrappers = self.universe.wrappers
         classes = {
             'CropObservations': wrappers.experimental.CropObservations,
+            'CropRegion': crop_region,
             'Vision': wrappers.Vision
         }
         if not wrapper_name in classes:
@@ -57,6 +67,88 @@ class Universe:
         wrappers = self.universe.wrappers
         return UniverseEnv(wrappers.Unvectorize(wrappers.BlockingReset(env)))
 
+    def allocate_remotes(self, num_remotes, image):
+        """
+        Start Docker containers to serve as remotes.
+
+        The image may be a Universe runtime ID, such as
+        'flashgames', or a Docker image.
+        Returns a list of remote descriptions, as from
+        list_remotes.
+        """
+        self._check_enabled()
+        args = ['docker', 'run', '-d', '-p', str(VNC_PORT), '-p', str(REWARDER_PORT),
+                '--label', REMOTE_LABEL + '=' + image]
+        args.extend(self._runtime_args(image))
+        container_ids = []
+        try:
+            for _ in range(num_remotes):
+                container_ids.append(_docker(args).strip())
+            return [self._describe_remote(cid) for cid in container_ids]
+        except UniverseException:
+            if container_ids:
+                _docker(['docker', 'rm', '-f'] + container_ids)
+            raise
+
+    def list_remotes(self):
+        """
+        Describe the remotes which clients have allocated.
+
+        Each remote is a dict with an 'id', the 'image' it
+        was allocated with, and an 'address' which can be
+        passed to configure() in the remotes option.
+        """
+        self._check_enabled()
+        output = _docker(['docker', 'ps', '-q', '--no-trunc',
+                          '--filter', 'label=' + REMOTE_LABEL])
+        return [self._describe_remote(cid) for cid in output.split()]
+
+    def release_remotes(self, container_ids):
+        """
+        Stop and remove remotes by ID.
+        """
+        self._check_enabled()
+        allocated = set(remote['id'] for remote in self.list_remotes())
+        for container_id in container_ids:
+            if container_id not in allocated:
+                raise UniverseException('unknown remote: ' + container_id)
+        if container_ids:
+            _docker(['docker', 'rm', '-f'] + list(container_ids))
+
+    def _runtime_args(self, image):
+        runtime_spec = getattr(self.universe, 'runtime_spec', None)
+        if runtime_spec is None:
+            return [image]
+        try:
+            runtime = runtime_spec(image)
+        # pylint: disable=W0703
+        except Exception:
+            return [image]
+        args = []
+        host_config = getattr(runtime, 'host_config', None) or {}
+        if host_config.get('privileged'):
+            args.append('--privileged')
+        if host_config.get('ipc_mode'):
+            args.extend(['--ipc', host_config['ipc_mode']])
+        for cap in host_config.get('cap_add', []):
+            args.extend(['--cap-add', cap])
+        return args + [runtime.image] + list(getattr(runtime, 'command', None) or [])
+
+    @staticmethod
+    def _describe_remote(container_id):
+        image = _docker(['docker', 'inspect', '--format',
+                         '{{index .Config.Labels "' + REMOTE_LABEL + '"}}',
+                         container_id]).strip()
+        ports = []
+        for port in [VNC_PORT, REWARDER_PORT]:
+            mapping = _docker(['docker', 'port', container_id, str(port)])
+            ports.append(mapping.split()[0].rsplit(':', 1)[1])
+        return {
+            'id': container_id,
+            'image': image,
+            'address': 'vnc://127.0.0.1:%s+%s' % tuple(ports)
+        }
+
     def _check_enabled(self):
         if not self.enabled:
             raise UniverseException('Universe is not enabled')
@@ -67,6 +159,29 @@ class Universe:
         if not isinstance(env.unwrapped, self.universe.envs.VNCEnv):
             raise UniverseException('not a Universe environment')
 
+def _docker(args):
+    """
+    Run a Docker command and return its output.
+    """
+    try:
+        output = subprocess.check_output(args, stderr=subprocess.STDOUT)
+    except OSError as exc:
+        raise UniverseException('cannot run docker: ' + str(exc))
+    except subprocess.CalledProcessError as exc:
+        raise UniverseException('%s failed: %s' % (' '.join(args[:2]),
+                                                   exc.output.decode('utf-8').strip()))
+    return output.decode('utf-8')
+
+def crop_region(env, width, height, x=0, y=0):
+    """
+    Crop the screen images of an environment to a region.
+
+    Unlike CropObservations, which finds the game screen
+    of known environments, the region is given explicitly.
+    """
+    from universe.wrappers.experimental.observation import _CropObservations
+    return _CropObservations(env, height=height, width=width, x=x, y=y)
+
 class UniverseEnv:
     """
     A pseudo environment wrapper with more useful spaces.